@@ -0,0 +1,299 @@
+// Command loadgen drives a running order book over gRPC with generated traffic
+// and reports per-operation latency percentiles and throughput
+//
+// Orders are spread over -markets markets with Zipf-skewed popularity, backs and
+// lays are mixed by -back-ratio and stakes are log-normal around -stake-median.
+// A share of operations (-cancel-ratio) cancels one of the worker's resting
+// orders instead of placing a new one
+//
+// PlaceBet needs a wallet reservation per order and the wallet service has no
+// reserve call, so reservations are created beforehand and passed in a file of
+// user_id,reservation_id lines (-reservations). The run ends after -duration or
+// when every reservation has been used, whichever comes first
+//
+// With -rate the operations are scheduled at a fixed rate and latency is measured
+// from each operation's scheduled start, so a slow server shows up as latency
+// rather than as fewer requests. Without it the workers send as fast as they can
+//
+//	loadgen -reservations reservations.csv -duration 1m
+//	loadgen -addr orderbook:8082 -reservations reservations.csv -rate 2000 -workers 64
+//	loadgen -reservations reservations.csv -markets 1 -cancel-ratio 0.3 -format json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	orderbookv1 "github.com/cypherlabdev/cypherlabdev-protos/gen/go/orderbook/v1"
+
+	"github.com/cypherlabdev/order-book-service/internal/config"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+)
+
+// Operations the report breaks latency down by
+const (
+	opPlace  = "place"
+	opCancel = "cancel"
+)
+
+// maxResting caps how many resting order IDs a worker keeps for cancels
+const maxResting = 1024
+
+func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fatalf("load config: %v", err)
+	}
+
+	var (
+		addr             = flag.String("addr", fmt.Sprintf("localhost:%d", cfg.GRPC.Port), "order book gRPC address")
+		reservationsPath = flag.String("reservations", "", "file of user_id,reservation_id lines, one per order to place")
+		duration         = flag.Duration("duration", 30*time.Second, "how long to run")
+		rate             = flag.Int("rate", 0, "operations per second across all workers, 0 sends as fast as possible")
+		workers          = flag.Int("workers", 16, "concurrent callers")
+		markets          = flag.Int("markets", 20, "number of markets to spread orders over")
+		marketSkew       = flag.Float64("market-skew", 1.2, "Zipf exponent of market popularity, must be > 1")
+		selections       = flag.Int("selections", 2, "selections per market")
+		backRatio        = flag.Float64("back-ratio", 0.5, "share of placements that back")
+		stakeMedian      = flag.Float64("stake-median", 10, "median stake")
+		stakeSigma       = flag.Float64("stake-sigma", 1, "spread of the log-normal stake distribution")
+		cancelRatio      = flag.Float64("cancel-ratio", 0.1, "share of operations that cancel a resting order")
+		seed             = flag.Int64("seed", time.Now().UnixNano(), "random seed, workers draw from seed+worker")
+		format           = flag.String("format", "text", "output format, text or json")
+	)
+	flag.Parse()
+
+	if *reservationsPath == "" {
+		fatalf("-reservations is required")
+	}
+	switch {
+	case *workers < 1:
+		fatalf("-workers must be at least 1")
+	case *rate < 0:
+		fatalf("-rate must not be negative")
+	case *markets < 1:
+		fatalf("-markets must be at least 1")
+	case *marketSkew <= 1:
+		fatalf("-market-skew must be greater than 1")
+	case *selections < 1:
+		fatalf("-selections must be at least 1")
+	case *backRatio < 0 || *backRatio > 1:
+		fatalf("-back-ratio must be between 0 and 1")
+	case *cancelRatio < 0 || *cancelRatio > 1:
+		fatalf("-cancel-ratio must be between 0 and 1")
+	case *stakeMedian <= 0:
+		fatalf("-stake-median must be positive")
+	}
+	if *format != "text" && *format != "json" {
+		fatalf("unknown -format %q", *format)
+	}
+
+	reservations, err := readReservations(*reservationsPath)
+	if err != nil {
+		fatalf("read reservations: %v", err)
+	}
+	if len(reservations) == 0 {
+		fatalf("%s has no reservations", *reservationsPath)
+	}
+
+	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fatalf("connect to %s: %v", *addr, err)
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	r := &run{
+		client:       orderbookv1.NewOrderBookServiceClient(conn),
+		reservations: reservations,
+		rate:         *rate,
+		start:        time.Now(),
+		stop:         cancel,
+	}
+	tc := trafficConfig{
+		Markets:     *markets,
+		MarketSkew:  *marketSkew,
+		Selections:  *selections,
+		BackRatio:   *backRatio,
+		StakeMedian: *stakeMedian,
+		StakeSigma:  *stakeSigma,
+		CancelRatio: *cancelRatio,
+	}
+
+	recorders := make([]*recorder, *workers)
+	var wg sync.WaitGroup
+	for i := range recorders {
+		recorders[i] = newRecorder()
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.loop(ctx)
+		}(&worker{run: r, traffic: newTraffic(tc, *seed+int64(i)), recorder: recorders[i]})
+	}
+	wg.Wait()
+
+	report := summarize(recorders, time.Since(r.start))
+	report.Target = *addr
+	report.Workers = *workers
+	report.Rate = *rate
+
+	if *format == "json" {
+		err = report.writeJSON(os.Stdout)
+	} else {
+		err = report.writeText(os.Stdout)
+	}
+	if err != nil {
+		fatalf("write report: %v", err)
+	}
+}
+
+// run is the state the workers share
+type run struct {
+	client       orderbookv1.OrderBookServiceClient
+	reservations []reservation
+	rate         int
+	start        time.Time
+	stop         context.CancelFunc
+
+	used atomic.Int64 // Reservations handed out
+	ops  atomic.Int64 // Operations scheduled, used with a rate
+}
+
+// reservation hands out the next unused reservation and ends the run once they are gone
+func (r *run) reservation() (reservation, bool) {
+	n := r.used.Add(1) - 1
+	if n >= int64(len(r.reservations)) {
+		r.stop()
+		return reservation{}, false
+	}
+	return r.reservations[n], true
+}
+
+// schedule returns when the next operation is due
+// Without a rate every operation is due now
+func (r *run) schedule() time.Time {
+	if r.rate == 0 {
+		return time.Now()
+	}
+	n := r.ops.Add(1) - 1
+	return r.start.Add(time.Duration(n) * time.Second / time.Duration(r.rate))
+}
+
+// worker sends operations one at a time
+type worker struct {
+	*run
+	traffic  *traffic
+	recorder *recorder
+	resting  []string // IDs of this worker's orders that may still rest on the book
+}
+
+func (w *worker) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		due := w.schedule()
+		if wait := time.Until(due); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if len(w.resting) > 0 && w.traffic.cancel() {
+			w.cancel(ctx, due)
+			continue
+		}
+		if !w.place(ctx, due) {
+			return
+		}
+	}
+}
+
+// place sends one PlaceBet, returning false once the reservations are used up
+func (w *worker) place(ctx context.Context, due time.Time) bool {
+	res, ok := w.reservation()
+	if !ok {
+		return false
+	}
+	p := w.traffic.next()
+
+	resp, err := w.client.PlaceBet(ctx, &orderbookv1.PlaceBetRequest{
+		UserId:         res.UserID.String(),
+		EventId:        p.MarketID,
+		BetType:        p.BetType,
+		Selection:      p.Selection,
+		Amount:         p.Stake.String(),
+		ReservationId:  res.ReservationID.String(),
+		IdempotencyKey: uuid.NewString(),
+	})
+	if err != nil {
+		w.fail(ctx, opPlace, err)
+		return true
+	}
+	w.recorder.record(opPlace, time.Since(due))
+
+	switch models.OrderStatus(resp.Status) {
+	case models.OrderStatusMatched:
+		w.recorder.matched++
+	case models.OrderStatusPartially:
+		w.recorder.matched++
+		w.rest(resp.OrderId)
+	case models.OrderStatusPending:
+		w.rest(resp.OrderId)
+	}
+	return true
+}
+
+// cancel sends a CancelBet for the most recent resting order
+// The order may have been filled since, which the server rejects; that is
+// counted as an error like any other
+func (w *worker) cancel(ctx context.Context, due time.Time) {
+	orderID := w.resting[len(w.resting)-1]
+	w.resting = w.resting[:len(w.resting)-1]
+
+	_, err := w.client.CancelBet(ctx, &orderbookv1.CancelBetRequest{
+		OrderId:        orderID,
+		IdempotencyKey: uuid.NewString(),
+	})
+	if err != nil {
+		w.fail(ctx, opCancel, err)
+		return
+	}
+	w.recorder.record(opCancel, time.Since(due))
+}
+
+func (w *worker) rest(orderID string) {
+	if len(w.resting) == maxResting {
+		w.resting = w.resting[1:]
+	}
+	w.resting = append(w.resting, orderID)
+}
+
+// fail counts an error by its gRPC code
+// Calls cut short by the end of the run are not the server's fault
+func (w *worker) fail(ctx context.Context, operation string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	w.recorder.fail(operation, status.Code(err).String())
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "loadgen: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// recorder collects the outcome of one worker's calls
+type recorder struct {
+	latencies map[string][]time.Duration // by operation
+	errors    map[string]int             // by operation and gRPC code, e.g. place/Unavailable
+	matched   int                        // placements that filled at least one resting order
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+	}
+}
+
+func (r *recorder) record(operation string, latency time.Duration) {
+	r.latencies[operation] = append(r.latencies[operation], latency)
+}
+
+func (r *recorder) fail(operation, code string) {
+	r.errors[operation+"/"+code]++
+}
+
+// report is the outcome of a load run
+type report struct {
+	Target     string                `json:"target"`
+	Workers    int                   `json:"workers"`
+	Rate       int                   `json:"rate"` // Target operations per second, 0 if unthrottled
+	Duration   time.Duration         `json:"duration_ns"`
+	Operations map[string]*opSummary `json:"operations"`
+	Errors     map[string]int        `json:"errors"`
+
+	// Placements that filled at least one resting order, a placement that fills
+	// several counts once
+	MatchedPlacements int     `json:"matched_placements"`
+	MatchedPerSecond  float64 `json:"matched_placements_per_second"`
+}
+
+// opSummary is the latency distribution of one operation
+type opSummary struct {
+	Count     int           `json:"count"`
+	PerSecond float64       `json:"per_second"`
+	P50       time.Duration `json:"p50_ns"`
+	P90       time.Duration `json:"p90_ns"`
+	P99       time.Duration `json:"p99_ns"`
+	P999      time.Duration `json:"p999_ns"`
+	Max       time.Duration `json:"max_ns"`
+}
+
+// summarize merges the workers' recorders
+func summarize(recorders []*recorder, elapsed time.Duration) *report {
+	r := &report{
+		Duration:   elapsed,
+		Operations: make(map[string]*opSummary),
+		Errors:     make(map[string]int),
+	}
+
+	latencies := make(map[string][]time.Duration)
+	for _, rec := range recorders {
+		for operation, durations := range rec.latencies {
+			latencies[operation] = append(latencies[operation], durations...)
+		}
+		for key, count := range rec.errors {
+			r.Errors[key] += count
+		}
+		r.MatchedPlacements += rec.matched
+	}
+
+	seconds := elapsed.Seconds()
+	for operation, durations := range latencies {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		r.Operations[operation] = &opSummary{
+			Count:     len(durations),
+			PerSecond: float64(len(durations)) / seconds,
+			P50:       percentile(durations, 0.50),
+			P90:       percentile(durations, 0.90),
+			P99:       percentile(durations, 0.99),
+			P999:      percentile(durations, 0.999),
+			Max:       durations[len(durations)-1],
+		}
+	}
+	r.MatchedPerSecond = float64(r.MatchedPlacements) / seconds
+	return r
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+func (r *report) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func (r *report) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	rate := "unthrottled"
+	if r.Rate > 0 {
+		rate = fmt.Sprintf("%d ops/s", r.Rate)
+	}
+	fmt.Fprintf(tw, "%s: %d workers, %s, %s\n\n", r.Target, r.Workers, rate, r.Duration.Round(time.Millisecond))
+
+	operations := make([]string, 0, len(r.Operations))
+	for operation := range r.Operations {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+
+	fmt.Fprintln(tw, "OPERATION\tCOUNT\tPER SEC\tP50\tP90\tP99\tP99.9\tMAX")
+	for _, operation := range operations {
+		s := r.Operations[operation]
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\n",
+			operation, s.Count, s.PerSecond,
+			roundLatency(s.P50), roundLatency(s.P90), roundLatency(s.P99), roundLatency(s.P999), roundLatency(s.Max))
+	}
+
+	fmt.Fprintf(tw, "\nmatched placements: %d (%.1f/s)\n", r.MatchedPlacements, r.MatchedPerSecond)
+
+	if len(r.Errors) > 0 {
+		keys := make([]string, 0, len(r.Errors))
+		for key := range r.Errors {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintln(tw, "\nERROR\tCOUNT")
+		for _, key := range keys {
+			fmt.Fprintf(tw, "%s\t%d\n", key, r.Errors[key])
+		}
+	}
+	return tw.Flush()
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// trafficConfig shapes the generated orders
+type trafficConfig struct {
+	Markets     int     // Number of markets orders are spread over
+	MarketSkew  float64 // Zipf exponent of market popularity, > 1; higher puts more orders on the top markets
+	Selections  int     // Selections per market
+	BackRatio   float64 // Share of placements that back, the rest lay
+	StakeMedian float64 // Median stake
+	StakeSigma  float64 // Spread of the log-normal stake distribution
+	CancelRatio float64 // Share of operations that cancel a resting order instead of placing one
+}
+
+// placement is one generated order
+type placement struct {
+	MarketID  string
+	Selection string
+	BetType   string
+	Stake     decimal.Decimal
+}
+
+// traffic draws orders from the configured distributions
+// Each worker has its own, so drawing needs no locking
+type traffic struct {
+	cfg  trafficConfig
+	rng  *rand.Rand
+	zipf *rand.Zipf
+}
+
+func newTraffic(cfg trafficConfig, seed int64) *traffic {
+	rng := rand.New(rand.NewSource(seed))
+	return &traffic{
+		cfg:  cfg,
+		rng:  rng,
+		zipf: rand.NewZipf(rng, cfg.MarketSkew, 1, uint64(cfg.Markets-1)),
+	}
+}
+
+// cancel returns true if the next operation should cancel a resting order
+func (t *traffic) cancel() bool {
+	return t.rng.Float64() < t.cfg.CancelRatio
+}
+
+// next draws a placement
+// A few markets take most of the orders, like the headline events of a day
+func (t *traffic) next() placement {
+	betType := "LAY"
+	if t.rng.Float64() < t.cfg.BackRatio {
+		betType = "BACK"
+	}
+
+	// Stakes are log-normal: mostly small, with a long tail of large ones
+	stake := t.cfg.StakeMedian * math.Exp(t.cfg.StakeSigma*t.rng.NormFloat64())
+	amount := decimal.NewFromFloat(stake).Round(2)
+	if amount.LessThan(minStake) {
+		amount = minStake
+	}
+
+	return placement{
+		MarketID:  fmt.Sprintf("loadgen-event-%d", t.zipf.Uint64()),
+		Selection: fmt.Sprintf("selection-%d", t.rng.Intn(t.cfg.Selections)),
+		BetType:   betType,
+		Stake:     amount,
+	}
+}
+
+var minStake = decimal.RequireFromString("0.01")
+
+// reservation is a wallet hold an order is placed against
+type reservation struct {
+	UserID        uuid.UUID
+	ReservationID uuid.UUID
+}
+
+// readReservations reads user_id,reservation_id lines
+// The wallet holds each reservation at exactly one order's liability, so every
+// placement uses its own line
+func readReservations(path string) ([]reservation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reservations []reservation
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		userID, reservationID, ok := strings.Cut(text, ",")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: want user_id,reservation_id", path, line)
+		}
+		r := reservation{}
+		if r.UserID, err = uuid.Parse(strings.TrimSpace(userID)); err != nil {
+			return nil, fmt.Errorf("%s:%d: user_id: %w", path, line, err)
+		}
+		if r.ReservationID, err = uuid.Parse(strings.TrimSpace(reservationID)); err != nil {
+			return nil, fmt.Errorf("%s:%d: reservation_id: %w", path, line, err)
+		}
+		reservations = append(reservations, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/cypherlabdev/order-book-service/internal/events"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/repository"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+// history is the recorded commands of a market in sequence order
+type history struct {
+	source   string
+	commands []*matchingengine.Command
+	skipped  map[string]int // recorded events that are not commands, by event type
+}
+
+// journalHistory reads the market's commands from the engine journal
+// The journal must reach back to the market's first command, i.e. not be truncated
+// behind a snapshot that covers the market
+func journalHistory(dir, marketID string) (*history, error) {
+	h := &history{source: "journal " + dir}
+	err := matchingengine.ReadJournal(dir, 0, func(cmd *matchingengine.Command) error {
+		if cmd.MarketID == marketID {
+			h.commands = append(h.commands, cmd)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// outboxHistory rebuilds the market's commands from its sequenced outbox events
+// Placements and cancellations are recorded there; market status changes, expiries,
+// amendments and reinstatements are not, so their sequence numbers show up as
+// divergences. Events removed by outbox retention are missing as well
+func outboxHistory(ctx context.Context, outboxRepo repository.OutboxRepository, marketID string) (*history, error) {
+	outboxEvents, err := outboxRepo.ListMarketEvents(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &history{source: "outbox", skipped: make(map[string]int)}
+	for _, outboxEvent := range outboxEvents {
+		cmd, err := outboxCommand(outboxEvent, marketID)
+		if err != nil {
+			return nil, fmt.Errorf("outbox event %s: %w", outboxEvent.ID, err)
+		}
+		if cmd == nil {
+			// Matches are produced by replaying the placement that made them
+			h.skipped[outboxEvent.EventType]++
+			continue
+		}
+		h.commands = append(h.commands, cmd)
+	}
+	return h, nil
+}
+
+// outboxCommand returns the command an outbox event records, nil if it records none
+func outboxCommand(outboxEvent *models.OutboxEvent, marketID string) (*matchingengine.Command, error) {
+	if outboxEvent.EventType != models.EventTypeOrderPlaced && outboxEvent.EventType != models.EventTypeOrderCancelled {
+		return nil, nil
+	}
+
+	event, err := events.FromPayload(outboxEvent.EventType, outboxEvent.SchemaVersion, outboxEvent.EventPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := &matchingengine.Command{
+		MarketID: marketID,
+		Sequence: *outboxEvent.MarketSequence,
+	}
+
+	switch e := event.(type) {
+	case *events.OrderPlacedV1:
+		order, err := placedOrder(e)
+		if err != nil {
+			return nil, err
+		}
+		if outboxEvent.SagaID != nil {
+			order.SagaID = outboxEvent.SagaID.String()
+		}
+		cmd.Type = matchingengine.CommandPlace
+		cmd.Time = e.PlacedAt
+		cmd.Order = order
+
+	case *events.OrderCancelledV1:
+		orderID, err := uuid.Parse(e.OrderID)
+		if err != nil {
+			return nil, fmt.Errorf("parse order_id: %w", err)
+		}
+		cmd.Type = matchingengine.CommandCancel
+		cmd.Time = e.CancelledAt
+		cmd.OrderID = orderID
+
+	default:
+		return nil, fmt.Errorf("unexpected %s payload %T", outboxEvent.EventType, event)
+	}
+	return cmd, nil
+}
+
+// placedOrder rebuilds an order as the service submitted it to the engine
+func placedOrder(e *events.OrderPlacedV1) (*models.Order, error) {
+	id, err := uuid.Parse(e.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("parse order_id: %w", err)
+	}
+	userID, err := uuid.Parse(e.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("parse user_id: %w", err)
+	}
+	amount, err := decimal.NewFromString(e.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("parse amount: %w", err)
+	}
+	odds, err := decimal.NewFromString(e.Odds)
+	if err != nil {
+		return nil, fmt.Errorf("parse odds: %w", err)
+	}
+
+	return &models.Order{
+		ID:            id,
+		UserID:        userID,
+		MarketID:      e.EventID,
+		SelectionID:   e.Selection,
+		Side:          models.OrderSide(e.BetType),
+		Price:         odds,
+		Size:          amount,
+		SizeMatched:   decimal.Zero,
+		SizeRemaining: amount,
+		Status:        models.OrderStatusPending,
+		ReservationID: e.ReservationID,
+		PlacedAt:      e.PlacedAt,
+		Version:       1,
+	}, nil
+}
@@ -0,0 +1,118 @@
+// Command replay rebuilds a market by replaying its recorded history through a
+// fresh matching engine, and prints or diffs the resulting matches and book
+//
+// History comes from the engine journal (-journal) or from the sequenced outbox
+// events in the database (-outbox). With -diff the replayed matches are compared
+// with the matches table and the exit status is 1 if they differ
+//
+//	replay -market event-123 -journal /var/lib/order-book/journal
+//	replay -market event-123 -outbox -diff
+//	replay -market event-123 -journal /var/lib/order-book/journal -until 1500 -format json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+
+	"github.com/cypherlabdev/order-book-service/internal/config"
+	"github.com/cypherlabdev/order-book-service/internal/repository"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fatalf("load config: %v", err)
+	}
+
+	var (
+		marketID    = flag.String("market", "", "market (event) ID to replay")
+		journalDir  = flag.String("journal", cfg.Engine.JournalDir, "engine journal directory to replay")
+		fromOutbox  = flag.Bool("outbox", false, "replay the sequenced outbox events in the database instead of the journal")
+		databaseURL = flag.String("database-url", cfg.Database.URL, "database for -outbox and -diff")
+		diff        = flag.Bool("diff", false, "compare the replayed matches with the matches table")
+		until       = flag.Int64("until", 0, "replay only the commands that completed by this market sequence, 0 replays everything")
+		format      = flag.String("format", "text", "output format, text or json")
+		timeout     = flag.Duration("timeout", time.Minute, "time limit for database queries")
+	)
+	flag.Parse()
+
+	if *marketID == "" {
+		fatalf("-market is required")
+	}
+	if !*fromOutbox && *journalDir == "" {
+		fatalf("-journal or -outbox is required")
+	}
+	if *format != "text" && *format != "json" {
+		fatalf("unknown -format %q", *format)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var pool *pgxpool.Pool
+	if *fromOutbox || *diff {
+		pool, err = pgxpool.New(ctx, *databaseURL)
+		if err != nil {
+			fatalf("connect to database: %v", err)
+		}
+		defer pool.Close()
+	}
+
+	// Repository logs would interleave with the report
+	logger := zerolog.Nop()
+
+	var (
+		history      *history
+		replayerOpts []matchingengine.SequencerOption
+	)
+	if *fromOutbox {
+		history, err = outboxHistory(ctx, repository.NewPostgresOutboxRepository(pool, logger), *marketID)
+	} else {
+		history, err = journalHistory(*journalDir, *marketID)
+		if err == nil {
+			// Name matches as the server did, so the replay reproduces their IDs
+			var journalID uuid.UUID
+			journalID, err = matchingengine.ReadJournalID(*journalDir)
+			replayerOpts = append(replayerOpts, matchingengine.WithMatchIDs(matchingengine.SequenceMatchIDs(journalID)))
+		}
+	}
+	if err != nil {
+		fatalf("read history: %v", err)
+	}
+
+	report := replay(history, matchingengine.NewMarketReplayer(*marketID, replayerOpts...), *until)
+
+	if *diff {
+		recorded, err := repository.NewPostgresOrderRepository(pool, logger).GetMatchesByMarketID(ctx, *marketID)
+		if err != nil {
+			fatalf("read recorded matches: %v", err)
+		}
+		report.Diff = diffMatches(report.Matches, recorded, *until)
+	}
+
+	if *format == "json" {
+		err = report.writeJSON(os.Stdout)
+	} else {
+		err = report.writeText(os.Stdout)
+	}
+	if err != nil {
+		fatalf("write report: %v", err)
+	}
+
+	if report.Diff != nil && !report.Diff.empty() {
+		os.Exit(1)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "replay: "+format+"\n", args...)
+	os.Exit(2)
+}
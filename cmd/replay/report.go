@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+// report is the outcome of replaying a market
+type report struct {
+	MarketID    string               `json:"market_id"`
+	Source      string               `json:"source"`
+	Commands    int                  `json:"commands"`
+	Sequence    int64                `json:"sequence"` // Last sequence number the replay gave out
+	Status      models.MarketStatus  `json:"status"`
+	Skipped     map[string]int       `json:"skipped,omitempty"`
+	Divergences []*divergence        `json:"divergences"`
+	Matches     []*models.Match      `json:"matches"`
+	Books       []*models.MarketBook `json:"books"`
+	Diff        *matchDiff           `json:"diff,omitempty"`
+}
+
+// divergence is a recorded command the replay did not reproduce
+// Either it failed, or it ended on a different sequence number than recorded
+type divergence struct {
+	Index            int    `json:"index"` // Position of the command in the history
+	Command          string `json:"command"`
+	OrderID          string `json:"order_id,omitempty"`
+	RecordedSequence int64  `json:"recorded_sequence"`
+	ReplayedSequence int64  `json:"replayed_sequence"`
+	Error            string `json:"error,omitempty"`
+}
+
+// replay applies the history to a fresh market
+// With until set, it stops at the first command that ends past that sequence
+func replay(h *history, replayer *matchingengine.MarketReplayer, until int64) *report {
+	r := &report{
+		Source:      h.source,
+		Skipped:     h.skipped,
+		Divergences: []*divergence{},
+		Matches:     []*models.Match{},
+	}
+
+	for i, cmd := range h.commands {
+		if until > 0 && cmd.Sequence > until {
+			break
+		}
+
+		batch, err := replayer.Apply(cmd)
+		r.Commands++
+		if err != nil {
+			r.Divergences = append(r.Divergences, newDivergence(i, cmd, replayer.Sequence(), err))
+			continue
+		}
+		if replayer.Sequence() != cmd.Sequence {
+			r.Divergences = append(r.Divergences, newDivergence(i, cmd, replayer.Sequence(), nil))
+		}
+		r.Matches = append(r.Matches, batch.Matches()...)
+	}
+
+	r.MarketID = replayer.MarketID()
+	r.Sequence = replayer.Sequence()
+	r.Status = replayer.Status()
+	r.Books = replayer.Books()
+	return r
+}
+
+func newDivergence(index int, cmd *matchingengine.Command, replayed int64, err error) *divergence {
+	d := &divergence{
+		Index:            index,
+		Command:          cmd.Type.String(),
+		RecordedSequence: cmd.Sequence,
+		ReplayedSequence: replayed,
+	}
+	switch {
+	case cmd.Order != nil:
+		d.OrderID = cmd.Order.ID.String()
+	case cmd.Type == matchingengine.CommandCancel || cmd.Type == matchingengine.CommandAmend:
+		d.OrderID = cmd.OrderID.String()
+	}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	return d
+}
+
+// matchDiff compares replayed matches with recorded ones
+// Matches are the same trade if they pair the same orders at the same price and
+// size; IDs are not compared, outbox replays and matches made before the journal
+// existed get fresh ones
+type matchDiff struct {
+	Missing     []*models.Match `json:"missing"`     // Recorded but not made by the replay
+	Unexpected  []*models.Match `json:"unexpected"`  // Made by the replay but not recorded
+	Resequenced []*resequenced  `json:"resequenced"` // The same trade under another sequence number
+}
+
+type resequenced struct {
+	Recorded *models.Match `json:"recorded"`
+	Replayed *models.Match `json:"replayed"`
+}
+
+func (d *matchDiff) empty() bool {
+	return len(d.Missing) == 0 && len(d.Unexpected) == 0 && len(d.Resequenced) == 0
+}
+
+func matchKey(m *models.Match) string {
+	return fmt.Sprintf("%s/%s/%s/%s", m.BackOrderID, m.LayOrderID, m.Price.String(), m.Size.String())
+}
+
+// diffMatches pairs replayed and recorded matches in order
+// With until set, recorded matches sequenced after it are left out
+func diffMatches(replayed, recorded []*models.Match, until int64) *matchDiff {
+	d := &matchDiff{
+		Missing:     []*models.Match{},
+		Unexpected:  []*models.Match{},
+		Resequenced: []*resequenced{},
+	}
+
+	pending := make(map[string][]*models.Match)
+	var keys []*models.Match // recorded matches in order, to report the missing ones in order
+	for _, match := range recorded {
+		if until > 0 && match.Sequence > until {
+			continue
+		}
+		key := matchKey(match)
+		pending[key] = append(pending[key], match)
+		keys = append(keys, match)
+	}
+
+	paired := make(map[*models.Match]bool)
+	for _, match := range replayed {
+		key := matchKey(match)
+		candidates := pending[key]
+		if len(candidates) == 0 {
+			d.Unexpected = append(d.Unexpected, match)
+			continue
+		}
+
+		recordedMatch := candidates[0]
+		pending[key] = candidates[1:]
+		paired[recordedMatch] = true
+
+		// Matches made before sequencing have no number to compare
+		if recordedMatch.Sequence != 0 && recordedMatch.Sequence != match.Sequence {
+			d.Resequenced = append(d.Resequenced, &resequenced{Recorded: recordedMatch, Replayed: match})
+		}
+	}
+
+	for _, match := range keys {
+		if !paired[match] {
+			d.Missing = append(d.Missing, match)
+		}
+	}
+	return d
+}
+
+func (r *report) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func (r *report) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "market %s replayed from %s: %d commands, sequence %d, status %s\n",
+		r.MarketID, r.Source, r.Commands, r.Sequence, r.Status)
+
+	if len(r.Skipped) > 0 {
+		types := make([]string, 0, len(r.Skipped))
+		for eventType := range r.Skipped {
+			types = append(types, eventType)
+		}
+		sort.Strings(types)
+		fmt.Fprint(tw, "skipped events:")
+		for _, eventType := range types {
+			fmt.Fprintf(tw, " %s=%d", eventType, r.Skipped[eventType])
+		}
+		fmt.Fprintln(tw)
+	}
+
+	fmt.Fprintf(tw, "\ndivergences (%d)\n", len(r.Divergences))
+	if len(r.Divergences) > 0 {
+		fmt.Fprintln(tw, "INDEX\tCOMMAND\tORDER\tRECORDED\tREPLAYED\tERROR")
+		for _, d := range r.Divergences {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%s\n",
+				d.Index, d.Command, d.OrderID, d.RecordedSequence, d.ReplayedSequence, d.Error)
+		}
+	}
+
+	fmt.Fprintf(tw, "\nmatches (%d)\n", len(r.Matches))
+	writeMatches(tw, r.Matches)
+
+	for _, book := range r.Books {
+		fmt.Fprintf(tw, "\nbook %s\n", book.SelectionID)
+		fmt.Fprintln(tw, "SIDE\tPRICE\tSIZE\tORDERS")
+		for _, level := range book.BackOrders {
+			fmt.Fprintf(tw, "back\t%s\t%s\t%d\n", level.Price, level.TotalSize, level.OrderCount)
+		}
+		for _, level := range book.LayOrders {
+			fmt.Fprintf(tw, "lay\t%s\t%s\t%d\n", level.Price, level.TotalSize, level.OrderCount)
+		}
+	}
+
+	if d := r.Diff; d != nil {
+		fmt.Fprintf(tw, "\ndiff: %d missing, %d unexpected, %d resequenced\n",
+			len(d.Missing), len(d.Unexpected), len(d.Resequenced))
+		if len(d.Missing) > 0 {
+			fmt.Fprintln(tw, "missing")
+			writeMatches(tw, d.Missing)
+		}
+		if len(d.Unexpected) > 0 {
+			fmt.Fprintln(tw, "unexpected")
+			writeMatches(tw, d.Unexpected)
+		}
+		if len(d.Resequenced) > 0 {
+			fmt.Fprintln(tw, "resequenced")
+			fmt.Fprintln(tw, "RECORDED\tREPLAYED\tBACK ORDER\tLAY ORDER\tPRICE\tSIZE")
+			for _, m := range d.Resequenced {
+				fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\n",
+					m.Recorded.Sequence, m.Replayed.Sequence, m.Recorded.BackOrderID, m.Recorded.LayOrderID,
+					m.Recorded.Price, m.Recorded.Size)
+			}
+		}
+	}
+
+	return tw.Flush()
+}
+
+func writeMatches(w io.Writer, matches []*models.Match) {
+	if len(matches) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "SEQUENCE\tSELECTION\tBACK ORDER\tLAY ORDER\tPRICE\tSIZE")
+	for _, m := range matches {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", m.Sequence, m.SelectionID, m.BackOrderID, m.LayOrderID, m.Price, m.Size)
+	}
+}
@@ -2,15 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
-	"github.com/IBM/sarama"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
@@ -20,10 +21,14 @@ import (
 	grpcHandler "github.com/cypherlabdev/order-book-service/internal/handler/grpc"
 	"github.com/cypherlabdev/order-book-service/internal/handler/grpc/interceptors"
 	httpHandler "github.com/cypherlabdev/order-book-service/internal/handler/http"
+	"github.com/cypherlabdev/order-book-service/internal/maintenance"
 	"github.com/cypherlabdev/order-book-service/internal/messaging"
 	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/replication"
 	"github.com/cypherlabdev/order-book-service/internal/repository"
 	"github.com/cypherlabdev/order-book-service/internal/service"
+	"github.com/cypherlabdev/order-book-service/internal/sharding"
+	walletGRPC "github.com/cypherlabdev/order-book-service/internal/wallet/grpcclient"
 	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
 	orderbookv1 "github.com/cypherlabdev/cypherlabdev-protos/gen/go/orderbook/v1"
 )
@@ -61,44 +66,187 @@ func main() {
 	// 5. Run migrations (optional - can use migrate CLI instead)
 	// For production, migrations should be run separately as part of deployment
 
-	// 6. Initialize Kafka producer
-	kafkaConfig := sarama.NewConfig()
-	kafkaConfig.Producer.RequiredAcks = sarama.WaitForAll
-	kafkaConfig.Producer.Return.Successes = true
-	kafkaConfig.Producer.Retry.Max = 3
-	kafkaConfig.Producer.Compression = sarama.CompressionSnappy
-
-	kafkaProducer, err := sarama.NewSyncProducer(cfg.Kafka.Brokers, kafkaConfig)
+	// 6. Initialize event sink (Kafka, NATS JetStream or a local file)
+	eventSink, err := messaging.NewEventSink(messaging.SinkConfig{
+		Type: cfg.Sink.Type,
+		Kafka: messaging.ProducerConfig{
+			Brokers:         cfg.Kafka.Brokers,
+			Delivery:        cfg.Kafka.Delivery,
+			TransactionalID: cfg.Kafka.TransactionalID,
+		},
+		NATS: messaging.NATSConfig{
+			URL:           cfg.Sink.NATSURL,
+			SubjectPrefix: cfg.Sink.NATSSubjectPrefix,
+		},
+		File: cfg.Sink.File,
+	}, logger)
 	if err != nil {
-		logger.Fatal().Err(err).Msg("failed to create Kafka producer")
+		logger.Fatal().Err(err).Str("sink", cfg.Sink.Type).Msg("failed to create event sink")
 	}
-	defer kafkaProducer.Close()
-	logger.Info().Strs("brokers", cfg.Kafka.Brokers).Msg("kafka producer initialized")
+	defer eventSink.Close()
+	logger.Info().
+		Str("sink", eventSink.Name()).
+		Strs("brokers", cfg.Kafka.Brokers).
+		Str("delivery", cfg.Kafka.Delivery).
+		Msg("event sink initialized")
 
 	// 7. Initialize repositories
 	orderRepo := repository.NewPostgresOrderRepository(dbPool, logger)
 	outboxRepo := repository.NewPostgresOutboxRepository(dbPool, logger)
 	idempotencyRepo := repository.NewPostgresIdempotencyRepository(dbPool, logger)
+	sagaRepo := repository.NewPostgresSagaRepository(dbPool, logger)
+	marketRepo := repository.NewPostgresMarketRepository(dbPool, logger)
 
 	// 7a. Initialize matching engine
-	// In production, you would have one engine per market/selection pair
-	// For now, initialize a single engine for demonstration
-	matchingEngine := matchingengine.NewEngine("default-market", "default-selection")
-	logger.Info().Msg("matching engine initialized")
+	// Each market runs its commands on its own goroutine, with an engine per selection
+	var sequencerOpts []matchingengine.SequencerOption
+	var journal *matchingengine.Journal
+	if cfg.Engine.JournalDir != "" {
+		journal, err = matchingengine.OpenJournal(cfg.Engine.JournalDir, matchingengine.JournalOptions{
+			SegmentSize: cfg.Engine.JournalSegmentSize,
+			SyncDelay:   cfg.Engine.JournalSyncDelay,
+		})
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open engine journal")
+		}
+		defer journal.Close()
+		// Match IDs follow from the journal, so replaying it reproduces them.
+		// Commands a crash left between the journal and the database are
+		// decided against the orders table
+		sequencerOpts = append(sequencerOpts,
+			matchingengine.WithJournal(journal),
+			matchingengine.WithMatchIDs(matchingengine.SequenceMatchIDs(journal.ID())),
+			matchingengine.WithCommittedCheck(service.CommandCommitted(orderRepo)),
+		)
+	}
+
+	// Hot standby: replicas start as standbys and the elected one takes commands.
+	// Journaled commands wait for a standby to receive them before they persist
+	var replicaAcks *matchingengine.ReplicaAcks
+	if cfg.Engine.ReplicationEnabled {
+		if journal == nil {
+			logger.Fatal().Msg("engine replication requires ENGINE_JOURNAL_DIR")
+		}
+		if cfg.Engine.ShardingEnabled {
+			logger.Fatal().Msg("engine replication and sharding cannot be combined")
+		}
+		replicaAcks = matchingengine.NewReplicaAcks(journal, cfg.Engine.ReplicationAckTimeout)
+		sequencerOpts = append(sequencerOpts,
+			matchingengine.WithStandby(),
+			matchingengine.WithReplicaAcks(replicaAcks),
+		)
+	}
+
+	sequencer := matchingengine.NewSequencer(sequencerOpts...)
+	defer sequencer.Close()
+
+	// Rebuild the books from the latest snapshot and the journal after it
+	// before any command arrives
+	var snapshotStore matchingengine.SnapshotStore
+	var snapshotter *service.EngineSnapshotter
+	if journal != nil {
+		switch cfg.Engine.SnapshotStore {
+		case "postgres":
+			snapshotStore = repository.NewPostgresSnapshotRepository(dbPool, cfg.Engine.ID, logger)
+		default:
+			snapshotDir := cfg.Engine.SnapshotDir
+			if snapshotDir == "" {
+				snapshotDir = filepath.Join(cfg.Engine.JournalDir, "snapshots")
+			}
+			snapshotStore, err = matchingengine.NewFileSnapshotStore(snapshotDir)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("failed to open engine snapshot store")
+			}
+		}
+
+		snapshot, err := snapshotStore.Latest(context.Background())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to load engine snapshot")
+		}
+		replayed, err := sequencer.Recover(context.Background(), cfg.Engine.JournalDir, snapshot)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to replay engine journal")
+		}
+
+		event := logger.Info().
+			Str("dir", cfg.Engine.JournalDir).
+			Int("commands", replayed)
+		if snapshot != nil {
+			event = event.Int64("snapshot_position", snapshot.Position)
+		}
+		event.Msg("engine journal replayed")
+
+		snapshotter = service.NewEngineSnapshotter(sequencer, snapshotStore, journal, cfg.Engine.SnapshotInterval, logger)
+	}
+	logger.Info().Msg("market sequencer initialized")
+
+	// 7b. Connect to wallet service for liability reservations
+	walletClient, err := walletGRPC.NewWalletClient(cfg.Wallet.Address, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create wallet client")
+	}
+	defer walletClient.Close()
+	logger.Info().Str("address", cfg.Wallet.Address).Msg("wallet client initialized")
 
 	// 8. Initialize service layer
+	// With replication, transactions only commit under this replica's leader epoch
+	var engineDB service.Database = dbPool
+	var fencedDB *service.FencedDatabase
+	var engineLeaderRepo repository.EngineLeaderRepository
+	engineElection := cfg.Service.Name + ":engine"
+	if cfg.Engine.ReplicationEnabled {
+		engineLeaderRepo = repository.NewPostgresEngineLeaderRepository(dbPool, logger)
+		fencedDB = service.NewFencedDatabase(dbPool, engineLeaderRepo, engineElection)
+		engineDB = fencedDB
+	}
+
 	orderService := service.NewOrderService(
-		dbPool,
+		engineDB,
 		orderRepo,
 		outboxRepo,
 		idempotencyRepo,
-		matchingEngine,
+		sagaRepo,
+		sequencer,
+		walletClient,
 		metrics,
 		logger,
 	)
 
-	// 9. Initialize gRPC handler
-	orderHandler := grpcHandler.NewOrderBookHandler(orderService, logger)
+	outboxAdminService := service.NewOutboxAdminService(outboxRepo, metrics, logger)
+
+	marketService := service.NewMarketService(engineDB, marketRepo, orderService, sequencer, metrics, logger)
+
+	// Sharding: markets are spread over the StatefulSet's pods, calls for a market
+	// another pod owns are forwarded to it
+	var routedOrderService service.OrderService = orderService
+	var routedMarketService service.MarketService = marketService
+	var shards *sharding.Shards
+	if cfg.Engine.ShardingEnabled {
+		if cfg.Engine.ShardOrdinal < 0 {
+			logger.Fatal().Msg("engine sharding requires ENGINE_SHARD_ORDINAL or a StatefulSet pod hostname")
+		}
+		shardTransport := sharding.NewClient(cfg.Engine.ShardPeerAddress)
+		shards = sharding.NewShards(
+			sequencer,
+			repository.NewPostgresShardRepository(dbPool, logger),
+			shardTransport,
+			snapshotter,
+			sharding.Config{
+				Layout:        cfg.Engine.ShardLayout,
+				Ordinal:       cfg.Engine.ShardOrdinal,
+				Replicas:      cfg.Engine.ShardReplicas,
+				CheckInterval: cfg.Engine.ShardCheckInterval,
+			},
+			metrics,
+			logger,
+		)
+		routedOrderService = sharding.NewOrderRouter(orderService, orderRepo, shards, shardTransport, metrics)
+		routedMarketService = sharding.NewMarketRouter(marketService, shards, shardTransport, metrics)
+	}
+
+	// 9. Initialize gRPC handlers
+	orderHandler := grpcHandler.NewOrderBookHandler(routedOrderService, logger)
+	outboxAdminHandler := grpcHandler.NewOutboxAdminHandler(outboxAdminService, logger)
 
 	// 10. Create gRPC server with interceptors
 	grpcServer := grpc.NewServer(
@@ -112,13 +260,21 @@ func main() {
 
 	// Register proto service
 	orderbookv1.RegisterOrderBookServiceServer(grpcServer, orderHandler)
+	orderbookv1.RegisterOutboxAdminServiceServer(grpcServer, outboxAdminHandler)
 	logger.Info().Msg("gRPC server configured with interceptors")
 
 	// 11. Create HTTP server (health + metrics)
 	httpMux := http.NewServeMux()
 	httpMux.HandleFunc("/health", httpHandler.HealthHandler())
-	httpMux.HandleFunc("/ready", httpHandler.ReadyHandler(dbPool, kafkaProducer, logger))
+	httpMux.HandleFunc("/ready", httpHandler.ReadyHandler(dbPool, eventSink, logger))
 	httpMux.Handle("/metrics", promhttp.Handler())
+	if cfg.Engine.ReplicationEnabled {
+		replication.NewHandler(sequencer, journal, replicaAcks, logger).Register(httpMux)
+		httpMux.HandleFunc("/leader", httpHandler.LeaderHandler(sequencer))
+	}
+	if shards != nil {
+		sharding.NewHandler(shards, routedOrderService, routedMarketService, logger).Register(httpMux)
+	}
 
 	httpServer := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.HTTP.Port),
@@ -128,12 +284,155 @@ func main() {
 	}
 
 	// 12. Start outbox publisher (background goroutine)
-	publisher := messaging.NewOutboxPublisher(outboxRepo, kafkaProducer, logger)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	var outboxWakeups <-chan struct{}
+	if cfg.Outbox.NotifyEnabled {
+		notifier := messaging.NewOutboxNotifier(dbPool, logger)
+		outboxWakeups = notifier.Wakeups()
+		go notifier.Start(ctx)
+	}
+
+	// Only Kafka has producer delivery modes, other sinks deliver at least once
+	delivery := cfg.Kafka.Delivery
+	if eventSink.Name() != messaging.SinkKafka {
+		delivery = messaging.DeliveryAtLeastOnce
+	}
+
+	publisher := messaging.NewOutboxPublisher(
+		outboxRepo,
+		eventSink,
+		messaging.PublisherConfig{
+			PollInterval: cfg.Outbox.PollInterval,
+			BatchSize:    cfg.Outbox.BatchSize,
+			ClaimLease:   cfg.Outbox.ClaimLease,
+
+			RetryBackoff: messaging.RetryBackoff{
+				Initial:    cfg.Outbox.RetryInitialBackoff,
+				Max:        cfg.Outbox.RetryMaxBackoff,
+				Multiplier: cfg.Outbox.RetryMultiplier,
+				Jitter:     cfg.Outbox.RetryJitter,
+			},
+			DeadLetterTopic: cfg.Outbox.DLQTopic,
+
+			Encoding:          cfg.Outbox.EventEncoding,
+			MessageFormat:     cfg.Outbox.MessageFormat,
+			CloudEventsSource: cfg.Outbox.CloudEventsSource,
+			Delivery:          delivery,
+		},
+		outboxWakeups,
+		metrics,
+		logger,
+	)
+
 	go publisher.Start(ctx)
-	logger.Info().Msg("outbox publisher started")
+	logger.Info().Bool("notify", cfg.Outbox.NotifyEnabled).Msg("outbox publisher started")
+
+	// Workers that drive the engine, started once this replica leads it
+	var engineWorkers []func(ctx context.Context)
+
+	// Kafka order commands (optional), results go out through the event sink
+	if cfg.Commands.Enabled {
+		commandGroup, err := messaging.NewCommandConsumerGroup(cfg.Kafka.Brokers, cfg.Commands.GroupID)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to create command consumer group")
+		}
+		defer commandGroup.Close()
+
+		commandCfg := messaging.DefaultCommandConsumerConfig()
+		commandCfg.Topic = cfg.Commands.Topic
+		commandCfg.ReplyTopic = cfg.Commands.ReplyTopic
+		commandCfg.MaxAttempts = cfg.Commands.MaxAttempts
+
+		commandConsumer := messaging.NewCommandConsumer(commandGroup, routedOrderService, eventSink, commandCfg, metrics, logger)
+		engineWorkers = append(engineWorkers, commandConsumer.Start)
+	}
+
+	// Table housekeeping, only the replica holding the advisory lock runs it
+	if cfg.Maintenance.Enabled {
+		maintenanceCfg := maintenance.Config{
+			Interval:         cfg.Maintenance.Interval,
+			BatchSize:        cfg.Maintenance.BatchSize,
+			BatchPause:       cfg.Maintenance.BatchPause,
+			MaxBatchesPerRun: cfg.Maintenance.MaxBatchesPerRun,
+		}
+		jobs := []maintenance.Job{
+			maintenance.OutboxCleanupJob(outboxRepo, cfg.Maintenance.OutboxRetention),
+			maintenance.IdempotencyCleanupJob(idempotencyRepo),
+			maintenance.MarketInboxCleanupJob(marketRepo, cfg.Maintenance.InboxRetention),
+		}
+		leader := maintenance.NewPostgresLeader(dbPool, cfg.Service.Name+":maintenance", logger)
+
+		scheduler := maintenance.NewScheduler(leader, jobs, maintenanceCfg, metrics, logger)
+		go scheduler.Start(ctx)
+	}
+
+	// Engine snapshots bound how much of the journal recovery replays
+	if snapshotter != nil {
+		go snapshotter.Start(ctx)
+	}
+
+	// Upstream market status feed (optional) suspends, closes and settles markets
+	if cfg.Markets.StatusEnabled {
+		marketGroup, err := messaging.NewMarketStatusConsumerGroup(cfg.Kafka.Brokers, cfg.Markets.StatusGroupID)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to create market status consumer group")
+		}
+		defer marketGroup.Close()
+
+		marketCfg := messaging.DefaultMarketStatusConsumerConfig()
+		marketCfg.Topic = cfg.Markets.StatusTopic
+
+		marketConsumer := messaging.NewMarketStatusConsumer(marketGroup, routedMarketService, marketCfg, metrics, logger)
+		engineWorkers = append(engineWorkers, marketConsumer.Start)
+	}
+
+	// Suspended and closed markets keep rejecting orders across restarts
+	startEngine := func(ctx context.Context) {
+		// Books recovered for markets another pod holds are dropped first
+		if shards != nil {
+			if err := shards.Load(ctx); err != nil {
+				logger.Fatal().Err(err).Msg("failed to load engine shards")
+			}
+			go shards.Start(ctx)
+		}
+		if err := routedMarketService.RestoreMarkets(ctx, nil); err != nil {
+			logger.Fatal().Err(err).Msg("failed to restore market status")
+		}
+		for _, start := range engineWorkers {
+			go start(ctx)
+		}
+	}
+
+	if cfg.Engine.ReplicationEnabled {
+		replicator := service.NewEngineReplicator(
+			sequencer,
+			journal,
+			snapshotter,
+			maintenance.NewPostgresLeader(dbPool, engineElection, logger),
+			engineLeaderRepo,
+			replication.NewClient(),
+			fencedDB,
+			service.EngineReplicatorConfig{
+				Election:      engineElection,
+				EngineID:      cfg.Engine.ID,
+				Address:       cfg.Engine.ReplicationAddress,
+				CheckInterval: cfg.Engine.ReplicationCheckInterval,
+				DrainTimeout:  cfg.Engine.ReplicationDrainTimeout,
+			},
+			metrics,
+			logger,
+		)
+		// A replica that lost leadership restarts and reloads as a standby
+		go func() {
+			if err := replicator.Run(ctx, startEngine); err != nil && !errors.Is(err, context.Canceled) {
+				logger.Fatal().Err(err).Msg("matching engine replication failed")
+			}
+		}()
+	} else {
+		startEngine(ctx)
+	}
 
 	// 13. Start servers
 	go func() {
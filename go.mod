@@ -14,6 +14,9 @@ require (
 	// Database
 	github.com/jackc/pgx/v5 v5.7.4
 
+	// NATS
+	github.com/nats-io/nats.go v1.37.0
+
 	// Observability
 	github.com/prometheus/client_golang v1.19.0
 
@@ -28,6 +31,7 @@ require (
 )
 
 require (
+	github.com/pashagolub/pgxmock/v4 v4.9.0
 	github.com/stretchr/testify v1.10.0
 	go.uber.org/mock v0.6.0
 )
@@ -56,11 +60,12 @@ require (
 	github.com/jcmturner/gofork v1.7.6 // indirect
 	github.com/jcmturner/gokrb5/v8 v8.4.4 // indirect
 	github.com/jcmturner/rpc/v2 v2.0.3 // indirect
-	github.com/klauspost/compress v1.17.0 // indirect
+	github.com/klauspost/compress v1.17.2 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
-	github.com/pashagolub/pgxmock/v4 v4.9.0 // indirect
+	github.com/nats-io/nkeys v0.4.7 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
 	github.com/pierrec/lz4/v4 v4.1.21 // indirect
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
 	github.com/prometheus/client_model v0.5.0 // indirect
@@ -5,16 +5,24 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds all configuration for the service
 type Config struct {
-	Service  ServiceConfig
-	Database DatabaseConfig
-	Kafka    KafkaConfig
-	GRPC     GRPCConfig
-	HTTP     HTTPConfig
-	Logging  LoggingConfig
+	Service     ServiceConfig
+	Database    DatabaseConfig
+	Kafka       KafkaConfig
+	Sink        SinkConfig
+	Outbox      OutboxConfig
+	Commands    CommandsConfig
+	Markets     MarketsConfig
+	Maintenance MaintenanceConfig
+	Engine      EngineConfig
+	Wallet      WalletConfig
+	GRPC        GRPCConfig
+	HTTP        HTTPConfig
+	Logging     LoggingConfig
 }
 
 // ServiceConfig holds service-level configuration
@@ -35,7 +43,99 @@ type DatabaseConfig struct {
 
 // KafkaConfig holds Kafka broker configuration
 type KafkaConfig struct {
-	Brokers []string
+	Brokers         []string
+	Delivery        string // at_least_once, idempotent or transactional
+	TransactionalID string // Unique per instance and stable across its restarts
+}
+
+// SinkConfig selects where outbox events are published
+type SinkConfig struct {
+	Type              string // kafka, nats or file
+	NATSURL           string
+	NATSSubjectPrefix string // Prepended to the topic to form the JetStream subject
+	File              string // JSON lines file for the file sink, stdout by default
+}
+
+// OutboxConfig holds outbox publisher configuration
+type OutboxConfig struct {
+	NotifyEnabled bool          // LISTEN for insert notifications instead of relying on polling
+	PollInterval  time.Duration // Fallback poll, slower when notifications are enabled
+	BatchSize     int
+	ClaimLease    time.Duration
+	DLQTopic      string // Kafka topic receiving events that exhausted their retries
+
+	// Failed events are retried after RetryInitialBackoff * RetryMultiplier^(n-1),
+	// capped at RetryMaxBackoff and spread by ±RetryJitter
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
+	RetryMultiplier     float64
+	RetryJitter         float64
+
+	EventEncoding     string // Kafka payload encoding: protobuf or json
+	MessageFormat     string // Kafka message headers: plain or cloudevents
+	CloudEventsSource string // ce_source when MessageFormat is cloudevents
+}
+
+// CommandsConfig holds the Kafka order command consumer configuration
+type CommandsConfig struct {
+	Enabled     bool
+	Topic       string
+	GroupID     string
+	ReplyTopic  string // Default topic for command results, a reply_to header overrides it
+	MaxAttempts int    // Attempts for transient failures before a command fails
+}
+
+// MarketsConfig holds the upstream market status consumer configuration
+type MarketsConfig struct {
+	StatusEnabled bool
+	StatusTopic   string
+	StatusGroupID string
+}
+
+// MaintenanceConfig holds the table housekeeping job configuration
+type MaintenanceConfig struct {
+	Enabled          bool
+	Interval         time.Duration
+	BatchSize        int           // Rows deleted per statement
+	BatchPause       time.Duration // Pause between batches
+	MaxBatchesPerRun int
+	OutboxRetention  time.Duration // How long published outbox events are kept
+	InboxRetention   time.Duration // How long applied market status message IDs are kept
+}
+
+// EngineConfig holds matching engine configuration
+type EngineConfig struct {
+	JournalDir         string        // Command journal directory, the journal is off when empty
+	JournalSegmentSize int64         // Bytes per journal segment file
+	JournalSyncDelay   time.Duration // How long a journal flush gathers appends before fsync
+	ID                 string        // Names this replica's snapshots in Postgres, stable across restarts
+	SnapshotStore      string        // file or postgres
+	SnapshotDir        string        // Directory for the file store, <journal dir>/snapshots by default
+	SnapshotInterval   time.Duration // Time between snapshots, each truncates the journal before it
+
+	// Hot standby; replicas elect a leader and the others follow its journal
+	// Failover waits for Postgres to release the dead leader's advisory lock, so
+	// keep the server's TCP keepalives short
+	ReplicationEnabled       bool
+	ReplicationAddress       string        // Base URL other replicas reach this replica's HTTP server at
+	ReplicationAckTimeout    time.Duration // How long a command waits for a standby before detaching it
+	ReplicationCheckInterval time.Duration // Time between leadership checks
+	ReplicationDrainTimeout  time.Duration // How long a new leader reads the old leader's remaining journal
+
+	// Sharding; markets are spread over the pods of a StatefulSet, which forward
+	// calls to the pod owning the market. The replica count is read from Postgres,
+	// ShardReplicas only seeds it
+	ShardingEnabled    bool
+	ShardLayout        string        // Layout name, shared by all replicas
+	ShardReplicas      int           // Initial number of replicas markets are spread over
+	ShardOrdinal       int           // This replica's pod ordinal, from the hostname by default
+	ShardPeerAddress   string        // Base URL of a replica with %d in place of its ordinal
+	ShardCheckInterval time.Duration // Time between layout checks
+}
+
+// WalletConfig holds wallet-service client configuration
+type WalletConfig struct {
+	Address string
 }
 
 // GRPCConfig holds gRPC server configuration
@@ -69,7 +169,61 @@ func LoadConfig() (*Config, error) {
 			Database: getEnv("DB_NAME", "orderbook"),
 		},
 		Kafka: KafkaConfig{
-			Brokers: getEnvSlice("KAFKA_BROKERS", []string{"localhost:9092"}),
+			Brokers:         getEnvSlice("KAFKA_BROKERS", []string{"localhost:9092"}),
+			Delivery:        getEnv("KAFKA_DELIVERY", "at_least_once"),
+			TransactionalID: getEnv("KAFKA_TRANSACTIONAL_ID", defaultTransactionalID()),
+		},
+		Sink: SinkConfig{
+			Type:              getEnv("EVENT_SINK", "kafka"),
+			NATSURL:           getEnv("NATS_URL", "nats://localhost:4222"),
+			NATSSubjectPrefix: getEnv("NATS_SUBJECT_PREFIX", ""),
+			File:              getEnv("EVENT_SINK_FILE", "stdout"),
+		},
+		Commands: CommandsConfig{
+			Enabled:     getEnvBool("COMMANDS_ENABLED", false),
+			Topic:       getEnv("COMMANDS_TOPIC", "order.commands"),
+			GroupID:     getEnv("COMMANDS_GROUP_ID", "order-book-service"),
+			ReplyTopic:  getEnv("COMMANDS_REPLY_TOPIC", "order.command-results"),
+			MaxAttempts: getEnvInt("COMMANDS_MAX_ATTEMPTS", 5),
+		},
+		Markets: MarketsConfig{
+			StatusEnabled: getEnvBool("MARKET_STATUS_ENABLED", false),
+			StatusTopic:   getEnv("MARKET_STATUS_TOPIC", "market.status"),
+			StatusGroupID: getEnv("MARKET_STATUS_GROUP_ID", "order-book-service-market-status"),
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:          getEnvBool("MAINTENANCE_ENABLED", true),
+			Interval:         getEnvDuration("MAINTENANCE_INTERVAL", 10*time.Minute),
+			BatchSize:        getEnvInt("MAINTENANCE_BATCH_SIZE", 1000),
+			BatchPause:       getEnvDuration("MAINTENANCE_BATCH_PAUSE", 100*time.Millisecond),
+			MaxBatchesPerRun: getEnvInt("MAINTENANCE_MAX_BATCHES_PER_RUN", 100),
+			OutboxRetention:  getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+			InboxRetention:   getEnvDuration("MARKET_STATUS_INBOX_RETENTION", 30*24*time.Hour),
+		},
+		Engine: EngineConfig{
+			JournalDir:         getEnv("ENGINE_JOURNAL_DIR", ""),
+			JournalSegmentSize: int64(getEnvInt("ENGINE_JOURNAL_SEGMENT_SIZE", 64<<20)),
+			JournalSyncDelay:   getEnvDuration("ENGINE_JOURNAL_SYNC_DELAY", 0),
+			ID:                 getEnv("ENGINE_ID", defaultEngineID()),
+			SnapshotStore:      getEnv("ENGINE_SNAPSHOT_STORE", "file"),
+			SnapshotDir:        getEnv("ENGINE_SNAPSHOT_DIR", ""),
+			SnapshotInterval:   getEnvDuration("ENGINE_SNAPSHOT_INTERVAL", 5*time.Minute),
+
+			ReplicationEnabled:       getEnvBool("ENGINE_REPLICATION_ENABLED", false),
+			ReplicationAddress:       getEnv("ENGINE_REPLICATION_ADDRESS", defaultReplicationAddress()),
+			ReplicationAckTimeout:    getEnvDuration("ENGINE_REPLICATION_ACK_TIMEOUT", 100*time.Millisecond),
+			ReplicationCheckInterval: getEnvDuration("ENGINE_REPLICATION_CHECK_INTERVAL", 2*time.Second),
+			ReplicationDrainTimeout:  getEnvDuration("ENGINE_REPLICATION_DRAIN_TIMEOUT", 2*time.Second),
+
+			ShardingEnabled:    getEnvBool("ENGINE_SHARDING_ENABLED", false),
+			ShardLayout:        getEnv("ENGINE_SHARD_LAYOUT", getEnv("SERVICE_NAME", "order-book-service")),
+			ShardReplicas:      getEnvInt("ENGINE_SHARD_REPLICAS", 1),
+			ShardOrdinal:       getEnvInt("ENGINE_SHARD_ORDINAL", defaultShardOrdinal()),
+			ShardPeerAddress:   getEnv("ENGINE_SHARD_PEER_ADDRESS", defaultShardPeerAddress()),
+			ShardCheckInterval: getEnvDuration("ENGINE_SHARD_CHECK_INTERVAL", 5*time.Second),
+		},
+		Wallet: WalletConfig{
+			Address: getEnv("WALLET_SERVICE_ADDR", "localhost:8081"),
 		},
 		GRPC: GRPCConfig{
 			Port: getEnvInt("GRPC_PORT", 8082),
@@ -83,6 +237,24 @@ func LoadConfig() (*Config, error) {
 		},
 	}
 
+	// Poll slowly when notifications do the waking
+	cfg.Outbox.NotifyEnabled = getEnvBool("OUTBOX_NOTIFY_ENABLED", true)
+	defaultPoll := 100 * time.Millisecond
+	if cfg.Outbox.NotifyEnabled {
+		defaultPoll = 2 * time.Second
+	}
+	cfg.Outbox.PollInterval = getEnvDuration("OUTBOX_POLL_INTERVAL", defaultPoll)
+	cfg.Outbox.BatchSize = getEnvInt("OUTBOX_BATCH_SIZE", 100)
+	cfg.Outbox.ClaimLease = getEnvDuration("OUTBOX_CLAIM_LEASE", 30*time.Second)
+	cfg.Outbox.DLQTopic = getEnv("OUTBOX_DLQ_TOPIC", "order.events.dlq")
+	cfg.Outbox.RetryInitialBackoff = getEnvDuration("OUTBOX_RETRY_INITIAL_BACKOFF", time.Second)
+	cfg.Outbox.RetryMaxBackoff = getEnvDuration("OUTBOX_RETRY_MAX_BACKOFF", 5*time.Minute)
+	cfg.Outbox.RetryMultiplier = getEnvFloat("OUTBOX_RETRY_MULTIPLIER", 2)
+	cfg.Outbox.RetryJitter = getEnvFloat("OUTBOX_RETRY_JITTER", 0.2)
+	cfg.Outbox.EventEncoding = getEnv("OUTBOX_EVENT_ENCODING", "protobuf")
+	cfg.Outbox.MessageFormat = getEnv("OUTBOX_MESSAGE_FORMAT", "plain")
+	cfg.Outbox.CloudEventsSource = getEnv("OUTBOX_CLOUDEVENTS_SOURCE", "/order-book-service")
+
 	// Build database URL
 	cfg.Database.URL = fmt.Sprintf(
 		"postgres://%s:%s@%s:%d/%s?sslmode=disable",
@@ -104,6 +276,50 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// defaultTransactionalID derives a Kafka transactional id from the hostname
+// Stable pod names (e.g. a StatefulSet) keep it stable across restarts
+func defaultTransactionalID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "local"
+	}
+	return "order-book-service-" + hostname
+}
+
+// defaultEngineID names the engine after the hostname
+// Stable pod names keep it pointing at the same journal across restarts
+func defaultEngineID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "local"
+	}
+	return hostname
+}
+
+// defaultReplicationAddress reaches this replica's HTTP server by hostname
+// StatefulSet pods resolve by hostname through their headless service
+func defaultReplicationAddress() string {
+	return fmt.Sprintf("http://%s:%d", defaultEngineID(), getEnvInt("HTTP_PORT", 9092))
+}
+
+// defaultShardOrdinal reads the pod ordinal from a StatefulSet hostname, <name>-<ordinal>
+// Returns -1 for other hostnames
+func defaultShardOrdinal() int {
+	hostname := defaultEngineID()
+	ordinal, err := strconv.Atoi(hostname[strings.LastIndexByte(hostname, '-')+1:])
+	if err != nil || ordinal < 0 {
+		return -1
+	}
+	return ordinal
+}
+
+// defaultShardPeerAddress reaches the other pods of this replica's StatefulSet by hostname
+func defaultShardPeerAddress() string {
+	hostname := defaultEngineID()
+	prefix := hostname[:strings.LastIndexByte(hostname, '-')+1]
+	return fmt.Sprintf("http://%s%%d:%d", prefix, getEnvInt("HTTP_PORT", 9092))
+}
+
 // getEnvInt gets an integer environment variable or returns a default value
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -114,6 +330,36 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvBool gets a boolean environment variable or returns a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvFloat gets a float environment variable or returns a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDuration gets a duration environment variable (e.g. "250ms") or returns a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+	return defaultValue
+}
+
 // getEnvSlice gets a comma-separated environment variable as a slice
 func getEnvSlice(key string, defaultValue []string) []string {
 	if value := os.Getenv(key); value != "" {
@@ -0,0 +1,257 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Protobuf wire encoding for event structs
+// Fields carry their field number in a `pb:"N"` tag matching events.proto;
+// string, bool, int32, int64 and time.Time (google.protobuf.Timestamp) are supported
+// Zero values are omitted as in proto3, unknown fields are skipped on decode
+
+// protoField is a struct field mapped to a protobuf field number
+type protoField struct {
+	index  int
+	number protowire.Number
+	kind   reflect.Kind
+	isTime bool
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	fieldsCache sync.Map // reflect.Type -> []protoField
+)
+
+// protoFields returns the tagged fields of an event struct in field number order
+func protoFields(t reflect.Type) ([]protoField, error) {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.([]protoField), nil
+	}
+
+	fields := make([]protoField, 0, t.NumField())
+	seen := make(map[protowire.Number]string)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("pb")
+		if tag == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(tag)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%s.%s: invalid pb tag %q", t.Name(), sf.Name, tag)
+		}
+		number := protowire.Number(n)
+		if other, dup := seen[number]; dup {
+			return nil, fmt.Errorf("%s: field number %d used by %s and %s", t.Name(), n, other, sf.Name)
+		}
+		seen[number] = sf.Name
+
+		field := protoField{index: i, number: number, kind: sf.Type.Kind(), isTime: sf.Type == timeType}
+		switch {
+		case field.isTime:
+		case field.kind == reflect.String, field.kind == reflect.Bool,
+			field.kind == reflect.Int32, field.kind == reflect.Int64:
+		default:
+			return nil, fmt.Errorf("%s.%s: unsupported field type %s", t.Name(), sf.Name, sf.Type)
+		}
+		fields = append(fields, field)
+	}
+
+	for i := 1; i < len(fields); i++ {
+		for j := i; j > 0 && fields[j].number < fields[j-1].number; j-- {
+			fields[j], fields[j-1] = fields[j-1], fields[j]
+		}
+	}
+
+	fieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+// structValue returns the addressable struct behind an event pointer
+func structValue(event Event) (reflect.Value, error) {
+	v := reflect.ValueOf(event)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("event %T must be a non-nil struct pointer", event)
+	}
+	return v.Elem(), nil
+}
+
+// MarshalProto encodes an event in protobuf wire format
+func MarshalProto(event Event) ([]byte, error) {
+	v, err := structValue(event)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := protoFields(v.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	for _, field := range fields {
+		fv := v.Field(field.index)
+		switch {
+		case field.isTime:
+			t := fv.Interface().(time.Time)
+			if t.IsZero() {
+				continue
+			}
+			b = protowire.AppendTag(b, field.number, protowire.BytesType)
+			b = protowire.AppendBytes(b, marshalTimestamp(t))
+		case field.kind == reflect.String:
+			if fv.String() == "" {
+				continue
+			}
+			b = protowire.AppendTag(b, field.number, protowire.BytesType)
+			b = protowire.AppendString(b, fv.String())
+		case field.kind == reflect.Bool:
+			if !fv.Bool() {
+				continue
+			}
+			b = protowire.AppendTag(b, field.number, protowire.VarintType)
+			b = protowire.AppendVarint(b, protowire.EncodeBool(true))
+		default: // int32, int64
+			if fv.Int() == 0 {
+				continue
+			}
+			b = protowire.AppendTag(b, field.number, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(fv.Int()))
+		}
+	}
+
+	return b, nil
+}
+
+// UnmarshalProto decodes protobuf wire format into an event
+func UnmarshalProto(data []byte, event Event) error {
+	v, err := structValue(event)
+	if err != nil {
+		return err
+	}
+	fields, err := protoFields(v.Type())
+	if err != nil {
+		return err
+	}
+
+	byNumber := make(map[protowire.Number]protoField, len(fields))
+	for _, field := range fields {
+		byNumber[field.number] = field
+	}
+
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("decode tag: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		field, known := byNumber[number]
+		if !known {
+			// Field added by a newer schema
+			n = protowire.ConsumeFieldValue(number, wireType, data)
+			if n < 0 {
+				return fmt.Errorf("skip field %d: %w", number, protowire.ParseError(n))
+			}
+			data = data[n:]
+			continue
+		}
+
+		fv := v.Field(field.index)
+		switch {
+		case field.isTime, field.kind == reflect.String:
+			if wireType != protowire.BytesType {
+				return fmt.Errorf("field %d: unexpected wire type %d", number, wireType)
+			}
+			raw, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return fmt.Errorf("field %d: %w", number, protowire.ParseError(n))
+			}
+			data = data[n:]
+
+			if field.isTime {
+				t, err := unmarshalTimestamp(raw)
+				if err != nil {
+					return fmt.Errorf("field %d: %w", number, err)
+				}
+				fv.Set(reflect.ValueOf(t))
+			} else {
+				fv.SetString(string(raw))
+			}
+		default: // bool, int32, int64
+			if wireType != protowire.VarintType {
+				return fmt.Errorf("field %d: unexpected wire type %d", number, wireType)
+			}
+			x, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return fmt.Errorf("field %d: %w", number, protowire.ParseError(n))
+			}
+			data = data[n:]
+
+			if field.kind == reflect.Bool {
+				fv.SetBool(protowire.DecodeBool(x))
+			} else if field.kind == reflect.Int32 {
+				fv.SetInt(int64(int32(x)))
+			} else {
+				fv.SetInt(int64(x))
+			}
+		}
+	}
+
+	return nil
+}
+
+// marshalTimestamp encodes a time as google.protobuf.Timestamp
+func marshalTimestamp(t time.Time) []byte {
+	var b []byte
+	if seconds := t.Unix(); seconds != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(seconds))
+	}
+	if nanos := t.Nanosecond(); nanos != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(nanos))
+	}
+	return b
+}
+
+// unmarshalTimestamp decodes a google.protobuf.Timestamp into UTC time
+func unmarshalTimestamp(data []byte) (time.Time, error) {
+	var seconds, nanos int64
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return time.Time{}, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if wireType != protowire.VarintType || (number != 1 && number != 2) {
+			n = protowire.ConsumeFieldValue(number, wireType, data)
+			if n < 0 {
+				return time.Time{}, protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+
+		x, n := protowire.ConsumeVarint(data)
+		if n < 0 {
+			return time.Time{}, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if number == 1 {
+			seconds = int64(x)
+		} else {
+			nanos = int64(int32(x))
+		}
+	}
+
+	return time.Unix(seconds, nanos).UTC(), nil
+}
@@ -0,0 +1,166 @@
+// Package events defines the typed, versioned payloads the order book publishes
+//
+// Every event type in models/outbox.go has a schema here. Payloads are stored in
+// the outbox as JSON and encoded for Kafka as protobuf (see events.proto) or JSON
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// Payload encodings
+const (
+	EncodingProtobuf = "protobuf"
+	EncodingJSON     = "json"
+
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+)
+
+// ProtoPackage is the protobuf package of the event messages in events.proto
+// Messages are versioned individually by name suffix (OrderPlacedV1, ...)
+const ProtoPackage = "orderbook.events"
+
+// ErrUnknownSchema is returned for an event type and version without a schema
+var ErrUnknownSchema = errors.New("unknown event schema")
+
+// Event is a typed event payload
+type Event interface {
+	// EventType returns the models.EventType* constant of the event
+	EventType() string
+
+	// SchemaVersion returns the payload schema version
+	SchemaVersion() int
+
+	// SchemaName returns the protobuf message name without package
+	SchemaName() string
+}
+
+// schemaKey identifies a payload schema
+type schemaKey struct {
+	eventType string
+	version   int
+}
+
+// schemas maps every published event type and version to its payload type
+var schemas = map[schemaKey]func() Event{
+	{models.EventTypeOrderPlaced, 1}:      func() Event { return &OrderPlacedV1{} },
+	{models.EventTypeOrderMatched, 1}:     func() Event { return &OrderMatchedV1{} },
+	{models.EventTypeOrderPartial, 1}:     func() Event { return &OrderPartiallyMatchedV1{} },
+	{models.EventTypeOrderCancelled, 1}:   func() Event { return &OrderCancelledV1{} },
+	{models.EventTypeOrderExpired, 1}:     func() Event { return &OrderExpiredV1{} },
+	{models.EventTypeOrderSettled, 1}:     func() Event { return &OrderSettledV1{} },
+	{models.EventTypeOrderCompensated, 1}: func() Event { return &OrderCompensatedV1{} },
+	{models.EventTypeMatchCreated, 1}:     func() Event { return &MatchCreatedV1{} },
+	{models.EventTypeMatchSettled, 1}:     func() Event { return &MatchSettledV1{} },
+	{models.EventTypeMatchReversed, 1}:    func() Event { return &MatchReversedV1{} },
+}
+
+// New returns an empty payload for an event type and schema version
+func New(eventType string, version int) (Event, error) {
+	factory, ok := schemas[schemaKey{eventType, version}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownSchema, eventType, version)
+	}
+	return factory(), nil
+}
+
+// FullSchemaName returns the fully qualified protobuf message name of an event
+func FullSchemaName(event Event) string {
+	return ProtoPackage + "." + event.SchemaName()
+}
+
+// NewOutboxEvent wraps a typed event for the transactional outbox
+func NewOutboxEvent(aggregateType string, aggregateID uuid.UUID, event Event, sagaID *uuid.UUID) (*models.OutboxEvent, error) {
+	payload, err := ToPayload(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OutboxEvent{
+		AggregateID:   aggregateID,
+		AggregateType: aggregateType,
+		EventType:     event.EventType(),
+		SchemaVersion: event.SchemaVersion(),
+		EventPayload:  payload,
+		SagaID:        sagaID,
+	}, nil
+}
+
+// ToPayload converts an event to the JSON object stored in the outbox
+func ToPayload(event Event) (map[string]interface{}, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s payload: %w", event.EventType(), err)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("unmarshal %s payload: %w", event.EventType(), err)
+	}
+	return payload, nil
+}
+
+// FromPayload converts a stored outbox payload back to its typed event
+func FromPayload(eventType string, version int, payload map[string]interface{}) (Event, error) {
+	event, err := New(eventType, version)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s payload: %w", eventType, err)
+	}
+	if err := json.Unmarshal(data, event); err != nil {
+		return nil, fmt.Errorf("decode %s v%d payload: %w", eventType, version, err)
+	}
+	return event, nil
+}
+
+// Marshal encodes an event for publishing
+// Returns the encoded payload and its content type
+func Marshal(event Event, encoding string) ([]byte, string, error) {
+	switch encoding {
+	case EncodingProtobuf:
+		data, err := MarshalProto(event)
+		if err != nil {
+			return nil, "", fmt.Errorf("encode %s as protobuf: %w", event.EventType(), err)
+		}
+		return data, ContentTypeProtobuf, nil
+	case EncodingJSON:
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, "", fmt.Errorf("encode %s as json: %w", event.EventType(), err)
+		}
+		return data, ContentTypeJSON, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported event encoding %q", encoding)
+	}
+}
+
+// Unmarshal decodes a published payload given its event type, schema version and content type
+func Unmarshal(eventType string, version int, contentType string, data []byte) (Event, error) {
+	event, err := New(eventType, version)
+	if err != nil {
+		return nil, err
+	}
+
+	switch contentType {
+	case ContentTypeProtobuf:
+		err = UnmarshalProto(data, event)
+	case ContentTypeJSON:
+		err = json.Unmarshal(data, event)
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", contentType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode %s v%d: %w", eventType, version, err)
+	}
+	return event, nil
+}
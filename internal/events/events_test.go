@@ -0,0 +1,249 @@
+package events
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Contract tests: the golden files in testdata pin the JSON and protobuf shape
+// of every schema. A failing golden test means consumers would see a different
+// payload; bump the schema version instead of running with -update
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+var (
+	fixedTime = time.Date(2025, 3, 1, 12, 30, 15, 250000000, time.UTC)
+	orderID   = "0b9d1a5e-7c1f-4f7e-9a52-3d4c2e6f8a10"
+	userID    = "5f2b7c3d-1e4a-4b6c-8d9e-0a1b2c3d4e5f"
+	matchID   = "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"
+	layID     = "1c2d3e4f-5a6b-4c7d-8e9f-0a1b2c3d4e5f"
+	sagaID    = "7e6d5c4b-3a29-4180-9f7e-6d5c4b3a2918"
+)
+
+// samples returns a fully populated payload for every registered schema
+func samples() []Event {
+	return []Event{
+		&OrderPlacedV1{
+			OrderID: orderID, UserID: userID, EventID: "event-42", BetType: "back",
+			Selection: "home", Amount: "10.00", Odds: "2.50", PotentialPayout: "25.00",
+			ReservationID: "res-1", PlacedAt: fixedTime, Status: "partially_matched",
+			SizeMatched: "4.00", MatchesCount: 2,
+		},
+		&OrderMatchedV1{OrderID: orderID, UserID: userID, SizeMatched: "10.00", MatchedAt: fixedTime},
+		&OrderPartiallyMatchedV1{
+			OrderID: orderID, UserID: userID, SizeMatched: "4.00", SizeRemaining: "6.00", MatchedAt: fixedTime,
+		},
+		&OrderCancelledV1{OrderID: orderID, UserID: userID, CancelledAt: fixedTime},
+		&OrderExpiredV1{OrderID: orderID, UserID: userID, SizeRemaining: "6.00", ExpiredAt: fixedTime},
+		&OrderSettledV1{OrderID: orderID, UserID: userID, Result: "win", ActualPayout: "25.00", SettledAt: fixedTime},
+		&OrderCompensatedV1{
+			OrderID: orderID, UserID: userID, SagaID: sagaID, Reason: "wallet reservation failed",
+			ReversedMatches: 2, CompensatedAt: fixedTime,
+		},
+		&MatchCreatedV1{
+			MatchID: matchID, MarketID: "event-42", SelectionID: "home", BackOrderID: orderID,
+			LayOrderID: layID, MatchedSize: "4.00", MatchedPrice: "2.50", MatchedAt: fixedTime,
+		},
+		&MatchSettledV1{MatchID: matchID, Result: "back_won", SettledAt: fixedTime},
+		&MatchReversedV1{
+			MatchID: matchID, BackOrderID: orderID, LayOrderID: layID, MatchedSize: "4.00",
+			MatchedPrice: "2.50", Reason: "wallet reservation failed", ReversedAt: fixedTime,
+		},
+	}
+}
+
+func TestSchemas_CoverEveryEventType(t *testing.T) {
+	eventTypes := []string{
+		models.EventTypeOrderPlaced,
+		models.EventTypeOrderMatched,
+		models.EventTypeOrderPartial,
+		models.EventTypeOrderCancelled,
+		models.EventTypeOrderExpired,
+		models.EventTypeOrderSettled,
+		models.EventTypeOrderCompensated,
+		models.EventTypeMatchCreated,
+		models.EventTypeMatchSettled,
+		models.EventTypeMatchReversed,
+	}
+
+	for _, eventType := range eventTypes {
+		event, err := New(eventType, 1)
+		require.NoError(t, err, eventType)
+		assert.Equal(t, eventType, event.EventType())
+		assert.Equal(t, 1, event.SchemaVersion())
+	}
+	assert.Len(t, schemas, len(eventTypes), "every schema needs a sample and an event type constant")
+	assert.Len(t, samples(), len(schemas))
+
+	_, err := New("bet.matched", 1)
+	assert.ErrorIs(t, err, ErrUnknownSchema)
+}
+
+func TestGolden(t *testing.T) {
+	for _, event := range samples() {
+		t.Run(event.SchemaName(), func(t *testing.T) {
+			jsonData, err := json.MarshalIndent(event, "", "  ")
+			require.NoError(t, err)
+			checkGolden(t, event.SchemaName()+".json", append(jsonData, '\n'))
+
+			protoData, err := MarshalProto(event)
+			require.NoError(t, err)
+			checkGolden(t, event.SchemaName()+".pb.hex", []byte(hex.EncodeToString(protoData)+"\n"))
+		})
+	}
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.MkdirAll("testdata", 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "missing golden file, run go test -update once for a new schema")
+	assert.Equal(t, string(want), string(got), "payload shape of %s changed", name)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, encoding := range []string{EncodingProtobuf, EncodingJSON} {
+		for _, event := range samples() {
+			t.Run(encoding+"/"+event.SchemaName(), func(t *testing.T) {
+				data, contentType, err := Marshal(event, encoding)
+				require.NoError(t, err)
+
+				decoded, err := Unmarshal(event.EventType(), event.SchemaVersion(), contentType, data)
+				require.NoError(t, err)
+				assert.Equal(t, event, decoded)
+			})
+		}
+	}
+}
+
+func TestOutboxPayloadRoundTrip(t *testing.T) {
+	for _, event := range samples() {
+		t.Run(event.SchemaName(), func(t *testing.T) {
+			outboxEvent, err := NewOutboxEvent(models.AggregateTypeOrder, [16]byte{1}, event, nil)
+			require.NoError(t, err)
+			assert.Equal(t, event.EventType(), outboxEvent.EventType)
+			assert.Equal(t, event.SchemaVersion(), outboxEvent.SchemaVersion)
+
+			decoded, err := FromPayload(outboxEvent.EventType, outboxEvent.SchemaVersion, outboxEvent.EventPayload)
+			require.NoError(t, err)
+			assert.Equal(t, event, decoded)
+		})
+	}
+}
+
+func TestUnmarshalProto_SkipsUnknownFields(t *testing.T) {
+	event := &OrderCancelledV1{OrderID: orderID, UserID: userID, CancelledAt: fixedTime}
+	data, err := MarshalProto(event)
+	require.NoError(t, err)
+
+	// Field 15 (string) as a newer producer might add it
+	data = append(data, 0x7a, 0x03, 'n', 'e', 'w')
+
+	var decoded OrderCancelledV1
+	require.NoError(t, UnmarshalProto(data, &decoded))
+	assert.Equal(t, event, &decoded)
+}
+
+// protoMessage is a message parsed from events.proto
+type protoMessage map[string]protoFieldDef // field name -> definition
+
+type protoFieldDef struct {
+	typ    string
+	number int
+}
+
+var (
+	messageRe = regexp.MustCompile(`^message (\w+) \{$`)
+	fieldRe   = regexp.MustCompile(`^([\w.]+) (\w+) = (\d+);$`)
+)
+
+// parseProto reads the messages of events.proto
+// Only the flat scalar messages used in this package are understood
+func parseProto(t *testing.T) map[string]protoMessage {
+	t.Helper()
+
+	f, err := os.Open("events.proto")
+	require.NoError(t, err)
+	defer f.Close()
+
+	messages := make(map[string]protoMessage)
+	var current protoMessage
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "package "):
+			assert.Equal(t, "package "+ProtoPackage+";", line)
+		case messageRe.MatchString(line):
+			current = make(protoMessage)
+			messages[messageRe.FindStringSubmatch(line)[1]] = current
+		case line == "}":
+			current = nil
+		case current != nil && fieldRe.MatchString(line):
+			m := fieldRe.FindStringSubmatch(line)
+			number, _ := strconv.Atoi(m[3])
+			current[m[2]] = protoFieldDef{typ: m[1], number: number}
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return messages
+}
+
+func TestProtoFile_MatchesGoTypes(t *testing.T) {
+	messages := parseProto(t)
+	assert.Len(t, messages, len(schemas), "events.proto and the schema registry disagree")
+
+	protoTypes := map[reflect.Kind]string{
+		reflect.String: "string",
+		reflect.Bool:   "bool",
+		reflect.Int32:  "int32",
+		reflect.Int64:  "int64",
+	}
+
+	for _, event := range samples() {
+		t.Run(event.SchemaName(), func(t *testing.T) {
+			message, ok := messages[event.SchemaName()]
+			require.True(t, ok, "message %s missing from events.proto", event.SchemaName())
+
+			typ := reflect.TypeOf(event).Elem()
+			require.Equal(t, len(message), typ.NumField(), "field count")
+
+			for i := 0; i < typ.NumField(); i++ {
+				sf := typ.Field(i)
+				name := strings.Split(sf.Tag.Get("json"), ",")[0]
+				def, ok := message[name]
+				require.True(t, ok, "field %s missing from events.proto", name)
+
+				number, err := strconv.Atoi(sf.Tag.Get("pb"))
+				require.NoError(t, err)
+				assert.Equal(t, def.number, number, "field number of %s", name)
+
+				wantType := protoTypes[sf.Type.Kind()]
+				if sf.Type == timeType {
+					wantType = "google.protobuf.Timestamp"
+				}
+				assert.Equal(t, wantType, def.typ, "type of %s", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+)
+
+// Match event payloads, version 1
+// Field numbers follow the same rules as the order events
+
+// MatchCreatedV1 is published when a back and a lay order are matched
+type MatchCreatedV1 struct {
+	MatchID      string    `json:"match_id" pb:"1"`
+	MarketID     string    `json:"market_id" pb:"2"`
+	SelectionID  string    `json:"selection_id" pb:"3"`
+	BackOrderID  string    `json:"back_order_id" pb:"4"`
+	LayOrderID   string    `json:"lay_order_id" pb:"5"`
+	MatchedSize  string    `json:"matched_size" pb:"6"`
+	MatchedPrice string    `json:"matched_price" pb:"7"`
+	MatchedAt    time.Time `json:"matched_at" pb:"8"`
+}
+
+func (*MatchCreatedV1) EventType() string  { return models.EventTypeMatchCreated }
+func (*MatchCreatedV1) SchemaVersion() int { return 1 }
+func (*MatchCreatedV1) SchemaName() string { return "MatchCreatedV1" }
+
+// MatchSettledV1 is published when a match is settled
+type MatchSettledV1 struct {
+	MatchID   string    `json:"match_id" pb:"1"`
+	Result    string    `json:"result" pb:"2"` // back_won, lay_won, void
+	SettledAt time.Time `json:"settled_at" pb:"3"`
+}
+
+func (*MatchSettledV1) EventType() string  { return models.EventTypeMatchSettled }
+func (*MatchSettledV1) SchemaVersion() int { return 1 }
+func (*MatchSettledV1) SchemaName() string { return "MatchSettledV1" }
+
+// MatchReversedV1 is published when saga compensation undoes a match
+type MatchReversedV1 struct {
+	MatchID      string    `json:"match_id" pb:"1"`
+	BackOrderID  string    `json:"back_order_id" pb:"2"`
+	LayOrderID   string    `json:"lay_order_id" pb:"3"`
+	MatchedSize  string    `json:"matched_size" pb:"4"`
+	MatchedPrice string    `json:"matched_price" pb:"5"`
+	Reason       string    `json:"reason" pb:"6"`
+	ReversedAt   time.Time `json:"reversed_at" pb:"7"`
+}
+
+func (*MatchReversedV1) EventType() string  { return models.EventTypeMatchReversed }
+func (*MatchReversedV1) SchemaVersion() int { return 1 }
+func (*MatchReversedV1) SchemaName() string { return "MatchReversedV1" }
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+)
+
+// Order event payloads, version 1
+// Field numbers are part of the wire contract: never reuse or renumber them,
+// add a new field or a new schema version instead
+// Amounts, odds and sizes are decimal strings
+
+// OrderPlacedV1 is published when an order is accepted onto the book
+type OrderPlacedV1 struct {
+	OrderID         string    `json:"order_id" pb:"1"`
+	UserID          string    `json:"user_id" pb:"2"`
+	EventID         string    `json:"event_id" pb:"3"` // Sporting event (market) the bet is on
+	BetType         string    `json:"bet_type" pb:"4"`
+	Selection       string    `json:"selection" pb:"5"`
+	Amount          string    `json:"amount" pb:"6"`
+	Odds            string    `json:"odds" pb:"7"`
+	PotentialPayout string    `json:"potential_payout" pb:"8"`
+	ReservationID   string    `json:"reservation_id" pb:"9"`
+	PlacedAt        time.Time `json:"placed_at" pb:"10"`
+	Status          string    `json:"status" pb:"11"`
+	SizeMatched     string    `json:"size_matched" pb:"12"`
+	MatchesCount    int32     `json:"matches_count" pb:"13"`
+}
+
+func (*OrderPlacedV1) EventType() string  { return models.EventTypeOrderPlaced }
+func (*OrderPlacedV1) SchemaVersion() int { return 1 }
+func (*OrderPlacedV1) SchemaName() string { return "OrderPlacedV1" }
+
+// OrderMatchedV1 is published when an order's full size has been matched
+type OrderMatchedV1 struct {
+	OrderID     string    `json:"order_id" pb:"1"`
+	UserID      string    `json:"user_id" pb:"2"`
+	SizeMatched string    `json:"size_matched" pb:"3"`
+	MatchedAt   time.Time `json:"matched_at" pb:"4"`
+}
+
+func (*OrderMatchedV1) EventType() string  { return models.EventTypeOrderMatched }
+func (*OrderMatchedV1) SchemaVersion() int { return 1 }
+func (*OrderMatchedV1) SchemaName() string { return "OrderMatchedV1" }
+
+// OrderPartiallyMatchedV1 is published when part of an order has been matched
+type OrderPartiallyMatchedV1 struct {
+	OrderID       string    `json:"order_id" pb:"1"`
+	UserID        string    `json:"user_id" pb:"2"`
+	SizeMatched   string    `json:"size_matched" pb:"3"`
+	SizeRemaining string    `json:"size_remaining" pb:"4"`
+	MatchedAt     time.Time `json:"matched_at" pb:"5"`
+}
+
+func (*OrderPartiallyMatchedV1) EventType() string  { return models.EventTypeOrderPartial }
+func (*OrderPartiallyMatchedV1) SchemaVersion() int { return 1 }
+func (*OrderPartiallyMatchedV1) SchemaName() string { return "OrderPartiallyMatchedV1" }
+
+// OrderCancelledV1 is published when an order is cancelled
+type OrderCancelledV1 struct {
+	OrderID     string    `json:"order_id" pb:"1"`
+	UserID      string    `json:"user_id" pb:"2"`
+	CancelledAt time.Time `json:"cancelled_at" pb:"3"`
+}
+
+func (*OrderCancelledV1) EventType() string  { return models.EventTypeOrderCancelled }
+func (*OrderCancelledV1) SchemaVersion() int { return 1 }
+func (*OrderCancelledV1) SchemaName() string { return "OrderCancelledV1" }
+
+// OrderExpiredV1 is published when an unmatched order lapses
+type OrderExpiredV1 struct {
+	OrderID       string    `json:"order_id" pb:"1"`
+	UserID        string    `json:"user_id" pb:"2"`
+	SizeRemaining string    `json:"size_remaining" pb:"3"`
+	ExpiredAt     time.Time `json:"expired_at" pb:"4"`
+}
+
+func (*OrderExpiredV1) EventType() string  { return models.EventTypeOrderExpired }
+func (*OrderExpiredV1) SchemaVersion() int { return 1 }
+func (*OrderExpiredV1) SchemaName() string { return "OrderExpiredV1" }
+
+// OrderSettledV1 is published when an order is settled
+type OrderSettledV1 struct {
+	OrderID      string    `json:"order_id" pb:"1"`
+	UserID       string    `json:"user_id" pb:"2"`
+	Result       string    `json:"result" pb:"3"` // win, loss
+	ActualPayout string    `json:"actual_payout" pb:"4"`
+	SettledAt    time.Time `json:"settled_at" pb:"5"`
+}
+
+func (*OrderSettledV1) EventType() string  { return models.EventTypeOrderSettled }
+func (*OrderSettledV1) SchemaVersion() int { return 1 }
+func (*OrderSettledV1) SchemaName() string { return "OrderSettledV1" }
+
+// OrderCompensatedV1 is published when a saga's order placement is undone
+type OrderCompensatedV1 struct {
+	OrderID         string    `json:"order_id" pb:"1"`
+	UserID          string    `json:"user_id" pb:"2"`
+	SagaID          string    `json:"saga_id" pb:"3"`
+	Reason          string    `json:"reason" pb:"4"`
+	ReversedMatches int32     `json:"reversed_matches" pb:"5"`
+	CompensatedAt   time.Time `json:"compensated_at" pb:"6"`
+}
+
+func (*OrderCompensatedV1) EventType() string  { return models.EventTypeOrderCompensated }
+func (*OrderCompensatedV1) SchemaVersion() int { return 1 }
+func (*OrderCompensatedV1) SchemaName() string { return "OrderCompensatedV1" }
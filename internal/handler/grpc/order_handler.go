@@ -2,7 +2,8 @@ package grpc
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"strings"
 
 	"github.com/cypherlabdev/order-book-service/internal/models"
 	"github.com/cypherlabdev/order-book-service/internal/service"
@@ -136,12 +137,13 @@ func (h *OrderBookHandler) CancelBet(ctx context.Context, req *orderbookv1.Cance
 	}
 
 	// Call service layer
-	if err := h.orderService.CancelOrder(ctx, serviceReq); err != nil {
+	result, err := h.orderService.CancelOrder(ctx, serviceReq)
+	if err != nil {
 		return nil, h.mapError(err)
 	}
 
 	return &orderbookv1.CancelBetResponse{
-		Status: "cancelled",
+		Status: strings.ToLower(string(result.Status)),
 	}, nil
 }
 
@@ -179,16 +181,51 @@ func (h *OrderBookHandler) SettleBet(ctx context.Context, req *orderbookv1.Settl
 	}
 
 	// Call service layer
-	if err := h.orderService.SettleOrder(ctx, serviceReq); err != nil {
+	result, err := h.orderService.SettleOrder(ctx, serviceReq)
+	if err != nil {
 		return nil, h.mapError(err)
 	}
 
 	return &orderbookv1.SettleBetResponse{
-		TransactionId: orderID.String(), // Using order ID as transaction ID
-		Status:        fmt.Sprintf("settled_%s", req.Result),
+		TransactionId: result.OrderID.String(), // Using order ID as transaction ID
+		Status:        string(result.Status),
 	}, nil
 }
 
+// CompensatePlaceBet undoes a saga's bet placement for Temporal compensation
+func (h *OrderBookHandler) CompensatePlaceBet(ctx context.Context, req *orderbookv1.CompensatePlaceBetRequest) (*orderbookv1.CompensatePlaceBetResponse, error) {
+	// Parse saga ID
+	sagaID, err := uuid.Parse(req.SagaId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid saga_id: %v", err)
+	}
+
+	// Create service request
+	serviceReq := &service.CompensatePlaceOrderRequest{
+		SagaID: sagaID,
+		Reason: req.Reason,
+	}
+
+	// Call service layer
+	result, err := h.orderService.CompensatePlaceOrder(ctx, serviceReq)
+	if err != nil {
+		return nil, h.mapError(err)
+	}
+
+	resp := &orderbookv1.CompensatePlaceBetResponse{
+		Status:          "compensated",
+		ReversedMatches: int32(result.ReversedMatches),
+	}
+	if result.OrderID != nil {
+		resp.OrderId = result.OrderID.String()
+	}
+	if result.AlreadyCompensated {
+		resp.Status = "already_compensated"
+	}
+
+	return resp, nil
+}
+
 // GetBetStatus retrieves the status of a bet
 func (h *OrderBookHandler) GetBetStatus(ctx context.Context, req *orderbookv1.GetBetStatusRequest) (*orderbookv1.GetBetStatusResponse, error) {
 	// Parse order ID
@@ -216,14 +253,37 @@ func (h *OrderBookHandler) GetBetStatus(ctx context.Context, req *orderbookv1.Ge
 }
 
 // mapError maps internal errors to gRPC status codes
+// Errors replayed from an idempotency key wrap the original error and map the same way
 func (h *OrderBookHandler) mapError(err error) error {
-	switch err {
-	case models.ErrOrderNotFound:
+	switch {
+	case errors.Is(err, models.ErrOrderNotFound):
 		return status.Error(codes.NotFound, "order not found")
-	case models.ErrIdempotencyMismatch:
+	case errors.Is(err, models.ErrIdempotencyMismatch):
 		return status.Error(codes.AlreadyExists, "idempotency key already used with different request")
-	case models.ErrOptimisticLock:
+	case errors.Is(err, models.ErrIdempotencyInProgress):
+		return status.Error(codes.Aborted, "request with this idempotency key is still in progress, please retry")
+	case errors.Is(err, models.ErrIdempotencyClaimLost):
+		return status.Error(codes.Aborted, "request with this idempotency key was taken over by a retry, please retry")
+	case errors.Is(err, models.ErrOptimisticLock):
 		return status.Error(codes.Aborted, "concurrent modification detected, please retry")
+	case errors.Is(err, models.ErrInvalidOrderStatus):
+		return status.Error(codes.FailedPrecondition, "order status does not allow this operation")
+	case errors.Is(err, models.ErrSagaCompensated):
+		return status.Error(codes.FailedPrecondition, "saga has already been compensated")
+	case errors.Is(err, models.ErrReservationRequired):
+		return status.Error(codes.InvalidArgument, "reservation_id is required")
+	case errors.Is(err, models.ErrReservationMismatch):
+		return status.Error(codes.PermissionDenied, "reservation belongs to a different user")
+	case errors.Is(err, models.ErrInsufficientReservation):
+		return status.Error(codes.FailedPrecondition, "reservation does not cover order liability")
+	case errors.Is(err, models.ErrMarketSuspended):
+		return status.Error(codes.FailedPrecondition, "market is suspended")
+	case errors.Is(err, models.ErrMarketClosed):
+		return status.Error(codes.FailedPrecondition, "market is closed")
+	case errors.Is(err, models.ErrNotLeader), errors.Is(err, models.ErrFenced):
+		return status.Error(codes.Unavailable, "matching engine leader is changing, please retry")
+	case errors.Is(err, models.ErrNotMarketOwner):
+		return status.Error(codes.Unavailable, "market is moving to another replica, please retry")
 	default:
 		h.logger.Error().Err(err).Msg("internal error")
 		return status.Error(codes.Internal, "internal server error")
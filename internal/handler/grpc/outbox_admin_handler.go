@@ -0,0 +1,144 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	orderbookv1 "github.com/cypherlabdev/cypherlabdev-protos/gen/go/orderbook/v1"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/service"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// OutboxAdminHandler implements the gRPC OutboxAdminService
+type OutboxAdminHandler struct {
+	orderbookv1.UnimplementedOutboxAdminServiceServer
+	adminService service.OutboxAdminService
+	logger       zerolog.Logger
+}
+
+// NewOutboxAdminHandler creates a new outbox admin gRPC handler
+func NewOutboxAdminHandler(adminService service.OutboxAdminService, logger zerolog.Logger) *OutboxAdminHandler {
+	return &OutboxAdminHandler{
+		adminService: adminService,
+		logger:       logger.With().Str("component", "grpc_outbox_admin_handler").Logger(),
+	}
+}
+
+// ListDeadLetteredEvents lists outbox events that exhausted their retries
+func (h *OutboxAdminHandler) ListDeadLetteredEvents(ctx context.Context, req *orderbookv1.ListDeadLetteredEventsRequest) (*orderbookv1.ListDeadLetteredEventsResponse, error) {
+	if req.Offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset must not be negative")
+	}
+
+	events, err := h.adminService.ListDeadLetteredEvents(ctx, int(req.Limit), int(req.Offset))
+	if err != nil {
+		return nil, h.mapError(err)
+	}
+
+	resp := &orderbookv1.ListDeadLetteredEventsResponse{
+		Events: make([]*orderbookv1.OutboxEvent, 0, len(events)),
+	}
+	for _, event := range events {
+		pb, err := h.toProtoOutboxEvent(event)
+		if err != nil {
+			return nil, err
+		}
+		resp.Events = append(resp.Events, pb)
+	}
+
+	return resp, nil
+}
+
+// GetOutboxEvent returns a single outbox event, including its payload and last error
+func (h *OutboxAdminHandler) GetOutboxEvent(ctx context.Context, req *orderbookv1.GetOutboxEventRequest) (*orderbookv1.GetOutboxEventResponse, error) {
+	// Parse event ID
+	eventID, err := uuid.Parse(req.EventId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid event_id: %v", err)
+	}
+
+	event, err := h.adminService.GetOutboxEvent(ctx, eventID)
+	if err != nil {
+		return nil, h.mapError(err)
+	}
+
+	pb, err := h.toProtoOutboxEvent(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orderbookv1.GetOutboxEventResponse{
+		Event: pb,
+	}, nil
+}
+
+// ReplayDeadLetteredEvent queues a dead-lettered event for publishing again
+func (h *OutboxAdminHandler) ReplayDeadLetteredEvent(ctx context.Context, req *orderbookv1.ReplayDeadLetteredEventRequest) (*orderbookv1.ReplayDeadLetteredEventResponse, error) {
+	// Parse event ID
+	eventID, err := uuid.Parse(req.EventId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid event_id: %v", err)
+	}
+
+	if err := h.adminService.ReplayDeadLetteredEvent(ctx, eventID); err != nil {
+		return nil, h.mapError(err)
+	}
+
+	return &orderbookv1.ReplayDeadLetteredEventResponse{
+		Status: "queued",
+	}, nil
+}
+
+// toProtoOutboxEvent converts an outbox event to its admin API representation
+func (h *OutboxAdminHandler) toProtoOutboxEvent(event *models.OutboxEvent) (*orderbookv1.OutboxEvent, error) {
+	payload, err := json.Marshal(event.EventPayload)
+	if err != nil {
+		h.logger.Error().Err(err).Str("event_id", event.ID.String()).Msg("failed to marshal outbox event payload")
+		return nil, status.Error(codes.Internal, "failed to encode outbox event payload")
+	}
+
+	pb := &orderbookv1.OutboxEvent{
+		Id:                event.ID.String(),
+		AggregateId:       event.AggregateID.String(),
+		AggregateType:     event.AggregateType,
+		AggregateSequence: event.AggregateSequence,
+		EventType:         event.EventType,
+		PayloadJson:       string(payload),
+		CreatedAt:         timestamppb.New(event.CreatedAt),
+		RetryCount:        int32(event.RetryCount),
+		MaxRetries:        int32(event.MaxRetries),
+	}
+	if event.SagaID != nil {
+		pb.SagaId = event.SagaID.String()
+	}
+	if event.LastError != nil {
+		pb.LastError = *event.LastError
+	}
+	if event.ProcessedAt != nil {
+		pb.ProcessedAt = timestamppb.New(*event.ProcessedAt)
+	}
+	if event.DeadLetteredAt != nil {
+		pb.DeadLetteredAt = timestamppb.New(*event.DeadLetteredAt)
+	}
+
+	return pb, nil
+}
+
+// mapError maps internal errors to gRPC status codes
+func (h *OutboxAdminHandler) mapError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrOutboxEventNotFound):
+		return status.Error(codes.NotFound, "outbox event not found")
+	case errors.Is(err, models.ErrOutboxNotDeadLettered):
+		return status.Error(codes.FailedPrecondition, "outbox event is not dead-lettered")
+	default:
+		h.logger.Error().Err(err).Msg("internal error")
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
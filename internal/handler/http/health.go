@@ -6,7 +6,8 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/IBM/sarama"
+	"github.com/cypherlabdev/order-book-service/internal/messaging"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 )
@@ -23,7 +24,7 @@ func HealthHandler() http.HandlerFunc {
 }
 
 // ReadyHandler returns a readiness check (checks dependencies)
-func ReadyHandler(db *pgxpool.Pool, kafkaProducer sarama.SyncProducer, logger zerolog.Logger) http.HandlerFunc {
+func ReadyHandler(db *pgxpool.Pool, eventSink messaging.EventSink, logger zerolog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 		defer cancel()
@@ -43,16 +44,16 @@ func ReadyHandler(db *pgxpool.Pool, kafkaProducer sarama.SyncProducer, logger ze
 			return
 		}
 
-		// Check Kafka (simple check - if producer is nil, it's not ready)
-		if kafkaProducer == nil {
-			logger.Error().Msg("kafka producer is nil")
+		// Check event sink (simple check - if sink is nil, it's not ready)
+		if eventSink == nil {
+			logger.Error().Msg("event sink is nil")
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusServiceUnavailable)
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"status": "unavailable",
 				"checks": map[string]string{
-					"database": "ok",
-					"kafka":    "failed",
+					"database":   "ok",
+					"event_sink": "failed",
 				},
 			})
 			return
@@ -64,9 +65,29 @@ func ReadyHandler(db *pgxpool.Pool, kafkaProducer sarama.SyncProducer, logger ze
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"status": "ready",
 			"checks": map[string]string{
-				"database": "ok",
-				"kafka":    "ok",
+				"database":   "ok",
+				"event_sink": "ok",
+				"sink_type":  eventSink.Name(),
 			},
 		})
 	}
 }
+
+// LeaderHandler reports whether this replica's matching engine is the leader
+// Standbys answer 503, so a Service selecting on it routes commands to the leader
+func LeaderHandler(sequencer *matchingengine.Sequencer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if sequencer.Standby() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{
+				"status": "standby",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "leader",
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package maintenance
+
+import (
+	"context"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/repository"
+)
+
+// Job names, used as the job label on maintenance metrics
+const (
+	JobOutboxCleanup      = "outbox_cleanup"
+	JobIdempotencyCleanup = "idempotency_cleanup"
+	JobMarketInboxCleanup = "market_inbox_cleanup"
+)
+
+// OutboxCleanupJob deletes outbox events published more than retention ago
+// Dead-lettered and unpublished events are kept
+func OutboxCleanupJob(outboxRepo repository.OutboxRepository, retention time.Duration) Job {
+	return Job{
+		Name: JobOutboxCleanup,
+		RunBatch: func(ctx context.Context, limit int) (int64, error) {
+			return outboxRepo.CleanupProcessedEvents(ctx, retention, limit)
+		},
+	}
+}
+
+// IdempotencyCleanupJob deletes idempotency keys past their expiry
+func IdempotencyCleanupJob(idempotencyRepo repository.IdempotencyRepository) Job {
+	return Job{
+		Name: JobIdempotencyCleanup,
+		RunBatch: func(ctx context.Context, limit int) (int64, error) {
+			return idempotencyRepo.CleanupExpired(ctx, limit)
+		},
+	}
+}
+
+// MarketInboxCleanupJob deletes market status inbox entries processed more than retention ago
+// Retention must exceed how long the upstream feed may redeliver a message
+func MarketInboxCleanupJob(marketRepo repository.MarketRepository, retention time.Duration) Job {
+	return Job{
+		Name: JobMarketInboxCleanup,
+		RunBatch: func(ctx context.Context, limit int) (int64, error) {
+			return marketRepo.CleanupProcessedMessages(ctx, retention, limit)
+		},
+	}
+}
@@ -0,0 +1,101 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// Leader decides whether this replica runs the maintenance jobs
+// The matching engine elects its leader with one as well, under its own lock
+type Leader interface {
+	// Acquire returns true if this replica is, or has just become, the leader
+	// Safe to call on every tick; a lost leadership is reported as false
+	Acquire(ctx context.Context) (bool, error)
+
+	// Release gives up leadership so another replica can take over
+	Release(ctx context.Context)
+}
+
+// PostgresLeader elects a leader with a session-level advisory lock
+// The lock is held by a dedicated pool connection, so it is released by
+// Postgres when the replica dies or its connection drops
+type PostgresLeader struct {
+	pool    *pgxpool.Pool
+	lockKey string
+	logger  zerolog.Logger
+
+	mu   sync.Mutex
+	conn *pgxpool.Conn // Holds the lock while leader
+}
+
+// NewPostgresLeader creates a leader elector for the named lock
+func NewPostgresLeader(pool *pgxpool.Pool, lockKey string, logger zerolog.Logger) *PostgresLeader {
+	return &PostgresLeader{
+		pool:    pool,
+		lockKey: lockKey,
+		logger:  logger.With().Str("component", "maintenance_leader").Logger(),
+	}
+}
+
+// Acquire returns true if this replica holds the advisory lock
+func (l *PostgresLeader) Acquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		// The lock lives as long as the session, check it is still there
+		if err := l.conn.Ping(ctx); err == nil {
+			return true, nil
+		}
+		l.logger.Warn().Str("lock_key", l.lockKey).Msg("leader connection lost, leadership released")
+		l.conn.Conn().Close(ctx)
+		l.conn.Release()
+		l.conn = nil
+	}
+
+	conn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("acquire leader connection: %w", err)
+	}
+
+	var acquired bool
+	query := `SELECT pg_try_advisory_lock(hashtextextended($1, 0))`
+	if err := conn.QueryRow(ctx, query, l.lockKey).Scan(&acquired); err != nil {
+		conn.Release()
+		return false, fmt.Errorf("try leader lock: %w", err)
+	}
+
+	if !acquired {
+		conn.Release()
+		return false, nil
+	}
+
+	l.conn = conn
+	l.logger.Info().Str("lock_key", l.lockKey).Msg("became leader")
+	return true, nil
+}
+
+// Release unlocks the advisory lock and returns the connection to the pool
+func (l *PostgresLeader) Release(ctx context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return
+	}
+
+	query := `SELECT pg_advisory_unlock(hashtextextended($1, 0))`
+	if _, err := l.conn.Exec(ctx, query, l.lockKey); err != nil {
+		// Closing the session drops the lock as well
+		l.logger.Warn().Err(err).Msg("failed to unlock leader lock, closing connection")
+		l.conn.Conn().Close(ctx)
+	}
+	l.conn.Release()
+	l.conn = nil
+
+	l.logger.Info().Str("lock_key", l.lockKey).Msg("leadership released")
+}
@@ -0,0 +1,151 @@
+package maintenance
+
+import (
+	"context"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/rs/zerolog"
+)
+
+// Job is a housekeeping task that removes rows in batches
+type Job struct {
+	Name string
+
+	// RunBatch removes up to limit rows and returns how many it removed
+	// Returning fewer than limit means nothing is left for this run
+	RunBatch func(ctx context.Context, limit int) (int64, error)
+}
+
+// Config tunes how often jobs run and how much work each run does
+type Config struct {
+	Interval         time.Duration // Time between runs, also how often leadership is checked
+	BatchSize        int           // Rows removed per statement, keeps row locks short
+	BatchPause       time.Duration // Pause between batches so cleanup yields to live traffic
+	MaxBatchesPerRun int           // Caps a run, the rest is picked up by the next one
+}
+
+// DefaultConfig returns the default maintenance settings
+func DefaultConfig() Config {
+	return Config{
+		Interval:         10 * time.Minute,
+		BatchSize:        1000,
+		BatchPause:       100 * time.Millisecond,
+		MaxBatchesPerRun: 100,
+	}
+}
+
+// Scheduler runs the maintenance jobs on the replica that holds leadership
+type Scheduler struct {
+	leader  Leader
+	jobs    []Job
+	cfg     Config
+	metrics *observability.Metrics
+	logger  zerolog.Logger
+}
+
+// NewScheduler creates a new maintenance scheduler
+func NewScheduler(leader Leader, jobs []Job, cfg Config, metrics *observability.Metrics, logger zerolog.Logger) *Scheduler {
+	return &Scheduler{
+		leader:  leader,
+		jobs:    jobs,
+		cfg:     cfg,
+		metrics: metrics,
+		logger:  logger.With().Str("component", "maintenance_scheduler").Logger(),
+	}
+}
+
+// Start runs the jobs every interval until the context is cancelled
+// Leadership is released on return so another replica takes over without waiting
+func (s *Scheduler) Start(ctx context.Context) {
+	s.logger.Info().
+		Dur("interval", s.cfg.Interval).
+		Int("batch_size", s.cfg.BatchSize).
+		Int("jobs", len(s.jobs)).
+		Msg("maintenance scheduler started")
+
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.leader.Release(releaseCtx)
+		s.metrics.MaintenanceLeader.Set(0)
+	}()
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			s.logger.Info().Msg("maintenance scheduler stopping")
+			return
+		}
+	}
+}
+
+// tick runs every job once if this replica is the leader
+func (s *Scheduler) tick(ctx context.Context) {
+	leader, err := s.leader.Acquire(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("failed to check maintenance leadership")
+		leader = false
+	}
+
+	if !leader {
+		s.metrics.MaintenanceLeader.Set(0)
+		return
+	}
+	s.metrics.MaintenanceLeader.Set(1)
+
+	for _, job := range s.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		s.runJob(ctx, job)
+	}
+}
+
+// runJob runs batches of a job until one comes back short or the run is capped
+func (s *Scheduler) runJob(ctx context.Context, job Job) {
+	start := time.Now()
+	var removed int64
+	batches := 0
+
+	defer func() {
+		s.metrics.MaintenanceRowsDeleted.WithLabelValues(job.Name).Add(float64(removed))
+		s.metrics.MaintenanceRunDuration.WithLabelValues(job.Name).Observe(time.Since(start).Seconds())
+	}()
+
+	for batches < s.cfg.MaxBatchesPerRun {
+		n, err := job.RunBatch(ctx, s.cfg.BatchSize)
+		batches++
+		removed += n
+		if err != nil {
+			s.metrics.MaintenanceErrors.WithLabelValues(job.Name).Inc()
+			s.logger.Error().Err(err).
+				Str("job", job.Name).
+				Int64("removed", removed).
+				Msg("maintenance job failed")
+			return
+		}
+		if n < int64(s.cfg.BatchSize) {
+			break
+		}
+
+		select {
+		case <-time.After(s.cfg.BatchPause):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	s.logger.Info().
+		Str("job", job.Name).
+		Int64("removed", removed).
+		Int("batches", batches).
+		Dur("duration", time.Since(start)).
+		Msg("maintenance job finished")
+}
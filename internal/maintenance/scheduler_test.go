@@ -0,0 +1,148 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLeader reports a scripted leadership state
+type fakeLeader struct {
+	leader   bool
+	err      error
+	releases int
+}
+
+func (l *fakeLeader) Acquire(context.Context) (bool, error) { return l.leader, l.err }
+func (l *fakeLeader) Release(context.Context)               { l.releases++ }
+
+// batchJob removes rows from a fixed backlog and records the limits it was called with
+type batchJob struct {
+	backlog int64
+	limits  []int
+	failOn  int // batch number that fails, 0 never
+}
+
+func (j *batchJob) job(name string) Job {
+	return Job{
+		Name: name,
+		RunBatch: func(_ context.Context, limit int) (int64, error) {
+			j.limits = append(j.limits, limit)
+			if len(j.limits) == j.failOn {
+				return 0, errors.New("lock timeout")
+			}
+			n := int64(limit)
+			if j.backlog < n {
+				n = j.backlog
+			}
+			j.backlog -= n
+			return n, nil
+		},
+	}
+}
+
+func testConfig() Config {
+	return Config{
+		Interval:         time.Hour,
+		BatchSize:        10,
+		BatchPause:       time.Millisecond,
+		MaxBatchesPerRun: 5,
+	}
+}
+
+func newTestScheduler(leader Leader, jobs ...Job) (*Scheduler, *observability.Metrics) {
+	metrics := observability.NewMetricsWithRegistry(prometheus.NewRegistry())
+	return NewScheduler(leader, jobs, testConfig(), metrics, zerolog.Nop()), metrics
+}
+
+func TestScheduler_RunsBatchesUntilShort(t *testing.T) {
+	outbox := &batchJob{backlog: 25}
+	keys := &batchJob{backlog: 0}
+	scheduler, metrics := newTestScheduler(&fakeLeader{leader: true}, outbox.job(JobOutboxCleanup), keys.job(JobIdempotencyCleanup))
+
+	scheduler.tick(context.Background())
+
+	assert.Equal(t, []int{10, 10, 10}, outbox.limits)
+	assert.Zero(t, outbox.backlog)
+	assert.Equal(t, []int{10}, keys.limits)
+
+	assert.Equal(t, 25.0, testutil.ToFloat64(metrics.MaintenanceRowsDeleted.WithLabelValues(JobOutboxCleanup)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.MaintenanceRowsDeleted.WithLabelValues(JobIdempotencyCleanup)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.MaintenanceLeader))
+}
+
+func TestScheduler_CapsBatchesPerRun(t *testing.T) {
+	outbox := &batchJob{backlog: 1000}
+	scheduler, _ := newTestScheduler(&fakeLeader{leader: true}, outbox.job(JobOutboxCleanup))
+
+	scheduler.tick(context.Background())
+	assert.Len(t, outbox.limits, 5)
+	assert.Equal(t, int64(950), outbox.backlog)
+
+	// The next run carries on where the capped one stopped
+	scheduler.tick(context.Background())
+	assert.Equal(t, int64(900), outbox.backlog)
+}
+
+func TestScheduler_FollowerRunsNothing(t *testing.T) {
+	for _, leader := range []*fakeLeader{{}, {err: errors.New("connection refused")}} {
+		outbox := &batchJob{backlog: 25}
+		scheduler, metrics := newTestScheduler(leader, outbox.job(JobOutboxCleanup))
+
+		scheduler.tick(context.Background())
+
+		assert.Empty(t, outbox.limits)
+		assert.Equal(t, 0.0, testutil.ToFloat64(metrics.MaintenanceLeader))
+	}
+}
+
+func TestScheduler_FailedJobDoesNotStopOthers(t *testing.T) {
+	outbox := &batchJob{backlog: 25, failOn: 2}
+	keys := &batchJob{backlog: 3}
+	scheduler, metrics := newTestScheduler(&fakeLeader{leader: true}, outbox.job(JobOutboxCleanup), keys.job(JobIdempotencyCleanup))
+
+	scheduler.tick(context.Background())
+
+	assert.Len(t, outbox.limits, 2)
+	assert.Equal(t, 10.0, testutil.ToFloat64(metrics.MaintenanceRowsDeleted.WithLabelValues(JobOutboxCleanup)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.MaintenanceErrors.WithLabelValues(JobOutboxCleanup)))
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.MaintenanceRowsDeleted.WithLabelValues(JobIdempotencyCleanup)))
+}
+
+func TestScheduler_ReleasesLeadershipOnStop(t *testing.T) {
+	leader := &fakeLeader{leader: true}
+	ran := make(chan struct{}, 1)
+	job := Job{Name: JobOutboxCleanup, RunBatch: func(context.Context, int) (int64, error) {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		return 0, nil
+	}}
+	scheduler, _ := newTestScheduler(leader, job)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Start(ctx)
+		close(done)
+	}()
+
+	// The first run happens immediately on start
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run on start")
+	}
+	cancel()
+	<-done
+
+	assert.Equal(t, 1, leader.releases)
+}
@@ -0,0 +1,48 @@
+package messaging
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryBackoff computes exponential retry delays with jitter
+// Delay for attempt n (1-based) is Initial * Multiplier^(n-1), capped at Max,
+// then spread by up to ±Jitter of itself so retries from a burst don't align
+type RetryBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64 // Fraction of the delay, 0 disables jitter
+}
+
+// DefaultRetryBackoff returns the backoff used for outbox publish retries
+func DefaultRetryBackoff() RetryBackoff {
+	return RetryBackoff{
+		Initial:    time.Second,
+		Max:        5 * time.Minute,
+		Multiplier: 2,
+		Jitter:     0.2,
+	}
+}
+
+// Delay returns how long to wait before the next attempt after attempt failures
+func (b RetryBackoff) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt-1))
+	if delay > float64(b.Max) || math.IsInf(delay, 0) {
+		delay = float64(b.Max)
+	}
+
+	if b.Jitter > 0 {
+		delay += delay * b.Jitter * (2*rand.Float64() - 1)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+
+	return time.Duration(delay)
+}
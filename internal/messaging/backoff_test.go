@@ -0,0 +1,32 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBackoff_Delay(t *testing.T) {
+	backoff := RetryBackoff{Initial: time.Second, Max: time.Minute, Multiplier: 2}
+
+	assert.Equal(t, time.Second, backoff.Delay(1))
+	assert.Equal(t, 2*time.Second, backoff.Delay(2))
+	assert.Equal(t, 8*time.Second, backoff.Delay(4))
+	assert.Equal(t, time.Minute, backoff.Delay(10))
+	assert.Equal(t, time.Minute, backoff.Delay(10000))
+	assert.Equal(t, time.Second, backoff.Delay(0))
+}
+
+func TestRetryBackoff_JitterStaysInBounds(t *testing.T) {
+	backoff := RetryBackoff{Initial: 10 * time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.2}
+
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 1000; i++ {
+		delay := backoff.Delay(1)
+		assert.GreaterOrEqual(t, delay, 8*time.Second)
+		assert.LessOrEqual(t, delay, 12*time.Second)
+		seen[delay] = true
+	}
+	assert.Greater(t, len(seen), 1, "jitter should vary the delay")
+}
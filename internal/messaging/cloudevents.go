@@ -0,0 +1,112 @@
+package messaging
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Message formats of published events
+const (
+	// MessageFormatPlain carries event metadata in service specific headers
+	MessageFormatPlain = "plain"
+
+	// MessageFormatCloudEvents emits CloudEvents 1.0 in Kafka binary content mode
+	// Attributes travel as ce_ headers and the value is the event data as is
+	MessageFormatCloudEvents = "cloudevents"
+)
+
+// CloudEventsSpecVersion is the CloudEvents version emitted in ce_specversion
+const CloudEventsSpecVersion = "1.0"
+
+// DefaultCloudEventsSource identifies this service in ce_source
+const DefaultCloudEventsSource = "/order-book-service"
+
+// plainHeaders returns the service specific headers of an event
+func plainHeaders(event *models.OutboxEvent, contentType, schema string) []Header {
+	headers := []Header{
+		header("event_type", event.EventType),
+		header("schema_version", strconv.Itoa(event.SchemaVersion)),
+		header("content_type", contentType),
+		header("aggregate_type", event.AggregateType),
+		header("aggregate_sequence", strconv.FormatInt(event.AggregateSequence, 10)),
+	}
+
+	if schema != "" {
+		headers = append(headers, header("schema", schema))
+	}
+	if event.SagaID != nil {
+		headers = append(headers, header("saga_id", event.SagaID.String()))
+	}
+	if event.MarketSequence != nil {
+		headers = append(headers, header("market_sequence", strconv.FormatInt(*event.MarketSequence, 10)))
+	}
+
+	return headers
+}
+
+// cloudEventHeaders returns the binary mode CloudEvents headers of an event
+// Service metadata is carried as extension attributes, which must be lower
+// case alphanumeric
+func cloudEventHeaders(event *models.OutboxEvent, source, contentType, schema string) []Header {
+	headers := []Header{
+		header("ce_specversion", CloudEventsSpecVersion),
+		header("ce_id", event.ID.String()),
+		header("ce_source", source),
+		header("ce_type", event.EventType),
+		header("ce_subject", event.AggregateID.String()),
+		header("ce_time", event.CreatedAt.UTC().Format(time.RFC3339Nano)),
+		header("content-type", contentType),
+		header("ce_schemaversion", strconv.Itoa(event.SchemaVersion)),
+		header("ce_aggregatetype", event.AggregateType),
+		header("ce_aggregatesequence", strconv.FormatInt(event.AggregateSequence, 10)),
+	}
+
+	if schema != "" {
+		headers = append(headers, header("ce_protoschema", schema))
+	}
+	if event.SagaID != nil {
+		headers = append(headers, header("ce_sagaid", event.SagaID.String()))
+	}
+	if event.MarketSequence != nil {
+		headers = append(headers, header("ce_marketsequence", strconv.FormatInt(*event.MarketSequence, 10)))
+	}
+
+	return headers
+}
+
+// traceHeaders returns the W3C trace context headers of ctx
+// In binary mode CloudEvents the distributed tracing extension maps to the
+// same traceparent and tracestate headers
+func traceHeaders(ctx context.Context) []Header {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+
+	var headers []Header
+	for _, key := range []string{"traceparent", "tracestate"} {
+		if value := carrier.Get(key); value != "" {
+			headers = append(headers, header(key, value))
+		}
+	}
+	return headers
+}
+
+// eventTraceContext returns ctx with the trace context stored on the event as remote parent
+func eventTraceContext(ctx context.Context, event *models.OutboxEvent) context.Context {
+	if event.TraceParent == nil {
+		return ctx
+	}
+
+	carrier := propagation.MapCarrier{"traceparent": *event.TraceParent}
+	if event.TraceState != nil {
+		carrier["tracestate"] = *event.TraceState
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
+
+func header(key, value string) Header {
+	return Header{Key: key, Value: value}
+}
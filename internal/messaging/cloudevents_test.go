@@ -0,0 +1,87 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/events"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxPublisher_CloudEventsBinaryMode(t *testing.T) {
+	sagaID := uuid.New()
+	event, err := events.NewOutboxEvent(models.AggregateTypeOrder, uuid.New(), &events.OrderCancelledV1{
+		OrderID:     uuid.NewString(),
+		UserID:      uuid.NewString(),
+		CancelledAt: time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC),
+	}, &sagaID)
+	require.NoError(t, err)
+	event.ID = uuid.New()
+	event.AggregateSequence = 3
+	marketSequence := int64(42)
+	event.MarketSequence = &marketSequence
+	event.CreatedAt = time.Date(2025, 3, 1, 12, 30, 0, 500, time.FixedZone("CET", 3600))
+
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	traceState := "vendor=value"
+	event.TraceParent = &traceParent
+	event.TraceState = &traceState
+
+	cfg := DefaultPublisherConfig()
+	cfg.MessageFormat = MessageFormatCloudEvents
+	cfg.CloudEventsSource = "/test/order-book"
+	producer := newRecordingProducer()
+	publisher := NewOutboxPublisher(nil, NewKafkaSink(producer, zerolog.Nop()), cfg, nil, testMetrics(), zerolog.Nop())
+
+	require.NoError(t, publisher.publishEvent(context.Background(), event))
+	require.Len(t, producer.messages, 1)
+
+	h := headers(producer.messages[0])
+	assert.Equal(t, map[string]string{
+		"ce_specversion":       "1.0",
+		"ce_id":                event.ID.String(),
+		"ce_source":            "/test/order-book",
+		"ce_type":              models.EventTypeOrderCancelled,
+		"ce_subject":           event.AggregateID.String(),
+		"ce_time":              "2025-03-01T11:30:00.0000005Z",
+		"content-type":         events.ContentTypeProtobuf,
+		"ce_schemaversion":     "1",
+		"ce_aggregatetype":     models.AggregateTypeOrder,
+		"ce_aggregatesequence": "3",
+		"ce_protoschema":       "orderbook.events.OrderCancelledV1",
+		"ce_sagaid":            sagaID.String(),
+		"ce_marketsequence":    "42",
+		"ce_delivery":          DeliveryAtLeastOnce,
+		"traceparent":          traceParent,
+		"tracestate":           traceState,
+	}, h)
+}
+
+func TestOutboxPublisher_PlainFormatWithoutTraceContext(t *testing.T) {
+	event, err := events.NewOutboxEvent(models.AggregateTypeOrder, uuid.New(), &events.OrderCancelledV1{
+		OrderID: uuid.NewString(),
+	}, nil)
+	require.NoError(t, err)
+	event.ID = uuid.New()
+
+	producer := newRecordingProducer()
+	publisher := NewOutboxPublisher(nil, NewKafkaSink(producer, zerolog.Nop()), DefaultPublisherConfig(), nil, testMetrics(), zerolog.Nop())
+
+	require.NoError(t, publisher.publishEvent(context.Background(), event))
+	require.Len(t, producer.messages, 1)
+
+	h := headers(producer.messages[0])
+	assert.Equal(t, models.EventTypeOrderCancelled, h["event_type"])
+	assert.Equal(t, event.ID.String(), h[HeaderDedupKey])
+	assert.Equal(t, DeliveryAtLeastOnce, h[HeaderDelivery])
+	for key := range h {
+		assert.NotContains(t, key, "ce_")
+	}
+	assert.NotContains(t, h, "traceparent")
+	assert.NotContains(t, h, "market_sequence")
+}
@@ -0,0 +1,347 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/service"
+	"github.com/rs/zerolog"
+)
+
+// HeaderReplyTo overrides the reply topic for a single command
+const HeaderReplyTo = "reply_to"
+
+// CommandConsumerConfig configures the order command consumer
+type CommandConsumerConfig struct {
+	Topic        string
+	ReplyTopic   string       // Default topic for command results
+	MaxAttempts  int          // Attempts for errors that may succeed on retry, then the command fails
+	RetryBackoff RetryBackoff // Delay between attempts and between failed result publishes
+}
+
+// DefaultCommandConsumerConfig returns the default command consumer settings
+func DefaultCommandConsumerConfig() CommandConsumerConfig {
+	return CommandConsumerConfig{
+		Topic:       "order.commands",
+		ReplyTopic:  "order.command-results",
+		MaxAttempts: 5,
+		RetryBackoff: RetryBackoff{
+			Initial:    100 * time.Millisecond,
+			Max:        10 * time.Second,
+			Multiplier: 2,
+			Jitter:     0.2,
+		},
+	}
+}
+
+// CommandConsumer reads PlaceOrder and CancelOrder commands from Kafka as part
+// of a consumer group and publishes a result for each to the reply topic
+// Offsets are committed only after the result is published, so a crash
+// re-runs the command; the idempotency key makes the rerun return the
+// original order
+type CommandConsumer struct {
+	group        sarama.ConsumerGroup
+	orderService service.OrderService
+	sink         EventSink
+	topic        string
+	replyTopic   string
+	maxAttempts  int
+	retryBackoff RetryBackoff
+	metrics      *observability.Metrics
+	logger       zerolog.Logger
+}
+
+// NewCommandConsumerGroup joins the command consumer group
+// Reads only committed messages, so commands from aborted producer
+// transactions are never executed
+func NewCommandConsumerGroup(brokers []string, groupID string) (sarama.ConsumerGroup, error) {
+	group, err := newConsumerGroup(brokers, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("create command consumer group: %w", err)
+	}
+	return group, nil
+}
+
+// newConsumerGroup joins a consumer group that starts from the oldest
+// unconsumed message and reads only committed messages
+func newConsumerGroup(brokers []string, groupID string) (sarama.ConsumerGroup, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_8_0_0
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
+	config.Consumer.Return.Errors = true
+
+	return sarama.NewConsumerGroup(brokers, groupID, config)
+}
+
+// NewCommandConsumer creates a new command consumer
+func NewCommandConsumer(
+	group sarama.ConsumerGroup,
+	orderService service.OrderService,
+	sink EventSink,
+	cfg CommandConsumerConfig,
+	metrics *observability.Metrics,
+	logger zerolog.Logger,
+) *CommandConsumer {
+	return &CommandConsumer{
+		group:        group,
+		orderService: orderService,
+		sink:         sink,
+		topic:        cfg.Topic,
+		replyTopic:   cfg.ReplyTopic,
+		maxAttempts:  cfg.MaxAttempts,
+		retryBackoff: cfg.RetryBackoff,
+		metrics:      metrics,
+		logger:       logger.With().Str("component", "command_consumer").Logger(),
+	}
+}
+
+// Start consumes commands until the context is cancelled
+// Consume returns on every rebalance, so it is called in a loop
+func (c *CommandConsumer) Start(ctx context.Context) {
+	c.logger.Info().Str("topic", c.topic).Str("reply_topic", c.replyTopic).Msg("command consumer started")
+
+	go func() {
+		for err := range c.group.Errors() {
+			c.logger.Error().Err(err).Msg("command consumer group error")
+		}
+	}()
+
+	for {
+		if err := c.group.Consume(ctx, []string{c.topic}, c); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			c.logger.Error().Err(err).Msg("command consumer session failed")
+
+			select {
+			case <-time.After(c.retryBackoff.Delay(1)):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			c.logger.Info().Msg("command consumer stopping")
+			return
+		}
+	}
+}
+
+// Setup is called at the start of a consumer group session
+func (c *CommandConsumer) Setup(session sarama.ConsumerGroupSession) error {
+	c.logger.Info().Interface("claims", session.Claims()).Msg("command partitions assigned")
+	return nil
+}
+
+// Cleanup is called at the end of a consumer group session
+func (c *CommandConsumer) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim processes the commands of one partition in order
+func (c *CommandConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if err := c.handleMessage(session.Context(), msg); err != nil {
+				// Session is ending, the uncommitted command is redelivered
+				return nil
+			}
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// handleMessage executes a command and publishes its result
+// Returns an error only if the context ended before the result was published
+func (c *CommandConsumer) handleMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	start := time.Now()
+
+	var cmd Command
+	var result *CommandResult
+	if err := json.Unmarshal(msg.Value, &cmd); err != nil {
+		c.logger.Warn().Err(err).
+			Int32("partition", msg.Partition).
+			Int64("offset", msg.Offset).
+			Msg("malformed command")
+		result = failedResult(&cmd, CommandErrInvalidArgument, fmt.Errorf("malformed command: %w", err))
+	} else {
+		result = c.execute(ctx, &cmd)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	replyTopic := c.replyTopic
+	for _, h := range msg.Headers {
+		if string(h.Key) == HeaderReplyTo && len(h.Value) > 0 {
+			replyTopic = string(h.Value)
+		}
+	}
+
+	if err := c.publishResult(ctx, replyTopic, result); err != nil {
+		return err
+	}
+
+	c.metrics.CommandsProcessed.WithLabelValues(result.Type, result.Status).Inc()
+	c.metrics.CommandProcessingDuration.WithLabelValues(result.Type).Observe(time.Since(start).Seconds())
+
+	c.logger.Info().
+		Str("command_id", result.CommandID).
+		Str("type", result.Type).
+		Str("status", result.Status).
+		Str("error_code", result.ErrorCode).
+		Msg("command processed")
+
+	return nil
+}
+
+// execute dispatches a command, retrying errors that may succeed on retry
+func (c *CommandConsumer) execute(ctx context.Context, cmd *Command) *CommandResult {
+	var err error
+	for attempt := 1; ; attempt++ {
+		var result *CommandResult
+		result, err = c.dispatch(ctx, cmd)
+		if err == nil {
+			return result
+		}
+
+		code, permanent := commandErrorCode(err)
+		if permanent || attempt >= c.maxAttempts {
+			return failedResult(cmd, code, err)
+		}
+
+		c.logger.Warn().Err(err).
+			Str("command_id", cmd.CommandID).
+			Int("attempt", attempt).
+			Msg("command failed, retrying")
+
+		select {
+		case <-time.After(c.retryBackoff.Delay(attempt)):
+		case <-ctx.Done():
+			return failedResult(cmd, CommandErrInternal, ctx.Err())
+		}
+	}
+}
+
+// dispatch runs a command against the order service
+func (c *CommandConsumer) dispatch(ctx context.Context, cmd *Command) (*CommandResult, error) {
+	switch {
+	case cmd.Type == CommandPlaceOrder && cmd.PlaceOrder != nil:
+		p := cmd.PlaceOrder
+		order, err := c.orderService.PlaceOrder(ctx, &service.PlaceOrderRequest{
+			UserID:         p.UserID,
+			EventID:        p.EventID,
+			BetType:        p.BetType,
+			Selection:      p.Selection,
+			Amount:         p.Amount,
+			Odds:           p.Odds,
+			ReservationID:  p.ReservationID,
+			SagaID:         p.SagaID,
+			IdempotencyKey: cmd.IdempotencyKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return succeededResult(cmd, order), nil
+
+	case cmd.Type == CommandCancelOrder && cmd.CancelOrder != nil:
+		cancelled, err := c.orderService.CancelOrder(ctx, &service.CancelOrderRequest{
+			OrderID:        cmd.CancelOrder.OrderID,
+			SagaID:         cmd.CancelOrder.SagaID,
+			IdempotencyKey: cmd.IdempotencyKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result := succeededResult(cmd, nil)
+		result.OrderID = &cancelled.OrderID
+		result.OrderStatus = string(cancelled.Status)
+		return result, nil
+
+	default:
+		return failedResult(cmd, CommandErrInvalidArgument, fmt.Errorf("unsupported command type %q or missing body", cmd.Type)), nil
+	}
+}
+
+// publishResult publishes a command result, retrying until the context ends
+// Blocking the partition beats committing a command nobody hears back about
+func (c *CommandConsumer) publishResult(ctx context.Context, topic string, result *CommandResult) error {
+	value, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshal command result: %w", err)
+	}
+
+	key := result.IdempotencyKey
+	if key == "" {
+		key = result.CommandID
+	}
+	msg := &Message{
+		ID:    result.CommandID,
+		Topic: topic,
+		Key:   key,
+		Value: value,
+		Headers: []Header{
+			header("command_id", result.CommandID),
+			header("command_type", result.Type),
+			header("status", result.Status),
+			header("content_type", "application/json"),
+		},
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.sink.Send(ctx, msg)
+		if err == nil {
+			return nil
+		}
+
+		c.logger.Error().Err(err).
+			Str("command_id", result.CommandID).
+			Str("topic", topic).
+			Int("attempt", attempt).
+			Msg("failed to publish command result")
+
+		select {
+		case <-time.After(c.retryBackoff.Delay(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func succeededResult(cmd *Command, order *models.Order) *CommandResult {
+	result := &CommandResult{
+		CommandID:      cmd.CommandID,
+		Type:           cmd.Type,
+		IdempotencyKey: cmd.IdempotencyKey,
+		Status:         CommandSucceeded,
+		ProcessedAt:    time.Now().UTC(),
+	}
+	if order != nil {
+		result.OrderID = &order.ID
+		result.OrderStatus = string(order.Status)
+	}
+	return result
+}
+
+func failedResult(cmd *Command, code string, err error) *CommandResult {
+	return &CommandResult{
+		CommandID:      cmd.CommandID,
+		Type:           cmd.Type,
+		IdempotencyKey: cmd.IdempotencyKey,
+		Status:         CommandFailed,
+		ErrorCode:      code,
+		Error:          err.Error(),
+		ProcessedAt:    time.Now().UTC(),
+	}
+}
@@ -0,0 +1,235 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/service"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubOrderService implements the order service calls the consumer makes
+type stubOrderService struct {
+	service.OrderService
+	placeOrder  func(*service.PlaceOrderRequest) (*models.Order, error)
+	cancelOrder func(*service.CancelOrderRequest) (*service.CancelOrderResult, error)
+}
+
+func (s *stubOrderService) PlaceOrder(_ context.Context, req *service.PlaceOrderRequest) (*models.Order, error) {
+	return s.placeOrder(req)
+}
+
+func (s *stubOrderService) CancelOrder(_ context.Context, req *service.CancelOrderRequest) (*service.CancelOrderResult, error) {
+	return s.cancelOrder(req)
+}
+
+// recordingSink is an EventSink that keeps sent messages
+type recordingSink struct {
+	mu       sync.Mutex
+	messages []*Message
+	fail     int // number of sends to fail before succeeding
+}
+
+func (s *recordingSink) Send(_ context.Context, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("sink unavailable")
+	}
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+func (s *recordingSink) Close() error { return nil }
+
+func newTestCommandConsumer(orderService service.OrderService, sink EventSink) *CommandConsumer {
+	cfg := DefaultCommandConsumerConfig()
+	cfg.RetryBackoff = RetryBackoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1}
+	return NewCommandConsumer(nil, orderService, sink, cfg, testMetrics(), zerolog.Nop())
+}
+
+func commandMessage(t *testing.T, cmd Command, headers ...*sarama.RecordHeader) *sarama.ConsumerMessage {
+	value, err := json.Marshal(cmd)
+	require.NoError(t, err)
+	return &sarama.ConsumerMessage{Topic: "order.commands", Value: value, Headers: headers}
+}
+
+func singleResult(t *testing.T, sink *recordingSink) (*Message, CommandResult) {
+	t.Helper()
+	require.Len(t, sink.messages, 1)
+
+	var result CommandResult
+	require.NoError(t, json.Unmarshal(sink.messages[0].Value, &result))
+	return sink.messages[0], result
+}
+
+func placeOrderCommand() Command {
+	return Command{
+		CommandID:      uuid.NewString(),
+		Type:           CommandPlaceOrder,
+		IdempotencyKey: "idem-" + uuid.NewString(),
+		PlaceOrder: &PlaceOrderCommand{
+			UserID:    uuid.New(),
+			EventID:   "event-1",
+			BetType:   "back",
+			Selection: "home",
+			Amount:    decimal.RequireFromString("10"),
+			Odds:      decimal.RequireFromString("2.5"),
+		},
+	}
+}
+
+func TestCommandConsumer_PlaceOrder(t *testing.T) {
+	cmd := placeOrderCommand()
+	order := &models.Order{ID: uuid.New(), Status: models.OrderStatusPending}
+
+	var got *service.PlaceOrderRequest
+	orders := &stubOrderService{placeOrder: func(req *service.PlaceOrderRequest) (*models.Order, error) {
+		got = req
+		return order, nil
+	}}
+	sink := &recordingSink{}
+	consumer := newTestCommandConsumer(orders, sink)
+
+	require.NoError(t, consumer.handleMessage(context.Background(), commandMessage(t, cmd)))
+
+	require.NotNil(t, got)
+	assert.Equal(t, cmd.IdempotencyKey, got.IdempotencyKey)
+	assert.Equal(t, cmd.PlaceOrder.UserID, got.UserID)
+	assert.True(t, cmd.PlaceOrder.Amount.Equal(got.Amount))
+
+	msg, result := singleResult(t, sink)
+	assert.Equal(t, "order.command-results", msg.Topic)
+	assert.Equal(t, cmd.IdempotencyKey, msg.Key)
+	assert.Equal(t, CommandSucceeded, result.Status)
+	assert.Equal(t, cmd.CommandID, result.CommandID)
+	assert.Equal(t, &order.ID, result.OrderID)
+	assert.Equal(t, string(models.OrderStatusPending), result.OrderStatus)
+}
+
+func TestCommandConsumer_CancelOrderToReplyTo(t *testing.T) {
+	orderID := uuid.New()
+	cmd := Command{
+		CommandID:      uuid.NewString(),
+		Type:           CommandCancelOrder,
+		IdempotencyKey: "idem-cancel",
+		CancelOrder:    &CancelOrderCommand{OrderID: orderID},
+	}
+	orders := &stubOrderService{cancelOrder: func(req *service.CancelOrderRequest) (*service.CancelOrderResult, error) {
+		assert.Equal(t, orderID, req.OrderID)
+		return &service.CancelOrderResult{OrderID: orderID, Status: models.OrderStatusCancelled, CancelledAt: time.Now()}, nil
+	}}
+	sink := &recordingSink{}
+	consumer := newTestCommandConsumer(orders, sink)
+
+	replyTo := &sarama.RecordHeader{Key: []byte(HeaderReplyTo), Value: []byte("validator.replies")}
+	require.NoError(t, consumer.handleMessage(context.Background(), commandMessage(t, cmd, replyTo)))
+
+	msg, result := singleResult(t, sink)
+	assert.Equal(t, "validator.replies", msg.Topic)
+	assert.Equal(t, CommandSucceeded, result.Status)
+	assert.Equal(t, &orderID, result.OrderID)
+	assert.Equal(t, string(models.OrderStatusCancelled), result.OrderStatus)
+}
+
+func TestCommandConsumer_Failures(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCode  string
+		wantCalls int
+	}{
+		{"not found", fmt.Errorf("get order: %w", models.ErrOrderNotFound), CommandErrNotFound, 1},
+		{"idempotency mismatch", models.ErrIdempotencyMismatch, CommandErrAlreadyExists, 1},
+		{"invalid status", models.ErrInvalidOrderStatus, CommandErrFailedPrecondition, 1},
+		{"market suspended", models.ErrMarketSuspended, CommandErrFailedPrecondition, 1},
+		{"transient until attempts run out", errors.New("connection reset"), CommandErrInternal, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			orders := &stubOrderService{placeOrder: func(*service.PlaceOrderRequest) (*models.Order, error) {
+				calls++
+				return nil, tt.err
+			}}
+			sink := &recordingSink{}
+			consumer := newTestCommandConsumer(orders, sink)
+
+			require.NoError(t, consumer.handleMessage(context.Background(), commandMessage(t, placeOrderCommand())))
+
+			assert.Equal(t, tt.wantCalls, calls)
+			_, result := singleResult(t, sink)
+			assert.Equal(t, CommandFailed, result.Status)
+			assert.Equal(t, tt.wantCode, result.ErrorCode)
+			assert.Nil(t, result.OrderID)
+		})
+	}
+}
+
+func TestCommandConsumer_RetriesTransientErrors(t *testing.T) {
+	calls := 0
+	orders := &stubOrderService{placeOrder: func(*service.PlaceOrderRequest) (*models.Order, error) {
+		calls++
+		if calls < 3 {
+			return nil, errors.New("connection reset")
+		}
+		return &models.Order{ID: uuid.New(), Status: models.OrderStatusMatched}, nil
+	}}
+	sink := &recordingSink{fail: 2} // result publishing is retried too
+	consumer := newTestCommandConsumer(orders, sink)
+
+	require.NoError(t, consumer.handleMessage(context.Background(), commandMessage(t, placeOrderCommand())))
+
+	assert.Equal(t, 3, calls)
+	_, result := singleResult(t, sink)
+	assert.Equal(t, CommandSucceeded, result.Status)
+}
+
+func TestCommandConsumer_MalformedCommands(t *testing.T) {
+	orders := &stubOrderService{}
+	sink := &recordingSink{}
+	consumer := newTestCommandConsumer(orders, sink)
+
+	malformed := &sarama.ConsumerMessage{Value: []byte(`{"command_id":`)}
+	require.NoError(t, consumer.handleMessage(context.Background(), malformed))
+
+	unknown := commandMessage(t, Command{CommandID: "c-2", Type: "SettleOrder"})
+	require.NoError(t, consumer.handleMessage(context.Background(), unknown))
+
+	require.Len(t, sink.messages, 2)
+	for _, msg := range sink.messages {
+		var result CommandResult
+		require.NoError(t, json.Unmarshal(msg.Value, &result))
+		assert.Equal(t, CommandFailed, result.Status)
+		assert.Equal(t, CommandErrInvalidArgument, result.ErrorCode)
+	}
+}
+
+func TestCommandConsumer_CancelledBeforeResultPublished(t *testing.T) {
+	orders := &stubOrderService{placeOrder: func(*service.PlaceOrderRequest) (*models.Order, error) {
+		return &models.Order{ID: uuid.New()}, nil
+	}}
+	sink := &recordingSink{fail: 1 << 30}
+	consumer := newTestCommandConsumer(orders, sink)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// The command must stay uncommitted so it is redelivered
+	assert.Error(t, consumer.handleMessage(ctx, commandMessage(t, placeOrderCommand())))
+	assert.Empty(t, sink.messages)
+}
@@ -0,0 +1,98 @@
+package messaging
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Command types accepted on the command topic
+const (
+	CommandPlaceOrder  = "PlaceOrder"
+	CommandCancelOrder = "CancelOrder"
+)
+
+// Command result statuses
+const (
+	CommandSucceeded = "succeeded"
+	CommandFailed    = "failed"
+)
+
+// Command result error codes, mirroring the gRPC status codes of the same failure
+const (
+	CommandErrInvalidArgument    = "invalid_argument"
+	CommandErrNotFound           = "not_found"
+	CommandErrFailedPrecondition = "failed_precondition"
+	CommandErrAlreadyExists      = "already_exists"
+	CommandErrInternal           = "internal"
+)
+
+// Command is a JSON message on the command topic
+// Exactly one of PlaceOrder and CancelOrder is set, matching Type
+type Command struct {
+	CommandID      string              `json:"command_id"`
+	Type           string              `json:"type"`
+	IdempotencyKey string              `json:"idempotency_key"`
+	PlaceOrder     *PlaceOrderCommand  `json:"place_order,omitempty"`
+	CancelOrder    *CancelOrderCommand `json:"cancel_order,omitempty"`
+}
+
+// PlaceOrderCommand carries the fields of service.PlaceOrderRequest
+// Amounts and odds are decimal strings
+type PlaceOrderCommand struct {
+	UserID        uuid.UUID       `json:"user_id"`
+	EventID       string          `json:"event_id"`
+	BetType       string          `json:"bet_type"`
+	Selection     string          `json:"selection"`
+	Amount        decimal.Decimal `json:"amount"`
+	Odds          decimal.Decimal `json:"odds"`
+	ReservationID *uuid.UUID      `json:"reservation_id,omitempty"`
+	SagaID        *uuid.UUID      `json:"saga_id,omitempty"`
+}
+
+// CancelOrderCommand carries the fields of service.CancelOrderRequest
+type CancelOrderCommand struct {
+	OrderID uuid.UUID  `json:"order_id"`
+	SagaID  *uuid.UUID `json:"saga_id,omitempty"`
+}
+
+// CommandResult is published to the reply topic for every command
+type CommandResult struct {
+	CommandID      string     `json:"command_id"`
+	Type           string     `json:"type"`
+	IdempotencyKey string     `json:"idempotency_key"`
+	Status         string     `json:"status"` // succeeded, failed
+	OrderID        *uuid.UUID `json:"order_id,omitempty"`
+	OrderStatus    string     `json:"order_status,omitempty"`
+	ErrorCode      string     `json:"error_code,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	ProcessedAt    time.Time  `json:"processed_at"`
+}
+
+// commandErrorCode maps a service error to a result error code
+// Returns false for errors that may succeed on retry
+func commandErrorCode(err error) (string, bool) {
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		return CommandErrInvalidArgument, true
+	case errors.Is(err, models.ErrOrderNotFound):
+		return CommandErrNotFound, true
+	case errors.Is(err, models.ErrIdempotencyMismatch):
+		return CommandErrAlreadyExists, true
+	case errors.Is(err, models.ErrInvalidOrderStatus),
+		errors.Is(err, models.ErrSagaCompensated),
+		errors.Is(err, models.ErrReservationRequired),
+		errors.Is(err, models.ErrReservationMismatch),
+		errors.Is(err, models.ErrInsufficientReservation),
+		errors.Is(err, models.ErrMarketSuspended),
+		errors.Is(err, models.ErrMarketClosed):
+		return CommandErrFailedPrecondition, true
+	default:
+		return CommandErrInternal, false
+	}
+}
@@ -0,0 +1,83 @@
+package messaging
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileSink writes messages as JSON lines, for local development and tests
+type FileSink struct {
+	mu  sync.Mutex
+	out io.WriteCloser
+	enc *json.Encoder
+}
+
+// fileRecord is the JSON line written for a message
+// JSON values are embedded as is, anything else (protobuf) is base64 encoded
+type fileRecord struct {
+	ID          string            `json:"id"`
+	Topic       string            `json:"topic"`
+	Key         string            `json:"key"`
+	Headers     map[string]string `json:"headers"`
+	Value       json.RawMessage   `json:"value,omitempty"`
+	ValueBase64 string            `json:"value_base64,omitempty"`
+}
+
+// NewFileSink creates a sink appending to path, "stdout" or "-" for standard output
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" || path == "-" || path == "stdout" {
+		return NewWriterSink(nopCloser{os.Stdout}), nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open event sink file: %w", err)
+	}
+	return NewWriterSink(f), nil
+}
+
+// NewWriterSink creates a sink writing JSON lines to w
+func NewWriterSink(w io.WriteCloser) *FileSink {
+	return &FileSink{out: w, enc: json.NewEncoder(w)}
+}
+
+// Name returns the messaging system name
+func (s *FileSink) Name() string { return SinkFile }
+
+// Close closes the underlying file
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.out.Close()
+}
+
+// Send writes a message as one JSON line
+func (s *FileSink) Send(_ context.Context, msg *Message) error {
+	record := fileRecord{
+		ID:      msg.ID,
+		Topic:   msg.Topic,
+		Key:     msg.Key,
+		Headers: make(map[string]string, len(msg.Headers)),
+	}
+	for _, h := range msg.Headers {
+		record.Headers[h.Key] = h.Value
+	}
+	if json.Valid(msg.Value) {
+		record.Value = msg.Value
+	} else {
+		record.ValueBase64 = base64.StdEncoding.EncodeToString(msg.Value)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.enc.Encode(record); err != nil {
+		return fmt.Errorf("write event to file sink: %w", err)
+	}
+	return nil
+}
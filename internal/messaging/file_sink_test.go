@@ -0,0 +1,80 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cypherlabdev/order-book-service/internal/events"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bufferCloser struct{ bytes.Buffer }
+
+func (*bufferCloser) Close() error { return nil }
+
+func TestFileSink_WritesPublishedEvents(t *testing.T) {
+	placed := &events.OrderPlacedV1{OrderID: uuid.NewString(), UserID: uuid.NewString(), Amount: "10.00"}
+
+	tests := []struct {
+		name     string
+		encoding string
+	}{
+		{"json", events.EncodingJSON},
+		{"protobuf", events.EncodingProtobuf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := events.NewOutboxEvent(models.AggregateTypeOrder, uuid.New(), placed, nil)
+			require.NoError(t, err)
+			event.ID = uuid.New()
+
+			out := &bufferCloser{}
+			cfg := DefaultPublisherConfig()
+			cfg.Encoding = tt.encoding
+			publisher := NewOutboxPublisher(nil, NewWriterSink(out), cfg, nil, testMetrics(), zerolog.Nop())
+			require.NoError(t, publisher.publishEvent(context.Background(), event))
+
+			var record fileRecord
+			require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+			assert.Equal(t, event.ID.String(), record.ID)
+			assert.Equal(t, "order.events", record.Topic)
+			assert.Equal(t, event.AggregateID.String(), record.Key)
+			assert.Equal(t, models.EventTypeOrderPlaced, record.Headers["event_type"])
+
+			value := []byte(record.Value)
+			if record.ValueBase64 != "" {
+				value, err = base64.StdEncoding.DecodeString(record.ValueBase64)
+				require.NoError(t, err)
+			}
+			decoded, err := events.Unmarshal(models.EventTypeOrderPlaced, 1, record.Headers["content_type"], value)
+			require.NoError(t, err)
+			assert.Equal(t, placed, decoded)
+		})
+	}
+}
+
+func TestFileSink_AppendsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	for i := 0; i < 2; i++ {
+		sink, err := NewFileSink(path)
+		require.NoError(t, err)
+		require.NoError(t, sink.Send(context.Background(), &Message{ID: "id", Topic: "order.events", Value: []byte(`{"n":1}`)}))
+		require.NoError(t, sink.Close())
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 2)
+}
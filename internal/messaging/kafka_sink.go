@@ -0,0 +1,98 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/rs/zerolog"
+)
+
+// KafkaSink publishes messages with a sarama sync producer
+// A transactional producer sends every message in its own Kafka transaction
+type KafkaSink struct {
+	producer sarama.SyncProducer
+	logger   zerolog.Logger
+}
+
+// NewKafkaSink creates a Kafka event sink
+func NewKafkaSink(producer sarama.SyncProducer, logger zerolog.Logger) *KafkaSink {
+	return &KafkaSink{
+		producer: producer,
+		logger:   logger.With().Str("component", "kafka_sink").Logger(),
+	}
+}
+
+// Name returns the messaging system name
+func (s *KafkaSink) Name() string { return SinkKafka }
+
+// Close closes the producer
+func (s *KafkaSink) Close() error { return s.producer.Close() }
+
+// Send publishes a message and waits for the broker acknowledgement
+func (s *KafkaSink) Send(ctx context.Context, msg *Message) error {
+	headers := make([]sarama.RecordHeader, len(msg.Headers))
+	for i, h := range msg.Headers {
+		headers[i] = sarama.RecordHeader{Key: []byte(h.Key), Value: []byte(h.Value)}
+	}
+
+	partition, offset, err := s.send(&sarama.ProducerMessage{
+		Topic:   msg.Topic,
+		Key:     sarama.StringEncoder(msg.Key),
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send to Kafka: %w", err)
+	}
+
+	s.logger.Debug().
+		Str("topic", msg.Topic).
+		Int32("partition", partition).
+		Int64("offset", offset).
+		Msg("published message to Kafka")
+
+	return nil
+}
+
+// send sends a message, inside its own Kafka transaction for a transactional producer
+func (s *KafkaSink) send(msg *sarama.ProducerMessage) (int32, int64, error) {
+	if !s.producer.IsTransactional() {
+		return s.producer.SendMessage(msg)
+	}
+
+	if err := s.producer.BeginTxn(); err != nil {
+		return 0, 0, fmt.Errorf("begin kafka transaction: %w", err)
+	}
+
+	partition, offset, err := s.producer.SendMessage(msg)
+	if err == nil {
+		if err = s.producer.CommitTxn(); err != nil {
+			err = fmt.Errorf("commit kafka transaction: %w", err)
+		}
+	}
+	if err != nil {
+		s.abortTxn()
+		return 0, 0, err
+	}
+
+	return partition, offset, nil
+}
+
+// abortTxn aborts the open Kafka transaction after a failed send or commit
+// A fatal producer state (e.g. fenced by a newer instance with the same
+// transactional id) can't be recovered, sends keep failing until restart
+func (s *KafkaSink) abortTxn() {
+	status := s.producer.TxnStatus()
+	if status&sarama.ProducerTxnFlagFatalError != 0 {
+		s.logger.Error().Msg("kafka producer is in a fatal transaction state, restart required")
+		return
+	}
+	if status&(sarama.ProducerTxnFlagInTransaction|sarama.ProducerTxnFlagAbortableError) == 0 {
+		return
+	}
+
+	if err := s.producer.AbortTxn(); err != nil {
+		s.logger.Error().Err(err).Msg("failed to abort kafka transaction")
+	}
+}
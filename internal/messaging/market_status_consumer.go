@@ -0,0 +1,213 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/service"
+	"github.com/go-playground/validator/v10"
+	"github.com/rs/zerolog"
+)
+
+// MarketStatusMessage is a JSON message on the upstream market status topic
+// WinningSelectionID is set for result messages only
+type MarketStatusMessage struct {
+	MessageID          string    `json:"message_id"`
+	MarketID           string    `json:"market_id"`
+	Type               string    `json:"type"` // suspend, resume, close, result
+	WinningSelectionID string    `json:"winning_selection_id,omitempty"`
+	OccurredAt         time.Time `json:"occurred_at"`
+}
+
+// MarketStatusConsumerConfig configures the market status consumer
+type MarketStatusConsumerConfig struct {
+	Topic        string
+	RetryBackoff RetryBackoff // Delay between attempts of a status change that failed
+}
+
+// DefaultMarketStatusConsumerConfig returns the default market status consumer settings
+func DefaultMarketStatusConsumerConfig() MarketStatusConsumerConfig {
+	return MarketStatusConsumerConfig{
+		Topic: "market.status",
+		RetryBackoff: RetryBackoff{
+			Initial:    100 * time.Millisecond,
+			Max:        30 * time.Second,
+			Multiplier: 2,
+			Jitter:     0.2,
+		},
+	}
+}
+
+// MarketStatusConsumer reads upstream market status messages from Kafka and
+// applies them through the market service
+// A status change is retried until it succeeds and the offset is committed only
+// afterwards, since skipping a suspend or result would leave the market trading;
+// redeliveries are skipped by the market service using the message ID
+type MarketStatusConsumer struct {
+	group         sarama.ConsumerGroup
+	marketService service.MarketService
+	topic         string
+	retryBackoff  RetryBackoff
+	metrics       *observability.Metrics
+	logger        zerolog.Logger
+}
+
+// NewMarketStatusConsumerGroup joins the market status consumer group
+func NewMarketStatusConsumerGroup(brokers []string, groupID string) (sarama.ConsumerGroup, error) {
+	group, err := newConsumerGroup(brokers, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("create market status consumer group: %w", err)
+	}
+	return group, nil
+}
+
+// NewMarketStatusConsumer creates a new market status consumer
+func NewMarketStatusConsumer(
+	group sarama.ConsumerGroup,
+	marketService service.MarketService,
+	cfg MarketStatusConsumerConfig,
+	metrics *observability.Metrics,
+	logger zerolog.Logger,
+) *MarketStatusConsumer {
+	return &MarketStatusConsumer{
+		group:         group,
+		marketService: marketService,
+		topic:         cfg.Topic,
+		retryBackoff:  cfg.RetryBackoff,
+		metrics:       metrics,
+		logger:        logger.With().Str("component", "market_status_consumer").Logger(),
+	}
+}
+
+// Start consumes market status messages until the context is cancelled
+// Consume returns on every rebalance, so it is called in a loop
+func (c *MarketStatusConsumer) Start(ctx context.Context) {
+	c.logger.Info().Str("topic", c.topic).Msg("market status consumer started")
+
+	go func() {
+		for err := range c.group.Errors() {
+			c.logger.Error().Err(err).Msg("market status consumer group error")
+		}
+	}()
+
+	for {
+		if err := c.group.Consume(ctx, []string{c.topic}, c); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			c.logger.Error().Err(err).Msg("market status consumer session failed")
+
+			select {
+			case <-time.After(c.retryBackoff.Delay(1)):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			c.logger.Info().Msg("market status consumer stopping")
+			return
+		}
+	}
+}
+
+// Setup is called at the start of a consumer group session
+func (c *MarketStatusConsumer) Setup(session sarama.ConsumerGroupSession) error {
+	c.logger.Info().Interface("claims", session.Claims()).Msg("market status partitions assigned")
+	return nil
+}
+
+// Cleanup is called at the end of a consumer group session
+func (c *MarketStatusConsumer) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim applies the status messages of one partition in order
+func (c *MarketStatusConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if err := c.handleMessage(session.Context(), msg); err != nil {
+				// Session is ending, the uncommitted message is redelivered
+				return nil
+			}
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// handleMessage applies a status message, retrying until it succeeds or is rejected
+// Returns an error only if the context ended before the message was applied
+func (c *MarketStatusConsumer) handleMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	var status MarketStatusMessage
+	if err := json.Unmarshal(msg.Value, &status); err != nil {
+		c.metrics.MarketStatusMessages.WithLabelValues("", "malformed").Inc()
+		c.logger.Error().Err(err).
+			Int32("partition", msg.Partition).
+			Int64("offset", msg.Offset).
+			Msg("malformed market status message")
+		return nil
+	}
+
+	// Without an upstream ID the message position identifies a redelivery
+	messageID := status.MessageID
+	if messageID == "" {
+		messageID = fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)
+	}
+
+	req := &service.MarketStatusRequest{
+		MessageID:          messageID,
+		MarketID:           status.MarketID,
+		Type:               status.Type,
+		WinningSelectionID: status.WinningSelectionID,
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, err := c.marketService.ApplyMarketStatus(ctx, req)
+		if err == nil {
+			outcome := "applied"
+			if result.AlreadyProcessed {
+				outcome = "duplicate"
+			}
+			c.metrics.MarketStatusMessages.WithLabelValues(status.Type, outcome).Inc()
+			return nil
+		}
+
+		if isRejectedMarketStatus(err) {
+			c.metrics.MarketStatusMessages.WithLabelValues(status.Type, "rejected").Inc()
+			c.logger.Error().Err(err).
+				Str("message_id", messageID).
+				Str("market_id", status.MarketID).
+				Str("type", status.Type).
+				Msg("market status message rejected")
+			return nil
+		}
+
+		c.logger.Warn().Err(err).
+			Str("message_id", messageID).
+			Str("market_id", status.MarketID).
+			Int("attempt", attempt).
+			Msg("failed to apply market status, retrying")
+
+		select {
+		case <-time.After(c.retryBackoff.Delay(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// isRejectedMarketStatus returns true for errors that retrying cannot fix
+func isRejectedMarketStatus(err error) bool {
+	var validationErrs validator.ValidationErrors
+	return errors.As(err, &validationErrs) || errors.Is(err, models.ErrInvalidMarketTransition)
+}
@@ -0,0 +1,118 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/service"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubMarketService records status changes and fails as scripted
+type stubMarketService struct {
+	service.MarketService
+	requests []*service.MarketStatusRequest
+	errs     []error // returned in order, then nil
+}
+
+func (s *stubMarketService) ApplyMarketStatus(_ context.Context, req *service.MarketStatusRequest) (*service.MarketStatusResult, error) {
+	s.requests = append(s.requests, req)
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &service.MarketStatusResult{Status: models.MarketStatusSuspended}, nil
+}
+
+func newTestMarketStatusConsumer(markets service.MarketService) *MarketStatusConsumer {
+	cfg := DefaultMarketStatusConsumerConfig()
+	cfg.RetryBackoff = RetryBackoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1}
+	return NewMarketStatusConsumer(nil, markets, cfg, testMetrics(), zerolog.Nop())
+}
+
+func marketStatusMessage(t *testing.T, status MarketStatusMessage) *sarama.ConsumerMessage {
+	value, err := json.Marshal(status)
+	require.NoError(t, err)
+	return &sarama.ConsumerMessage{Topic: "market.status", Partition: 2, Offset: 41, Value: value}
+}
+
+func TestMarketStatusConsumer_AppliesMessage(t *testing.T) {
+	markets := &stubMarketService{}
+	consumer := newTestMarketStatusConsumer(markets)
+
+	msg := marketStatusMessage(t, MarketStatusMessage{
+		MessageID:          "feed-1",
+		MarketID:           "event-123",
+		Type:               service.MarketChangeResult,
+		WinningSelectionID: "team-a",
+	})
+	require.NoError(t, consumer.handleMessage(context.Background(), msg))
+
+	require.Len(t, markets.requests, 1)
+	assert.Equal(t, &service.MarketStatusRequest{
+		MessageID:          "feed-1",
+		MarketID:           "event-123",
+		Type:               service.MarketChangeResult,
+		WinningSelectionID: "team-a",
+	}, markets.requests[0])
+}
+
+func TestMarketStatusConsumer_MessageIDFallsBackToPosition(t *testing.T) {
+	markets := &stubMarketService{}
+	consumer := newTestMarketStatusConsumer(markets)
+
+	msg := marketStatusMessage(t, MarketStatusMessage{MarketID: "event-123", Type: service.MarketChangeSuspend})
+	require.NoError(t, consumer.handleMessage(context.Background(), msg))
+
+	require.Len(t, markets.requests, 1)
+	assert.Equal(t, "market.status/2/41", markets.requests[0].MessageID)
+}
+
+func TestMarketStatusConsumer_RetriesUntilApplied(t *testing.T) {
+	markets := &stubMarketService{errs: []error{errors.New("connection reset"), errors.New("connection reset")}}
+	consumer := newTestMarketStatusConsumer(markets)
+
+	msg := marketStatusMessage(t, MarketStatusMessage{MessageID: "feed-1", MarketID: "event-123", Type: service.MarketChangeClose})
+	require.NoError(t, consumer.handleMessage(context.Background(), msg))
+
+	assert.Len(t, markets.requests, 3)
+}
+
+func TestMarketStatusConsumer_RejectedMessagesAreSkipped(t *testing.T) {
+	markets := &stubMarketService{errs: []error{fmt.Errorf("%w: resume market CLOSED", models.ErrInvalidMarketTransition)}}
+	consumer := newTestMarketStatusConsumer(markets)
+
+	msg := marketStatusMessage(t, MarketStatusMessage{MessageID: "feed-1", MarketID: "event-123", Type: service.MarketChangeResume})
+	require.NoError(t, consumer.handleMessage(context.Background(), msg))
+	assert.Len(t, markets.requests, 1)
+
+	malformed := &sarama.ConsumerMessage{Value: []byte(`{"market_id":`)}
+	require.NoError(t, consumer.handleMessage(context.Background(), malformed))
+	assert.Len(t, markets.requests, 1)
+}
+
+func TestMarketStatusConsumer_CancelledBeforeApplied(t *testing.T) {
+	markets := &stubMarketService{errs: make([]error, 1<<20)}
+	for i := range markets.errs {
+		markets.errs[i] = errors.New("database unavailable")
+	}
+	consumer := newTestMarketStatusConsumer(markets)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// The message must stay uncommitted so it is redelivered
+	msg := marketStatusMessage(t, MarketStatusMessage{MessageID: "feed-1", MarketID: "event-123", Type: service.MarketChangeSuspend})
+	assert.Error(t, consumer.handleMessage(ctx, msg))
+}
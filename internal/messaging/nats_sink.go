@@ -0,0 +1,103 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog"
+)
+
+// NATSConfig configures the NATS JetStream sink
+type NATSConfig struct {
+	URL            string
+	SubjectPrefix  string        // Prepended to the topic to form the subject
+	PublishTimeout time.Duration // Applied when the context has no deadline
+}
+
+// NATSSink publishes messages to NATS JetStream
+// The subjects must be bound to a stream; the message ID is sent as
+// Nats-Msg-Id so the stream's duplicate window drops republished events
+type NATSSink struct {
+	conn          *nats.Conn
+	js            jetstream.JetStream
+	subjectPrefix string
+	timeout       time.Duration
+	logger        zerolog.Logger
+}
+
+// NewNATSSink connects to NATS and creates a JetStream sink
+func NewNATSSink(cfg NATSConfig, logger zerolog.Logger) (*NATSSink, error) {
+	logger = logger.With().Str("component", "nats_sink").Logger()
+
+	conn, err := nats.Connect(cfg.URL,
+		nats.Name("order-book-service"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			logger.Warn().Err(err).Msg("disconnected from NATS")
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			logger.Info().Str("url", nc.ConnectedUrl()).Msg("reconnected to NATS")
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connect to NATS: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("create JetStream context: %w", err)
+	}
+
+	timeout := cfg.PublishTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &NATSSink{
+		conn:          conn,
+		js:            js,
+		subjectPrefix: cfg.SubjectPrefix,
+		timeout:       timeout,
+		logger:        logger,
+	}, nil
+}
+
+// Name returns the messaging system name
+func (s *NATSSink) Name() string { return SinkNATS }
+
+// Close drains pending publishes and closes the connection
+func (s *NATSSink) Close() error { return s.conn.Drain() }
+
+// Send publishes a message and waits for the stream acknowledgement
+func (s *NATSSink) Send(ctx context.Context, msg *Message) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	natsMsg := nats.NewMsg(s.subjectPrefix + msg.Topic)
+	natsMsg.Data = msg.Value
+	natsMsg.Header.Set("key", msg.Key)
+	for _, h := range msg.Headers {
+		natsMsg.Header.Add(h.Key, h.Value)
+	}
+
+	ack, err := s.js.PublishMsg(ctx, natsMsg, jetstream.WithMsgID(msg.ID))
+	if err != nil {
+		return fmt.Errorf("failed to publish to JetStream: %w", err)
+	}
+
+	s.logger.Debug().
+		Str("subject", natsMsg.Subject).
+		Str("stream", ack.Stream).
+		Uint64("sequence", ack.Sequence).
+		Bool("duplicate", ack.Duplicate).
+		Msg("published message to JetStream")
+
+	return nil
+}
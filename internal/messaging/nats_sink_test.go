@@ -0,0 +1,63 @@
+package messaging
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATSSink_DeduplicatesByMessageID(t *testing.T) {
+	url := os.Getenv("TEST_NATS_URL")
+	if url == "" {
+		t.Skip("TEST_NATS_URL not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Private stream per run
+	prefix := "test" + uuid.NewString()[:8] + "."
+	conn, err := nats.Connect(url)
+	require.NoError(t, err)
+	defer conn.Close()
+	js, err := jetstream.New(conn)
+	require.NoError(t, err)
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     prefix[:len(prefix)-1],
+		Subjects: []string{prefix + ">"},
+	})
+	require.NoError(t, err)
+	defer js.DeleteStream(context.Background(), stream.CachedInfo().Config.Name)
+
+	sink, err := NewNATSSink(NATSConfig{URL: url, SubjectPrefix: prefix}, zerolog.Nop())
+	require.NoError(t, err)
+	defer sink.Close()
+
+	msg := &Message{
+		ID:      uuid.NewString(),
+		Topic:   "order.events",
+		Key:     uuid.NewString(),
+		Value:   []byte(`{"order_id":"1"}`),
+		Headers: []Header{header("event_type", "order.placed")},
+	}
+	require.NoError(t, sink.Send(ctx, msg))
+	require.NoError(t, sink.Send(ctx, msg))
+
+	info, err := stream.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), info.State.Msgs)
+
+	stored, err := stream.GetLastMsgForSubject(ctx, prefix+"order.events")
+	require.NoError(t, err)
+	assert.Equal(t, msg.Value, stored.Data)
+	assert.Equal(t, msg.Key, stored.Header.Get("key"))
+	assert.Equal(t, "order.placed", stored.Header.Get("event_type"))
+}
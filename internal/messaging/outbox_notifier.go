@@ -0,0 +1,95 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// OutboxNotifyChannel is the channel the outbox insert trigger notifies on
+const OutboxNotifyChannel = "outbox_events"
+
+// OutboxNotifier LISTENs for outbox inserts and wakes the publisher
+// A dropped connection is re-established, the publisher's poll covers the gap
+type OutboxNotifier struct {
+	pool           *pgxpool.Pool
+	logger         zerolog.Logger
+	wakeups        chan struct{}
+	reconnectDelay time.Duration
+}
+
+// NewOutboxNotifier creates a new outbox notifier
+func NewOutboxNotifier(pool *pgxpool.Pool, logger zerolog.Logger) *OutboxNotifier {
+	return &OutboxNotifier{
+		pool:           pool,
+		logger:         logger.With().Str("component", "outbox_notifier").Logger(),
+		wakeups:        make(chan struct{}, 1),
+		reconnectDelay: time.Second,
+	}
+}
+
+// Wakeups returns the channel signalled when new outbox events may be available
+// Signals are coalesced, a pending wakeup covers any number of notifications
+func (n *OutboxNotifier) Wakeups() <-chan struct{} {
+	return n.wakeups
+}
+
+// Start listens for notifications until the context is cancelled
+func (n *OutboxNotifier) Start(ctx context.Context) {
+	n.logger.Info().Msg("outbox notifier started")
+
+	for {
+		err := n.listen(ctx)
+		if ctx.Err() != nil {
+			n.logger.Info().Msg("outbox notifier stopping")
+			return
+		}
+
+		n.logger.Warn().Err(err).
+			Dur("retry_in", n.reconnectDelay).
+			Msg("outbox listen connection lost")
+
+		select {
+		case <-time.After(n.reconnectDelay):
+		case <-ctx.Done():
+			n.logger.Info().Msg("outbox notifier stopping")
+			return
+		}
+	}
+}
+
+// listen holds a dedicated connection in LISTEN mode and forwards notifications
+func (n *OutboxNotifier) listen(ctx context.Context) error {
+	pooled, err := n.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire listen connection: %w", err)
+	}
+	// The connection is left in LISTEN state, don't hand it back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+OutboxNotifyChannel); err != nil {
+		return fmt.Errorf("listen on %s: %w", OutboxNotifyChannel, err)
+	}
+
+	// Events committed while we weren't listening have no pending notification
+	n.wake()
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return fmt.Errorf("wait for notification: %w", err)
+		}
+		n.wake()
+	}
+}
+
+// wake signals the publisher without blocking
+func (n *OutboxNotifier) wake() {
+	select {
+	case n.wakeups <- struct{}{}:
+	default:
+	}
+}
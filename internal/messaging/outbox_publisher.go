@@ -3,56 +3,154 @@ package messaging
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 
-	"github.com/IBM/sarama"
-	"github.com/rs/zerolog"
+	"github.com/cypherlabdev/order-book-service/internal/events"
 	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
 	"github.com/cypherlabdev/order-book-service/internal/repository"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// OutboxPublisher polls the outbox table and publishes events to Kafka
+// PublisherConfig tunes how the outbox publisher claims events
+type PublisherConfig struct {
+	PollInterval time.Duration // Fallback poll, wakeups trigger a drain in between
+	BatchSize    int
+	ClaimLease   time.Duration // How long claimed events stay reserved for an instance
+
+	RetryBackoff    RetryBackoff // Delay before a failed event is attempted again
+	DeadLetterTopic string       // Receives a copy of every event that exhausts its retries
+
+	Encoding          string // events.EncodingProtobuf or events.EncodingJSON
+	MessageFormat     string // MessageFormatPlain or MessageFormatCloudEvents
+	Delivery          string // Delivery mode of the producer, advertised to consumers
+	CloudEventsSource string // ce_source of CloudEvents messages
+}
+
+// DefaultPublisherConfig returns the publisher settings used without notifications
+func DefaultPublisherConfig() PublisherConfig {
+	return PublisherConfig{
+		PollInterval: 100 * time.Millisecond,
+		BatchSize:    100,
+		ClaimLease:   30 * time.Second,
+
+		RetryBackoff:    DefaultRetryBackoff(),
+		DeadLetterTopic: "order.events.dlq",
+
+		Encoding:          events.EncodingProtobuf,
+		MessageFormat:     MessageFormatPlain,
+		Delivery:          DeliveryAtLeastOnce,
+		CloudEventsSource: DefaultCloudEventsSource,
+	}
+}
+
+// OutboxPublisher polls the outbox table and publishes events to an EventSink
+// Events are claimed under a lease, so several instances can run side by side
 type OutboxPublisher struct {
-	outboxRepo     repository.OutboxRepository
-	kafkaProducer  sarama.SyncProducer
-	logger         zerolog.Logger
-	pollInterval   time.Duration
-	batchSize      int
-	topicMap       map[string]string // event_type -> Kafka topic
+	outboxRepo        repository.OutboxRepository
+	sink              EventSink
+	logger            zerolog.Logger
+	instanceID        string          // claim owner for this publisher
+	wakeups           <-chan struct{} // drain immediately on signal, nil to rely on polling
+	pollInterval      time.Duration
+	batchSize         int
+	claimLease        time.Duration // how long claimed events stay reserved for this instance
+	retryBackoff      RetryBackoff
+	deadLetterTopic   string
+	encoding          string // payload encoding for events with a known schema
+	messageFormat     string // plain or CloudEvents headers
+	cloudEventsSource string
+	delivery          string
+	tracer            trace.Tracer
+	backlogRefresh    time.Duration // how often the dead-letter backlog gauge is refreshed
+	metrics           *observability.Metrics
+	topicMap          map[string]string // event_type -> topic
 }
 
 // NewOutboxPublisher creates a new outbox publisher
+// wakeups is typically OutboxNotifier.Wakeups() and may be nil
 func NewOutboxPublisher(
 	outboxRepo repository.OutboxRepository,
-	kafkaProducer sarama.SyncProducer,
+	sink EventSink,
+	cfg PublisherConfig,
+	wakeups <-chan struct{},
+	metrics *observability.Metrics,
 	logger zerolog.Logger,
 ) *OutboxPublisher {
+	instanceID := newInstanceID()
 	return &OutboxPublisher{
-		outboxRepo:    outboxRepo,
-		kafkaProducer: kafkaProducer,
-		logger:        logger.With().Str("component", "outbox_publisher").Logger(),
-		pollInterval:  100 * time.Millisecond,
-		batchSize:     100,
+		outboxRepo:        outboxRepo,
+		sink:              sink,
+		logger:            logger.With().Str("component", "outbox_publisher").Str("instance_id", instanceID).Logger(),
+		instanceID:        instanceID,
+		wakeups:           wakeups,
+		pollInterval:      cfg.PollInterval,
+		batchSize:         cfg.BatchSize,
+		claimLease:        cfg.ClaimLease,
+		retryBackoff:      cfg.RetryBackoff,
+		deadLetterTopic:   cfg.DeadLetterTopic,
+		encoding:          cfg.Encoding,
+		messageFormat:     cfg.MessageFormat,
+		cloudEventsSource: cfg.CloudEventsSource,
+		delivery:          cfg.Delivery,
+		tracer:            otel.Tracer("order-book-service"),
+		backlogRefresh:    30 * time.Second,
+		metrics:           metrics,
 		topicMap: map[string]string{
-			"order.placed":  "order.events",
-			"order.matched": "order.events",
-			"order.settled": "order.settlements",
-			"order.cancelled": "order.events",
+			models.EventTypeOrderPlaced:      "order.events",
+			models.EventTypeOrderMatched:     "order.events",
+			models.EventTypeOrderPartial:     "order.events",
+			models.EventTypeOrderCancelled:   "order.events",
+			models.EventTypeOrderExpired:     "order.events",
+			models.EventTypeOrderSettled:     "order.settlements",
+			models.EventTypeOrderCompensated: "order.events",
+			models.EventTypeMatchCreated:     "order.events",
+			models.EventTypeMatchSettled:     "order.settlements",
+			models.EventTypeMatchReversed:    "order.events",
 		},
 	}
 }
 
+// newInstanceID builds a claim owner that is unique per process
+func newInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	return hostname + "-" + uuid.NewString()
+}
+
 // Start begins polling for outbox events
+// A wakeup drains straight away, the ticker catches anything a wakeup missed
 func (p *OutboxPublisher) Start(ctx context.Context) {
-	p.logger.Info().Msg("outbox publisher started")
+	p.logger.Info().
+		Dur("poll_interval", p.pollInterval).
+		Bool("notifications", p.wakeups != nil).
+		Msg("outbox publisher started")
 	ticker := time.NewTicker(p.pollInterval)
 	defer ticker.Stop()
+	backlogTicker := time.NewTicker(p.backlogRefresh)
+	defer backlogTicker.Stop()
+
+	p.refreshDeadLetterBacklog(ctx)
 
 	for {
 		select {
 		case <-ticker.C:
-			p.publishPending(ctx)
+			p.drain(ctx)
+		case <-p.wakeups:
+			p.drain(ctx)
+		case <-backlogTicker.C:
+			p.refreshDeadLetterBacklog(ctx)
 		case <-ctx.Done():
 			p.logger.Info().Msg("outbox publisher stopping")
 			return
@@ -60,18 +158,28 @@ func (p *OutboxPublisher) Start(ctx context.Context) {
 	}
 }
 
-// publishPending retrieves and publishes unprocessed events
-func (p *OutboxPublisher) publishPending(ctx context.Context) {
-	events, err := p.outboxRepo.GetUnprocessedEvents(ctx, p.batchSize)
-	if err != nil {
-		p.logger.Error().Err(err).Msg("failed to get unprocessed events")
-		return
+// drain publishes until a claim makes no progress
+// Each claim only returns the next event of every aggregate, so a backlog for
+// one aggregate takes several rounds
+func (p *OutboxPublisher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		if p.publishPending(ctx) == 0 {
+			return
+		}
 	}
+}
 
-	if len(events) == 0 {
-		return
+// publishPending claims and publishes unprocessed events
+// Returns the number of events published
+func (p *OutboxPublisher) publishPending(ctx context.Context) int {
+	events, err := p.outboxRepo.ClaimUnprocessedEvents(ctx, p.instanceID, p.batchSize, p.claimLease)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to claim unprocessed events")
+		return 0
 	}
 
+	published := 0
+
 	for _, event := range events {
 		publishErr := p.publishEvent(ctx, event)
 		if publishErr != nil {
@@ -81,62 +189,172 @@ func (p *OutboxPublisher) publishPending(ctx context.Context) {
 				Str("event_type", event.EventType).
 				Msg("failed to publish event")
 
-			// Increment retry count
-			if err := p.outboxRepo.IncrementRetryCount(ctx, event.ID, publishErr.Error()); err != nil {
+			p.metrics.OutboxEventsFailed.WithLabelValues(event.EventType).Inc()
+
+			// Increment retry count and back off before the next attempt
+			retryAfter := p.retryBackoff.Delay(event.RetryCount + 1)
+			deadLettered, err := p.outboxRepo.IncrementRetryCount(ctx, event.ID, p.instanceID, publishErr.Error(), retryAfter)
+			if err != nil {
 				p.logger.Error().Err(err).Msg("failed to increment retry count")
+				continue
+			}
+			if deadLettered {
+				p.deadLetter(event, publishErr)
 			}
 		} else {
 			// Mark as processed
-			if err := p.outboxRepo.MarkProcessed(ctx, event.ID); err != nil {
+			if err := p.outboxRepo.MarkProcessed(ctx, event.ID, p.instanceID); err != nil {
 				p.logger.Error().Err(err).Msg("failed to mark event as processed")
+				continue
 			}
+			p.metrics.OutboxEventsPublished.WithLabelValues(event.EventType).Inc()
+			published++
 		}
 	}
+
+	return published
 }
 
-// publishEvent publishes a single event to Kafka
-func (p *OutboxPublisher) publishEvent(ctx context.Context, event *models.OutboxEvent) error {
-	// Get Kafka topic for this event type
-	topic, ok := p.topicMap[event.EventType]
-	if !ok {
-		topic = "order.events" // Default topic
-	}
+// deadLetter records an event that exhausted its retries and copies it to the DLQ topic
+// The outbox row stays the source of truth, a failed DLQ send is only logged
+func (p *OutboxPublisher) deadLetter(event *models.OutboxEvent, publishErr error) {
+	p.metrics.OutboxEventsDeadLettered.WithLabelValues(event.EventType).Inc()
+	p.metrics.OutboxDeadLetterBacklog.Inc()
+
+	p.logger.Error().
+		Err(publishErr).
+		Str("event_id", event.ID.String()).
+		Str("event_type", event.EventType).
+		Str("aggregate_id", event.AggregateID.String()).
+		Msg("outbox event dead-lettered, later events for its aggregate are held until replay")
 
-	// Create Kafka message
-	payload, err := json.Marshal(event.EventPayload)
+	errMsg := publishErr.Error()
+	now := time.Now()
+	event.RetryCount++
+	event.LastError = &errMsg
+	event.DeadLetteredAt = &now
+
+	value, err := json.Marshal(event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event payload: %w", err)
+		p.logger.Error().Err(err).Str("event_id", event.ID.String()).Msg("failed to marshal dead-lettered event")
+		return
 	}
 
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Key:   sarama.StringEncoder(event.AggregateID.String()),
-		Value: sarama.ByteEncoder(payload),
-		Headers: []sarama.RecordHeader{
-			{Key: []byte("event_type"), Value: []byte(event.EventType)},
-			{Key: []byte("aggregate_type"), Value: []byte(event.AggregateType)},
+	msg := &Message{
+		ID:    event.ID.String(),
+		Topic: p.deadLetterTopic,
+		Key:   event.AggregateID.String(),
+		Value: value,
+		Headers: []Header{
+			header("event_id", event.ID.String()),
+			header("event_type", event.EventType),
+			header("original_topic", p.topicFor(event.EventType)),
+			header("retry_count", strconv.Itoa(event.RetryCount)),
+			header("error", errMsg),
 		},
 	}
 
-	if event.SagaID != nil {
-		msg.Headers = append(msg.Headers, sarama.RecordHeader{
-			Key:   []byte("saga_id"),
-			Value: []byte(event.SagaID.String()),
-		})
+	if err := p.sink.Send(context.Background(), msg); err != nil {
+		p.logger.Error().Err(err).
+			Str("event_id", event.ID.String()).
+			Str("topic", p.deadLetterTopic).
+			Msg("failed to send event to dead-letter topic")
 	}
+}
 
-	// Send to Kafka
-	partition, offset, err := p.kafkaProducer.SendMessage(msg)
+// refreshDeadLetterBacklog sets the backlog gauge from the outbox table
+func (p *OutboxPublisher) refreshDeadLetterBacklog(ctx context.Context) {
+	count, err := p.outboxRepo.CountDeadLettered(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to send to Kafka: %w", err)
+		p.logger.Error().Err(err).Msg("failed to count dead-lettered events")
+		return
+	}
+	p.metrics.OutboxDeadLetterBacklog.Set(float64(count))
+}
+
+// topicFor returns the topic for an event type
+func (p *OutboxPublisher) topicFor(eventType string) string {
+	if topic, ok := p.topicMap[eventType]; ok {
+		return topic
+	}
+	return "order.events" // Default topic
+}
+
+// publishEvent publishes a single event to the sink
+func (p *OutboxPublisher) publishEvent(ctx context.Context, event *models.OutboxEvent) error {
+	// Get topic for this event type
+	topic := p.topicFor(event.EventType)
+
+	// Create message
+	payload, contentType, schema, err := p.encodePayload(event)
+	if err != nil {
+		return err
+	}
+
+	// Continue the trace of the request that wrote the event
+	ctx, span := p.tracer.Start(eventTraceContext(ctx, event), topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", p.sink.Name()),
+			attribute.String("messaging.destination.name", topic),
+			attribute.String("messaging.message.id", event.ID.String()),
+		),
+	)
+	defer span.End()
+
+	var headers []Header
+	if p.messageFormat == MessageFormatCloudEvents {
+		headers = cloudEventHeaders(event, p.cloudEventsSource, contentType, schema)
+		headers = append(headers, header("ce_"+HeaderDelivery, p.delivery))
+	} else {
+		headers = plainHeaders(event, contentType, schema)
+		headers = append(headers, header(HeaderDedupKey, event.ID.String()), header(HeaderDelivery, p.delivery))
+	}
+
+	msg := &Message{
+		ID:      event.ID.String(),
+		Topic:   topic,
+		Key:     event.AggregateID.String(),
+		Value:   payload,
+		Headers: append(headers, traceHeaders(ctx)...),
+	}
+
+	if err := p.sink.Send(ctx, msg); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
 	}
 
 	p.logger.Debug().
 		Str("event_type", event.EventType).
 		Str("topic", topic).
-		Int32("partition", partition).
-		Int64("offset", offset).
-		Msg("published event to Kafka")
+		Msg("published event")
 
 	return nil
 }
+
+// encodePayload encodes an event payload for publishing
+// Events without a registered schema are published as their stored JSON
+func (p *OutboxPublisher) encodePayload(event *models.OutboxEvent) (payload []byte, contentType, schema string, err error) {
+	typed, err := events.FromPayload(event.EventType, event.SchemaVersion, event.EventPayload)
+	if errors.Is(err, events.ErrUnknownSchema) {
+		p.logger.Warn().
+			Str("event_type", event.EventType).
+			Int("schema_version", event.SchemaVersion).
+			Msg("no schema registered for event, publishing raw json")
+
+		payload, err = json.Marshal(event.EventPayload)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("failed to marshal event payload: %w", err)
+		}
+		return payload, events.ContentTypeJSON, "", nil
+	}
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to decode event payload: %w", err)
+	}
+
+	payload, contentType, err = events.Marshal(typed, p.encoding)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to encode event payload: %w", err)
+	}
+	return payload, contentType, events.FullSchemaName(typed), nil
+}
@@ -0,0 +1,572 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cypherlabdev/order-book-service/internal/events"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProducer is a sarama.SyncProducer fake that records sends per message key
+type recordingProducer struct {
+	mu        sync.Mutex
+	sends     map[string]int
+	sequences map[string][]int64                     // key -> aggregate_sequence headers in send order
+	fail      func(key string, sequence int64) error // optional send failure injection
+
+	messages     []*sarama.ProducerMessage // successfully sent messages in send order
+	deadLettered []*sarama.ProducerMessage // messages sent to the dead-letter topic
+}
+
+func newRecordingProducer() *recordingProducer {
+	return &recordingProducer{
+		sends:     make(map[string]int),
+		sequences: make(map[string][]int64),
+	}
+}
+
+func (p *recordingProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	key, err := msg.Key.Encode()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var sequence int64
+	for _, header := range msg.Headers {
+		if string(header.Key) == "aggregate_sequence" {
+			sequence, err = strconv.ParseInt(string(header.Value), 10, 64)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if msg.Topic == DefaultPublisherConfig().DeadLetterTopic {
+		p.deadLettered = append(p.deadLettered, msg)
+		return 0, 0, nil
+	}
+
+	if p.fail != nil {
+		if err := p.fail(string(key), sequence); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	p.messages = append(p.messages, msg)
+	p.sends[string(key)]++
+	p.sequences[string(key)] = append(p.sequences[string(key)], sequence)
+	return 0, int64(len(p.sends)), nil
+}
+
+func (p *recordingProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	for _, msg := range msgs {
+		if _, _, err := p.SendMessage(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *recordingProducer) Close() error { return nil }
+func (p *recordingProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return sarama.ProducerTxnFlagReady
+}
+func (p *recordingProducer) IsTransactional() bool { return false }
+func (p *recordingProducer) BeginTxn() error       { return nil }
+func (p *recordingProducer) CommitTxn() error      { return nil }
+func (p *recordingProducer) AbortTxn() error       { return nil }
+func (p *recordingProducer) AddOffsetsToTxn(map[string][]*sarama.PartitionOffsetMetadata, string) error {
+	return nil
+}
+func (p *recordingProducer) AddMessageToTxn(*sarama.ConsumerMessage, string, *string) error {
+	return nil
+}
+
+func (p *recordingProducer) counts() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	counts := make(map[string]int, len(p.sends))
+	for key, n := range p.sends {
+		counts[key] = n
+	}
+	return counts
+}
+
+// headers returns the headers of a message by key
+func headers(msg *sarama.ProducerMessage) map[string]string {
+	values := make(map[string]string, len(msg.Headers))
+	for _, header := range msg.Headers {
+		values[string(header.Key)] = string(header.Value)
+	}
+	return values
+}
+
+// testMetrics creates metrics on a private registry
+func testMetrics() *observability.Metrics {
+	return observability.NewMetricsWithRegistry(prometheus.NewRegistry())
+}
+
+// fastRetryConfig returns publisher settings with millisecond retry backoff
+func fastRetryConfig() PublisherConfig {
+	cfg := DefaultPublisherConfig()
+	cfg.RetryBackoff = RetryBackoff{Initial: time.Millisecond, Max: 5 * time.Millisecond, Multiplier: 2}
+	return cfg
+}
+
+// setupTestDatabase connects to TEST_DATABASE_URL and migrates a throwaway schema
+// Skips the test when no database is configured
+func setupTestDatabase(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping database test")
+	}
+
+	ctx := context.Background()
+	schema := "outbox_test_" + uuid.NewString()[:8]
+
+	admin, err := pgxpool.New(ctx, databaseURL)
+	require.NoError(t, err)
+	_, err = admin.Exec(ctx, fmt.Sprintf("CREATE SCHEMA %s", schema))
+	require.NoError(t, err)
+
+	config, err := pgxpool.ParseConfig(databaseURL)
+	require.NoError(t, err)
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+	config.MaxConns = 16
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		pool.Close()
+		_, _ = admin.Exec(context.Background(), fmt.Sprintf("DROP SCHEMA %s CASCADE", schema))
+		admin.Close()
+	})
+
+	migrations, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
+	require.NoError(t, err)
+	sort.Strings(migrations)
+
+	for _, migration := range migrations {
+		sql, err := os.ReadFile(migration)
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, string(sql))
+		require.NoError(t, err, "apply %s", filepath.Base(migration))
+	}
+
+	return pool
+}
+
+// insertEvents writes count outbox events, each under its own aggregate
+func insertEvents(t *testing.T, pool *pgxpool.Pool, outboxRepo repository.OutboxRepository, count int) []uuid.UUID {
+	t.Helper()
+
+	aggregateIDs := make([]uuid.UUID, 0, count)
+	for i := 0; i < count; i++ {
+		aggregateIDs = append(aggregateIDs, uuid.New())
+	}
+
+	insertAggregateEvents(t, pool, outboxRepo, aggregateIDs...)
+	return aggregateIDs
+}
+
+// insertAggregateEvents writes one outbox event per entry, in order, in a single transaction
+func insertAggregateEvents(t *testing.T, pool *pgxpool.Pool, outboxRepo repository.OutboxRepository, aggregateIDs ...uuid.UUID) {
+	t.Helper()
+
+	ctx := context.Background()
+	tx, err := pool.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+
+	for i, aggregateID := range aggregateIDs {
+		event := &models.OutboxEvent{
+			AggregateID:   aggregateID,
+			AggregateType: models.AggregateTypeOrder,
+			EventType:     models.EventTypeOrderPlaced,
+			EventPayload:  map[string]interface{}{"sequence": i},
+		}
+		require.NoError(t, outboxRepo.Create(ctx, tx, event))
+	}
+
+	require.NoError(t, tx.Commit(ctx))
+}
+
+// countUnprocessed returns the number of events not yet published
+func countUnprocessed(pool *pgxpool.Pool) int {
+	var remaining int
+	err := pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM outbox_events WHERE processed_at IS NULL`).Scan(&remaining)
+	if err != nil {
+		return -1
+	}
+	return remaining
+}
+
+func TestOutboxPublisher_ConcurrentInstancesPublishOnce(t *testing.T) {
+	pool := setupTestDatabase(t)
+	outboxRepo := repository.NewPostgresOutboxRepository(pool, zerolog.Nop())
+
+	const eventCount = 500
+	aggregateIDs := insertEvents(t, pool, outboxRepo, eventCount)
+
+	producer := newRecordingProducer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Several instances with small batches so claims overlap heavily
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		cfg := DefaultPublisherConfig()
+		cfg.PollInterval = time.Millisecond
+		cfg.BatchSize = 7
+		publisher := NewOutboxPublisher(outboxRepo, NewKafkaSink(producer, zerolog.Nop()), cfg, nil, testMetrics(), zerolog.Nop())
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			publisher.Start(ctx)
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return countUnprocessed(pool) == 0
+	}, 30*time.Second, 10*time.Millisecond)
+
+	cancel()
+	wg.Wait()
+
+	counts := producer.counts()
+	assert.Len(t, counts, eventCount)
+	for _, aggregateID := range aggregateIDs {
+		assert.Equal(t, 1, counts[aggregateID.String()], "event for aggregate %s", aggregateID)
+	}
+}
+
+func TestOutboxRepository_ExpiredClaimIsReclaimed(t *testing.T) {
+	pool := setupTestDatabase(t)
+	outboxRepo := repository.NewPostgresOutboxRepository(pool, zerolog.Nop())
+	ctx := context.Background()
+
+	insertEvents(t, pool, outboxRepo, 1)
+
+	claimed, err := outboxRepo.ClaimUnprocessedEvents(ctx, "publisher-a", 10, 50*time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+
+	// Leased to publisher-a, so publisher-b gets nothing
+	none, err := outboxRepo.ClaimUnprocessedEvents(ctx, "publisher-b", 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	time.Sleep(100 * time.Millisecond)
+
+	reclaimed, err := outboxRepo.ClaimUnprocessedEvents(ctx, "publisher-b", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, reclaimed, 1)
+	assert.Equal(t, claimed[0].ID, reclaimed[0].ID)
+
+	// publisher-a lost its claim and can no longer settle the event
+	assert.Error(t, outboxRepo.MarkProcessed(ctx, claimed[0].ID, "publisher-a"))
+	assert.NoError(t, outboxRepo.MarkProcessed(ctx, claimed[0].ID, "publisher-b"))
+}
+
+func TestOutboxPublisher_FailedEventBlocksOnlyItsAggregate(t *testing.T) {
+	pool := setupTestDatabase(t)
+	outboxRepo := repository.NewPostgresOutboxRepository(pool, zerolog.Nop())
+
+	blocked, other := uuid.New(), uuid.New()
+	insertAggregateEvents(t, pool, outboxRepo, blocked, other, blocked, other, blocked, other)
+
+	// The first event of the blocked aggregate never goes out
+	producer := newRecordingProducer()
+	producer.fail = func(key string, sequence int64) error {
+		if key == blocked.String() && sequence == 1 {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}
+
+	publisher := NewOutboxPublisher(outboxRepo, NewKafkaSink(producer, zerolog.Nop()), fastRetryConfig(), nil, testMetrics(), zerolog.Nop())
+	ctx := context.Background()
+
+	// Drain until the failing event exhausts its retries
+	require.Eventually(t, func() bool {
+		publisher.drain(ctx)
+
+		var retries int
+		err := pool.QueryRow(ctx,
+			`SELECT retry_count FROM outbox_events WHERE aggregate_id = $1 AND aggregate_sequence = 1`,
+			blocked).Scan(&retries)
+		return err == nil && retries == models.DefaultOutboxMaxRetries
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// A further round publishes nothing for the blocked aggregate
+	publisher.drain(ctx)
+	assert.Equal(t, 3, countUnprocessed(pool))
+
+	producer.mu.Lock()
+	defer producer.mu.Unlock()
+	assert.Equal(t, []int64{1, 2, 3}, producer.sequences[other.String()])
+	assert.Empty(t, producer.sequences[blocked.String()])
+
+	// Exhausting the retries dead-letters the event and copies it to the DLQ
+	require.Len(t, producer.deadLettered, 1)
+	key, err := producer.deadLettered[0].Key.Encode()
+	require.NoError(t, err)
+	assert.Equal(t, blocked.String(), string(key))
+
+	deadLettered, err := outboxRepo.ListDeadLettered(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, deadLettered, 1)
+	assert.True(t, deadLettered[0].IsDeadLettered())
+	require.NotNil(t, deadLettered[0].LastError)
+	assert.Equal(t, "broker unavailable", *deadLettered[0].LastError)
+}
+
+func TestOutboxPublisher_ReplayReleasesDeadLetteredAggregate(t *testing.T) {
+	pool := setupTestDatabase(t)
+	outboxRepo := repository.NewPostgresOutboxRepository(pool, zerolog.Nop())
+
+	aggregateID := uuid.New()
+	insertAggregateEvents(t, pool, outboxRepo, aggregateID, aggregateID)
+
+	// Broker rejects everything until it recovers
+	var recovered bool
+	producer := newRecordingProducer()
+	producer.fail = func(key string, sequence int64) error {
+		if !recovered {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}
+
+	publisher := NewOutboxPublisher(outboxRepo, NewKafkaSink(producer, zerolog.Nop()), fastRetryConfig(), nil, testMetrics(), zerolog.Nop())
+	ctx := context.Background()
+
+	var deadLettered []*models.OutboxEvent
+	require.Eventually(t, func() bool {
+		publisher.drain(ctx)
+		var err error
+		deadLettered, err = outboxRepo.ListDeadLettered(ctx, 10, 0)
+		return err == nil && len(deadLettered) == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	count, err := outboxRepo.CountDeadLettered(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Only dead-lettered events can be replayed
+	assert.ErrorIs(t, outboxRepo.ReplayDeadLettered(ctx, uuid.New()), models.ErrOutboxEventNotFound)
+
+	producer.mu.Lock()
+	recovered = true
+	producer.mu.Unlock()
+	require.NoError(t, outboxRepo.ReplayDeadLettered(ctx, deadLettered[0].ID))
+	assert.ErrorIs(t, outboxRepo.ReplayDeadLettered(ctx, deadLettered[0].ID), models.ErrOutboxNotDeadLettered)
+
+	require.Eventually(t, func() bool {
+		publisher.drain(ctx)
+		return countUnprocessed(pool) == 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	producer.mu.Lock()
+	defer producer.mu.Unlock()
+	assert.Equal(t, []int64{1, 2}, producer.sequences[aggregateID.String()])
+}
+
+func TestOutboxPublisher_RetriedEventKeepsAggregateOrder(t *testing.T) {
+	pool := setupTestDatabase(t)
+	outboxRepo := repository.NewPostgresOutboxRepository(pool, zerolog.Nop())
+
+	aggregateID := uuid.New()
+	insertAggregateEvents(t, pool, outboxRepo, aggregateID, aggregateID, aggregateID)
+
+	// Fail the first event once, later events must wait for its retry
+	failed := false
+	producer := newRecordingProducer()
+	producer.fail = func(key string, sequence int64) error {
+		if sequence == 1 && !failed {
+			failed = true
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}
+
+	publisher := NewOutboxPublisher(outboxRepo, NewKafkaSink(producer, zerolog.Nop()), fastRetryConfig(), nil, testMetrics(), zerolog.Nop())
+	ctx := context.Background()
+
+	require.Eventually(t, func() bool {
+		publisher.drain(ctx)
+		return countUnprocessed(pool) == 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	producer.mu.Lock()
+	defer producer.mu.Unlock()
+	assert.True(t, failed)
+	assert.Equal(t, []int64{1, 2, 3}, producer.sequences[aggregateID.String()])
+}
+
+func TestOutboxPublisher_NotificationWakesPublisher(t *testing.T) {
+	pool := setupTestDatabase(t)
+	outboxRepo := repository.NewPostgresOutboxRepository(pool, zerolog.Nop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notifier := NewOutboxNotifier(pool, zerolog.Nop())
+	go notifier.Start(ctx)
+
+	// Poll far too slowly to be what publishes the event
+	cfg := DefaultPublisherConfig()
+	cfg.PollInterval = time.Hour
+	producer := newRecordingProducer()
+	publisher := NewOutboxPublisher(outboxRepo, NewKafkaSink(producer, zerolog.Nop()), cfg, notifier.Wakeups(), testMetrics(), zerolog.Nop())
+	go publisher.Start(ctx)
+
+	// Let the initial wakeup from connecting pass before inserting
+	time.Sleep(200 * time.Millisecond)
+	aggregateIDs := insertEvents(t, pool, outboxRepo, 1)
+
+	require.Eventually(t, func() bool {
+		return producer.counts()[aggregateIDs[0].String()] == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestOutboxPublisher_FailedEventWaitsForBackoff(t *testing.T) {
+	pool := setupTestDatabase(t)
+	outboxRepo := repository.NewPostgresOutboxRepository(pool, zerolog.Nop())
+
+	aggregateIDs := insertEvents(t, pool, outboxRepo, 1)
+
+	attempts := 0
+	producer := newRecordingProducer()
+	producer.fail = func(key string, sequence int64) error {
+		attempts++
+		return errors.New("broker unavailable")
+	}
+
+	cfg := DefaultPublisherConfig()
+	cfg.RetryBackoff = RetryBackoff{Initial: time.Hour, Max: time.Hour, Multiplier: 2}
+	publisher := NewOutboxPublisher(outboxRepo, NewKafkaSink(producer, zerolog.Nop()), cfg, nil, testMetrics(), zerolog.Nop())
+	ctx := context.Background()
+
+	// Repeated drains make a single attempt, the retry isn't due for an hour
+	for i := 0; i < 5; i++ {
+		publisher.drain(ctx)
+	}
+	assert.Equal(t, 1, attempts)
+
+	var retries int
+	var dueIn float64
+	require.NoError(t, pool.QueryRow(ctx,
+		`SELECT retry_count, EXTRACT(EPOCH FROM next_attempt_at - NOW()::timestamp)
+		 FROM outbox_events WHERE aggregate_id = $1`,
+		aggregateIDs[0]).Scan(&retries, &dueIn))
+	assert.Equal(t, 1, retries)
+	assert.InDelta(t, time.Hour.Seconds(), dueIn, 60)
+}
+
+func TestOutboxPublisher_EncodesVersionedPayload(t *testing.T) {
+	placedAt := time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+	placed := &events.OrderPlacedV1{
+		OrderID:      uuid.NewString(),
+		UserID:       uuid.NewString(),
+		EventID:      "event-1",
+		BetType:      "back",
+		Selection:    "home",
+		Amount:       "10.00",
+		Odds:         "2.50",
+		PlacedAt:     placedAt,
+		Status:       "pending",
+		MatchesCount: 2,
+	}
+
+	tests := []struct {
+		name        string
+		encoding    string
+		contentType string
+	}{
+		{"protobuf", events.EncodingProtobuf, events.ContentTypeProtobuf},
+		{"json", events.EncodingJSON, events.ContentTypeJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := events.NewOutboxEvent(models.AggregateTypeOrder, uuid.New(), placed, nil)
+			require.NoError(t, err)
+			event.ID = uuid.New()
+
+			cfg := DefaultPublisherConfig()
+			cfg.Encoding = tt.encoding
+			producer := newRecordingProducer()
+			publisher := NewOutboxPublisher(nil, NewKafkaSink(producer, zerolog.Nop()), cfg, nil, testMetrics(), zerolog.Nop())
+
+			require.NoError(t, publisher.publishEvent(context.Background(), event))
+			require.Len(t, producer.messages, 1)
+
+			msg := producer.messages[0]
+			h := headers(msg)
+			assert.Equal(t, models.EventTypeOrderPlaced, h["event_type"])
+			assert.Equal(t, "1", h["schema_version"])
+			assert.Equal(t, tt.contentType, h["content_type"])
+			assert.Equal(t, "orderbook.events.OrderPlacedV1", h["schema"])
+
+			value, err := msg.Value.Encode()
+			require.NoError(t, err)
+			decoded, err := events.Unmarshal(h["event_type"], 1, h["content_type"], value)
+			require.NoError(t, err)
+			assert.Equal(t, placed, decoded)
+		})
+	}
+}
+
+func TestOutboxPublisher_UnknownSchemaPublishesRawJSON(t *testing.T) {
+	event := &models.OutboxEvent{
+		ID:            uuid.New(),
+		AggregateID:   uuid.New(),
+		AggregateType: models.AggregateTypeMatch,
+		EventType:     "bet.matched",
+		SchemaVersion: 1,
+		EventPayload:  map[string]interface{}{"match_id": "m-1"},
+	}
+
+	producer := newRecordingProducer()
+	publisher := NewOutboxPublisher(nil, NewKafkaSink(producer, zerolog.Nop()), DefaultPublisherConfig(), nil, testMetrics(), zerolog.Nop())
+
+	require.NoError(t, publisher.publishEvent(context.Background(), event))
+	require.Len(t, producer.messages, 1)
+
+	msg := producer.messages[0]
+	h := headers(msg)
+	assert.Equal(t, events.ContentTypeJSON, h["content_type"])
+	assert.NotContains(t, h, "schema")
+
+	value, err := msg.Value.Encode()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"match_id":"m-1"}`, string(value))
+}
@@ -0,0 +1,85 @@
+package messaging
+
+import (
+	"fmt"
+
+	"github.com/IBM/sarama"
+)
+
+// Delivery modes of the Kafka producer
+const (
+	// DeliveryAtLeastOnce retries sends, so broker retries and publisher
+	// crashes can both duplicate messages
+	DeliveryAtLeastOnce = "at_least_once"
+
+	// DeliveryIdempotent lets the broker discard duplicates caused by producer
+	// retries within a session
+	DeliveryIdempotent = "idempotent"
+
+	// DeliveryTransactional sends every message in its own Kafka transaction;
+	// read_committed consumers never see aborted attempts
+	DeliveryTransactional = "transactional"
+)
+
+// Headers telling consumers how to deduplicate
+// No producer mode covers a crash between a successful send and MarkProcessed,
+// the event is published again with the same dedup_key after its lease expires
+const (
+	// HeaderDedupKey carries the outbox event ID, stable across republishing
+	HeaderDedupKey = "dedup_key"
+
+	// HeaderDelivery carries the producer delivery mode
+	HeaderDelivery = "delivery"
+)
+
+// ProducerConfig configures the Kafka producer used by the outbox publisher
+type ProducerConfig struct {
+	Brokers         []string
+	Delivery        string // DeliveryAtLeastOnce, DeliveryIdempotent or DeliveryTransactional
+	TransactionalID string // Required for DeliveryTransactional, unique and stable per instance
+}
+
+// NewKafkaProducer creates a sync producer for the configured delivery mode
+func NewKafkaProducer(cfg ProducerConfig) (sarama.SyncProducer, error) {
+	config, err := newSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("create %s kafka producer: %w", cfg.Delivery, err)
+	}
+	return producer, nil
+}
+
+// newSaramaConfig builds the sarama settings of a delivery mode
+func newSaramaConfig(cfg ProducerConfig) (*sarama.Config, error) {
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = 3
+	config.Producer.Compression = sarama.CompressionSnappy
+
+	switch cfg.Delivery {
+	case DeliveryAtLeastOnce, "":
+	case DeliveryTransactional:
+		if cfg.TransactionalID == "" {
+			return nil, fmt.Errorf("transactional delivery requires a transactional id")
+		}
+		config.Producer.Transaction.ID = cfg.TransactionalID
+		fallthrough
+	case DeliveryIdempotent:
+		config.Version = sarama.V2_8_0_0
+		config.Producer.Idempotent = true
+		config.Producer.Retry.Max = 10
+		config.Net.MaxOpenRequests = 1
+	default:
+		return nil, fmt.Errorf("unsupported kafka delivery mode %q", cfg.Delivery)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s kafka producer config: %w", cfg.Delivery, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,128 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/cypherlabdev/order-book-service/internal/events"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSaramaConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		cfg             ProducerConfig
+		wantIdempotent  bool
+		wantTransaction string
+		wantErr         bool
+	}{
+		{name: "default", cfg: ProducerConfig{}},
+		{name: "at least once", cfg: ProducerConfig{Delivery: DeliveryAtLeastOnce}},
+		{name: "idempotent", cfg: ProducerConfig{Delivery: DeliveryIdempotent}, wantIdempotent: true},
+		{
+			name:            "transactional",
+			cfg:             ProducerConfig{Delivery: DeliveryTransactional, TransactionalID: "order-book-0"},
+			wantIdempotent:  true,
+			wantTransaction: "order-book-0",
+		},
+		{name: "transactional without id", cfg: ProducerConfig{Delivery: DeliveryTransactional}, wantErr: true},
+		{name: "unknown mode", cfg: ProducerConfig{Delivery: "exactly_once"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := newSaramaConfig(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIdempotent, config.Producer.Idempotent)
+			assert.Equal(t, tt.wantTransaction, config.Producer.Transaction.ID)
+			assert.Equal(t, sarama.WaitForAll, config.Producer.RequiredAcks)
+		})
+	}
+}
+
+// transactionalProducer is a recordingProducer that tracks Kafka transactions
+type transactionalProducer struct {
+	*recordingProducer
+	status    sarama.ProducerTxnStatusFlag
+	commitErr error
+	commits   int
+	aborts    int
+}
+
+func newTransactionalProducer() *transactionalProducer {
+	return &transactionalProducer{recordingProducer: newRecordingProducer(), status: sarama.ProducerTxnFlagReady}
+}
+
+func (p *transactionalProducer) IsTransactional() bool { return true }
+
+func (p *transactionalProducer) TxnStatus() sarama.ProducerTxnStatusFlag { return p.status }
+
+func (p *transactionalProducer) BeginTxn() error {
+	p.status = sarama.ProducerTxnFlagInTransaction
+	return nil
+}
+
+func (p *transactionalProducer) CommitTxn() error {
+	if p.commitErr != nil {
+		p.status |= sarama.ProducerTxnFlagAbortableError
+		return p.commitErr
+	}
+	p.commits++
+	p.status = sarama.ProducerTxnFlagReady
+	return nil
+}
+
+func (p *transactionalProducer) AbortTxn() error {
+	p.aborts++
+	p.status = sarama.ProducerTxnFlagReady
+	return nil
+}
+
+func transactionalTestEvent(t *testing.T) *models.OutboxEvent {
+	event, err := events.NewOutboxEvent(models.AggregateTypeOrder, uuid.New(), &events.OrderCancelledV1{
+		OrderID: uuid.NewString(),
+	}, nil)
+	require.NoError(t, err)
+	event.ID = uuid.New()
+	return event
+}
+
+func TestOutboxPublisher_TransactionalSendCommits(t *testing.T) {
+	producer := newTransactionalProducer()
+	cfg := DefaultPublisherConfig()
+	cfg.Delivery = DeliveryTransactional
+	publisher := NewOutboxPublisher(nil, NewKafkaSink(producer, zerolog.Nop()), cfg, nil, testMetrics(), zerolog.Nop())
+
+	event := transactionalTestEvent(t)
+	require.NoError(t, publisher.publishEvent(context.Background(), event))
+
+	assert.Equal(t, 1, producer.commits)
+	assert.Zero(t, producer.aborts)
+	require.Len(t, producer.messages, 1)
+	h := headers(producer.messages[0])
+	assert.Equal(t, event.ID.String(), h[HeaderDedupKey])
+	assert.Equal(t, DeliveryTransactional, h[HeaderDelivery])
+}
+
+func TestOutboxPublisher_TransactionalCommitFailureAborts(t *testing.T) {
+	producer := newTransactionalProducer()
+	producer.commitErr = errors.New("coordinator unavailable")
+	publisher := NewOutboxPublisher(nil, NewKafkaSink(producer, zerolog.Nop()), DefaultPublisherConfig(), nil, testMetrics(), zerolog.Nop())
+
+	err := publisher.publishEvent(context.Background(), transactionalTestEvent(t))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, producer.commitErr)
+
+	assert.Equal(t, 1, producer.aborts)
+	assert.Equal(t, sarama.ProducerTxnFlagReady, producer.status)
+}
@@ -0,0 +1,74 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog"
+)
+
+// Event sink types
+const (
+	SinkKafka = "kafka"
+	SinkNATS  = "nats"
+	SinkFile  = "file"
+)
+
+// Header is a message header, kept in order
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Message is a published event, independent of the transport
+type Message struct {
+	ID      string // Outbox event ID, used for broker side deduplication where supported
+	Topic   string // Kafka topic, NATS subject suffix or file record field
+	Key     string // Ordering key, the aggregate ID
+	Value   []byte
+	Headers []Header
+}
+
+// EventSink delivers outbox messages to a transport
+type EventSink interface {
+	// Send delivers a message and returns once the transport has accepted it
+	Send(ctx context.Context, msg *Message) error
+
+	// Name returns the messaging system name, used for tracing and logs
+	Name() string
+
+	// Close releases the transport connection
+	Close() error
+}
+
+// SinkConfig selects and configures the event sink
+type SinkConfig struct {
+	Type  string // SinkKafka, SinkNATS or SinkFile
+	Kafka ProducerConfig
+	NATS  NATSConfig
+	File  string // Path of the JSON lines file, "stdout" or "-" for standard output
+}
+
+// NewEventSink creates the configured event sink
+func NewEventSink(cfg SinkConfig, logger zerolog.Logger) (EventSink, error) {
+	switch cfg.Type {
+	case SinkKafka, "":
+		producer, err := NewKafkaProducer(cfg.Kafka)
+		if err != nil {
+			return nil, err
+		}
+		return NewKafkaSink(producer, logger), nil
+	case SinkNATS:
+		return NewNATSSink(cfg.NATS, logger)
+	case SinkFile:
+		return NewFileSink(cfg.File)
+	default:
+		return nil, fmt.Errorf("unsupported event sink %q", cfg.Type)
+	}
+}
+
+// nopCloser adapts writers that must not be closed, such as stdout
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
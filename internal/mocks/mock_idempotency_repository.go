@@ -15,6 +15,7 @@ import (
 	reflect "reflect"
 	time "time"
 
+	uuid "github.com/google/uuid"
 	v5 "github.com/jackc/pgx/v5"
 	gomock "go.uber.org/mock/gomock"
 )
@@ -43,61 +44,75 @@ func (m *MockIdempotencyRepository) EXPECT() *MockIdempotencyRepositoryMockRecor
 	return m.recorder
 }
 
-// Check mocks base method.
-func (m *MockIdempotencyRepository) Check(ctx context.Context, key, requestHash string) (json.RawMessage, bool, error) {
+// Claim mocks base method.
+func (m *MockIdempotencyRepository) Claim(ctx context.Context, key string, claimToken uuid.UUID, requestHash string, lease time.Duration) (json.RawMessage, bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Check", ctx, key, requestHash)
+	ret := m.ctrl.Call(m, "Claim", ctx, key, claimToken, requestHash, lease)
 	ret0, _ := ret[0].(json.RawMessage)
 	ret1, _ := ret[1].(bool)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
 
-// Check indicates an expected call of Check.
-func (mr *MockIdempotencyRepositoryMockRecorder) Check(ctx, key, requestHash any) *gomock.Call {
+// Claim indicates an expected call of Claim.
+func (mr *MockIdempotencyRepositoryMockRecorder) Claim(ctx, key, claimToken, requestHash, lease any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockIdempotencyRepository)(nil).Check), ctx, key, requestHash)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Claim", reflect.TypeOf((*MockIdempotencyRepository)(nil).Claim), ctx, key, claimToken, requestHash, lease)
 }
 
 // CleanupExpired mocks base method.
-func (m *MockIdempotencyRepository) CleanupExpired(ctx context.Context) (int64, error) {
+func (m *MockIdempotencyRepository) CleanupExpired(ctx context.Context, limit int) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CleanupExpired", ctx)
+	ret := m.ctrl.Call(m, "CleanupExpired", ctx, limit)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CleanupExpired indicates an expected call of CleanupExpired.
-func (mr *MockIdempotencyRepositoryMockRecorder) CleanupExpired(ctx any) *gomock.Call {
+func (mr *MockIdempotencyRepositoryMockRecorder) CleanupExpired(ctx, limit any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanupExpired", reflect.TypeOf((*MockIdempotencyRepository)(nil).CleanupExpired), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanupExpired", reflect.TypeOf((*MockIdempotencyRepository)(nil).CleanupExpired), ctx, limit)
+}
+
+// Release mocks base method.
+func (m *MockIdempotencyRepository) Release(ctx context.Context, key string, claimToken uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx, key, claimToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockIdempotencyRepositoryMockRecorder) Release(ctx, key, claimToken any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockIdempotencyRepository)(nil).Release), ctx, key, claimToken)
 }
 
 // Store mocks base method.
-func (m *MockIdempotencyRepository) Store(ctx context.Context, key, requestHash string, responseData any, ttl time.Duration) error {
+func (m *MockIdempotencyRepository) Store(ctx context.Context, key string, claimToken uuid.UUID, requestHash string, responseData any, ttl time.Duration) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Store", ctx, key, requestHash, responseData, ttl)
+	ret := m.ctrl.Call(m, "Store", ctx, key, claimToken, requestHash, responseData, ttl)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Store indicates an expected call of Store.
-func (mr *MockIdempotencyRepositoryMockRecorder) Store(ctx, key, requestHash, responseData, ttl any) *gomock.Call {
+func (mr *MockIdempotencyRepositoryMockRecorder) Store(ctx, key, claimToken, requestHash, responseData, ttl any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Store", reflect.TypeOf((*MockIdempotencyRepository)(nil).Store), ctx, key, requestHash, responseData, ttl)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Store", reflect.TypeOf((*MockIdempotencyRepository)(nil).Store), ctx, key, claimToken, requestHash, responseData, ttl)
 }
 
 // StoreInTransaction mocks base method.
-func (m *MockIdempotencyRepository) StoreInTransaction(ctx context.Context, tx v5.Tx, key, requestHash string, responseData any, ttl time.Duration) error {
+func (m *MockIdempotencyRepository) StoreInTransaction(ctx context.Context, tx v5.Tx, key string, claimToken uuid.UUID, requestHash string, responseData any, ttl time.Duration) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "StoreInTransaction", ctx, tx, key, requestHash, responseData, ttl)
+	ret := m.ctrl.Call(m, "StoreInTransaction", ctx, tx, key, claimToken, requestHash, responseData, ttl)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // StoreInTransaction indicates an expected call of StoreInTransaction.
-func (mr *MockIdempotencyRepositoryMockRecorder) StoreInTransaction(ctx, tx, key, requestHash, responseData, ttl any) *gomock.Call {
+func (mr *MockIdempotencyRepositoryMockRecorder) StoreInTransaction(ctx, tx, key, claimToken, requestHash, responseData, ttl any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreInTransaction", reflect.TypeOf((*MockIdempotencyRepository)(nil).StoreInTransaction), ctx, tx, key, requestHash, responseData, ttl)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreInTransaction", reflect.TypeOf((*MockIdempotencyRepository)(nil).StoreInTransaction), ctx, tx, key, claimToken, requestHash, responseData, ttl)
 }
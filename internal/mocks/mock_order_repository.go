@@ -72,6 +72,20 @@ func (mr *MockOrderRepositoryMockRecorder) Create(ctx, tx, order any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockOrderRepository)(nil).Create), ctx, tx, order)
 }
 
+// CreateMatch mocks base method.
+func (m *MockOrderRepository) CreateMatch(ctx context.Context, tx v5.Tx, match *models.Match) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMatch", ctx, tx, match)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateMatch indicates an expected call of CreateMatch.
+func (mr *MockOrderRepositoryMockRecorder) CreateMatch(ctx, tx, match any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMatch", reflect.TypeOf((*MockOrderRepository)(nil).CreateMatch), ctx, tx, match)
+}
+
 // GetByID mocks base method.
 func (m *MockOrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Order, error) {
 	m.ctrl.T.Helper()
@@ -147,6 +161,36 @@ func (mr *MockOrderRepositoryMockRecorder) GetByUserID(ctx, userID, limit, offse
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUserID", reflect.TypeOf((*MockOrderRepository)(nil).GetByUserID), ctx, userID, limit, offset)
 }
 
+// GetMatchesByMarketID mocks base method.
+func (m *MockOrderRepository) GetMatchesByMarketID(ctx context.Context, marketID string) ([]*models.Match, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMatchesByMarketID", ctx, marketID)
+	ret0, _ := ret[0].([]*models.Match)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMatchesByMarketID indicates an expected call of GetMatchesByMarketID.
+func (mr *MockOrderRepositoryMockRecorder) GetMatchesByMarketID(ctx, marketID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMatchesByMarketID", reflect.TypeOf((*MockOrderRepository)(nil).GetMatchesByMarketID), ctx, marketID)
+}
+
+// GetMatchesByOrderID mocks base method.
+func (m *MockOrderRepository) GetMatchesByOrderID(ctx context.Context, tx v5.Tx, orderID uuid.UUID) ([]*models.Match, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMatchesByOrderID", ctx, tx, orderID)
+	ret0, _ := ret[0].([]*models.Match)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMatchesByOrderID indicates an expected call of GetMatchesByOrderID.
+func (mr *MockOrderRepositoryMockRecorder) GetMatchesByOrderID(ctx, tx, orderID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMatchesByOrderID", reflect.TypeOf((*MockOrderRepository)(nil).GetMatchesByOrderID), ctx, tx, orderID)
+}
+
 // GetPendingOrders mocks base method.
 func (m *MockOrderRepository) GetPendingOrders(ctx context.Context, marketID string) ([]*models.Order, error) {
 	m.ctrl.T.Helper()
@@ -162,6 +206,35 @@ func (mr *MockOrderRepositoryMockRecorder) GetPendingOrders(ctx, marketID any) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingOrders", reflect.TypeOf((*MockOrderRepository)(nil).GetPendingOrders), ctx, marketID)
 }
 
+// GetUnsettledMatchedOrders mocks base method.
+func (m *MockOrderRepository) GetUnsettledMatchedOrders(ctx context.Context, marketID string) ([]*models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUnsettledMatchedOrders", ctx, marketID)
+	ret0, _ := ret[0].([]*models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUnsettledMatchedOrders indicates an expected call of GetUnsettledMatchedOrders.
+func (mr *MockOrderRepositoryMockRecorder) GetUnsettledMatchedOrders(ctx, marketID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnsettledMatchedOrders", reflect.TypeOf((*MockOrderRepository)(nil).GetUnsettledMatchedOrders), ctx, marketID)
+}
+
+// ReverseMatch mocks base method.
+func (m *MockOrderRepository) ReverseMatch(ctx context.Context, tx v5.Tx, matchID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseMatch", ctx, tx, matchID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReverseMatch indicates an expected call of ReverseMatch.
+func (mr *MockOrderRepositoryMockRecorder) ReverseMatch(ctx, tx, matchID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseMatch", reflect.TypeOf((*MockOrderRepository)(nil).ReverseMatch), ctx, tx, matchID)
+}
+
 // Settle mocks base method.
 func (m *MockOrderRepository) Settle(ctx context.Context, tx v5.Tx, id uuid.UUID, result string, actualPayout decimal.Decimal) error {
 	m.ctrl.T.Helper()
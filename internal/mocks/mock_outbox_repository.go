@@ -44,19 +44,49 @@ func (m *MockOutboxRepository) EXPECT() *MockOutboxRepositoryMockRecorder {
 	return m.recorder
 }
 
+// ClaimUnprocessedEvents mocks base method.
+func (m *MockOutboxRepository) ClaimUnprocessedEvents(ctx context.Context, owner string, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimUnprocessedEvents", ctx, owner, limit, lease)
+	ret0, _ := ret[0].([]*models.OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimUnprocessedEvents indicates an expected call of ClaimUnprocessedEvents.
+func (mr *MockOutboxRepositoryMockRecorder) ClaimUnprocessedEvents(ctx, owner, limit, lease any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimUnprocessedEvents", reflect.TypeOf((*MockOutboxRepository)(nil).ClaimUnprocessedEvents), ctx, owner, limit, lease)
+}
+
 // CleanupProcessedEvents mocks base method.
-func (m *MockOutboxRepository) CleanupProcessedEvents(ctx context.Context, olderThan time.Duration) (int64, error) {
+func (m *MockOutboxRepository) CleanupProcessedEvents(ctx context.Context, olderThan time.Duration, limit int) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CleanupProcessedEvents", ctx, olderThan)
+	ret := m.ctrl.Call(m, "CleanupProcessedEvents", ctx, olderThan, limit)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CleanupProcessedEvents indicates an expected call of CleanupProcessedEvents.
-func (mr *MockOutboxRepositoryMockRecorder) CleanupProcessedEvents(ctx, olderThan any) *gomock.Call {
+func (mr *MockOutboxRepositoryMockRecorder) CleanupProcessedEvents(ctx, olderThan, limit any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanupProcessedEvents", reflect.TypeOf((*MockOutboxRepository)(nil).CleanupProcessedEvents), ctx, olderThan)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanupProcessedEvents", reflect.TypeOf((*MockOutboxRepository)(nil).CleanupProcessedEvents), ctx, olderThan, limit)
+}
+
+// CountDeadLettered mocks base method.
+func (m *MockOutboxRepository) CountDeadLettered(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountDeadLettered", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountDeadLettered indicates an expected call of CountDeadLettered.
+func (mr *MockOutboxRepositoryMockRecorder) CountDeadLettered(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountDeadLettered", reflect.TypeOf((*MockOutboxRepository)(nil).CountDeadLettered), ctx)
 }
 
 // Create mocks base method.
@@ -73,45 +103,90 @@ func (mr *MockOutboxRepositoryMockRecorder) Create(ctx, tx, event any) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockOutboxRepository)(nil).Create), ctx, tx, event)
 }
 
-// GetUnprocessedEvents mocks base method.
-func (m *MockOutboxRepository) GetUnprocessedEvents(ctx context.Context, limit int) ([]*models.OutboxEvent, error) {
+// GetByID mocks base method.
+func (m *MockOutboxRepository) GetByID(ctx context.Context, eventID uuid.UUID) (*models.OutboxEvent, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUnprocessedEvents", ctx, limit)
-	ret0, _ := ret[0].([]*models.OutboxEvent)
+	ret := m.ctrl.Call(m, "GetByID", ctx, eventID)
+	ret0, _ := ret[0].(*models.OutboxEvent)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUnprocessedEvents indicates an expected call of GetUnprocessedEvents.
-func (mr *MockOutboxRepositoryMockRecorder) GetUnprocessedEvents(ctx, limit any) *gomock.Call {
+// GetByID indicates an expected call of GetByID.
+func (mr *MockOutboxRepositoryMockRecorder) GetByID(ctx, eventID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnprocessedEvents", reflect.TypeOf((*MockOutboxRepository)(nil).GetUnprocessedEvents), ctx, limit)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockOutboxRepository)(nil).GetByID), ctx, eventID)
 }
 
 // IncrementRetryCount mocks base method.
-func (m *MockOutboxRepository) IncrementRetryCount(ctx context.Context, eventID uuid.UUID, errorMsg string) error {
+func (m *MockOutboxRepository) IncrementRetryCount(ctx context.Context, eventID uuid.UUID, owner, errorMsg string, retryAfter time.Duration) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IncrementRetryCount", ctx, eventID, errorMsg)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "IncrementRetryCount", ctx, eventID, owner, errorMsg, retryAfter)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // IncrementRetryCount indicates an expected call of IncrementRetryCount.
-func (mr *MockOutboxRepositoryMockRecorder) IncrementRetryCount(ctx, eventID, errorMsg any) *gomock.Call {
+func (mr *MockOutboxRepositoryMockRecorder) IncrementRetryCount(ctx, eventID, owner, errorMsg, retryAfter any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementRetryCount", reflect.TypeOf((*MockOutboxRepository)(nil).IncrementRetryCount), ctx, eventID, errorMsg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementRetryCount", reflect.TypeOf((*MockOutboxRepository)(nil).IncrementRetryCount), ctx, eventID, owner, errorMsg, retryAfter)
+}
+
+// ListDeadLettered mocks base method.
+func (m *MockOutboxRepository) ListDeadLettered(ctx context.Context, limit, offset int) ([]*models.OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeadLettered", ctx, limit, offset)
+	ret0, _ := ret[0].([]*models.OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeadLettered indicates an expected call of ListDeadLettered.
+func (mr *MockOutboxRepositoryMockRecorder) ListDeadLettered(ctx, limit, offset any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeadLettered", reflect.TypeOf((*MockOutboxRepository)(nil).ListDeadLettered), ctx, limit, offset)
+}
+
+// ListMarketEvents mocks base method.
+func (m *MockOutboxRepository) ListMarketEvents(ctx context.Context, marketID string) ([]*models.OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMarketEvents", ctx, marketID)
+	ret0, _ := ret[0].([]*models.OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMarketEvents indicates an expected call of ListMarketEvents.
+func (mr *MockOutboxRepositoryMockRecorder) ListMarketEvents(ctx, marketID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMarketEvents", reflect.TypeOf((*MockOutboxRepository)(nil).ListMarketEvents), ctx, marketID)
 }
 
 // MarkProcessed mocks base method.
-func (m *MockOutboxRepository) MarkProcessed(ctx context.Context, eventID uuid.UUID) error {
+func (m *MockOutboxRepository) MarkProcessed(ctx context.Context, eventID uuid.UUID, owner string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MarkProcessed", ctx, eventID)
+	ret := m.ctrl.Call(m, "MarkProcessed", ctx, eventID, owner)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // MarkProcessed indicates an expected call of MarkProcessed.
-func (mr *MockOutboxRepositoryMockRecorder) MarkProcessed(ctx, eventID any) *gomock.Call {
+func (mr *MockOutboxRepositoryMockRecorder) MarkProcessed(ctx, eventID, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkProcessed", reflect.TypeOf((*MockOutboxRepository)(nil).MarkProcessed), ctx, eventID, owner)
+}
+
+// ReplayDeadLettered mocks base method.
+func (m *MockOutboxRepository) ReplayDeadLettered(ctx context.Context, eventID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayDeadLettered", ctx, eventID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplayDeadLettered indicates an expected call of ReplayDeadLettered.
+func (mr *MockOutboxRepositoryMockRecorder) ReplayDeadLettered(ctx, eventID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkProcessed", reflect.TypeOf((*MockOutboxRepository)(nil).MarkProcessed), ctx, eventID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayDeadLettered", reflect.TypeOf((*MockOutboxRepository)(nil).ReplayDeadLettered), ctx, eventID)
 }
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EngineLeader records which replica leads the matching engine
+// Epoch grows with every election; writes made under an earlier epoch are fenced off
+type EngineLeader struct {
+	Name      string    `json:"name"`       // Election name
+	Epoch     int64     `json:"epoch"`      // Fencing token of the leadership
+	EngineID  string    `json:"engine_id"`  // Replica that won the election
+	JournalID uuid.UUID `json:"journal_id"` // Journal the leader sequences into
+	Address   string    `json:"address"`    // Base URL of the leader's replication stream
+	ElectedAt time.Time `json:"elected_at"`
+}
+
+// ShardLayout is how many replicas the markets are spread over
+// Version grows with every change, replicas rebalance when they see a new one
+type ShardLayout struct {
+	Name      string    `json:"name"`     // Layout name, shared by all replicas
+	Replicas  int       `json:"replicas"` // Pod ordinals 0 to Replicas-1 own markets
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
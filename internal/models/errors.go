@@ -4,8 +4,70 @@ import "errors"
 
 // Repository errors
 var (
-	ErrOrderNotFound       = errors.New("order not found")
-	ErrOptimisticLock      = errors.New("optimistic lock failure: version mismatch")
-	ErrIdempotencyMismatch = errors.New("idempotency key exists with different request hash")
-	ErrInvalidOrderStatus  = errors.New("invalid order status for operation")
+	ErrOrderNotFound         = errors.New("order not found")
+	ErrOptimisticLock        = errors.New("optimistic lock failure: version mismatch")
+	ErrIdempotencyMismatch   = errors.New("idempotency key exists with different request hash")
+	ErrIdempotencyInProgress = errors.New("request with this idempotency key is still in progress")
+	ErrIdempotencyClaimLost  = errors.New("idempotency key was claimed by a retry after the lease expired")
+	ErrInvalidOrderStatus    = errors.New("invalid order status for operation")
+	ErrInvalidAmendment      = errors.New("invalid order amendment")
+	ErrSagaCompensated       = errors.New("saga has already been compensated")
 )
+
+// Outbox errors
+var (
+	ErrOutboxEventNotFound   = errors.New("outbox event not found")
+	ErrOutboxNotDeadLettered = errors.New("outbox event is not dead-lettered")
+)
+
+// Wallet reservation errors
+var (
+	ErrReservationRequired     = errors.New("wallet reservation is required")
+	ErrReservationMismatch     = errors.New("wallet reservation belongs to a different user")
+	ErrInsufficientReservation = errors.New("wallet reservation does not cover order liability")
+)
+
+// Market errors
+var (
+	ErrMarketSuspended         = errors.New("market is suspended")
+	ErrMarketClosed            = errors.New("market is closed")
+	ErrInvalidMarketTransition = errors.New("invalid market status transition")
+)
+
+// Engine replication errors
+var (
+	ErrNotLeader = errors.New("matching engine is a standby, not the leader")
+	ErrFenced    = errors.New("matching engine leadership has passed to another replica")
+)
+
+// Engine sharding errors
+var (
+	ErrNotMarketOwner = errors.New("market is owned by another replica")
+)
+
+// errorCodes gives domain errors a stable code so a stored failure can be replayed
+// Only errors that a retry of the same request cannot fix are listed, transient ones
+// such as a suspended market or a short reservation release the key instead
+var errorCodes = map[string]error{
+	"order_not_found":      ErrOrderNotFound,
+	"invalid_order_status": ErrInvalidOrderStatus,
+	"saga_compensated":     ErrSagaCompensated,
+	"reservation_required": ErrReservationRequired,
+	"reservation_mismatch": ErrReservationMismatch,
+	"market_closed":        ErrMarketClosed,
+}
+
+// ErrorCode returns the code of the domain error err wraps, "" if it has none
+func ErrorCode(err error) string {
+	for code, domainErr := range errorCodes {
+		if errors.Is(err, domainErr) {
+			return code
+		}
+	}
+	return ""
+}
+
+// ErrorFromCode returns the domain error with the given code, nil if the code is unknown
+func ErrorFromCode(code string) error {
+	return errorCodes[code]
+}
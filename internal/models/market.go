@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// MarketStatus represents the trading state of a market
+type MarketStatus string
+
+const (
+	MarketStatusOpen      MarketStatus = "OPEN"      // Accepting orders
+	MarketStatusSuspended MarketStatus = "SUSPENDED" // Temporarily not accepting orders
+	MarketStatusClosed    MarketStatus = "CLOSED"    // No more orders, resting orders expired
+	MarketStatusSettled   MarketStatus = "SETTLED"   // Result known, matched orders settled
+)
+
+// Market holds the state of a market as driven by the upstream feed
+// Markets without a row are open
+type Market struct {
+	ID                 string       `json:"id"`
+	Status             MarketStatus `json:"status"`
+	WinningSelectionID *string      `json:"winning_selection_id,omitempty"`
+	UpdatedAt          time.Time    `json:"updated_at"`
+}
+
+// AcceptsOrders returns true if orders may be placed on the market
+func (m *Market) AcceptsOrders() bool {
+	return m.Status == MarketStatusOpen
+}
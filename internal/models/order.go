@@ -24,6 +24,9 @@ const (
 	OrderStatusPartially OrderStatus = "PARTIALLY" // Partially matched
 	OrderStatusCancelled OrderStatus = "CANCELLED" // Cancelled by user or system
 	OrderStatusExpired   OrderStatus = "EXPIRED"   // Market closed before match
+	OrderStatusCompensated OrderStatus = "COMPENSATED" // Placement undone by saga compensation
+	OrderStatusSettledWin  OrderStatus = "settled_win"  // Settled as a winning bet
+	OrderStatusSettledLoss OrderStatus = "settled_loss" // Settled as a losing bet
 )
 
 // Order represents an order in the order book
@@ -47,6 +50,25 @@ type Order struct {
 	Version         int64           `json:"version"`           // Optimistic locking
 }
 
+// Liability returns the amount the order risks if fully matched
+func (o *Order) Liability() decimal.Decimal {
+	return Liability(o.Side, o.Price, o.Size)
+}
+
+// RemainingLiability returns the amount still held for the unmatched portion
+func (o *Order) RemainingLiability() decimal.Decimal {
+	return Liability(o.Side, o.Price, o.SizeRemaining)
+}
+
+// Liability returns what a bettor risks for a stake at the given odds
+// Back bettors risk their stake, lay bettors risk stake * (odds - 1)
+func Liability(side OrderSide, price, size decimal.Decimal) decimal.Decimal {
+	if side == OrderSideLay {
+		return size.Mul(price.Sub(decimal.NewFromInt(1)))
+	}
+	return size
+}
+
 // Match represents a matched trade between two orders
 type Match struct {
 	ID           uuid.UUID       `json:"id"`
@@ -56,12 +78,29 @@ type Match struct {
 	LayOrderID   uuid.UUID       `json:"lay_order_id"`
 	BackUserID   uuid.UUID       `json:"back_user_id"`
 	LayUserID    uuid.UUID       `json:"lay_user_id"`
+	BackReservationID string     `json:"back_reservation_id"` // Wallet reservation of back order
+	LayReservationID  string     `json:"lay_reservation_id"`  // Wallet reservation of lay order
 	Price        decimal.Decimal `json:"price"`           // Matched odds
 	Size         decimal.Decimal `json:"size"`            // Matched stake
 	BackLiability decimal.Decimal `json:"back_liability"` // Back bettor's risk
 	LayLiability  decimal.Decimal `json:"lay_liability"`  // Lay bettor's risk
 	MatchedAt    time.Time       `json:"matched_at"`
 	SettledAt    *time.Time      `json:"settled_at,omitempty"`
+	ReversedAt   *time.Time      `json:"reversed_at,omitempty"` // Set when undone by saga compensation
+	Sequence     int64           `json:"sequence"`              // Position in the market's event sequence
+}
+
+// CounterpartyOrderID returns the ID of the other order in the match
+func (m *Match) CounterpartyOrderID(orderID uuid.UUID) uuid.UUID {
+	if m.BackOrderID == orderID {
+		return m.LayOrderID
+	}
+	return m.BackOrderID
+}
+
+// IsReversed returns true if the match has been undone
+func (m *Match) IsReversed() bool {
+	return m.ReversedAt != nil
 }
 
 // MarketBook represents the order book for a specific market
@@ -8,24 +8,39 @@ import (
 
 // OutboxEvent represents an event to be published to Kafka via the transactional outbox pattern
 type OutboxEvent struct {
-	ID            uuid.UUID              `json:"id" db:"id"`
-	AggregateID   uuid.UUID              `json:"aggregate_id" db:"aggregate_id"`
-	AggregateType string                 `json:"aggregate_type" db:"aggregate_type"`
-	EventType     string                 `json:"event_type" db:"event_type"`
-	EventPayload  map[string]interface{} `json:"event_payload" db:"event_payload"`
-	SagaID        *uuid.UUID             `json:"saga_id,omitempty" db:"saga_id"`
-	CreatedAt     time.Time              `json:"created_at" db:"created_at"`
-	ProcessedAt   *time.Time             `json:"processed_at,omitempty" db:"processed_at"`
-	RetryCount    int                    `json:"retry_count" db:"retry_count"`
-	MaxRetries    int                    `json:"max_retries" db:"max_retries"`
-	LastError     *string                `json:"last_error,omitempty" db:"last_error"`
+	ID                uuid.UUID              `json:"id" db:"id"`
+	AggregateID       uuid.UUID              `json:"aggregate_id" db:"aggregate_id"`
+	AggregateType     string                 `json:"aggregate_type" db:"aggregate_type"`
+	AggregateSequence int64                  `json:"aggregate_sequence" db:"aggregate_sequence"` // Position within the aggregate's event stream
+	EventType         string                 `json:"event_type" db:"event_type"`
+	SchemaVersion     int                    `json:"schema_version" db:"schema_version"` // Version of the payload schema in internal/events
+	EventPayload      map[string]interface{} `json:"event_payload" db:"event_payload"`
+	SagaID            *uuid.UUID             `json:"saga_id,omitempty" db:"saga_id"`
+	CreatedAt         time.Time              `json:"created_at" db:"created_at"`
+	ProcessedAt       *time.Time             `json:"processed_at,omitempty" db:"processed_at"`
+	RetryCount        int                    `json:"retry_count" db:"retry_count"`
+	MaxRetries        int                    `json:"max_retries" db:"max_retries"`
+	LastError         *string                `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt     *time.Time             `json:"next_attempt_at,omitempty" db:"next_attempt_at"`   // Retry backoff, nil when due immediately
+	DeadLetteredAt    *time.Time             `json:"dead_lettered_at,omitempty" db:"dead_lettered_at"` // Set once retries are exhausted
+	TraceParent       *string                `json:"trace_parent,omitempty" db:"trace_parent"`         // W3C trace context of the writing request
+	TraceState        *string                `json:"trace_state,omitempty" db:"trace_state"`
+	MarketSequence    *int64                 `json:"market_sequence,omitempty" db:"market_sequence"` // Position in the market's event sequence, nil for events outside it
 }
 
+// DefaultOutboxMaxRetries is the publish attempt limit applied when an event doesn't set one
+const DefaultOutboxMaxRetries = 8
+
 // IsProcessed returns true if the event has been successfully published
 func (e *OutboxEvent) IsProcessed() bool {
 	return e.ProcessedAt != nil
 }
 
+// IsDeadLettered returns true if the event exhausted its retries and awaits replay
+func (e *OutboxEvent) IsDeadLettered() bool {
+	return e.DeadLetteredAt != nil
+}
+
 // CanRetry returns true if the event can be retried
 func (e *OutboxEvent) CanRetry() bool {
 	return e.RetryCount < e.MaxRetries
@@ -39,12 +54,14 @@ const (
 
 // EventType constants for order book service
 const (
-	EventTypeOrderPlaced     = "order.placed"
-	EventTypeOrderMatched    = "order.matched"
-	EventTypeOrderPartial    = "order.partially_matched"
-	EventTypeOrderCancelled  = "order.cancelled"
-	EventTypeOrderExpired    = "order.expired"
-	EventTypeMatchCreated    = "match.created"
-	EventTypeMatchSettled    = "match.settled"
-	EventTypeOrderSettled    = "order.settled"
+	EventTypeOrderPlaced      = "order.placed"
+	EventTypeOrderMatched     = "order.matched"
+	EventTypeOrderPartial     = "order.partially_matched"
+	EventTypeOrderCancelled   = "order.cancelled"
+	EventTypeOrderExpired     = "order.expired"
+	EventTypeMatchCreated     = "match.created"
+	EventTypeMatchSettled     = "match.settled"
+	EventTypeOrderSettled     = "order.settled"
+	EventTypeOrderCompensated = "order.compensated"
+	EventTypeMatchReversed    = "match.reversed"
 )
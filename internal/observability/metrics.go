@@ -17,6 +17,9 @@ type Metrics struct {
 	OrderAmountTotal      prometheus.Counter
 	OrderPayoutTotal      prometheus.Counter
 
+	// Saga compensation
+	SagaCompensationsTotal *prometheus.CounterVec
+
 	// Active orders gauge
 	ActiveOrders          prometheus.Gauge
 
@@ -31,6 +34,35 @@ type Metrics struct {
 	// Outbox publisher
 	OutboxEventsPublished *prometheus.CounterVec
 	OutboxEventsFailed    *prometheus.CounterVec
+	OutboxEventsDeadLettered *prometheus.CounterVec
+	OutboxEventsReplayed     prometheus.Counter
+	OutboxDeadLetterBacklog  prometheus.Gauge
+
+	// Command consumer
+	CommandsProcessed         *prometheus.CounterVec
+	CommandProcessingDuration *prometheus.HistogramVec
+
+	// Market status feed
+	MarketStatusMessages *prometheus.CounterVec
+	OrdersExpiredTotal   prometheus.Counter
+
+	// Maintenance jobs
+	MaintenanceRowsDeleted *prometheus.CounterVec
+	MaintenanceRunDuration *prometheus.HistogramVec
+	MaintenanceErrors      *prometheus.CounterVec
+	MaintenanceLeader      prometheus.Gauge
+
+	// Engine replication
+	EngineLeader         prometheus.Gauge
+	EngineReplicationLag prometheus.Gauge
+
+	// Engine sharding
+	EngineShardMarkets   prometheus.Gauge
+	EngineShardHandoffs  *prometheus.CounterVec
+	EngineForwardedCalls *prometheus.CounterVec
+
+	// Wallet integration
+	WalletOperationErrors *prometheus.CounterVec
 }
 
 // NewMetrics creates and registers all Prometheus metrics with the default registry
@@ -71,6 +103,13 @@ func NewMetricsWithRegistry(reg prometheus.Registerer) *Metrics {
 			},
 			[]string{"match_type"}, // full, partial
 		),
+		SagaCompensationsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "orderbook_saga_compensations_total",
+				Help: "Total number of saga compensation requests",
+			},
+			[]string{"outcome"}, // compensated, no_order, already_compensated
+		),
 		OrderAmountTotal: factory.NewCounter(
 			prometheus.CounterOpts{
 				Name: "orderbook_order_amount_total",
@@ -134,5 +173,119 @@ func NewMetricsWithRegistry(reg prometheus.Registerer) *Metrics {
 			},
 			[]string{"event_type"},
 		),
+		OutboxEventsDeadLettered: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "orderbook_outbox_events_dead_lettered_total",
+				Help: "Total number of outbox events dead-lettered after exhausting retries",
+			},
+			[]string{"event_type"},
+		),
+		OutboxEventsReplayed: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "orderbook_outbox_events_replayed_total",
+				Help: "Total number of dead-lettered outbox events replayed",
+			},
+		),
+		OutboxDeadLetterBacklog: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "orderbook_outbox_dead_letter_backlog",
+				Help: "Number of dead-lettered outbox events awaiting replay",
+			},
+		),
+		CommandsProcessed: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "orderbook_commands_processed_total",
+				Help: "Total number of Kafka order commands processed",
+			},
+			[]string{"type", "status"},
+		),
+		CommandProcessingDuration: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "orderbook_command_processing_duration_seconds",
+				Help:    "Time to execute a Kafka order command and publish its result",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"type"},
+		),
+		MarketStatusMessages: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "orderbook_market_status_messages_total",
+				Help: "Total number of upstream market status messages consumed",
+			},
+			[]string{"type", "outcome"}, // applied, duplicate, rejected, malformed
+		),
+		OrdersExpiredTotal: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "orderbook_orders_expired_total",
+				Help: "Total number of resting orders expired by a market close",
+			},
+		),
+		MaintenanceRowsDeleted: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "orderbook_maintenance_rows_deleted_total",
+				Help: "Total number of rows removed by maintenance jobs",
+			},
+			[]string{"job"},
+		),
+		MaintenanceRunDuration: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "orderbook_maintenance_run_duration_seconds",
+				Help:    "Time taken by a maintenance job run",
+				Buckets: []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300},
+			},
+			[]string{"job"},
+		),
+		MaintenanceErrors: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "orderbook_maintenance_errors_total",
+				Help: "Total number of failed maintenance job runs",
+			},
+			[]string{"job"},
+		),
+		MaintenanceLeader: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "orderbook_maintenance_leader",
+				Help: "1 if this replica runs the maintenance jobs, 0 otherwise",
+			},
+		),
+		EngineLeader: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "orderbook_engine_leader",
+				Help: "1 if this replica leads the matching engine, 0 while it is a standby",
+			},
+		),
+		EngineReplicationLag: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "orderbook_engine_replication_lag_records",
+				Help: "Journal records of the leader a standby has not received yet",
+			},
+		),
+		EngineShardMarkets: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "orderbook_engine_shard_markets",
+				Help: "Markets whose book this replica holds",
+			},
+		),
+		EngineShardHandoffs: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "orderbook_engine_shard_handoffs_total",
+				Help: "Total number of market books taken over from another replica",
+			},
+			[]string{"result"}, // adopted or failed
+		),
+		EngineForwardedCalls: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "orderbook_engine_forwarded_calls_total",
+				Help: "Total number of calls forwarded to the replica owning the market",
+			},
+			[]string{"call"},
+		),
+		WalletOperationErrors: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "orderbook_wallet_operation_errors_total",
+				Help: "Total number of failed wallet reservation operations",
+			},
+			[]string{"operation"}, // verify, adjust, commit, release
+		),
 	}
 }
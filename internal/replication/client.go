@@ -0,0 +1,139 @@
+package replication
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+// Source is the leader's replication endpoints as seen by a standby
+type Source interface {
+	// Snapshot takes a snapshot of the leader's books
+	Snapshot(ctx context.Context, address string) (*matchingengine.Snapshot, error)
+
+	// Stream calls fn with the leader's journal records from position on, and
+	// heartbeats, until the stream ends, ctx is done or fn fails
+	// Returns matchingengine.ErrPositionUnavailable if the leader cannot start at
+	// position and models.ErrNotLeader if it is not the leader
+	Stream(ctx context.Context, address string, position int64, fn func(*matchingengine.StreamMessage) error) error
+
+	// Acknowledge tells the leader the standby holds every record before position
+	Acknowledge(ctx context.Context, address string, position int64) error
+}
+
+// idleTimeout is how long a stream may go without a message before it is dropped
+const idleTimeout = 5 * HeartbeatInterval
+
+// Client implements Source over HTTP
+type Client struct {
+	client *http.Client
+}
+
+// NewClient creates a replication client
+// Requests have no overall timeout since streams are long-lived, a stream that
+// stops sending heartbeats is dropped instead
+func NewClient() *Client {
+	return &Client{client: &http.Client{}}
+}
+
+// Snapshot takes a snapshot of the leader's books
+func (c *Client) Snapshot(ctx context.Context, address string) (*matchingengine.Snapshot, error) {
+	resp, err := c.do(ctx, http.MethodGet, address, SnapshotPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read leader snapshot: %w", err)
+	}
+	return matchingengine.UnmarshalSnapshot(data)
+}
+
+// Stream calls fn with the leader's journal records from position on, and heartbeats
+func (c *Client) Stream(ctx context.Context, address string, position int64, fn func(*matchingengine.StreamMessage) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	idle := time.AfterFunc(idleTimeout, cancel)
+	defer idle.Stop()
+
+	query := url.Values{"position": {strconv.FormatInt(position, 10)}}
+	resp, err := c.do(ctx, http.MethodGet, address, StreamPath, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body := bufio.NewReader(resp.Body)
+	for {
+		msg, err := matchingengine.ReadStreamMessage(body)
+		if err != nil {
+			if !idle.Stop() {
+				return fmt.Errorf("replication stream idle for %s", idleTimeout)
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		idle.Reset(idleTimeout)
+
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Acknowledge tells the leader the standby holds every record before position
+func (c *Client) Acknowledge(ctx context.Context, address string, position int64) error {
+	query := url.Values{"position": {strconv.FormatInt(position, 10)}}
+	resp, err := c.do(ctx, http.MethodPost, address, AckPath, query)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a request to the leader and maps error statuses
+func (c *Client) do(ctx context.Context, method, address, path string, query url.Values) (*http.Response, error) {
+	target := strings.TrimSuffix(address, "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build replication request: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch resp.StatusCode {
+	case http.StatusServiceUnavailable:
+		return nil, models.ErrNotLeader
+	case http.StatusConflict:
+		return nil, fmt.Errorf("%w: %s", matchingengine.ErrPositionUnavailable, strings.TrimSpace(string(message)))
+	default:
+		return nil, fmt.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+}
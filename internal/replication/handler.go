@@ -0,0 +1,202 @@
+// Package replication streams the matching engine journal from the leader to its
+// standbys over HTTP
+//
+// A standby loads a snapshot of the leader's books, follows the journal from the
+// snapshot's position and acknowledges the records it has received:
+//
+//	GET  /replication/snapshot             binary snapshot of every market
+//	GET  /replication/stream?position=N    journal records from N on, with heartbeats
+//	POST /replication/ack?position=N       the standby holds every record before N
+//
+// Only the leader serves them, a standby answers 503. A demoted leader keeps
+// serving its stream, so the replica taking over can read the commands it did
+// not receive yet. A stream position the journal no longer has, or does not have
+// yet, is answered with 409
+package replication
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+// Endpoint paths
+const (
+	SnapshotPath = "/replication/snapshot"
+	StreamPath   = "/replication/stream"
+	AckPath      = "/replication/ack"
+)
+
+// HeartbeatInterval is how often a stream sends the leader's journal position
+const HeartbeatInterval = time.Second
+
+// Handler serves the leader's side of replication
+type Handler struct {
+	sequencer *matchingengine.Sequencer
+	journal   *matchingengine.Journal
+	acks      *matchingengine.ReplicaAcks
+	logger    zerolog.Logger
+}
+
+// NewHandler creates the replication endpoints of a replica
+// acks may be nil when commands do not wait for standbys
+func NewHandler(
+	sequencer *matchingengine.Sequencer,
+	journal *matchingengine.Journal,
+	acks *matchingengine.ReplicaAcks,
+	logger zerolog.Logger,
+) *Handler {
+	return &Handler{
+		sequencer: sequencer,
+		journal:   journal,
+		acks:      acks,
+		logger:    logger.With().Str("component", "replication_handler").Logger(),
+	}
+}
+
+// Register adds the replication endpoints to mux
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+SnapshotPath, h.snapshot)
+	mux.HandleFunc("GET "+StreamPath, h.stream)
+	mux.HandleFunc("POST "+AckPath, h.ack)
+}
+
+func (h *Handler) snapshot(w http.ResponseWriter, r *http.Request) {
+	if h.sequencer.Standby() {
+		http.Error(w, models.ErrNotLeader.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	snapshot, err := h.sequencer.Snapshot(r.Context())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to snapshot engine for standby")
+		http.Error(w, "snapshot failed", http.StatusInternalServerError)
+		return
+	}
+	data, err := snapshot.MarshalBinary()
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to encode snapshot for standby")
+		http.Error(w, "snapshot failed", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info().
+		Str("standby", r.RemoteAddr).
+		Int64("journal_position", snapshot.Position).
+		Int("markets", len(snapshot.Markets)).
+		Msg("snapshot sent to standby")
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request) {
+	position, err := strconv.ParseInt(r.URL.Query().Get("position"), 10, 64)
+	if err != nil {
+		http.Error(w, "position is required", http.StatusBadRequest)
+		return
+	}
+	if !h.streaming() {
+		http.Error(w, models.ErrNotLeader.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err := h.journal.Available(position); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.logger.Warn().Err(err).Msg("failed to clear write deadline of replication stream")
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var mu sync.Mutex
+	send := func(msg *matchingengine.StreamMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := matchingengine.WriteStreamMessage(w, msg); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	h.logger.Info().
+		Str("standby", r.RemoteAddr).
+		Int64("position", position).
+		Msg("standby following journal")
+
+	// Heartbeats tell the standby how far behind it is, and end the stream once
+	// this replica follows another leader
+	heartbeats := make(chan struct{})
+	go func() {
+		defer close(heartbeats)
+		ticker := time.NewTicker(HeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			if !h.streaming() {
+				cancel()
+				return
+			}
+			heartbeat := &matchingengine.StreamMessage{
+				Position: h.journal.Position(),
+				Attached: h.acks != nil && h.acks.Attached(),
+			}
+			if err := send(heartbeat); err != nil {
+				cancel()
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	err = h.journal.Follow(ctx, position, func(cmd *matchingengine.Command) error {
+		return send(&matchingengine.StreamMessage{Command: cmd, Position: cmd.Position})
+	})
+	cancel()
+	<-heartbeats
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		h.logger.Warn().Err(err).Str("standby", r.RemoteAddr).Msg("replication stream failed")
+		return
+	}
+	h.logger.Info().Str("standby", r.RemoteAddr).Msg("standby stopped following journal")
+}
+
+// streaming returns true if the journal holds the commands this replica sequenced
+// A standby's journal holds what it replicated, under positions of its own
+func (h *Handler) streaming() bool {
+	return !h.sequencer.Standby() || h.sequencer.Demoted()
+}
+
+func (h *Handler) ack(w http.ResponseWriter, r *http.Request) {
+	position, err := strconv.ParseInt(r.URL.Query().Get("position"), 10, 64)
+	if err != nil {
+		http.Error(w, "position is required", http.StatusBadRequest)
+		return
+	}
+	if h.acks != nil {
+		h.acks.Acknowledge(position)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// EngineLeaderRepository defines the interface for matching engine leadership records
+type EngineLeaderRepository interface {
+	// Get retrieves the current leader of the election
+	// Returns nil if no leader has been elected yet
+	Get(ctx context.Context, name string) (*models.EngineLeader, error)
+
+	// Claim records the replica as leader under the next epoch
+	// Sets the leader's Epoch and ElectedAt. Waits for transactions that checked
+	// the previous epoch to finish, later ones fail CheckEpoch
+	Claim(ctx context.Context, leader *models.EngineLeader) error
+
+	// CheckEpoch returns models.ErrFenced unless epoch is the current one
+	// The leader record stays share-locked until the transaction ends, so no
+	// election completes while it runs
+	// MUST be called within a transaction
+	CheckEpoch(ctx context.Context, tx pgx.Tx, name string, epoch int64) error
+}
+
+// PostgresEngineLeaderRepository implements EngineLeaderRepository using PostgreSQL
+type PostgresEngineLeaderRepository struct {
+	pool   *pgxpool.Pool
+	logger zerolog.Logger
+}
+
+// NewPostgresEngineLeaderRepository creates a new PostgreSQL engine leader repository
+func NewPostgresEngineLeaderRepository(pool *pgxpool.Pool, logger zerolog.Logger) *PostgresEngineLeaderRepository {
+	return &PostgresEngineLeaderRepository{
+		pool:   pool,
+		logger: logger.With().Str("component", "postgres_engine_leader_repository").Logger(),
+	}
+}
+
+// Get retrieves the current leader of the election
+func (r *PostgresEngineLeaderRepository) Get(ctx context.Context, name string) (*models.EngineLeader, error) {
+	query := `
+		SELECT name, epoch, engine_id, journal_id, address, elected_at
+		FROM engine_leaders
+		WHERE name = $1
+	`
+
+	var leader models.EngineLeader
+	err := r.pool.QueryRow(ctx, query, name).Scan(
+		&leader.Name,
+		&leader.Epoch,
+		&leader.EngineID,
+		&leader.JournalID,
+		&leader.Address,
+		&leader.ElectedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error().Err(err).Str("name", name).Msg("failed to get engine leader")
+		return nil, fmt.Errorf("get engine leader: %w", err)
+	}
+	return &leader, nil
+}
+
+// Claim records the replica as leader under the next epoch
+func (r *PostgresEngineLeaderRepository) Claim(ctx context.Context, leader *models.EngineLeader) error {
+	query := `
+		INSERT INTO engine_leaders (name, epoch, engine_id, journal_id, address, elected_at)
+		VALUES ($1, 1, $2, $3, $4, NOW())
+		ON CONFLICT (name) DO UPDATE
+		SET epoch = engine_leaders.epoch + 1,
+		    engine_id = EXCLUDED.engine_id,
+		    journal_id = EXCLUDED.journal_id,
+		    address = EXCLUDED.address,
+		    elected_at = EXCLUDED.elected_at
+		RETURNING epoch, elected_at
+	`
+
+	err := r.pool.QueryRow(ctx, query, leader.Name, leader.EngineID, leader.JournalID, leader.Address).
+		Scan(&leader.Epoch, &leader.ElectedAt)
+	if err != nil {
+		r.logger.Error().Err(err).
+			Str("name", leader.Name).
+			Str("engine_id", leader.EngineID).
+			Msg("failed to claim engine leadership")
+		return fmt.Errorf("claim engine leadership: %w", err)
+	}
+
+	r.logger.Info().
+		Str("name", leader.Name).
+		Str("engine_id", leader.EngineID).
+		Int64("epoch", leader.Epoch).
+		Msg("engine leadership claimed")
+
+	return nil
+}
+
+// CheckEpoch returns models.ErrFenced unless epoch is the current one
+func (r *PostgresEngineLeaderRepository) CheckEpoch(ctx context.Context, tx pgx.Tx, name string, epoch int64) error {
+	// FOR SHARE conflicts with the epoch update, a lighter lock would not
+	query := `SELECT epoch FROM engine_leaders WHERE name = $1 FOR SHARE`
+
+	var current int64
+	err := tx.QueryRow(ctx, query, name).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: no leader elected", models.ErrFenced)
+	}
+	if err != nil {
+		r.logger.Error().Err(err).Str("name", name).Msg("failed to check engine epoch")
+		return fmt.Errorf("check engine epoch: %w", err)
+	}
+
+	if current != epoch {
+		r.logger.Warn().
+			Str("name", name).
+			Int64("epoch", epoch).
+			Int64("current_epoch", current).
+			Msg("write fenced off, another replica leads the engine")
+		return fmt.Errorf("%w: epoch %d, current %d", models.ErrFenced, epoch, current)
+	}
+	return nil
+}
@@ -10,29 +10,43 @@ import (
 	"time"
 
 	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 )
 
+// idempotencyStatusProcessing marks a key whose call has not completed yet
+const idempotencyStatusProcessing = "processing"
+
 // IdempotencyRepository defines the interface for idempotency key management
 type IdempotencyRepository interface {
-	// Check checks if idempotency key exists and validates request hash
-	// Returns response data if key exists and hash matches
+	// Claim marks the key as in progress for this call, holding it for lease
+	// The claim is recorded under claimToken, which completing or releasing it requires
+	// Returns claimed=true if the caller now owns the key and must complete or release it
+	// Returns the stored response if a call with the key already completed
+	// Returns ErrIdempotencyInProgress while another call holds the key
 	// Returns ErrIdempotencyMismatch if key exists but hash doesn't match
-	Check(ctx context.Context, key string, requestHash string) (responseData json.RawMessage, exists bool, err error)
+	Claim(ctx context.Context, key string, claimToken uuid.UUID, requestHash string, lease time.Duration) (responseData json.RawMessage, claimed bool, err error)
+
+	// Release removes an in-progress key so a retry can claim it again
+	// Used when the call failed without a response worth replaying. A claim that
+	// was taken over after its lease expired is left to its new holder
+	Release(ctx context.Context, key string, claimToken uuid.UUID) error
 
-	// Store stores idempotency key with response
+	// Store stores the response of the call holding claimToken and marks the key completed
 	// TTL determines when the key expires
-	Store(ctx context.Context, key string, requestHash string, responseData interface{}, ttl time.Duration) error
+	// Returns ErrIdempotencyClaimLost if a retry took the claim over
+	Store(ctx context.Context, key string, claimToken uuid.UUID, requestHash string, responseData interface{}, ttl time.Duration) error
 
-	// StoreInTransaction stores idempotency key within a transaction
-	// MUST be called within a transaction
-	StoreInTransaction(ctx context.Context, tx pgx.Tx, key string, requestHash string, responseData interface{}, ttl time.Duration) error
+	// StoreInTransaction stores the response like Store within a transaction
+	// MUST be called within a transaction, a lost claim must fail it
+	StoreInTransaction(ctx context.Context, tx pgx.Tx, key string, claimToken uuid.UUID, requestHash string, responseData interface{}, ttl time.Duration) error
 
-	// CleanupExpired removes expired idempotency keys
-	// Returns the number of deleted keys
-	CleanupExpired(ctx context.Context) (int64, error)
+	// CleanupExpired removes up to limit expired idempotency keys
+	// Returns the number of deleted keys, fewer than limit once none are left
+	CleanupExpired(ctx context.Context, limit int) (int64, error)
 }
 
 // PostgresIdempotencyRepository implements IdempotencyRepository using PostgreSQL
@@ -49,24 +63,59 @@ func NewPostgresIdempotencyRepository(pool *pgxpool.Pool, logger zerolog.Logger)
 	}
 }
 
-// Check checks if idempotency key exists and validates request hash
-func (r *PostgresIdempotencyRepository) Check(ctx context.Context, key string, requestHash string) (json.RawMessage, bool, error) {
+// Claim marks the key as in progress for this call, holding it for lease
+func (r *PostgresIdempotencyRepository) Claim(ctx context.Context, key string, claimToken uuid.UUID, requestHash string, lease time.Duration) (json.RawMessage, bool, error) {
+	// Expired keys and in-progress keys whose holder died are taken over, the new
+	// token fences the previous holder off
+	claimQuery := `
+		INSERT INTO idempotency_keys (idempotency_key, request_hash, status, claim_token, locked_until, created_at, expires_at)
+		VALUES ($1, $2, 'processing', $4, NOW() + make_interval(secs => $3), NOW(), NOW() + make_interval(secs => $3))
+		ON CONFLICT (idempotency_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+		    response_data = NULL,
+		    status = EXCLUDED.status,
+		    claim_token = EXCLUDED.claim_token,
+		    locked_until = EXCLUDED.locked_until,
+		    created_at = EXCLUDED.created_at,
+		    expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+		   OR (idempotency_keys.status = 'processing'
+		       AND idempotency_keys.locked_until <= NOW()
+		       AND idempotency_keys.request_hash = EXCLUDED.request_hash)
+		RETURNING idempotency_key
+	`
+
+	var claimedKey string
+	err := r.pool.QueryRow(ctx, claimQuery, key, requestHash, lease.Seconds(), claimToken).Scan(&claimedKey)
+	if err == nil {
+		r.logger.Debug().
+			Str("key", key).
+			Dur("lease", lease).
+			Msg("idempotency key claimed")
+		return nil, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		r.logger.Error().Err(err).
+			Str("key", key).
+			Msg("failed to claim idempotency key")
+		return nil, false, fmt.Errorf("claim idempotency key: %w", err)
+	}
+
+	// Someone else holds or completed the key
 	query := `
-		SELECT request_hash, response_data
+		SELECT request_hash, status, response_data
 		FROM idempotency_keys
-		WHERE idempotency_key = $1 AND expires_at > NOW()
+		WHERE idempotency_key = $1
 	`
 
-	var storedHash string
+	var storedHash, status string
 	var responseData json.RawMessage
 
-	err := r.pool.QueryRow(ctx, query, key).Scan(&storedHash, &responseData)
+	err = r.pool.QueryRow(ctx, query, key).Scan(&storedHash, &status, &responseData)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			r.logger.Debug().
-				Str("key", key).
-				Msg("idempotency key not found")
-			return nil, false, nil
+			// Released or cleaned up in between, the next claim will take it
+			return nil, false, models.ErrIdempotencyInProgress
 		}
 		r.logger.Error().Err(err).
 			Str("key", key).
@@ -81,61 +130,57 @@ func (r *PostgresIdempotencyRepository) Check(ctx context.Context, key string, r
 			Str("stored_hash", storedHash).
 			Str("request_hash", requestHash).
 			Msg("idempotency key hash mismatch")
-		return nil, true, models.ErrIdempotencyMismatch
+		return nil, false, models.ErrIdempotencyMismatch
+	}
+
+	if status == idempotencyStatusProcessing {
+		return nil, false, models.ErrIdempotencyInProgress
 	}
 
 	r.logger.Debug().
 		Str("key", key).
 		Msg("idempotency key found and validated")
 
-	return responseData, true, nil
+	return responseData, false, nil
 }
 
-// Store stores idempotency key with response
-func (r *PostgresIdempotencyRepository) Store(ctx context.Context, key string, requestHash string, responseData interface{}, ttl time.Duration) error {
-	query := `
-		INSERT INTO idempotency_keys (idempotency_key, request_hash, response_data, created_at, expires_at)
-		VALUES ($1, $2, $3, NOW(), NOW() + $4::interval)
-		ON CONFLICT (idempotency_key) DO UPDATE
-		SET request_hash = EXCLUDED.request_hash,
-		    response_data = EXCLUDED.response_data,
-		    expires_at = EXCLUDED.expires_at
-	`
+// Release removes an in-progress key so a retry can claim it again
+func (r *PostgresIdempotencyRepository) Release(ctx context.Context, key string, claimToken uuid.UUID) error {
+	query := `DELETE FROM idempotency_keys WHERE idempotency_key = $1 AND status = 'processing' AND claim_token = $2`
 
-	// Marshal response data to JSON
-	responseJSON, err := json.Marshal(responseData)
-	if err != nil {
+	if _, err := r.pool.Exec(ctx, query, key, claimToken); err != nil {
 		r.logger.Error().Err(err).
 			Str("key", key).
-			Msg("failed to marshal response data")
-		return fmt.Errorf("marshal response data: %w", err)
+			Msg("failed to release idempotency key")
+		return fmt.Errorf("release idempotency key: %w", err)
 	}
 
-	_, err = r.pool.Exec(ctx, query, key, requestHash, responseJSON, ttl.String())
-	if err != nil {
-		r.logger.Error().Err(err).
-			Str("key", key).
-			Msg("failed to store idempotency key")
-		return fmt.Errorf("store idempotency key: %w", err)
-	}
+	return nil
+}
 
-	r.logger.Debug().
-		Str("key", key).
-		Dur("ttl", ttl).
-		Msg("idempotency key stored")
+// Store stores the response of the call holding claimToken
+func (r *PostgresIdempotencyRepository) Store(ctx context.Context, key string, claimToken uuid.UUID, requestHash string, responseData interface{}, ttl time.Duration) error {
+	return r.store(ctx, r.pool.Exec, key, claimToken, requestHash, responseData, ttl)
+}
 
-	return nil
+// StoreInTransaction stores the response of the call holding claimToken within a transaction
+func (r *PostgresIdempotencyRepository) StoreInTransaction(ctx context.Context, tx pgx.Tx, key string, claimToken uuid.UUID, requestHash string, responseData interface{}, ttl time.Duration) error {
+	return r.store(ctx, tx.Exec, key, claimToken, requestHash, responseData, ttl)
 }
 
-// StoreInTransaction stores idempotency key within a transaction
-func (r *PostgresIdempotencyRepository) StoreInTransaction(ctx context.Context, tx pgx.Tx, key string, requestHash string, responseData interface{}, ttl time.Duration) error {
+// store completes the key through exec if claimToken still holds it
+func (r *PostgresIdempotencyRepository) store(ctx context.Context, exec func(context.Context, string, ...any) (pgconn.CommandTag, error), key string, claimToken uuid.UUID, requestHash string, responseData interface{}, ttl time.Duration) error {
 	query := `
-		INSERT INTO idempotency_keys (idempotency_key, request_hash, response_data, created_at, expires_at)
-		VALUES ($1, $2, $3, NOW(), NOW() + $4::interval)
-		ON CONFLICT (idempotency_key) DO UPDATE
-		SET request_hash = EXCLUDED.request_hash,
-		    response_data = EXCLUDED.response_data,
-		    expires_at = EXCLUDED.expires_at
+		UPDATE idempotency_keys
+		SET response_data = $4,
+		    status = 'completed',
+		    claim_token = NULL,
+		    locked_until = NULL,
+		    expires_at = NOW() + make_interval(secs => $5)
+		WHERE idempotency_key = $1
+		  AND claim_token = $2
+		  AND request_hash = $3
+		  AND status = 'processing'
 	`
 
 	// Marshal response data to JSON
@@ -147,30 +192,44 @@ func (r *PostgresIdempotencyRepository) StoreInTransaction(ctx context.Context,
 		return fmt.Errorf("marshal response data: %w", err)
 	}
 
-	_, err = tx.Exec(ctx, query, key, requestHash, responseJSON, ttl.String())
+	result, err := exec(ctx, query, key, claimToken, requestHash, responseJSON, ttl.Seconds())
 	if err != nil {
 		r.logger.Error().Err(err).
 			Str("key", key).
-			Msg("failed to store idempotency key in transaction")
-		return fmt.Errorf("store idempotency key in transaction: %w", err)
+			Msg("failed to store idempotency key")
+		return fmt.Errorf("store idempotency key: %w", err)
+	}
+
+	// The lease expired and a retry claimed the key, its call owns the response
+	if result.RowsAffected() == 0 {
+		r.logger.Warn().
+			Str("key", key).
+			Msg("idempotency key claim lost")
+		return models.ErrIdempotencyClaimLost
 	}
 
 	r.logger.Debug().
 		Str("key", key).
 		Dur("ttl", ttl).
-		Msg("idempotency key stored in transaction")
+		Msg("idempotency key stored")
 
 	return nil
 }
 
-// CleanupExpired removes expired idempotency keys
-func (r *PostgresIdempotencyRepository) CleanupExpired(ctx context.Context) (int64, error) {
+// CleanupExpired removes up to limit expired idempotency keys
+func (r *PostgresIdempotencyRepository) CleanupExpired(ctx context.Context, limit int) (int64, error) {
 	query := `
 		DELETE FROM idempotency_keys
-		WHERE expires_at < NOW()
+		WHERE idempotency_key IN (
+			SELECT idempotency_key FROM idempotency_keys
+			WHERE expires_at < NOW()
+			ORDER BY expires_at ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
 	`
 
-	result, err := r.pool.Exec(ctx, query)
+	result, err := r.pool.Exec(ctx, query, limit)
 	if err != nil {
 		r.logger.Error().Err(err).Msg("failed to cleanup expired idempotency keys")
 		return 0, fmt.Errorf("cleanup expired idempotency keys: %w", err)
@@ -178,7 +237,7 @@ func (r *PostgresIdempotencyRepository) CleanupExpired(ctx context.Context) (int
 
 	deletedCount := result.RowsAffected()
 	if deletedCount > 0 {
-		r.logger.Info().
+		r.logger.Debug().
 			Int64("deleted_count", deletedCount).
 			Msg("cleaned up expired idempotency keys")
 	}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// MarketRepository defines the interface for market state and the status message inbox
+type MarketRepository interface {
+	// Lock takes a transaction-scoped advisory lock on the market ID
+	// Serializes status changes of the same market
+	// MUST be called within a transaction
+	Lock(ctx context.Context, tx pgx.Tx, marketID string) error
+
+	// Get retrieves a market's state
+	// Returns an open market if the feed has never changed it
+	// MUST be called within a transaction
+	Get(ctx context.Context, tx pgx.Tx, marketID string) (*models.Market, error)
+
+	// Save inserts or updates a market's state
+	// MUST be called within a transaction
+	Save(ctx context.Context, tx pgx.Tx, market *models.Market) error
+
+	// ListNotOpen retrieves every market that does not accept orders
+	// Used to restore matching engine state on startup
+	ListNotOpen(ctx context.Context) ([]*models.Market, error)
+
+	// IsProcessed checks whether a status message has already been applied
+	// MUST be called within a transaction
+	IsProcessed(ctx context.Context, tx pgx.Tx, messageID string) (bool, error)
+
+	// MarkProcessed records that a status message has been applied
+	// MUST be called within a transaction
+	MarkProcessed(ctx context.Context, tx pgx.Tx, messageID, marketID, messageType string) error
+
+	// CleanupProcessedMessages deletes up to limit inbox entries processed more than olderThan ago
+	// Returns the number of deleted entries, fewer than limit once none are left
+	CleanupProcessedMessages(ctx context.Context, olderThan time.Duration, limit int) (int64, error)
+}
+
+// PostgresMarketRepository implements MarketRepository using PostgreSQL
+type PostgresMarketRepository struct {
+	pool   *pgxpool.Pool
+	logger zerolog.Logger
+}
+
+// NewPostgresMarketRepository creates a new PostgreSQL market repository
+func NewPostgresMarketRepository(pool *pgxpool.Pool, logger zerolog.Logger) *PostgresMarketRepository {
+	return &PostgresMarketRepository{
+		pool:   pool,
+		logger: logger.With().Str("component", "postgres_market_repository").Logger(),
+	}
+}
+
+// Lock takes a transaction-scoped advisory lock on the market ID
+func (r *PostgresMarketRepository) Lock(ctx context.Context, tx pgx.Tx, marketID string) error {
+	query := `SELECT pg_advisory_xact_lock(hashtextextended('market:' || $1, 0))`
+
+	if _, err := tx.Exec(ctx, query, marketID); err != nil {
+		r.logger.Error().Err(err).
+			Str("market_id", marketID).
+			Msg("failed to lock market")
+		return fmt.Errorf("lock market: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves a market's state
+func (r *PostgresMarketRepository) Get(ctx context.Context, tx pgx.Tx, marketID string) (*models.Market, error) {
+	query := `
+		SELECT market_id, status, winning_selection_id, updated_at
+		FROM markets
+		WHERE market_id = $1
+	`
+
+	var market models.Market
+	err := tx.QueryRow(ctx, query, marketID).Scan(
+		&market.ID,
+		&market.Status,
+		&market.WinningSelectionID,
+		&market.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &models.Market{ID: marketID, Status: models.MarketStatusOpen}, nil
+	}
+	if err != nil {
+		r.logger.Error().Err(err).
+			Str("market_id", marketID).
+			Msg("failed to get market")
+		return nil, fmt.Errorf("get market: %w", err)
+	}
+
+	return &market, nil
+}
+
+// Save inserts or updates a market's state
+func (r *PostgresMarketRepository) Save(ctx context.Context, tx pgx.Tx, market *models.Market) error {
+	query := `
+		INSERT INTO markets (market_id, status, winning_selection_id, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (market_id) DO UPDATE
+		SET status = EXCLUDED.status,
+			winning_selection_id = EXCLUDED.winning_selection_id,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`
+
+	err := tx.QueryRow(ctx, query,
+		market.ID,
+		market.Status,
+		market.WinningSelectionID,
+	).Scan(&market.UpdatedAt)
+	if err != nil {
+		r.logger.Error().Err(err).
+			Str("market_id", market.ID).
+			Str("status", string(market.Status)).
+			Msg("failed to save market")
+		return fmt.Errorf("save market: %w", err)
+	}
+
+	r.logger.Debug().
+		Str("market_id", market.ID).
+		Str("status", string(market.Status)).
+		Msg("market saved")
+
+	return nil
+}
+
+// ListNotOpen retrieves every market that does not accept orders
+func (r *PostgresMarketRepository) ListNotOpen(ctx context.Context) ([]*models.Market, error) {
+	query := `
+		SELECT market_id, status, winning_selection_id, updated_at
+		FROM markets
+		WHERE status <> $1
+		ORDER BY market_id ASC
+	`
+
+	rows, err := r.pool.Query(ctx, query, models.MarketStatusOpen)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("failed to list markets")
+		return nil, fmt.Errorf("list markets: %w", err)
+	}
+	defer rows.Close()
+
+	var markets []*models.Market
+	for rows.Next() {
+		var market models.Market
+		if err := rows.Scan(
+			&market.ID,
+			&market.Status,
+			&market.WinningSelectionID,
+			&market.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan market: %w", err)
+		}
+		markets = append(markets, &market)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate markets: %w", err)
+	}
+
+	return markets, nil
+}
+
+// IsProcessed checks whether a status message has already been applied
+func (r *PostgresMarketRepository) IsProcessed(ctx context.Context, tx pgx.Tx, messageID string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM market_status_inbox WHERE message_id = $1)`
+
+	var exists bool
+	if err := tx.QueryRow(ctx, query, messageID).Scan(&exists); err != nil {
+		r.logger.Error().Err(err).
+			Str("message_id", messageID).
+			Msg("failed to check market status message")
+		return false, fmt.Errorf("check market status message: %w", err)
+	}
+
+	return exists, nil
+}
+
+// MarkProcessed records that a status message has been applied
+func (r *PostgresMarketRepository) MarkProcessed(ctx context.Context, tx pgx.Tx, messageID, marketID, messageType string) error {
+	query := `
+		INSERT INTO market_status_inbox (message_id, market_id, message_type, processed_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (message_id) DO NOTHING
+	`
+
+	if _, err := tx.Exec(ctx, query, messageID, marketID, messageType); err != nil {
+		r.logger.Error().Err(err).
+			Str("message_id", messageID).
+			Str("market_id", marketID).
+			Msg("failed to record market status message")
+		return fmt.Errorf("record market status message: %w", err)
+	}
+
+	return nil
+}
+
+// CleanupProcessedMessages deletes up to limit inbox entries processed more than olderThan ago
+func (r *PostgresMarketRepository) CleanupProcessedMessages(ctx context.Context, olderThan time.Duration, limit int) (int64, error) {
+	query := `
+		DELETE FROM market_status_inbox
+		WHERE message_id IN (
+			SELECT message_id FROM market_status_inbox
+			WHERE processed_at < NOW() - make_interval(secs => $1)
+			ORDER BY processed_at ASC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+	`
+
+	result, err := r.pool.Exec(ctx, query, olderThan.Seconds(), limit)
+	if err != nil {
+		r.logger.Error().Err(err).
+			Dur("older_than", olderThan).
+			Msg("failed to cleanup market status inbox")
+		return 0, fmt.Errorf("cleanup market status inbox: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
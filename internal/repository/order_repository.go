@@ -45,6 +45,22 @@ type OrderRepository interface {
 	// Returns ErrOptimisticLock if version mismatch
 	UpdateMatched(ctx context.Context, tx pgx.Tx, id uuid.UUID, sizeMatched, sizeRemaining decimal.Decimal, status models.OrderStatus, version int64) error
 
+	// CreateMatch records a matched trade between two orders
+	// MUST be called within a transaction
+	CreateMatch(ctx context.Context, tx pgx.Tx, match *models.Match) error
+
+	// GetMatchesByOrderID gets all matches an order took part in, on either side
+	// MUST be called within a transaction
+	GetMatchesByOrderID(ctx context.Context, tx pgx.Tx, orderID uuid.UUID) ([]*models.Match, error)
+
+	// GetMatchesByMarketID gets all matches of a market in sequence order, reversed ones included
+	// Matches made before sequencing come first, in time order
+	GetMatchesByMarketID(ctx context.Context, marketID string) ([]*models.Match, error)
+
+	// ReverseMatch marks a match as reversed
+	// MUST be called within a transaction
+	ReverseMatch(ctx context.Context, tx pgx.Tx, matchID uuid.UUID) error
+
 	// GetByUserID gets orders for a user with pagination
 	// Returns empty slice if no orders found
 	GetByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.Order, error)
@@ -60,4 +76,8 @@ type OrderRepository interface {
 	// GetPendingOrders gets all pending orders for a market
 	// Used for matching engine
 	GetPendingOrders(ctx context.Context, marketID string) ([]*models.Order, error)
+
+	// GetUnsettledMatchedOrders gets orders of a market with a matched size that are not yet settled
+	// Used for market settlement, includes orders cancelled or expired after a partial match
+	GetUnsettledMatchedOrders(ctx context.Context, marketID string) ([]*models.Order, error)
 }
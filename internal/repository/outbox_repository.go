@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/cypherlabdev/order-book-service/internal/models"
@@ -11,6 +13,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // OutboxRepository defines the interface for outbox event operations
@@ -19,21 +22,47 @@ type OutboxRepository interface {
 	// MUST be called within a transaction
 	Create(ctx context.Context, tx pgx.Tx, event *models.OutboxEvent) error
 
-	// GetUnprocessedEvents retrieves unprocessed events for publishing
-	// Returns events that haven't been processed and haven't exceeded max retries
-	GetUnprocessedEvents(ctx context.Context, limit int) ([]*models.OutboxEvent, error)
-
-	// MarkProcessed marks an event as successfully published
-	// Updates processed_at timestamp
-	MarkProcessed(ctx context.Context, eventID uuid.UUID) error
-
-	// IncrementRetryCount increments the retry count for a failed publish
-	// Stores the error message for debugging
-	IncrementRetryCount(ctx context.Context, eventID uuid.UUID, errorMsg string) error
-
-	// CleanupProcessedEvents deletes old processed events to prevent table bloat
-	// Returns the number of deleted events
-	CleanupProcessedEvents(ctx context.Context, olderThan time.Duration) (int64, error)
+	// ClaimUnprocessedEvents claims unprocessed events for publishing by owner
+	// Returns events that haven't been processed, haven't exceeded max retries and
+	// aren't under another owner's unexpired lease or waiting out a retry
+	// backoff; rows locked by a concurrent
+	// claim are skipped so parallel publishers never receive the same event
+	// Only the oldest unprocessed event of each aggregate is claimable, so a
+	// failing event holds back later events for its aggregate
+	ClaimUnprocessedEvents(ctx context.Context, owner string, limit int, lease time.Duration) ([]*models.OutboxEvent, error)
+
+	// MarkProcessed marks an event claimed by owner as successfully published
+	// Updates processed_at timestamp and clears the claim
+	MarkProcessed(ctx context.Context, eventID uuid.UUID, owner string) error
+
+	// IncrementRetryCount increments the retry count for a failed publish by owner
+	// Stores the error message for debugging, releases the claim and holds the
+	// event back for retryAfter
+	// Returns true if this attempt exhausted the retries and dead-lettered the event
+	IncrementRetryCount(ctx context.Context, eventID uuid.UUID, owner string, errorMsg string, retryAfter time.Duration) (bool, error)
+
+	// GetByID retrieves an outbox event by ID
+	// Returns ErrOutboxEventNotFound if event doesn't exist
+	GetByID(ctx context.Context, eventID uuid.UUID) (*models.OutboxEvent, error)
+
+	// ListMarketEvents retrieves the sequenced events of a market's orders and matches
+	// Ordered by market sequence; events cleaned up by retention are missing
+	ListMarketEvents(ctx context.Context, marketID string) ([]*models.OutboxEvent, error)
+
+	// ListDeadLettered retrieves dead-lettered events, oldest first
+	ListDeadLettered(ctx context.Context, limit, offset int) ([]*models.OutboxEvent, error)
+
+	// CountDeadLettered returns the number of events awaiting replay
+	CountDeadLettered(ctx context.Context) (int64, error)
+
+	// ReplayDeadLettered resets a dead-lettered event so publishers pick it up again
+	// Returns ErrOutboxNotDeadLettered if the event isn't dead-lettered
+	ReplayDeadLettered(ctx context.Context, eventID uuid.UUID) error
+
+	// CleanupProcessedEvents deletes up to limit events processed more than olderThan ago
+	// Dead-lettered and pending events are never deleted
+	// Returns the number of deleted events, fewer than limit once none are left
+	CleanupProcessedEvents(ctx context.Context, olderThan time.Duration, limit int) (int64, error)
 }
 
 // PostgresOutboxRepository implements OutboxRepository using PostgreSQL
@@ -52,12 +81,25 @@ func NewPostgresOutboxRepository(pool *pgxpool.Pool, logger zerolog.Logger) *Pos
 
 // Create inserts a new outbox event within a transaction
 func (r *PostgresOutboxRepository) Create(ctx context.Context, tx pgx.Tx, event *models.OutboxEvent) error {
+	// The sequence row stays locked until commit, so concurrent writers for the
+	// same aggregate commit in sequence order
+	// Sequence rows are never deleted, consumers dedupe on the aggregate sequence
 	query := `
+		WITH seq AS (
+			INSERT INTO outbox_aggregate_sequences (aggregate_id, last_sequence)
+			VALUES ($2, 1)
+			ON CONFLICT (aggregate_id) DO UPDATE
+			SET last_sequence = outbox_aggregate_sequences.last_sequence + 1
+			RETURNING last_sequence
+		)
 		INSERT INTO outbox_events (
 			id, aggregate_id, aggregate_type, event_type, event_payload,
-			saga_id, created_at, retry_count, max_retries
+			saga_id, created_at, retry_count, max_retries, schema_version,
+			trace_parent, trace_state, market_sequence, aggregate_sequence
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, seq.last_sequence
+		FROM seq
+		RETURNING aggregate_sequence
 	`
 
 	// Generate UUID if not provided
@@ -68,6 +110,21 @@ func (r *PostgresOutboxRepository) Create(ctx context.Context, tx pgx.Tx, event
 	// Set created_at timestamp
 	event.CreatedAt = time.Now()
 
+	// Zero would make the event unpublishable
+	if event.MaxRetries <= 0 {
+		event.MaxRetries = models.DefaultOutboxMaxRetries
+	}
+
+	// Untyped payloads predate versioned schemas
+	if event.SchemaVersion <= 0 {
+		event.SchemaVersion = 1
+	}
+
+	// Carry the caller's trace over to the publisher
+	if event.TraceParent == nil {
+		captureTraceContext(ctx, event)
+	}
+
 	// Convert event payload to JSON
 	payloadJSON, err := json.Marshal(event.EventPayload)
 	if err != nil {
@@ -77,7 +134,7 @@ func (r *PostgresOutboxRepository) Create(ctx context.Context, tx pgx.Tx, event
 		return fmt.Errorf("marshal event payload: %w", err)
 	}
 
-	_, err = tx.Exec(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		event.ID,
 		event.AggregateID,
 		event.AggregateType,
@@ -87,7 +144,11 @@ func (r *PostgresOutboxRepository) Create(ctx context.Context, tx pgx.Tx, event
 		event.CreatedAt,
 		event.RetryCount,
 		event.MaxRetries,
-	)
+		event.SchemaVersion,
+		event.TraceParent,
+		event.TraceState,
+		event.MarketSequence,
+	).Scan(&event.AggregateSequence)
 
 	if err != nil {
 		r.logger.Error().Err(err).
@@ -102,85 +163,79 @@ func (r *PostgresOutboxRepository) Create(ctx context.Context, tx pgx.Tx, event
 		Str("event_type", event.EventType).
 		Str("aggregate_type", event.AggregateType).
 		Str("aggregate_id", event.AggregateID.String()).
+		Int64("aggregate_sequence", event.AggregateSequence).
 		Msg("outbox event created")
 
 	return nil
 }
 
-// GetUnprocessedEvents retrieves unprocessed events for publishing
-func (r *PostgresOutboxRepository) GetUnprocessedEvents(ctx context.Context, limit int) ([]*models.OutboxEvent, error) {
-	query := `
-		SELECT id, aggregate_id, aggregate_type, event_type, event_payload,
-		       saga_id, created_at, processed_at, retry_count, max_retries, last_error
-		FROM outbox_events
-		WHERE processed_at IS NULL AND retry_count < max_retries
-		ORDER BY created_at ASC
-		LIMIT $1
-	`
+// claimUnprocessedEventsQuery claims the oldest claimable events for $1, at most $2 for $3 seconds
+// An event is claimable only once every earlier event of its aggregate is processed
+const claimUnprocessedEventsQuery = `
+		UPDATE outbox_events
+		SET claimed_by = $1, claimed_until = NOW() + make_interval(secs => $3)
+		WHERE id IN (
+			SELECT e.id
+			FROM outbox_events e
+			WHERE e.processed_at IS NULL AND e.retry_count < e.max_retries
+			  AND (e.claimed_until IS NULL OR e.claimed_until < NOW())
+			  AND (e.next_attempt_at IS NULL OR e.next_attempt_at <= NOW())
+			  AND NOT EXISTS (
+				SELECT 1
+				FROM outbox_events prior
+				WHERE prior.aggregate_id = e.aggregate_id
+				  AND prior.aggregate_sequence < e.aggregate_sequence
+				  AND prior.processed_at IS NULL
+			  )
+			ORDER BY e.created_at ASC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxEventColumns
 
-	rows, err := r.pool.Query(ctx, query, limit)
+// ClaimUnprocessedEvents claims unprocessed events for publishing by owner
+func (r *PostgresOutboxRepository) ClaimUnprocessedEvents(ctx context.Context, owner string, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	rows, err := r.pool.Query(ctx, claimUnprocessedEventsQuery, owner, limit, lease.Seconds())
 	if err != nil {
-		r.logger.Error().Err(err).Msg("failed to query unprocessed events")
-		return nil, fmt.Errorf("query unprocessed events: %w", err)
+		r.logger.Error().Err(err).Msg("failed to claim unprocessed events")
+		return nil, fmt.Errorf("claim unprocessed events: %w", err)
 	}
-	defer rows.Close()
-
-	var events []*models.OutboxEvent
-	for rows.Next() {
-		var event models.OutboxEvent
-		var payloadJSON []byte
-
-		err := rows.Scan(
-			&event.ID,
-			&event.AggregateID,
-			&event.AggregateType,
-			&event.EventType,
-			&payloadJSON,
-			&event.SagaID,
-			&event.CreatedAt,
-			&event.ProcessedAt,
-			&event.RetryCount,
-			&event.MaxRetries,
-			&event.LastError,
-		)
-
-		if err != nil {
-			r.logger.Error().Err(err).Msg("failed to scan outbox event")
-			return nil, fmt.Errorf("scan outbox event: %w", err)
-		}
-
-		// Parse JSON payload
-		if err := json.Unmarshal(payloadJSON, &event.EventPayload); err != nil {
-			r.logger.Error().Err(err).
-				Str("event_id", event.ID.String()).
-				Msg("failed to unmarshal event payload")
-			return nil, fmt.Errorf("unmarshal event payload: %w", err)
-		}
 
-		events = append(events, &event)
+	events, err := r.scanEvents(rows)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		r.logger.Error().Err(err).Msg("rows error")
-		return nil, fmt.Errorf("rows error: %w", err)
-	}
+	// RETURNING does not preserve the subquery order
+	sortClaimedEvents(events)
 
 	r.logger.Debug().
+		Str("owner", owner).
 		Int("count", len(events)).
-		Msg("retrieved unprocessed events")
+		Msg("claimed unprocessed events")
 
 	return events, nil
 }
 
-// MarkProcessed marks an event as successfully published
-func (r *PostgresOutboxRepository) MarkProcessed(ctx context.Context, eventID uuid.UUID) error {
+// sortClaimedEvents puts claimed events in publish order, oldest first and by aggregate sequence on ties
+func sortClaimedEvents(events []*models.OutboxEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.Before(events[j].CreatedAt)
+		}
+		return events[i].AggregateSequence < events[j].AggregateSequence
+	})
+}
+
+// MarkProcessed marks an event claimed by owner as successfully published
+func (r *PostgresOutboxRepository) MarkProcessed(ctx context.Context, eventID uuid.UUID, owner string) error {
 	query := `
 		UPDATE outbox_events
-		SET processed_at = NOW()
-		WHERE id = $1
+		SET processed_at = NOW(), claimed_by = NULL, claimed_until = NULL
+		WHERE id = $1 AND claimed_by = $2
 	`
 
-	result, err := r.pool.Exec(ctx, query, eventID)
+	result, err := r.pool.Exec(ctx, query, eventID, owner)
 	if err != nil {
 		r.logger.Error().Err(err).
 			Str("event_id", eventID.String()).
@@ -191,8 +246,9 @@ func (r *PostgresOutboxRepository) MarkProcessed(ctx context.Context, eventID uu
 	if result.RowsAffected() == 0 {
 		r.logger.Warn().
 			Str("event_id", eventID.String()).
-			Msg("event not found")
-		return fmt.Errorf("event not found: %s", eventID.String())
+			Str("owner", owner).
+			Msg("event not found or claim lost")
+		return fmt.Errorf("event not found or claim lost: %s", eventID.String())
 	}
 
 	r.logger.Debug().
@@ -202,45 +258,168 @@ func (r *PostgresOutboxRepository) MarkProcessed(ctx context.Context, eventID uu
 	return nil
 }
 
-// IncrementRetryCount increments the retry count for a failed publish
-func (r *PostgresOutboxRepository) IncrementRetryCount(ctx context.Context, eventID uuid.UUID, errorMsg string) error {
+// IncrementRetryCount increments the retry count for a failed publish by owner
+func (r *PostgresOutboxRepository) IncrementRetryCount(ctx context.Context, eventID uuid.UUID, owner string, errorMsg string, retryAfter time.Duration) (bool, error) {
 	query := `
 		UPDATE outbox_events
-		SET retry_count = retry_count + 1, last_error = $2
-		WHERE id = $1
+		SET retry_count = retry_count + 1, last_error = $3,
+		    next_attempt_at = NOW() + make_interval(secs => $4),
+		    claimed_by = NULL, claimed_until = NULL,
+		    dead_lettered_at = CASE WHEN retry_count + 1 >= max_retries THEN NOW() END
+		WHERE id = $1 AND claimed_by = $2
+		RETURNING dead_lettered_at IS NOT NULL
 	`
 
-	result, err := r.pool.Exec(ctx, query, eventID, errorMsg)
+	var deadLettered bool
+	err := r.pool.QueryRow(ctx, query, eventID, owner, errorMsg, retryAfter.Seconds()).Scan(&deadLettered)
+	if errors.Is(err, pgx.ErrNoRows) {
+		r.logger.Warn().
+			Str("event_id", eventID.String()).
+			Str("owner", owner).
+			Msg("event not found or claim lost")
+		return false, fmt.Errorf("event not found or claim lost: %s", eventID.String())
+	}
 	if err != nil {
 		r.logger.Error().Err(err).
 			Str("event_id", eventID.String()).
 			Msg("failed to increment retry count")
-		return fmt.Errorf("increment retry count: %w", err)
-	}
-
-	if result.RowsAffected() == 0 {
-		r.logger.Warn().
-			Str("event_id", eventID.String()).
-			Msg("event not found")
-		return fmt.Errorf("event not found: %s", eventID.String())
+		return false, fmt.Errorf("increment retry count: %w", err)
 	}
 
 	r.logger.Debug().
 		Str("event_id", eventID.String()).
 		Int("error_length", len(errorMsg)).
+		Dur("retry_after", retryAfter).
+		Bool("dead_lettered", deadLettered).
 		Msg("retry count incremented")
 
+	return deadLettered, nil
+}
+
+// GetByID retrieves an outbox event by ID
+func (r *PostgresOutboxRepository) GetByID(ctx context.Context, eventID uuid.UUID) (*models.OutboxEvent, error) {
+	query := `SELECT ` + outboxEventColumns + ` FROM outbox_events WHERE id = $1`
+
+	event, err := scanOutboxEvent(r.pool.QueryRow(ctx, query, eventID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, models.ErrOutboxEventNotFound
+	}
+	if err != nil {
+		r.logger.Error().Err(err).
+			Str("event_id", eventID.String()).
+			Msg("failed to get outbox event")
+		return nil, fmt.Errorf("get outbox event: %w", err)
+	}
+
+	return event, nil
+}
+
+// ListMarketEvents retrieves the sequenced events of a market's orders and matches
+func (r *PostgresOutboxRepository) ListMarketEvents(ctx context.Context, marketID string) ([]*models.OutboxEvent, error) {
+	query := `
+		SELECT ` + outboxEventColumns + `
+		FROM outbox_events
+		WHERE market_sequence IS NOT NULL
+		  AND aggregate_id IN (
+			SELECT id FROM orders WHERE event_id = $1
+			UNION ALL
+			SELECT id FROM matches WHERE market_id = $1
+		  )
+		ORDER BY market_sequence ASC, created_at ASC
+	`
+
+	rows, err := r.pool.Query(ctx, query, marketID)
+	if err != nil {
+		r.logger.Error().Err(err).
+			Str("market_id", marketID).
+			Msg("failed to query market events")
+		return nil, fmt.Errorf("query market events: %w", err)
+	}
+
+	return r.scanEvents(rows)
+}
+
+// ListDeadLettered retrieves dead-lettered events, oldest first
+func (r *PostgresOutboxRepository) ListDeadLettered(ctx context.Context, limit, offset int) ([]*models.OutboxEvent, error) {
+	query := `
+		SELECT ` + outboxEventColumns + `
+		FROM outbox_events
+		WHERE dead_lettered_at IS NOT NULL AND processed_at IS NULL
+		ORDER BY dead_lettered_at ASC, id ASC
+		LIMIT $1 OFFSET $2
+	`
+
+	rows, err := r.pool.Query(ctx, query, limit, offset)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("failed to query dead-lettered events")
+		return nil, fmt.Errorf("query dead-lettered events: %w", err)
+	}
+
+	return r.scanEvents(rows)
+}
+
+// CountDeadLettered returns the number of events awaiting replay
+func (r *PostgresOutboxRepository) CountDeadLettered(ctx context.Context) (int64, error) {
+	query := `SELECT COUNT(*) FROM outbox_events WHERE dead_lettered_at IS NOT NULL AND processed_at IS NULL`
+
+	var count int64
+	if err := r.pool.QueryRow(ctx, query).Scan(&count); err != nil {
+		r.logger.Error().Err(err).Msg("failed to count dead-lettered events")
+		return 0, fmt.Errorf("count dead-lettered events: %w", err)
+	}
+
+	return count, nil
+}
+
+// ReplayDeadLettered resets a dead-lettered event so publishers pick it up again
+// last_error is kept for reference until the next attempt overwrites it
+func (r *PostgresOutboxRepository) ReplayDeadLettered(ctx context.Context, eventID uuid.UUID) error {
+	query := `
+		UPDATE outbox_events
+		SET retry_count = 0, dead_lettered_at = NULL, next_attempt_at = NULL,
+		    claimed_by = NULL, claimed_until = NULL
+		WHERE id = $1 AND dead_lettered_at IS NOT NULL AND processed_at IS NULL
+	`
+
+	result, err := r.pool.Exec(ctx, query, eventID)
+	if err != nil {
+		r.logger.Error().Err(err).
+			Str("event_id", eventID.String()).
+			Msg("failed to replay dead-lettered event")
+		return fmt.Errorf("replay dead-lettered event: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		// Distinguish a missing event from one that isn't dead-lettered
+		if _, err := r.GetByID(ctx, eventID); err != nil {
+			return err
+		}
+		return models.ErrOutboxNotDeadLettered
+	}
+
+	r.logger.Info().
+		Str("event_id", eventID.String()).
+		Msg("dead-lettered event replayed")
+
 	return nil
 }
 
-// CleanupProcessedEvents deletes old processed events to prevent table bloat
-func (r *PostgresOutboxRepository) CleanupProcessedEvents(ctx context.Context, olderThan time.Duration) (int64, error) {
+// CleanupProcessedEvents deletes up to limit events processed more than olderThan ago
+// Rows locked by a concurrent writer are skipped and picked up by a later batch
+func (r *PostgresOutboxRepository) CleanupProcessedEvents(ctx context.Context, olderThan time.Duration, limit int) (int64, error) {
 	query := `
 		DELETE FROM outbox_events
-		WHERE processed_at IS NOT NULL AND processed_at < NOW() - $1::interval
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE processed_at IS NOT NULL
+			  AND processed_at < NOW() - make_interval(secs => $1)
+			ORDER BY processed_at ASC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
 	`
 
-	result, err := r.pool.Exec(ctx, query, olderThan.String())
+	result, err := r.pool.Exec(ctx, query, olderThan.Seconds(), limit)
 	if err != nil {
 		r.logger.Error().Err(err).
 			Dur("older_than", olderThan).
@@ -250,7 +429,7 @@ func (r *PostgresOutboxRepository) CleanupProcessedEvents(ctx context.Context, o
 
 	deletedCount := result.RowsAffected()
 	if deletedCount > 0 {
-		r.logger.Info().
+		r.logger.Debug().
 			Int64("deleted_count", deletedCount).
 			Dur("older_than", olderThan).
 			Msg("cleaned up processed events")
@@ -258,3 +437,80 @@ func (r *PostgresOutboxRepository) CleanupProcessedEvents(ctx context.Context, o
 
 	return deletedCount, nil
 }
+
+// outboxEventColumns lists the columns read by scanOutboxEvent, in scan order
+const outboxEventColumns = `id, aggregate_id, aggregate_type, aggregate_sequence, event_type, schema_version, event_payload,
+		saga_id, created_at, processed_at, retry_count, max_retries, last_error, next_attempt_at, dead_lettered_at,
+		trace_parent, trace_state, market_sequence`
+
+// scanOutboxEvent scans a row selected with outboxEventColumns
+func scanOutboxEvent(row pgx.Row) (*models.OutboxEvent, error) {
+	var event models.OutboxEvent
+	var payloadJSON []byte
+
+	err := row.Scan(
+		&event.ID,
+		&event.AggregateID,
+		&event.AggregateType,
+		&event.AggregateSequence,
+		&event.EventType,
+		&event.SchemaVersion,
+		&payloadJSON,
+		&event.SagaID,
+		&event.CreatedAt,
+		&event.ProcessedAt,
+		&event.RetryCount,
+		&event.MaxRetries,
+		&event.LastError,
+		&event.NextAttemptAt,
+		&event.DeadLetteredAt,
+		&event.TraceParent,
+		&event.TraceState,
+		&event.MarketSequence,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse JSON payload
+	if err := json.Unmarshal(payloadJSON, &event.EventPayload); err != nil {
+		return nil, fmt.Errorf("unmarshal event payload %s: %w", event.ID, err)
+	}
+
+	return &event, nil
+}
+
+// scanEvents scans and closes rows selected with outboxEventColumns
+func (r *PostgresOutboxRepository) scanEvents(rows pgx.Rows) ([]*models.OutboxEvent, error) {
+	defer rows.Close()
+
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		event, err := scanOutboxEvent(rows)
+		if err != nil {
+			r.logger.Error().Err(err).Msg("failed to scan outbox event")
+			return nil, fmt.Errorf("scan outbox event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error().Err(err).Msg("rows error")
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return events, nil
+}
+
+// captureTraceContext records the W3C trace context of ctx on the event, if any
+func captureTraceContext(ctx context.Context, event *models.OutboxEvent) {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+
+	if traceParent := carrier.Get("traceparent"); traceParent != "" {
+		event.TraceParent = &traceParent
+	}
+	if traceState := carrier.Get("tracestate"); traceState != "" {
+		event.TraceState = &traceState
+	}
+}
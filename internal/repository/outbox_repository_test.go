@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// normalizeSQL collapses whitespace so clauses can be matched regardless of layout
+func normalizeSQL(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+func TestClaimUnprocessedEventsQuery(t *testing.T) {
+	query := normalizeSQL(claimUnprocessedEventsQuery)
+
+	tests := []struct {
+		name   string
+		clause string
+	}{
+		{"claims for the owner and lease", "SET claimed_by = $1, claimed_until = NOW() + make_interval(secs => $3)"},
+		{"skips processed and exhausted events", "WHERE e.processed_at IS NULL AND e.retry_count < e.max_retries"},
+		{"skips leased events", "AND (e.claimed_until IS NULL OR e.claimed_until < NOW())"},
+		{"skips events backing off", "AND (e.next_attempt_at IS NULL OR e.next_attempt_at <= NOW())"},
+		{
+			"waits for earlier events of the aggregate",
+			"AND NOT EXISTS ( SELECT 1 FROM outbox_events prior WHERE prior.aggregate_id = e.aggregate_id" +
+				" AND prior.aggregate_sequence < e.aggregate_sequence AND prior.processed_at IS NULL )",
+		},
+		{"takes the oldest events first", "ORDER BY e.created_at ASC LIMIT $2"},
+		{"skips rows other publishers hold", "FOR UPDATE SKIP LOCKED"},
+		{"returns the scanned columns", "RETURNING " + normalizeSQL(outboxEventColumns)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Contains(t, query, tt.clause)
+		})
+	}
+
+	// ClaimUnprocessedEvents binds exactly owner, limit and lease
+	assert.NotContains(t, query, "$4")
+}
+
+func TestSortClaimedEvents(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	orderA := uuid.New()
+	orderB := uuid.New()
+	event := func(aggregateID uuid.UUID, sequence int64, createdAt time.Time) *models.OutboxEvent {
+		return &models.OutboxEvent{
+			ID:                uuid.New(),
+			AggregateID:       aggregateID,
+			AggregateSequence: sequence,
+			CreatedAt:         createdAt,
+		}
+	}
+
+	first := event(orderA, 1, base)
+	second := event(orderB, 4, base.Add(time.Millisecond))
+	sameInstantLow := event(orderA, 2, base.Add(2*time.Millisecond))
+	sameInstantHigh := event(orderB, 5, base.Add(2*time.Millisecond))
+	last := event(orderA, 3, base.Add(time.Second))
+
+	// RETURNING hands rows back in no particular order
+	events := []*models.OutboxEvent{last, sameInstantHigh, second, sameInstantLow, first}
+	sortClaimedEvents(events)
+
+	require.Len(t, events, 5)
+	assert.Equal(t, []*models.OutboxEvent{first, second, sameInstantLow, sameInstantHigh, last}, events)
+}
@@ -318,6 +318,181 @@ func (r *PostgresOrderRepository) UpdateMatched(ctx context.Context, tx pgx.Tx,
 	return nil
 }
 
+// CreateMatch records a matched trade between two orders
+func (r *PostgresOrderRepository) CreateMatch(ctx context.Context, tx pgx.Tx, match *models.Match) error {
+	query := `
+		INSERT INTO matches (
+			id, market_id, selection_id, back_order_id, lay_order_id,
+			back_user_id, lay_user_id, price, size, back_liability,
+			lay_liability, matched_at, sequence
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+
+	_, err := tx.Exec(ctx, query,
+		match.ID,
+		match.MarketID,
+		match.SelectionID,
+		match.BackOrderID,
+		match.LayOrderID,
+		match.BackUserID,
+		match.LayUserID,
+		match.Price.String(),
+		match.Size.String(),
+		match.BackLiability.String(),
+		match.LayLiability.String(),
+		match.MatchedAt,
+		match.Sequence,
+	)
+
+	if err != nil {
+		r.logger.Error().Err(err).
+			Str("match_id", match.ID.String()).
+			Str("back_order_id", match.BackOrderID.String()).
+			Str("lay_order_id", match.LayOrderID.String()).
+			Msg("failed to create match")
+		return fmt.Errorf("create match: %w", err)
+	}
+
+	r.logger.Debug().
+		Str("match_id", match.ID.String()).
+		Str("price", match.Price.String()).
+		Str("size", match.Size.String()).
+		Msg("match created")
+
+	return nil
+}
+
+// GetMatchesByOrderID gets all matches an order took part in, on either side
+func (r *PostgresOrderRepository) GetMatchesByOrderID(ctx context.Context, tx pgx.Tx, orderID uuid.UUID) ([]*models.Match, error) {
+	query := `
+		SELECT id, market_id, selection_id, back_order_id, lay_order_id,
+			   back_user_id, lay_user_id, price, size, back_liability,
+			   lay_liability, matched_at, settled_at, reversed_at, COALESCE(sequence, 0)
+		FROM matches
+		WHERE back_order_id = $1 OR lay_order_id = $1
+		ORDER BY matched_at ASC, sequence ASC
+	`
+
+	rows, err := tx.Query(ctx, query, orderID)
+	if err != nil {
+		r.logger.Error().Err(err).
+			Str("order_id", orderID.String()).
+			Msg("failed to query matches by order")
+		return nil, fmt.Errorf("query matches by order: %w", err)
+	}
+
+	return r.scanMatches(rows)
+}
+
+// GetMatchesByMarketID gets all matches of a market in sequence order
+func (r *PostgresOrderRepository) GetMatchesByMarketID(ctx context.Context, marketID string) ([]*models.Match, error) {
+	query := `
+		SELECT id, market_id, selection_id, back_order_id, lay_order_id,
+			   back_user_id, lay_user_id, price, size, back_liability,
+			   lay_liability, matched_at, settled_at, reversed_at, COALESCE(sequence, 0)
+		FROM matches
+		WHERE market_id = $1
+		ORDER BY sequence ASC NULLS FIRST, matched_at ASC
+	`
+
+	rows, err := r.pool.Query(ctx, query, marketID)
+	if err != nil {
+		r.logger.Error().Err(err).
+			Str("market_id", marketID).
+			Msg("failed to query matches by market")
+		return nil, fmt.Errorf("query matches by market: %w", err)
+	}
+
+	return r.scanMatches(rows)
+}
+
+// scanMatches scans match rows selected in GetMatchesByOrderID column order
+func (r *PostgresOrderRepository) scanMatches(rows pgx.Rows) ([]*models.Match, error) {
+	defer rows.Close()
+
+	var matches []*models.Match
+	for rows.Next() {
+		var match models.Match
+		var priceStr, sizeStr, backLiabilityStr, layLiabilityStr string
+
+		err := rows.Scan(
+			&match.ID,
+			&match.MarketID,
+			&match.SelectionID,
+			&match.BackOrderID,
+			&match.LayOrderID,
+			&match.BackUserID,
+			&match.LayUserID,
+			&priceStr,
+			&sizeStr,
+			&backLiabilityStr,
+			&layLiabilityStr,
+			&match.MatchedAt,
+			&match.SettledAt,
+			&match.ReversedAt,
+			&match.Sequence,
+		)
+		if err != nil {
+			r.logger.Error().Err(err).Msg("failed to scan match")
+			return nil, fmt.Errorf("scan match: %w", err)
+		}
+
+		// Parse decimal amounts
+		if match.Price, err = decimal.NewFromString(priceStr); err != nil {
+			return nil, fmt.Errorf("parse price: %w", err)
+		}
+		if match.Size, err = decimal.NewFromString(sizeStr); err != nil {
+			return nil, fmt.Errorf("parse size: %w", err)
+		}
+		if match.BackLiability, err = decimal.NewFromString(backLiabilityStr); err != nil {
+			return nil, fmt.Errorf("parse back_liability: %w", err)
+		}
+		if match.LayLiability, err = decimal.NewFromString(layLiabilityStr); err != nil {
+			return nil, fmt.Errorf("parse lay_liability: %w", err)
+		}
+
+		matches = append(matches, &match)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error().Err(err).Msg("rows error")
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return matches, nil
+}
+
+// ReverseMatch marks a match as reversed
+func (r *PostgresOrderRepository) ReverseMatch(ctx context.Context, tx pgx.Tx, matchID uuid.UUID) error {
+	query := `
+		UPDATE matches
+		SET reversed_at = NOW()
+		WHERE id = $1 AND reversed_at IS NULL
+	`
+
+	result, err := tx.Exec(ctx, query, matchID)
+	if err != nil {
+		r.logger.Error().Err(err).
+			Str("match_id", matchID.String()).
+			Msg("failed to reverse match")
+		return fmt.Errorf("reverse match: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		r.logger.Warn().
+			Str("match_id", matchID.String()).
+			Msg("match not found or already reversed")
+		return fmt.Errorf("match not found or already reversed: %s", matchID.String())
+	}
+
+	r.logger.Info().
+		Str("match_id", matchID.String()).
+		Msg("match reversed")
+
+	return nil
+}
+
 // GetByUserID gets orders for a user with pagination
 func (r *PostgresOrderRepository) GetByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.Order, error) {
 	query := `
@@ -419,6 +594,34 @@ func (r *PostgresOrderRepository) GetPendingOrders(ctx context.Context, marketID
 	return r.scanOrders(rows)
 }
 
+// GetUnsettledMatchedOrders gets orders of a market with a matched size that are not yet settled
+func (r *PostgresOrderRepository) GetUnsettledMatchedOrders(ctx context.Context, marketID string) ([]*models.Order, error) {
+	query := `
+		SELECT id, user_id, market_id, selection_id, side, price, size,
+			   size_matched, size_remaining, status, reservation_id, saga_id,
+			   idempotency_key, placed_at, matched_at, cancelled_at, version
+		FROM orders
+		WHERE market_id = $1 AND size_matched > 0 AND status NOT IN ($2, $3, $4)
+		ORDER BY placed_at ASC
+	`
+
+	rows, err := r.pool.Query(ctx, query,
+		marketID,
+		models.OrderStatusSettledWin,
+		models.OrderStatusSettledLoss,
+		models.OrderStatusCompensated,
+	)
+	if err != nil {
+		r.logger.Error().Err(err).
+			Str("market_id", marketID).
+			Msg("failed to query unsettled matched orders")
+		return nil, fmt.Errorf("query unsettled matched orders: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanOrders(rows)
+}
+
 // scanOrder scans a single order from a row
 func (r *PostgresOrderRepository) scanOrder(ctx context.Context, row pgx.Row) (*models.Order, error) {
 	var order models.Order
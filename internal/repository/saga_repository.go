@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// SagaRepository defines the interface for saga compensation bookkeeping
+type SagaRepository interface {
+	// Lock takes a transaction-scoped advisory lock on the saga ID
+	// Serializes placement and compensation of the same saga
+	// MUST be called within a transaction
+	Lock(ctx context.Context, tx pgx.Tx, sagaID uuid.UUID) error
+
+	// IsCompensated checks whether the saga has already been compensated
+	// MUST be called within a transaction
+	IsCompensated(ctx context.Context, tx pgx.Tx, sagaID uuid.UUID) (bool, error)
+
+	// RecordCompensation records that the saga has been compensated
+	// orderID is nil when compensation arrives before the order was placed
+	// MUST be called within a transaction
+	RecordCompensation(ctx context.Context, tx pgx.Tx, sagaID uuid.UUID, orderID *uuid.UUID, reason string) error
+}
+
+// PostgresSagaRepository implements SagaRepository using PostgreSQL
+type PostgresSagaRepository struct {
+	pool   *pgxpool.Pool
+	logger zerolog.Logger
+}
+
+// NewPostgresSagaRepository creates a new PostgreSQL saga repository
+func NewPostgresSagaRepository(pool *pgxpool.Pool, logger zerolog.Logger) *PostgresSagaRepository {
+	return &PostgresSagaRepository{
+		pool:   pool,
+		logger: logger.With().Str("component", "postgres_saga_repository").Logger(),
+	}
+}
+
+// Lock takes a transaction-scoped advisory lock on the saga ID
+func (r *PostgresSagaRepository) Lock(ctx context.Context, tx pgx.Tx, sagaID uuid.UUID) error {
+	query := `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`
+
+	if _, err := tx.Exec(ctx, query, sagaID.String()); err != nil {
+		r.logger.Error().Err(err).
+			Str("saga_id", sagaID.String()).
+			Msg("failed to lock saga")
+		return fmt.Errorf("lock saga: %w", err)
+	}
+
+	return nil
+}
+
+// IsCompensated checks whether the saga has already been compensated
+func (r *PostgresSagaRepository) IsCompensated(ctx context.Context, tx pgx.Tx, sagaID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM saga_compensations WHERE saga_id = $1)`
+
+	var exists bool
+	if err := tx.QueryRow(ctx, query, sagaID).Scan(&exists); err != nil {
+		r.logger.Error().Err(err).
+			Str("saga_id", sagaID.String()).
+			Msg("failed to check saga compensation")
+		return false, fmt.Errorf("check saga compensation: %w", err)
+	}
+
+	return exists, nil
+}
+
+// RecordCompensation records that the saga has been compensated
+func (r *PostgresSagaRepository) RecordCompensation(ctx context.Context, tx pgx.Tx, sagaID uuid.UUID, orderID *uuid.UUID, reason string) error {
+	query := `
+		INSERT INTO saga_compensations (saga_id, order_id, reason, compensated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (saga_id) DO UPDATE
+		SET order_id = COALESCE(saga_compensations.order_id, EXCLUDED.order_id)
+	`
+
+	if _, err := tx.Exec(ctx, query, sagaID, orderID, reason); err != nil {
+		r.logger.Error().Err(err).
+			Str("saga_id", sagaID.String()).
+			Msg("failed to record saga compensation")
+		return fmt.Errorf("record saga compensation: %w", err)
+	}
+
+	r.logger.Debug().
+		Str("saga_id", sagaID.String()).
+		Bool("order_found", orderID != nil).
+		Msg("saga compensation recorded")
+
+	return nil
+}
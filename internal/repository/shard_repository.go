@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// ShardRepository defines the interface for the matching engine's shard layout and market owners
+type ShardRepository interface {
+	// EnsureLayout creates the layout with the given replicas unless it exists
+	// Returns the current layout, an existing one is left unchanged
+	EnsureLayout(ctx context.Context, name string, replicas int) (*models.ShardLayout, error)
+
+	// GetLayout retrieves a layout, nil if it does not exist
+	GetLayout(ctx context.Context, name string) (*models.ShardLayout, error)
+
+	// ClaimMarket records ordinal as the holder of a market nobody holds yet
+	// Returns the ordinal holding the market, which is ordinal unless another
+	// replica already holds it
+	ClaimMarket(ctx context.Context, marketID string, ordinal int) (int, error)
+
+	// TransferMarket moves a market from one holder to another
+	// Returns models.ErrNotMarketOwner if from no longer holds it
+	TransferMarket(ctx context.Context, marketID string, from, to int) error
+
+	// ListMarketOwners retrieves the holder of every market
+	ListMarketOwners(ctx context.Context) (map[string]int, error)
+}
+
+// PostgresShardRepository implements ShardRepository using PostgreSQL
+type PostgresShardRepository struct {
+	pool   *pgxpool.Pool
+	logger zerolog.Logger
+}
+
+// NewPostgresShardRepository creates a new PostgreSQL shard repository
+func NewPostgresShardRepository(pool *pgxpool.Pool, logger zerolog.Logger) *PostgresShardRepository {
+	return &PostgresShardRepository{
+		pool:   pool,
+		logger: logger.With().Str("component", "postgres_shard_repository").Logger(),
+	}
+}
+
+// EnsureLayout creates the layout with the given replicas unless it exists
+func (r *PostgresShardRepository) EnsureLayout(ctx context.Context, name string, replicas int) (*models.ShardLayout, error) {
+	query := `
+		INSERT INTO engine_shard_layouts (name, replicas, version, updated_at)
+		VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (name) DO NOTHING
+	`
+
+	if _, err := r.pool.Exec(ctx, query, name, replicas); err != nil {
+		r.logger.Error().Err(err).Str("name", name).Msg("failed to create shard layout")
+		return nil, fmt.Errorf("create shard layout: %w", err)
+	}
+	return r.GetLayout(ctx, name)
+}
+
+// GetLayout retrieves a layout, nil if it does not exist
+func (r *PostgresShardRepository) GetLayout(ctx context.Context, name string) (*models.ShardLayout, error) {
+	query := `
+		SELECT name, replicas, version, updated_at
+		FROM engine_shard_layouts
+		WHERE name = $1
+	`
+
+	var layout models.ShardLayout
+	err := r.pool.QueryRow(ctx, query, name).Scan(
+		&layout.Name,
+		&layout.Replicas,
+		&layout.Version,
+		&layout.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error().Err(err).Str("name", name).Msg("failed to get shard layout")
+		return nil, fmt.Errorf("get shard layout: %w", err)
+	}
+	return &layout, nil
+}
+
+// ClaimMarket records ordinal as the holder of a market nobody holds yet
+func (r *PostgresShardRepository) ClaimMarket(ctx context.Context, marketID string, ordinal int) (int, error) {
+	// The no-op update makes RETURNING yield the existing holder on conflict
+	query := `
+		INSERT INTO engine_market_owners (market_id, ordinal, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (market_id) DO UPDATE
+		SET market_id = EXCLUDED.market_id
+		RETURNING ordinal
+	`
+
+	var holder int
+	if err := r.pool.QueryRow(ctx, query, marketID, ordinal).Scan(&holder); err != nil {
+		r.logger.Error().Err(err).Str("market_id", marketID).Msg("failed to claim market")
+		return 0, fmt.Errorf("claim market: %w", err)
+	}
+	return holder, nil
+}
+
+// TransferMarket moves a market from one holder to another
+func (r *PostgresShardRepository) TransferMarket(ctx context.Context, marketID string, from, to int) error {
+	query := `
+		UPDATE engine_market_owners
+		SET ordinal = $3, updated_at = NOW()
+		WHERE market_id = $1 AND ordinal = $2
+	`
+
+	result, err := r.pool.Exec(ctx, query, marketID, from, to)
+	if err != nil {
+		r.logger.Error().Err(err).Str("market_id", marketID).Msg("failed to transfer market")
+		return fmt.Errorf("transfer market: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w: market %s is not held by %d", models.ErrNotMarketOwner, marketID, from)
+	}
+
+	r.logger.Info().
+		Str("market_id", marketID).
+		Int("from", from).
+		Int("to", to).
+		Msg("market transferred")
+
+	return nil
+}
+
+// ListMarketOwners retrieves the holder of every market
+func (r *PostgresShardRepository) ListMarketOwners(ctx context.Context) (map[string]int, error) {
+	query := `SELECT market_id, ordinal FROM engine_market_owners`
+
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("failed to list market owners")
+		return nil, fmt.Errorf("list market owners: %w", err)
+	}
+	defer rows.Close()
+
+	owners := make(map[string]int)
+	for rows.Next() {
+		var marketID string
+		var ordinal int
+		if err := rows.Scan(&marketID, &ordinal); err != nil {
+			return nil, fmt.Errorf("scan market owner: %w", err)
+		}
+		owners[marketID] = ordinal
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list market owners: %w", err)
+	}
+	return owners, nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// snapshotsRetained is how many snapshots are kept per engine
+// The one before the latest is kept in case the latest cannot be decoded
+const snapshotsRetained = 2
+
+// SnapshotRepository defines the interface for matching engine snapshots
+// Satisfies matchingengine.SnapshotStore
+type SnapshotRepository interface {
+	// Save stores a snapshot and drops the engine's older ones
+	Save(ctx context.Context, snapshot *matchingengine.Snapshot) error
+
+	// Latest retrieves the engine's most recent snapshot
+	// Returns nil if none has been saved
+	Latest(ctx context.Context) (*matchingengine.Snapshot, error)
+}
+
+// PostgresSnapshotRepository implements SnapshotRepository using PostgreSQL
+// Snapshots are scoped by engine ID, since each replica journals its own commands
+type PostgresSnapshotRepository struct {
+	pool     *pgxpool.Pool
+	engineID string
+	logger   zerolog.Logger
+}
+
+// NewPostgresSnapshotRepository creates a new PostgreSQL snapshot repository for an engine
+func NewPostgresSnapshotRepository(pool *pgxpool.Pool, engineID string, logger zerolog.Logger) *PostgresSnapshotRepository {
+	return &PostgresSnapshotRepository{
+		pool:     pool,
+		engineID: engineID,
+		logger:   logger.With().Str("component", "postgres_snapshot_repository").Logger(),
+	}
+}
+
+// Save stores a snapshot and drops the engine's older ones
+func (r *PostgresSnapshotRepository) Save(ctx context.Context, snapshot *matchingengine.Snapshot) error {
+	data, err := snapshot.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	insert := `
+		INSERT INTO engine_snapshots (engine_id, journal_position, taken_at, data)
+		VALUES ($1, $2, $3, $4)
+	`
+	if _, err := tx.Exec(ctx, insert, r.engineID, snapshot.Position, snapshot.TakenAt, data); err != nil {
+		r.logger.Error().Err(err).
+			Int64("journal_position", snapshot.Position).
+			Msg("failed to save snapshot")
+		return fmt.Errorf("save snapshot: %w", err)
+	}
+
+	prune := `
+		DELETE FROM engine_snapshots
+		WHERE engine_id = $1
+		  AND id NOT IN (
+			SELECT id FROM engine_snapshots
+			WHERE engine_id = $1
+			ORDER BY journal_position DESC, id DESC
+			LIMIT $2
+		  )
+	`
+	if _, err := tx.Exec(ctx, prune, r.engineID, snapshotsRetained); err != nil {
+		r.logger.Error().Err(err).Msg("failed to prune snapshots")
+		return fmt.Errorf("prune snapshots: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	r.logger.Debug().
+		Int64("journal_position", snapshot.Position).
+		Int("bytes", len(data)).
+		Msg("snapshot saved")
+
+	return nil
+}
+
+// Latest retrieves the engine's most recent snapshot
+func (r *PostgresSnapshotRepository) Latest(ctx context.Context) (*matchingengine.Snapshot, error) {
+	query := `
+		SELECT data
+		FROM engine_snapshots
+		WHERE engine_id = $1
+		ORDER BY journal_position DESC, id DESC
+		LIMIT 1
+	`
+
+	var data []byte
+	err := r.pool.QueryRow(ctx, query, r.engineID).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error().Err(err).Msg("failed to get latest snapshot")
+		return nil, fmt.Errorf("get latest snapshot: %w", err)
+	}
+
+	snapshot, err := matchingengine.UnmarshalSnapshot(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return snapshot, nil
+}
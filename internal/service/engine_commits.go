@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/repository"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+// CommandCommitted decides in-doubt journal commands against the orders table
+// A placement is committed once its order row exists, with its matches in the same
+// transaction, and a cancellation once the row is cancelled or compensated. Market
+// status changes, order removals and reinstatements have no persist step: the
+// database changed before they were submitted
+func CommandCommitted(orderRepo repository.OrderRepository) matchingengine.CommittedFunc {
+	return func(ctx context.Context, cmd *matchingengine.Command) (bool, error) {
+		orderID := cmd.OrderID
+		switch cmd.Type {
+		case matchingengine.CommandPlace:
+			orderID = cmd.Order.ID
+		case matchingengine.CommandCancel, matchingengine.CommandAmend:
+		default:
+			return true, nil
+		}
+
+		order, err := orderRepo.GetByID(ctx, orderID)
+		if errors.Is(err, models.ErrOrderNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to get order: %w", err)
+		}
+
+		switch cmd.Type {
+		case matchingengine.CommandCancel:
+			return order.Status == models.OrderStatusCancelled || order.Status == models.OrderStatusCompensated, nil
+		case matchingengine.CommandAmend:
+			return order.Price.Equal(cmd.Price) && order.Size.Equal(cmd.Size), nil
+		default:
+			return true, nil
+		}
+	}
+}
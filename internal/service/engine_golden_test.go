@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The golden file pins the matches of a scripted session byte for byte, IDs and
+// times included. Run go test -update after an intended change to matching
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+var goldenNamespace = uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+
+// steppingClock returns a clock that advances a second on every read
+func steppingClock() matchingengine.Clock {
+	now := time.Date(2026, 1, 2, 15, 4, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+func goldenOrder(n int, side models.OrderSide, price string, size int64) *models.Order {
+	ids := matchingengine.SequentialIDs(goldenNamespace, "order")
+	var id uuid.UUID
+	for i := 0; i < n; i++ {
+		id = ids()
+	}
+	amount := decimal.NewFromInt(size)
+	return &models.Order{
+		ID:            id,
+		UserID:        uuid.NewSHA1(goldenNamespace, []byte(string(side))),
+		MarketID:      "event-123",
+		SelectionID:   "team-a",
+		Side:          side,
+		Price:         decimal.RequireFromString(price),
+		Size:          amount,
+		SizeMatched:   decimal.Zero,
+		SizeRemaining: amount,
+		Status:        models.OrderStatusPending,
+		PlacedAt:      time.Date(2026, 1, 2, 15, 0, n, 0, time.UTC),
+		Version:       1,
+	}
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.MkdirAll("testdata", 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "missing golden file, run go test -update once")
+	assert.Equal(t, string(want), string(got), "matches of %s changed", name)
+}
+
+func encodeMatches(t *testing.T, matches []*models.Match) []byte {
+	data, err := json.MarshalIndent(matches, "", "  ")
+	require.NoError(t, err)
+	return append(data, '\n')
+}
+
+func TestEngine_ScriptedSessionIsDeterministic(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	journal, err := matchingengine.OpenJournal(dir, matchingengine.JournalOptions{})
+	require.NoError(t, err)
+
+	matchIDs := matchingengine.WithMatchIDs(matchingengine.SequenceMatchIDs(goldenNamespace))
+	sequencer := matchingengine.NewSequencer(
+		matchingengine.WithJournal(journal),
+		matchingengine.WithCommandClock(steppingClock()),
+		matchIDs,
+	)
+	defer sequencer.Close()
+
+	var live []*models.Match
+	persist := func(_ context.Context, batch *matchingengine.Batch) error {
+		live = append(live, batch.Matches()...)
+		return nil
+	}
+
+	// Partial fills across two levels, resting orders on both sides, a cancel and
+	// a later fill against what is left of the first lay
+	for _, order := range []*models.Order{
+		goldenOrder(1, models.OrderSideLay, "2.5", 100),
+		goldenOrder(2, models.OrderSideLay, "2.4", 40),
+		goldenOrder(3, models.OrderSideBack, "2.3", 120),
+		goldenOrder(4, models.OrderSideBack, "2.6", 50),
+		goldenOrder(5, models.OrderSideLay, "2.7", 80),
+	} {
+		_, err := sequencer.PlaceOrder(ctx, order, persist)
+		require.NoError(t, err)
+	}
+	_, err = sequencer.CancelOrder(ctx, "event-123", goldenOrder(5, models.OrderSideLay, "2.7", 80).ID, persist)
+	require.NoError(t, err)
+	_, err = sequencer.PlaceOrder(ctx, goldenOrder(6, models.OrderSideBack, "2.5", 30), persist)
+	require.NoError(t, err)
+
+	liveSnapshot, err := sequencer.Snapshot(ctx)
+	require.NoError(t, err)
+	require.NoError(t, journal.Close())
+
+	require.NotEmpty(t, live)
+	checkGolden(t, "engine_session.golden.json", encodeMatches(t, live))
+
+	// Replaying the journal reproduces the matches byte for byte
+	replayer := matchingengine.NewMarketReplayer("event-123", matchIDs)
+	var replayed []*models.Match
+	err = matchingengine.ReadJournal(dir, 0, func(cmd *matchingengine.Command) error {
+		batch, err := replayer.Apply(cmd)
+		if err != nil {
+			return err
+		}
+		replayed = append(replayed, batch.Matches()...)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, string(encodeMatches(t, live)), string(encodeMatches(t, replayed)))
+
+	// And so does recovery, down to the resting orders
+	recovered := matchingengine.NewSequencer(matchIDs)
+	defer recovered.Close()
+	_, err = recovered.Recover(ctx, dir, nil)
+	require.NoError(t, err)
+	recoveredSnapshot, err := recovered.Snapshot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, engineState(t, liveSnapshot), engineState(t, recoveredSnapshot))
+	assert.Equal(t, engineState(t, liveSnapshot), engineState(t, &matchingengine.Snapshot{
+		Markets: []*matchingengine.MarketSnapshot{replayer.Snapshot()},
+	}))
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cypherlabdev/order-book-service/internal/maintenance"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/replication"
+	"github.com/cypherlabdev/order-book-service/internal/repository"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+// ErrLeadershipLost is returned by EngineReplicator.Run when another replica took over
+var ErrLeadershipLost = errors.New("matching engine leadership lost")
+
+// EngineReplicatorConfig configures leader election and replication of the engine
+type EngineReplicatorConfig struct {
+	Election      string        // Leader record name, shared by all replicas
+	EngineID      string        // This replica's engine ID
+	Address       string        // Base URL other replicas reach this replica's replication endpoints at
+	CheckInterval time.Duration // How often leadership is checked and a lost leader stream retried
+	DrainTimeout  time.Duration // How long a new leader reads what is left of the old leader's stream
+}
+
+// EngineReplicator runs the matching engine of a replica as the leader or a hot standby
+//
+// Replicas start as standbys: they load a snapshot of the leader's books, follow
+// its journal stream and acknowledge it. When the leader's lock comes free a
+// standby takes it, claims the next epoch, which fences off the old leader's
+// transactions, applies what is left of the old leader's stream and starts
+// accepting commands. Only a standby that was caught up with the current leader,
+// the current leader restarted on its own journal, or any replica before the
+// first election may take over, so books that miss commands never lead
+type EngineReplicator struct {
+	sequencer   *matchingengine.Sequencer
+	standby     *matchingengine.Standby
+	journal     *matchingengine.Journal
+	snapshotter *EngineSnapshotter
+	elector     maintenance.Leader
+	leaders     repository.EngineLeaderRepository
+	source      replication.Source
+	fence       *FencedDatabase
+	config      EngineReplicatorConfig
+	metrics     *observability.Metrics
+	logger      zerolog.Logger
+
+	// Standby state, only used by Run
+	loaded int64 // epoch of the leader the books were loaded from, 0 if none
+	synced int64 // epoch of the leader the standby last caught up with, 0 if none
+}
+
+// NewEngineReplicator creates the replicator of a standby sequencer
+// The snapshotter must save this replica's own snapshots, and fence guard the
+// services' transactions
+func NewEngineReplicator(
+	sequencer *matchingengine.Sequencer,
+	journal *matchingengine.Journal,
+	snapshotter *EngineSnapshotter,
+	elector maintenance.Leader,
+	leaders repository.EngineLeaderRepository,
+	source replication.Source,
+	fence *FencedDatabase,
+	config EngineReplicatorConfig,
+	metrics *observability.Metrics,
+	logger zerolog.Logger,
+) *EngineReplicator {
+	return &EngineReplicator{
+		sequencer:   sequencer,
+		standby:     matchingengine.NewStandby(sequencer),
+		journal:     journal,
+		snapshotter: snapshotter,
+		elector:     elector,
+		leaders:     leaders,
+		source:      source,
+		fence:       fence,
+		config:      config,
+		metrics:     metrics,
+		logger:      logger.With().Str("component", "engine_replicator").Logger(),
+	}
+}
+
+// Run follows the leader until this replica is elected, calls promoted and leads
+// until the context is cancelled, then gives up leadership
+// Returns ErrLeadershipLost if another replica takes over in the meantime; the
+// replica's books may then be ahead of the new leader and must be reloaded
+func (r *EngineReplicator) Run(ctx context.Context, promoted func(ctx context.Context)) error {
+	r.metrics.EngineLeader.Set(0)
+	r.logger.Info().
+		Str("engine_id", r.config.EngineID).
+		Str("address", r.config.Address).
+		Msg("engine replica started as standby")
+
+	previous, err := r.awaitElection(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.promote(ctx, previous); err != nil {
+		r.elector.Release(context.Background())
+		return err
+	}
+
+	promoted(ctx)
+	return r.lead(ctx)
+}
+
+// awaitElection follows the leader until this replica may and does take over
+// Returns the leader record it takes over from, nil on the first election
+func (r *EngineReplicator) awaitElection(ctx context.Context) (*models.EngineLeader, error) {
+	for {
+		leader, err := r.leaders.Get(ctx, r.config.Election)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Warn().Err(err).Msg("failed to get engine leader")
+		}
+
+		if err == nil && r.eligible(leader) {
+			acquired, err := r.elector.Acquire(ctx)
+			if err != nil && ctx.Err() == nil {
+				r.logger.Warn().Err(err).Msg("failed to acquire engine leadership")
+			}
+			if acquired {
+				return leader, nil
+			}
+		}
+
+		// Follow the leader until its stream ends, then check the election again
+		if err == nil && leader != nil && leader.JournalID != r.journal.ID() {
+			r.follow(ctx, leader)
+		}
+
+		select {
+		case <-time.After(r.config.CheckInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// eligible returns true if this replica's books hold every command leader persisted
+func (r *EngineReplicator) eligible(leader *models.EngineLeader) bool {
+	switch {
+	case leader == nil:
+		return true
+	case leader.JournalID == r.journal.ID():
+		// This replica led last, its own journal recovered the books
+		return true
+	default:
+		return r.synced == leader.Epoch
+	}
+}
+
+// follow applies the leader's stream until it ends
+// The books are reloaded from a snapshot when they do not follow this leader's
+// journal, which a new leader's positions never continue
+func (r *EngineReplicator) follow(ctx context.Context, leader *models.EngineLeader) {
+	logger := r.logger.With().
+		Int64("epoch", leader.Epoch).
+		Str("leader", leader.EngineID).
+		Str("leader_address", leader.Address).
+		Logger()
+
+	if r.loaded != leader.Epoch {
+		if err := r.load(ctx, leader); err != nil {
+			if ctx.Err() == nil {
+				logger.Warn().Err(err).Msg("failed to load engine snapshot from leader")
+			}
+			return
+		}
+		logger.Info().Int64("position", r.standby.Position()).Msg("engine books loaded from leader")
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Acknowledgements are sent in the background, only the latest one counts
+	acks := make(chan int64, 1)
+	ack := func(position int64) {
+		select {
+		case <-acks:
+		default:
+		}
+		acks <- position
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case position := <-acks:
+				if err := r.source.Acknowledge(streamCtx, leader.Address, position); err != nil && streamCtx.Err() == nil {
+					logger.Debug().Err(err).Int64("position", position).Msg("failed to acknowledge leader stream")
+				}
+			case <-streamCtx.Done():
+				return
+			}
+		}
+	}()
+
+	err := r.source.Stream(streamCtx, leader.Address, r.standby.Position(), func(msg *matchingengine.StreamMessage) error {
+		if err := r.standby.Apply(ctx, msg); err != nil {
+			return fmt.Errorf("apply leader stream: %w", err)
+		}
+		ack(r.standby.Position())
+
+		if msg.Command == nil {
+			r.metrics.EngineReplicationLag.Set(float64(r.standby.Lag()))
+
+			// A leader that stopped waiting for acknowledgements may persist commands
+			// this standby never received
+			switch {
+			case r.standby.Synced():
+				if r.synced != leader.Epoch {
+					logger.Info().Int64("position", r.standby.Position()).Msg("standby caught up with leader")
+				}
+				r.synced = leader.Epoch
+			case r.synced == leader.Epoch && !r.standby.Attached():
+				logger.Warn().Int64("position", r.standby.Position()).Msg("leader detached standby")
+				r.synced = 0
+			default:
+				r.synced = 0
+			}
+		}
+		return nil
+	})
+	cancel()
+	<-done
+
+	switch {
+	case ctx.Err() != nil:
+	case err == nil:
+		logger.Info().Msg("leader stream ended")
+	case errors.Is(err, models.ErrNotLeader):
+		logger.Debug().Msg("replica is no longer the leader")
+	case errors.Is(err, matchingengine.ErrPositionUnavailable), errors.Is(err, matchingengine.ErrReplayDiverged):
+		// The books no longer follow the leader's journal
+		logger.Warn().Err(err).Msg("standby fell out of the leader's journal, reloading")
+		r.loaded = 0
+		r.synced = 0
+	default:
+		logger.Warn().Err(err).Msg("leader stream failed")
+	}
+}
+
+// load replaces the books with a snapshot of the leader
+func (r *EngineReplicator) load(ctx context.Context, leader *models.EngineLeader) error {
+	r.loaded = 0
+	r.synced = 0
+
+	snapshot, err := r.source.Snapshot(ctx, leader.Address)
+	if err != nil {
+		return err
+	}
+	if err := r.standby.Load(ctx, snapshot); err != nil {
+		return fmt.Errorf("load snapshot: %w", err)
+	}
+
+	// Local recovery must start from the loaded books, not from an older snapshot
+	// whose journal does not lead to them
+	if _, err := r.snapshotter.Checkpoint(ctx); err != nil {
+		return err
+	}
+
+	r.loaded = leader.Epoch
+	return nil
+}
+
+// promote makes this replica the leader after it acquired the lock
+func (r *EngineReplicator) promote(ctx context.Context, previous *models.EngineLeader) error {
+	self := &models.EngineLeader{
+		Name:      r.config.Election,
+		EngineID:  r.config.EngineID,
+		JournalID: r.journal.ID(),
+		Address:   r.config.Address,
+	}
+	if err := r.leaders.Claim(ctx, self); err != nil {
+		return fmt.Errorf("claim engine leadership: %w", err)
+	}
+
+	// The old leader can no longer persist commands, read what it journaled since
+	if previous != nil && previous.JournalID != self.JournalID && r.loaded == previous.Epoch {
+		r.drain(ctx, previous)
+	}
+
+	released, err := r.standby.Release(ctx)
+	if err != nil {
+		return fmt.Errorf("release replicated commands: %w", err)
+	}
+
+	r.fence.SetEpoch(self.Epoch)
+	r.sequencer.Promote()
+	r.metrics.EngineLeader.Set(1)
+	r.metrics.EngineReplicationLag.Set(0)
+
+	// New standbys load this leader's books, recovery starts from them too
+	if _, err := r.snapshotter.Checkpoint(ctx); err != nil {
+		r.logger.Warn().Err(err).Msg("failed to snapshot matching engine after promotion")
+	}
+
+	event := r.logger.Info().
+		Int64("epoch", self.Epoch).
+		Int64("journal_position", r.journal.Position()).
+		Int("released", released)
+	if previous != nil {
+		event = event.Str("previous_leader", previous.EngineID)
+	}
+	event.Msg("matching engine promoted to leader")
+	return nil
+}
+
+// drain applies the old leader's stream for at most DrainTimeout
+// A leader that died leaves nothing to drain, but the last command it journaled
+// for a market may or may not have been persisted, Release decides those against
+// the database
+func (r *EngineReplicator) drain(ctx context.Context, previous *models.EngineLeader) {
+	drainCtx, cancel := context.WithTimeout(ctx, r.config.DrainTimeout)
+	defer cancel()
+
+	start := r.standby.Position()
+	err := r.source.Stream(drainCtx, previous.Address, start, func(msg *matchingengine.StreamMessage) error {
+		return r.standby.Apply(ctx, msg)
+	})
+	if err != nil && drainCtx.Err() == nil {
+		r.logger.Debug().Err(err).Msg("old leader stream unavailable")
+	}
+
+	r.logger.Info().
+		Str("previous_leader", previous.EngineID).
+		Int64("records", r.standby.Position()-start).
+		Msg("old leader stream drained")
+}
+
+// lead checks leadership every CheckInterval until it is lost or the context is done
+func (r *EngineReplicator) lead(ctx context.Context) error {
+	ticker := time.NewTicker(r.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			leading, err := r.elector.Acquire(ctx)
+			if err != nil && ctx.Err() == nil {
+				r.logger.Warn().Err(err).Msg("failed to check engine leadership")
+			}
+			if leading || ctx.Err() != nil {
+				continue
+			}
+
+			r.sequencer.Demote()
+			r.fence.SetEpoch(0)
+			r.metrics.EngineLeader.Set(0)
+			r.logger.Error().Msg("matching engine leadership lost, serving journal to the new leader")
+
+			// The new leader drains this replica's stream before taking commands
+			select {
+			case <-time.After(r.config.DrainTimeout):
+			case <-ctx.Done():
+			}
+			return ErrLeadershipLost
+		case <-ctx.Done():
+			r.sequencer.Demote()
+			r.fence.SetEpoch(0)
+			r.metrics.EngineLeader.Set(0)
+			r.elector.Release(context.Background())
+			r.logger.Info().Msg("matching engine leadership released")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/mocks"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/replication"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// testElector is a leader lock the test hands out
+type testElector struct {
+	free     atomic.Bool
+	released atomic.Bool
+}
+
+func (e *testElector) Acquire(ctx context.Context) (bool, error) {
+	return e.free.Load(), nil
+}
+
+func (e *testElector) Release(ctx context.Context) {
+	e.released.Store(true)
+}
+
+// useReplicatedJournal replaces the test sequencer with a leader journaling to dir
+// whose commands wait for a standby's acknowledgement
+func (s *testServiceSetup) useReplicatedJournal(t *testing.T, dir string) (*matchingengine.Journal, *matchingengine.ReplicaAcks) {
+	journal, err := matchingengine.OpenJournal(dir, matchingengine.JournalOptions{SegmentSize: 512})
+	require.NoError(t, err)
+	acks := matchingengine.NewReplicaAcks(journal, time.Second)
+
+	s.sequencer.Close()
+	s.sequencer = matchingengine.NewSequencer(
+		matchingengine.WithJournal(journal),
+		matchingengine.WithReplicaAcks(acks),
+	)
+	s.service = NewOrderService(
+		s.mockPool,
+		s.mockOrderRepo,
+		s.mockOutboxRepo,
+		s.mockIdempotencyRepo,
+		s.mockSagaRepo,
+		s.sequencer,
+		s.wallet,
+		observability.NewMetricsWithRegistry(prometheus.NewRegistry()),
+		zerolog.Nop(),
+	)
+	return journal, acks
+}
+
+func TestEngineReplicator_StandbyTakesOverWithLeaderBooks(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	journal, acks := setup.useReplicatedJournal(t, t.TempDir())
+	defer journal.Close()
+	setup.expectPersistence()
+
+	mux := http.NewServeMux()
+	replication.NewHandler(setup.sequencer, journal, acks, zerolog.Nop()).Register(mux)
+	leaderServer := httptest.NewServer(mux)
+	defer leaderServer.Close()
+
+	// Orders placed before the standby starts reach it through the snapshot
+	setup.placeAndCommit(t, "LAY", 100, "idem-key-lay-a")
+	setup.placeAndCommit(t, "LAY", 100, "idem-key-lay-b")
+
+	var mu sync.Mutex
+	current := models.EngineLeader{
+		Name:      "engine",
+		Epoch:     1,
+		EngineID:  "leader",
+		JournalID: journal.ID(),
+		Address:   leaderServer.URL,
+	}
+	leaders := mocks.NewMockEngineLeaderRepository(setup.ctrl)
+	leaders.EXPECT().Get(gomock.Any(), "engine").DoAndReturn(func(ctx context.Context, name string) (*models.EngineLeader, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		leader := current
+		return &leader, nil
+	}).AnyTimes()
+	leaders.EXPECT().Claim(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, leader *models.EngineLeader) error {
+		mu.Lock()
+		defer mu.Unlock()
+		leader.Epoch = current.Epoch + 1
+		current = *leader
+		return nil
+	})
+
+	standbyJournal, err := matchingengine.OpenJournal(t.TempDir(), matchingengine.JournalOptions{SegmentSize: 512})
+	require.NoError(t, err)
+	defer standbyJournal.Close()
+	standbySequencer := matchingengine.NewSequencer(matchingengine.WithJournal(standbyJournal), matchingengine.WithStandby())
+	defer standbySequencer.Close()
+	store, err := matchingengine.NewFileSnapshotStore(t.TempDir())
+	require.NoError(t, err)
+
+	fencePool, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer fencePool.Close()
+	fence := NewFencedDatabase(fencePool, leaders, "engine")
+
+	elector := &testElector{}
+	replicator := NewEngineReplicator(
+		standbySequencer,
+		standbyJournal,
+		NewEngineSnapshotter(standbySequencer, store, standbyJournal, time.Hour, zerolog.Nop()),
+		elector,
+		leaders,
+		replication.NewClient(),
+		fence,
+		EngineReplicatorConfig{
+			Election:      "engine",
+			EngineID:      "standby",
+			Address:       "http://standby",
+			CheckInterval: 20 * time.Millisecond,
+			DrainTimeout:  200 * time.Millisecond,
+		},
+		observability.NewMetricsWithRegistry(prometheus.NewRegistry()),
+		zerolog.Nop(),
+	)
+
+	runCtx, stop := context.WithCancel(ctx)
+	promoted := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- replicator.Run(runCtx, func(context.Context) { close(promoted) })
+	}()
+
+	require.Eventually(t, acks.Attached, 5*time.Second, 10*time.Millisecond, "standby should catch up and attach")
+
+	// A standby refuses commands and transactions
+	_, err = standbySequencer.SetMarketStatus(ctx, "event-123", models.MarketStatusSuspended, nil)
+	assert.ErrorIs(t, err, models.ErrNotLeader)
+	_, err = fence.Begin(ctx)
+	assert.ErrorIs(t, err, models.ErrNotLeader)
+
+	// Replicated while attached, including a placement the leader aborted
+	setup.placeAndCommit(t, "LAY", 100, "idem-key-lay-c")
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(assert.AnError)
+	setup.mockPool.ExpectRollback()
+	_, err = setup.service.PlaceOrder(ctx, setup.placeRequest(t, "BACK", 150, "idem-key-aborted"))
+	require.ErrorIs(t, err, assert.AnError)
+	setup.placeAndCommit(t, "BACK", 250, "idem-key-back")
+
+	live, err := setup.sequencer.Snapshot(ctx)
+	require.NoError(t, err)
+
+	// The next heartbeat shows the standby caught up, then the leader dies
+	time.Sleep(replication.HeartbeatInterval + 500*time.Millisecond)
+	leaderServer.CloseClientConnections()
+	leaderServer.Close()
+	elector.free.Store(true)
+
+	select {
+	case <-promoted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("standby was not promoted")
+	}
+
+	assert.False(t, standbySequencer.Standby())
+	taken, err := standbySequencer.Snapshot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, engineState(t, live), engineState(t, taken))
+
+	mu.Lock()
+	assert.Equal(t, int64(2), current.Epoch)
+	assert.Equal(t, standbyJournal.ID(), current.JournalID)
+	mu.Unlock()
+
+	// The new leader takes commands, and its transactions check the claimed epoch
+	_, err = standbySequencer.SetMarketStatus(ctx, "event-123", models.MarketStatusSuspended, nil)
+	assert.NoError(t, err)
+
+	fencePool.ExpectBegin()
+	leaders.EXPECT().CheckEpoch(gomock.Any(), gomock.Any(), "engine", int64(2)).Return(nil)
+	tx, err := fence.Begin(ctx)
+	require.NoError(t, err)
+	fencePool.ExpectRollback()
+	require.NoError(t, tx.Rollback(ctx))
+
+	fencePool.ExpectBegin()
+	leaders.EXPECT().CheckEpoch(gomock.Any(), gomock.Any(), "engine", int64(2)).Return(models.ErrFenced)
+	fencePool.ExpectRollback()
+	_, err = fence.Begin(ctx)
+	assert.ErrorIs(t, err, models.ErrFenced)
+
+	// Shutting down gives up leadership
+	stop()
+	require.NoError(t, <-done)
+	assert.True(t, elector.released.Load())
+	assert.True(t, standbySequencer.Standby())
+	assert.NoError(t, fencePool.ExpectationsWereMet())
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestStandby_ReleaseDecidesHeldCommands(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	journal := setup.useJournal(t, t.TempDir())
+	defer journal.Close()
+	setup.expectPersistence()
+
+	setup.placeAndCommit(t, "LAY", 100, "idem-key-lay")
+	live := setup.book(t)
+
+	// The leader journals a placement it never stores
+	var inDoubt *models.Order
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().
+		Create(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ pgx.Tx, order *models.Order) error {
+			inDoubt = order
+			return assert.AnError
+		})
+	setup.mockPool.ExpectRollback()
+	_, err := setup.service.PlaceOrder(ctx, setup.placeRequest(t, "BACK", 150, "idem-key-in-doubt"))
+	require.ErrorIs(t, err, assert.AnError)
+
+	standbySequencer := matchingengine.NewSequencer(
+		matchingengine.WithStandby(),
+		matchingengine.WithCommittedCheck(CommandCommitted(setup.mockOrderRepo)),
+	)
+	defer standbySequencer.Close()
+	standby := matchingengine.NewStandby(standbySequencer)
+
+	// The standby receives the placement, the leader dies before its abort record
+	errLeaderDied := errors.New("leader died")
+	err = journal.Follow(ctx, 0, func(cmd *matchingengine.Command) error {
+		if cmd.Type == matchingengine.CommandAbort {
+			return errLeaderDied
+		}
+		return standby.Apply(ctx, &matchingengine.StreamMessage{Command: cmd, Position: cmd.Position})
+	})
+	require.ErrorIs(t, err, errLeaderDied)
+
+	require.NoError(t, standby.Apply(ctx, &matchingengine.StreamMessage{Position: standby.Position(), Attached: true}))
+	assert.True(t, standby.Synced())
+
+	// A leader that detached the standby may persist commands it never sent
+	require.NoError(t, standby.Apply(ctx, &matchingengine.StreamMessage{Position: standby.Position()}))
+	assert.False(t, standby.Synced())
+
+	// The orders table has no row for the held placement, promotion drops it
+	setup.mockOrderRepo.EXPECT().GetByID(gomock.Any(), inDoubt.ID).Return(nil, models.ErrOrderNotFound)
+
+	released, err := standby.Release(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, released)
+
+	book, err := standbySequencer.MarketBook(ctx, "event-123", "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, live.LayOrders, book.LayOrders)
+	assert.Empty(t, book.BackOrders)
+}
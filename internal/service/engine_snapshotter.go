@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+	"github.com/rs/zerolog"
+)
+
+// EngineSnapshotter periodically snapshots the matching engine and truncates its journal
+// Recovery then only replays the journal written since the latest snapshot
+type EngineSnapshotter struct {
+	sequencer *matchingengine.Sequencer
+	store     matchingengine.SnapshotStore
+	journal   *matchingengine.Journal
+	interval  time.Duration
+	logger    zerolog.Logger
+}
+
+// NewEngineSnapshotter creates a snapshotter that saves to store every interval
+func NewEngineSnapshotter(
+	sequencer *matchingengine.Sequencer,
+	store matchingengine.SnapshotStore,
+	journal *matchingengine.Journal,
+	interval time.Duration,
+	logger zerolog.Logger,
+) *EngineSnapshotter {
+	return &EngineSnapshotter{
+		sequencer: sequencer,
+		store:     store,
+		journal:   journal,
+		interval:  interval,
+		logger:    logger.With().Str("component", "engine_snapshotter").Logger(),
+	}
+}
+
+// Start takes a snapshot every interval until the context is cancelled
+func (s *EngineSnapshotter) Start(ctx context.Context) {
+	s.logger.Info().Dur("interval", s.interval).Msg("engine snapshotter started")
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.Checkpoint(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error().Err(err).Msg("failed to snapshot matching engine")
+			}
+		case <-ctx.Done():
+			s.logger.Info().Msg("engine snapshotter stopping")
+			return
+		}
+	}
+}
+
+// Checkpoint snapshots the engine, saves the snapshot and truncates the journal before it
+// The journal is only truncated once the snapshot is stored
+func (s *EngineSnapshotter) Checkpoint(ctx context.Context) (*matchingengine.Snapshot, error) {
+	start := time.Now()
+
+	snapshot, err := s.sequencer.Snapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot engine: %w", err)
+	}
+	if err := s.store.Save(ctx, snapshot); err != nil {
+		return nil, fmt.Errorf("save snapshot: %w", err)
+	}
+
+	removed, err := s.journal.Truncate(snapshot.Position)
+	if err != nil {
+		return nil, fmt.Errorf("truncate journal: %w", err)
+	}
+
+	s.logger.Info().
+		Int64("journal_position", snapshot.Position).
+		Int("markets", len(snapshot.Markets)).
+		Int("segments_removed", removed).
+		Dur("duration", time.Since(start)).
+		Msg("matching engine snapshot saved")
+
+	return snapshot, nil
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/repository"
+)
+
+// FencedDatabase begins transactions that only commit under the current engine epoch
+// Each transaction first checks the epoch this replica was elected with, and holds
+// the leader record until it ends, so once another replica is elected a stale
+// leader can no longer write orders and matches. Without an epoch, i.e. on a
+// standby, Begin fails with models.ErrNotLeader
+type FencedDatabase struct {
+	db       Database
+	leaders  repository.EngineLeaderRepository
+	election string
+	epoch    atomic.Int64
+}
+
+// NewFencedDatabase wraps db with the fencing token of the named election
+func NewFencedDatabase(db Database, leaders repository.EngineLeaderRepository, election string) *FencedDatabase {
+	return &FencedDatabase{
+		db:       db,
+		leaders:  leaders,
+		election: election,
+	}
+}
+
+// SetEpoch sets the epoch transactions are checked against, 0 refuses them all
+func (d *FencedDatabase) SetEpoch(epoch int64) {
+	d.epoch.Store(epoch)
+}
+
+// Begin starts a transaction and checks the epoch in it
+func (d *FencedDatabase) Begin(ctx context.Context) (pgx.Tx, error) {
+	epoch := d.epoch.Load()
+	if epoch == 0 {
+		return nil, models.ErrNotLeader
+	}
+
+	tx, err := d.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.leaders.CheckEpoch(ctx, tx, d.election, epoch); err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	return tx, nil
+}
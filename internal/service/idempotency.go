@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// Operations that scope idempotency keys
+const (
+	opPlaceOrder  = "place_order"
+	opCancelOrder = "cancel_order"
+	opSettleOrder = "settle_order"
+)
+
+const (
+	// idempotencyTTL is how long a stored response is replayed
+	idempotencyTTL = 24 * time.Hour
+
+	// idempotencyLease is how long a claim holds a key before a retry may take it over
+	// Must exceed the longest call, including wallet round trips
+	idempotencyLease = time.Minute
+
+	// Polling of a key held by a concurrent duplicate
+	idempotencyPollInitial = 10 * time.Millisecond
+	idempotencyPollMax     = 250 * time.Millisecond
+)
+
+// idempotentResponse is the response stored under an idempotency key
+// Either Result or ErrorCode is set, a replay returns exactly what the first call returned
+type idempotentResponse struct {
+	Result    json.RawMessage `json:"result,omitempty"`
+	ErrorCode string          `json:"error_code,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// idempotencyClaim is a key this call holds
+// A retry takes the key over once the lease expires, its new token fences this
+// call off, so completing or releasing the key needs the token it was claimed with
+type idempotencyClaim struct {
+	key         string
+	requestHash string
+	token       uuid.UUID
+	stored      bool // response committed with the call's transaction
+}
+
+// replayedError is a failure returned from a stored response
+// It unwraps to the domain error of its code so callers map it like the original
+type replayedError struct {
+	code    string
+	message string
+}
+
+func (e *replayedError) Error() string { return e.message }
+
+func (e *replayedError) Unwrap() error { return models.ErrorFromCode(e.code) }
+
+// scopedIdempotencyKey namespaces a client key by operation and user
+// Two users, or two operations of the same user, never share a stored response
+func scopedIdempotencyKey(operation string, userID uuid.UUID, key string) string {
+	return operation + ":" + userID.String() + ":" + key
+}
+
+// successResponse builds the stored response of a successful call
+func successResponse(result interface{}) (*idempotentResponse, error) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotent response: %w", err)
+	}
+	return &idempotentResponse{Result: raw}, nil
+}
+
+// replay decodes a stored result into result, or returns the stored failure
+func (r *idempotentResponse) replay(result interface{}) error {
+	if r.ErrorCode != "" {
+		return &replayedError{code: r.ErrorCode, message: r.Error}
+	}
+	if err := json.Unmarshal(r.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal cached response: %w", err)
+	}
+	return nil
+}
+
+// claimIdempotency claims key for this call, waiting while a concurrent duplicate holds it
+// Returns the stored response if a call with the key already completed, otherwise the
+// claim this call now holds, which it must finish with StoreInTransaction or finishFailure
+func (s *OrderServiceImpl) claimIdempotency(ctx context.Context, key, requestHash string) (*idempotentResponse, *idempotencyClaim, error) {
+	claim := &idempotencyClaim{key: key, requestHash: requestHash, token: uuid.New()}
+	wait := idempotencyPollInitial
+	for {
+		raw, claimed, err := s.idempotencyRepo.Claim(ctx, key, claim.token, requestHash, idempotencyLease)
+		switch {
+		case err == nil && claimed:
+			return nil, claim, nil
+		case err == nil:
+			var resp idempotentResponse
+			if err := json.Unmarshal(raw, &resp); err != nil {
+				return nil, nil, fmt.Errorf("failed to unmarshal cached response: %w", err)
+			}
+			return &resp, nil, nil
+		case errors.Is(err, models.ErrIdempotencyMismatch):
+			s.logger.Warn().
+				Str("idempotency_key", key).
+				Msg("idempotency key reused with different request")
+			return nil, nil, models.ErrIdempotencyMismatch
+		case !errors.Is(err, models.ErrIdempotencyInProgress):
+			return nil, nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+		}
+
+		// A duplicate is in flight, its result is replayed once stored
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, nil, models.ErrIdempotencyInProgress
+		}
+		wait = min(wait*2, idempotencyPollMax)
+	}
+}
+
+// finishFailure completes a claimed key for a failed call
+// Domain errors with a code are stored so a retry gets the same error, any other
+// failure releases the key so a retry runs the call again. A claim a retry took
+// over, or one whose response is already stored, is left alone
+func (s *OrderServiceImpl) finishFailure(ctx context.Context, claim *idempotencyClaim, err error) {
+	if claim.stored {
+		return
+	}
+
+	// The key must not stay claimed because the caller gave up
+	ctx = context.WithoutCancel(ctx)
+
+	code := models.ErrorCode(err)
+	if code == "" {
+		if releaseErr := s.idempotencyRepo.Release(ctx, claim.key, claim.token); releaseErr != nil {
+			s.logger.Error().Err(releaseErr).
+				Str("idempotency_key", claim.key).
+				Msg("failed to release idempotency key, retries wait for the lease")
+		}
+		return
+	}
+
+	resp := &idempotentResponse{ErrorCode: code, Error: err.Error()}
+	if storeErr := s.idempotencyRepo.Store(ctx, claim.key, claim.token, claim.requestHash, resp, idempotencyTTL); storeErr != nil {
+		s.logger.Error().Err(storeErr).
+			Str("idempotency_key", claim.key).
+			Str("error_code", code).
+			Msg("failed to store failed idempotent response")
+	}
+}
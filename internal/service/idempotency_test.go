@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// memoryIdempotencyRepository implements the claim semantics of the Postgres repository in memory
+type memoryIdempotencyRepository struct {
+	mu     sync.Mutex
+	keys   map[string]*memoryIdempotencyKey
+	claims int
+
+	// allClaimed is closed once Claim has been called waitFor times
+	waitFor    int
+	allClaimed chan struct{}
+}
+
+type memoryIdempotencyKey struct {
+	hash       string
+	token      uuid.UUID
+	processing bool
+	response   json.RawMessage
+}
+
+func newMemoryIdempotencyRepository(waitFor int) *memoryIdempotencyRepository {
+	return &memoryIdempotencyRepository{
+		keys:       map[string]*memoryIdempotencyKey{},
+		waitFor:    waitFor,
+		allClaimed: make(chan struct{}),
+	}
+}
+
+func (r *memoryIdempotencyRepository) Claim(_ context.Context, key string, claimToken uuid.UUID, requestHash string, _ time.Duration) (json.RawMessage, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.claims++
+	if r.claims == r.waitFor {
+		close(r.allClaimed)
+	}
+
+	stored, ok := r.keys[key]
+	switch {
+	case !ok:
+		r.keys[key] = &memoryIdempotencyKey{hash: requestHash, token: claimToken, processing: true}
+		return nil, true, nil
+	case stored.hash != requestHash:
+		return nil, false, models.ErrIdempotencyMismatch
+	case stored.processing:
+		return nil, false, models.ErrIdempotencyInProgress
+	default:
+		return stored.response, false, nil
+	}
+}
+
+// takeOver hands a processing key to a new claim, as a retry does once the lease expires
+func (r *memoryIdempotencyRepository) takeOver(key string) uuid.UUID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token := uuid.New()
+	r.keys[key].token = token
+	return token
+}
+
+func (r *memoryIdempotencyRepository) Release(_ context.Context, key string, claimToken uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.keys[key]; ok && stored.processing && stored.token == claimToken {
+		delete(r.keys, key)
+	}
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) Store(_ context.Context, key string, claimToken uuid.UUID, requestHash string, responseData interface{}, _ time.Duration) error {
+	raw, err := json.Marshal(responseData)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.keys[key]
+	if !ok || !stored.processing || stored.token != claimToken || stored.hash != requestHash {
+		return models.ErrIdempotencyClaimLost
+	}
+	r.keys[key] = &memoryIdempotencyKey{hash: requestHash, response: raw}
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) StoreInTransaction(ctx context.Context, _ pgx.Tx, key string, claimToken uuid.UUID, requestHash string, responseData interface{}, ttl time.Duration) error {
+	return r.Store(ctx, key, claimToken, requestHash, responseData, ttl)
+}
+
+func (r *memoryIdempotencyRepository) CleanupExpired(context.Context, int) (int64, error) {
+	return 0, nil
+}
+
+func TestOrderService_PlaceOrder_ConcurrentDuplicatesPlaceOnce(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	const duplicates = 8
+	idempotency := newMemoryIdempotencyRepository(duplicates)
+	setup.service = NewOrderService(
+		setup.mockPool,
+		setup.mockOrderRepo,
+		setup.mockOutboxRepo,
+		idempotency,
+		setup.mockSagaRepo,
+		setup.sequencer,
+		setup.wallet,
+		observability.NewMetricsWithRegistry(prometheus.NewRegistry()),
+		zerolog.Nop(),
+	)
+
+	userID := uuid.New()
+	req := &PlaceOrderRequest{
+		UserID:         userID,
+		EventID:        "event-123",
+		BetType:        "BACK",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromInt(100),
+		Odds:           decimal.NewFromFloat(2.5),
+		ReservationID:  setup.reserve(t, userID, decimal.NewFromInt(500), decimal.NewFromInt(100)),
+		IdempotencyKey: "idem-key-concurrent",
+	}
+
+	// Exactly one placement reaches the database and the engine
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().
+		Create(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, pgx.Tx, *models.Order) error {
+			// Hold the key until every duplicate has arrived and found it claimed
+			select {
+			case <-idempotency.allClaimed:
+			case <-time.After(5 * time.Second):
+				t.Error("duplicates did not reach the idempotency claim")
+			}
+			return nil
+		}).
+		Times(1)
+	setup.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	setup.mockOutboxRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	setup.mockPool.ExpectCommit()
+
+	start := make(chan struct{})
+	orders := make([]*models.Order, duplicates)
+	errs := make([]error, duplicates)
+	var wg sync.WaitGroup
+	for i := 0; i < duplicates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			orders[i], errs[i] = setup.service.PlaceOrder(context.Background(), req)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	// Every duplicate received the first call's order
+	for i := 0; i < duplicates; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, orders[0].ID, orders[i].ID)
+	}
+	assert.Len(t, setup.book(t).BackOrders, 1)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_PlaceOrder_ReleasedKeyRunsAgain(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	idempotency := newMemoryIdempotencyRepository(0)
+	setup.service = NewOrderService(
+		setup.mockPool,
+		setup.mockOrderRepo,
+		setup.mockOutboxRepo,
+		idempotency,
+		setup.mockSagaRepo,
+		setup.sequencer,
+		setup.wallet,
+		observability.NewMetricsWithRegistry(prometheus.NewRegistry()),
+		zerolog.Nop(),
+	)
+
+	userID := uuid.New()
+	req := &PlaceOrderRequest{
+		UserID:         userID,
+		EventID:        "event-123",
+		BetType:        "BACK",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromInt(100),
+		Odds:           decimal.NewFromFloat(2.5),
+		ReservationID:  setup.reserve(t, userID, decimal.NewFromInt(500), decimal.NewFromInt(100)),
+		IdempotencyKey: "idem-key-retry",
+	}
+
+	// The first attempt fails before anything is stored
+	setup.mockPool.ExpectBegin().WillReturnError(assert.AnError)
+	_, err := setup.service.PlaceOrder(context.Background(), req)
+	require.Error(t, err)
+	assert.Empty(t, idempotency.keys, "a transient failure must not keep the key claimed")
+
+	// The retry with the same key runs the placement
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockOutboxRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	order, err := setup.service.PlaceOrder(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusPending, order.Status)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_PlaceOrder_LostClaimFailsPlacement(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	idempotency := newMemoryIdempotencyRepository(0)
+	setup.service = NewOrderService(
+		setup.mockPool,
+		setup.mockOrderRepo,
+		setup.mockOutboxRepo,
+		idempotency,
+		setup.mockSagaRepo,
+		setup.sequencer,
+		setup.wallet,
+		observability.NewMetricsWithRegistry(prometheus.NewRegistry()),
+		zerolog.Nop(),
+	)
+
+	userID := uuid.New()
+	req := &PlaceOrderRequest{
+		UserID:         userID,
+		EventID:        "event-123",
+		BetType:        "BACK",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromInt(100),
+		Odds:           decimal.NewFromFloat(2.5),
+		ReservationID:  setup.reserve(t, userID, decimal.NewFromInt(500), decimal.NewFromInt(100)),
+		IdempotencyKey: "idem-key-slow",
+	}
+	key := scopedIdempotencyKey(opPlaceOrder, userID, "idem-key-slow")
+
+	// The placement outlives its lease and a retry claims the key meanwhile
+	var retry uuid.UUID
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().
+		Create(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, pgx.Tx, *models.Order) error {
+			retry = idempotency.takeOver(key)
+			return nil
+		})
+	setup.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockOutboxRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockPool.ExpectRollback()
+
+	_, err := setup.service.PlaceOrder(context.Background(), req)
+	assert.ErrorIs(t, err, models.ErrIdempotencyClaimLost)
+
+	// Nothing of the slow placement is kept, and the retry still holds the key
+	assert.Empty(t, setup.book(t).BackOrders)
+	require.Contains(t, idempotency.keys, key)
+	assert.True(t, idempotency.keys[key].processing)
+	assert.Equal(t, retry, idempotency.keys[key].token)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
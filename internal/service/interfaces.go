@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/cypherlabdev/order-book-service/internal/models"
 	"github.com/google/uuid"
@@ -15,12 +16,26 @@ type OrderService interface {
 	PlaceOrder(ctx context.Context, req *PlaceOrderRequest) (*models.Order, error)
 
 	// CancelOrder cancels an active order
-	// Only active orders can be cancelled
-	CancelOrder(ctx context.Context, req *CancelOrderRequest) error
+	// Only active orders can be cancelled; a repeated idempotency key returns the
+	// original result or error
+	CancelOrder(ctx context.Context, req *CancelOrderRequest) (*CancelOrderResult, error)
 
 	// SettleOrder settles a completed order (win or loss)
-	// Updates order status and records actual payout
-	SettleOrder(ctx context.Context, req *SettleOrderRequest) error
+	// Updates order status and records actual payout; a repeated idempotency key
+	// returns the original result or error
+	SettleOrder(ctx context.Context, req *SettleOrderRequest) (*SettleOrderResult, error)
+
+	// CompensatePlaceOrder undoes the order placed by a saga, reversing its matches
+	// Safe to call repeatedly and before the placement itself has been processed
+	CompensatePlaceOrder(ctx context.Context, req *CompensatePlaceOrderRequest) (*CompensatePlaceOrderResult, error)
+
+	// ExpireMarketOrders expires every resting order of a closed market
+	// Returns the number of orders expired, safe to call repeatedly
+	ExpireMarketOrders(ctx context.Context, marketID string) (int, error)
+
+	// SettleMarket settles every matched order of a resulted market
+	// Returns the number of orders settled, safe to call repeatedly
+	SettleMarket(ctx context.Context, marketID, winningSelectionID string) (int, error)
 
 	// GetOrderByID retrieves a single order by ID
 	GetOrderByID(ctx context.Context, orderID uuid.UUID) (*models.Order, error)
@@ -63,3 +78,84 @@ type SettleOrderRequest struct {
 	SagaID         *uuid.UUID
 	IdempotencyKey string          `validate:"required"`
 }
+
+// CancelOrderResult describes a cancelled order
+type CancelOrderResult struct {
+	OrderID     uuid.UUID          `json:"order_id"`
+	Status      models.OrderStatus `json:"status"`
+	CancelledAt time.Time          `json:"cancelled_at"`
+}
+
+// SettleOrderResult describes a settled order
+type SettleOrderResult struct {
+	OrderID      uuid.UUID          `json:"order_id"`
+	Result       string             `json:"result"`
+	ActualPayout decimal.Decimal    `json:"actual_payout"`
+	Status       models.OrderStatus `json:"status"`
+	SettledAt    time.Time          `json:"settled_at"`
+}
+
+// CompensatePlaceOrderRequest represents the request to undo a saga's order placement
+type CompensatePlaceOrderRequest struct {
+	SagaID uuid.UUID `validate:"required"`
+	Reason string
+}
+
+// CompensatePlaceOrderResult describes the outcome of a compensation
+type CompensatePlaceOrderResult struct {
+	OrderID            *uuid.UUID // nil if no order was placed for the saga
+	ReversedMatches    int
+	AlreadyCompensated bool
+}
+
+// MarketService applies upstream market status changes
+type MarketService interface {
+	// ApplyMarketStatus moves a market to the status in the request
+	// Suspend and resume toggle order acceptance, close expires resting orders and
+	// result settles matched orders; redelivered messages are skipped by message ID
+	ApplyMarketStatus(ctx context.Context, req *MarketStatusRequest) (*MarketStatusResult, error)
+
+	// RestoreMarkets loads the markets that do not accept orders into the matching engine
+	// Only markets owned passes are loaded, a nil owned loads every market
+	RestoreMarkets(ctx context.Context, owned MarketFilter) error
+}
+
+// MarketFilter reports whether a market belongs to this replica
+type MarketFilter func(ctx context.Context, marketID string) (bool, error)
+
+// Market status change types of the upstream feed
+const (
+	MarketChangeSuspend = "suspend"
+	MarketChangeResume  = "resume"
+	MarketChangeClose   = "close"
+	MarketChangeResult  = "result"
+)
+
+// MarketStatusRequest represents an upstream market status change
+type MarketStatusRequest struct {
+	MessageID          string `validate:"required"`
+	MarketID           string `validate:"required"`
+	Type               string `validate:"required,oneof=suspend resume close result"`
+	WinningSelectionID string `validate:"required_if=Type result"`
+}
+
+// MarketStatusResult describes the outcome of a market status change
+type MarketStatusResult struct {
+	Status           models.MarketStatus
+	OrdersExpired    int
+	OrdersSettled    int
+	AlreadyProcessed bool
+}
+
+// OutboxAdminService defines operator tooling for dead-lettered outbox events
+type OutboxAdminService interface {
+	// ListDeadLetteredEvents retrieves events that exhausted their retries, oldest first
+	ListDeadLetteredEvents(ctx context.Context, limit, offset int) ([]*models.OutboxEvent, error)
+
+	// GetOutboxEvent retrieves a single outbox event by ID for inspection
+	GetOutboxEvent(ctx context.Context, eventID uuid.UUID) (*models.OutboxEvent, error)
+
+	// ReplayDeadLetteredEvent puts a dead-lettered event back in the publish queue
+	// Later events for the same aggregate are published after it
+	ReplayDeadLetteredEvent(ctx context.Context, eventID uuid.UUID) error
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/repository"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+	"github.com/go-playground/validator/v10"
+	"github.com/rs/zerolog"
+)
+
+// MarketServiceImpl implements the MarketService interface
+type MarketServiceImpl struct {
+	db           Database
+	marketRepo   repository.MarketRepository
+	orderService OrderService
+	sequencer    *matchingengine.Sequencer
+	metrics      *observability.Metrics
+	logger       zerolog.Logger
+	validator    *validator.Validate
+}
+
+// NewMarketService creates a new market service instance
+func NewMarketService(
+	db Database,
+	marketRepo repository.MarketRepository,
+	orderService OrderService,
+	sequencer *matchingengine.Sequencer,
+	metrics *observability.Metrics,
+	logger zerolog.Logger,
+) MarketService {
+	return &MarketServiceImpl{
+		db:           db,
+		marketRepo:   marketRepo,
+		orderService: orderService,
+		sequencer:    sequencer,
+		metrics:      metrics,
+		logger:       logger.With().Str("component", "market_service").Logger(),
+		validator:    validator.New(),
+	}
+}
+
+// ApplyMarketStatus moves a market to the status in the request
+// The new status is stored before the book takes it and orders are expired or settled,
+// and the message is only recorded as processed once they are, so a redelivery
+// finishes an interrupted change
+func (s *MarketServiceImpl) ApplyMarketStatus(ctx context.Context, req *MarketStatusRequest) (*MarketStatusResult, error) {
+	// Validate request
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	// Start transaction
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize with other status changes of the same market
+	if err := s.marketRepo.Lock(ctx, tx, req.MarketID); err != nil {
+		return nil, fmt.Errorf("failed to lock market: %w", err)
+	}
+
+	processed, err := s.marketRepo.IsProcessed(ctx, tx, req.MessageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check market status message: %w", err)
+	}
+
+	market, err := s.marketRepo.Get(ctx, tx, req.MarketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market: %w", err)
+	}
+
+	if processed {
+		s.logger.Info().
+			Str("message_id", req.MessageID).
+			Str("market_id", req.MarketID).
+			Msg("market status message already processed")
+		return &MarketStatusResult{Status: market.Status, AlreadyProcessed: true}, nil
+	}
+
+	if err := transitionMarket(market, req); err != nil {
+		s.logger.Warn().
+			Str("message_id", req.MessageID).
+			Str("market_id", req.MarketID).
+			Str("type", req.Type).
+			Str("status", string(market.Status)).
+			Msg("rejecting market status change")
+		return nil, err
+	}
+
+	if err := s.marketRepo.Save(ctx, tx, market); err != nil {
+		return nil, fmt.Errorf("failed to save market: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Orders queued before the change are matched first, later ones see the new status
+	// The message stays unprocessed until the book has the status, a redelivery
+	// applies it again
+	if _, err := s.sequencer.SetMarketStatus(context.WithoutCancel(ctx), market.ID, market.Status, nil); err != nil {
+		return nil, fmt.Errorf("failed to apply market status to the book: %w", err)
+	}
+	result := &MarketStatusResult{Status: market.Status}
+
+	// Suspend and resume are complete once the book has the status
+	if req.Type == MarketChangeClose || req.Type == MarketChangeResult {
+		// A result can arrive without a close, expiring is a no-op for closed markets
+		result.OrdersExpired, err = s.orderService.ExpireMarketOrders(ctx, market.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expire market orders: %w", err)
+		}
+
+		if req.Type == MarketChangeResult {
+			result.OrdersSettled, err = s.orderService.SettleMarket(ctx, market.ID, req.WinningSelectionID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to settle market: %w", err)
+			}
+		}
+	}
+
+	if err := s.markProcessed(ctx, req); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().
+		Str("message_id", req.MessageID).
+		Str("market_id", market.ID).
+		Str("type", req.Type).
+		Str("status", string(market.Status)).
+		Int("orders_expired", result.OrdersExpired).
+		Int("orders_settled", result.OrdersSettled).
+		Msg("market status applied")
+
+	return result, nil
+}
+
+// RestoreMarkets loads the markets that do not accept orders into the sequencer
+// Each restored market gets state in the sequencer, so markets another replica
+// owns are left to it
+func (s *MarketServiceImpl) RestoreMarkets(ctx context.Context, owned MarketFilter) error {
+	markets, err := s.marketRepo.ListNotOpen(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list markets: %w", err)
+	}
+
+	restored := 0
+	for _, market := range markets {
+		if owned != nil {
+			own, err := owned(ctx, market.ID)
+			if err != nil {
+				return fmt.Errorf("failed to check owner of market %s: %w", market.ID, err)
+			}
+			if !own {
+				continue
+			}
+		}
+
+		_, err := s.sequencer.SetMarketStatus(ctx, market.ID, market.Status, nil)
+		if errors.Is(err, models.ErrNotMarketOwner) {
+			// Held by another replica, which restores it
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore market %s: %w", market.ID, err)
+		}
+		restored++
+	}
+
+	s.logger.Info().
+		Int("markets", restored).
+		Msg("market status restored")
+
+	return nil
+}
+
+// markProcessed records a status message as applied in its own transaction
+func (s *MarketServiceImpl) markProcessed(ctx context.Context, req *MarketStatusRequest) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.marketRepo.MarkProcessed(ctx, tx, req.MessageID, req.MarketID, req.Type); err != nil {
+		return fmt.Errorf("failed to record market status message: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// transitionMarket applies a status change to the market
+// Markets never reopen once closed, and a settled market keeps its result
+func transitionMarket(market *models.Market, req *MarketStatusRequest) error {
+	final := market.Status == models.MarketStatusClosed || market.Status == models.MarketStatusSettled
+
+	switch req.Type {
+	case MarketChangeSuspend, MarketChangeResume:
+		if final {
+			return fmt.Errorf("%w: %s market %s", models.ErrInvalidMarketTransition, req.Type, market.Status)
+		}
+		market.Status = models.MarketStatusSuspended
+		if req.Type == MarketChangeResume {
+			market.Status = models.MarketStatusOpen
+		}
+
+	case MarketChangeClose:
+		if market.Status != models.MarketStatusSettled {
+			market.Status = models.MarketStatusClosed
+		}
+
+	case MarketChangeResult:
+		if market.Status == models.MarketStatusSettled &&
+			market.WinningSelectionID != nil && *market.WinningSelectionID != req.WinningSelectionID {
+			return fmt.Errorf("%w: market already resulted with selection %s",
+				models.ErrInvalidMarketTransition, *market.WinningSelectionID)
+		}
+		winner := req.WinningSelectionID
+		market.Status = models.MarketStatusSettled
+		market.WinningSelectionID = &winner
+
+	default:
+		return fmt.Errorf("%w: unknown change %q", models.ErrInvalidMarketTransition, req.Type)
+	}
+
+	return nil
+}
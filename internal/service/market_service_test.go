@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/events"
+	"github.com/cypherlabdev/order-book-service/internal/mocks"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// setupMarketService creates a market service on top of the order service test setup
+func setupMarketService(t *testing.T) (*testServiceSetup, *mocks.MockMarketRepository, MarketService) {
+	setup := setupTestService(t)
+	marketRepo := mocks.NewMockMarketRepository(setup.ctrl)
+	metrics := observability.NewMetricsWithRegistry(prometheus.NewRegistry())
+
+	marketService := NewMarketService(setup.mockPool, marketRepo, setup.service, setup.sequencer, metrics, zerolog.Nop())
+	return setup, marketRepo, marketService
+}
+
+// expectMarket sets up the lock, inbox check and lookup every status change starts with
+func expectMarket(marketRepo *mocks.MockMarketRepository, messageID string, market *models.Market, processed bool) {
+	marketRepo.EXPECT().Lock(gomock.Any(), gomock.Any(), market.ID).Return(nil)
+	marketRepo.EXPECT().IsProcessed(gomock.Any(), gomock.Any(), messageID).Return(processed, nil)
+	marketRepo.EXPECT().Get(gomock.Any(), gomock.Any(), market.ID).Return(market, nil)
+}
+
+func TestMarketService_SuspendAndResume(t *testing.T) {
+	setup, marketRepo, marketService := setupMarketService(t)
+	defer setup.cleanup()
+	ctx := context.Background()
+
+	// Suspend
+	setup.mockPool.ExpectBegin()
+	expectMarket(marketRepo, "msg-1", &models.Market{ID: "event-123", Status: models.MarketStatusOpen}, false)
+	marketRepo.EXPECT().
+		Save(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ interface{}, market *models.Market) error {
+			assert.Equal(t, models.MarketStatusSuspended, market.Status)
+			return nil
+		})
+	setup.mockPool.ExpectCommit()
+	setup.mockPool.ExpectBegin()
+	marketRepo.EXPECT().MarkProcessed(gomock.Any(), gomock.Any(), "msg-1", "event-123", MarketChangeSuspend).Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	result, err := marketService.ApplyMarketStatus(ctx, &MarketStatusRequest{
+		MessageID: "msg-1",
+		MarketID:  "event-123",
+		Type:      MarketChangeSuspend,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.MarketStatusSuspended, result.Status)
+
+	// Orders on the suspended market are rejected before the wallet is touched
+	userID := uuid.New()
+	key := scopedIdempotencyKey(opPlaceOrder, userID, "idem-suspended")
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), key, gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
+	setup.mockIdempotencyRepo.EXPECT().
+		Release(gomock.Any(), key, gomock.Any()).
+		Return(nil)
+
+	_, err = setup.service.PlaceOrder(ctx, &PlaceOrderRequest{
+		UserID:         userID,
+		EventID:        "event-123",
+		BetType:        "BACK",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromInt(10),
+		Odds:           decimal.NewFromInt(2),
+		ReservationID:  setup.reserve(t, userID, decimal.NewFromInt(10), decimal.NewFromInt(10)),
+		IdempotencyKey: "idem-suspended",
+	})
+	assert.ErrorIs(t, err, models.ErrMarketSuspended)
+
+	// Resume
+	setup.mockPool.ExpectBegin()
+	expectMarket(marketRepo, "msg-2", &models.Market{ID: "event-123", Status: models.MarketStatusSuspended}, false)
+	marketRepo.EXPECT().Save(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockPool.ExpectCommit()
+	setup.mockPool.ExpectBegin()
+	marketRepo.EXPECT().MarkProcessed(gomock.Any(), gomock.Any(), "msg-2", "event-123", MarketChangeResume).Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	result, err = marketService.ApplyMarketStatus(ctx, &MarketStatusRequest{
+		MessageID: "msg-2",
+		MarketID:  "event-123",
+		Type:      MarketChangeResume,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.MarketStatusOpen, result.Status)
+	assert.NoError(t, setup.sequencer.CheckMarketOpen(context.Background(), "event-123"))
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestMarketService_EngineFailureLeavesMessageUnprocessed(t *testing.T) {
+	setup, marketRepo, marketService := setupMarketService(t)
+	defer setup.cleanup()
+	ctx := context.Background()
+
+	// A standby book refuses the status
+	setup.sequencer.Demote()
+
+	setup.mockPool.ExpectBegin()
+	expectMarket(marketRepo, "msg-1", &models.Market{ID: "event-123", Status: models.MarketStatusOpen}, false)
+	marketRepo.EXPECT().Save(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	// No MarkProcessed, so the redelivered suspend reaches the book
+	_, err := marketService.ApplyMarketStatus(ctx, &MarketStatusRequest{
+		MessageID: "msg-1",
+		MarketID:  "event-123",
+		Type:      MarketChangeSuspend,
+	})
+	assert.ErrorIs(t, err, models.ErrNotLeader)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+
+	setup.sequencer.Promote()
+	setup.mockPool.ExpectBegin()
+	expectMarket(marketRepo, "msg-1", &models.Market{ID: "event-123", Status: models.MarketStatusSuspended}, false)
+	marketRepo.EXPECT().Save(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockPool.ExpectCommit()
+	setup.mockPool.ExpectBegin()
+	marketRepo.EXPECT().MarkProcessed(gomock.Any(), gomock.Any(), "msg-1", "event-123", MarketChangeSuspend).Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	result, err := marketService.ApplyMarketStatus(ctx, &MarketStatusRequest{
+		MessageID: "msg-1",
+		MarketID:  "event-123",
+		Type:      MarketChangeSuspend,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.MarketStatusSuspended, result.Status)
+	assert.ErrorIs(t, setup.sequencer.CheckMarketOpen(ctx, "event-123"), models.ErrMarketSuspended)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestMarketService_DuplicateMessageSkipped(t *testing.T) {
+	setup, marketRepo, marketService := setupMarketService(t)
+	defer setup.cleanup()
+
+	setup.mockPool.ExpectBegin()
+	expectMarket(marketRepo, "msg-1", &models.Market{ID: "event-123", Status: models.MarketStatusSettled}, true)
+	setup.mockPool.ExpectRollback()
+
+	result, err := marketService.ApplyMarketStatus(context.Background(), &MarketStatusRequest{
+		MessageID:          "msg-1",
+		MarketID:           "event-123",
+		Type:               MarketChangeResult,
+		WinningSelectionID: "team-a",
+	})
+	require.NoError(t, err)
+	assert.True(t, result.AlreadyProcessed)
+	assert.Equal(t, models.MarketStatusSettled, result.Status)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestMarketService_CloseExpiresRestingOrders(t *testing.T) {
+	setup, marketRepo, marketService := setupMarketService(t)
+	defer setup.cleanup()
+
+	userID := uuid.New()
+	reservationID := setup.reserve(t, userID, decimal.NewFromInt(100), decimal.NewFromInt(40))
+	resting := &models.Order{
+		ID:            uuid.New(),
+		UserID:        userID,
+		MarketID:      "event-123",
+		Side:          models.OrderSideLay,
+		Price:         decimal.NewFromInt(3),
+		Size:          decimal.NewFromInt(30),
+		SizeMatched:   decimal.NewFromInt(10),
+		SizeRemaining: decimal.NewFromInt(20),
+		Status:        models.OrderStatusPartially,
+		ReservationID: reservationID.String(),
+		Version:       2,
+	}
+	filled := &models.Order{ID: uuid.New(), MarketID: "event-123", Status: models.OrderStatusMatched}
+
+	setup.mockPool.ExpectBegin()
+	expectMarket(marketRepo, "msg-close", &models.Market{ID: "event-123", Status: models.MarketStatusSuspended}, false)
+	marketRepo.EXPECT().Save(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	// The filled order left the book between the query and the lock
+	setup.mockOrderRepo.EXPECT().
+		GetPendingOrders(gomock.Any(), "event-123").
+		Return([]*models.Order{resting, filled}, nil)
+
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().GetByIDForUpdate(gomock.Any(), gomock.Any(), resting.ID).Return(resting, nil)
+	setup.mockOrderRepo.EXPECT().
+		Update(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ interface{}, order *models.Order) error {
+			assert.Equal(t, models.OrderStatusExpired, order.Status)
+			return nil
+		})
+	setup.mockOutboxRepo.EXPECT().
+		Create(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ interface{}, event *models.OutboxEvent) error {
+			assert.Equal(t, models.EventTypeOrderExpired, event.EventType)
+			return nil
+		})
+	setup.mockPool.ExpectCommit()
+
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().GetByIDForUpdate(gomock.Any(), gomock.Any(), filled.ID).Return(filled, nil)
+	setup.mockPool.ExpectRollback()
+
+	setup.mockPool.ExpectBegin()
+	marketRepo.EXPECT().MarkProcessed(gomock.Any(), gomock.Any(), "msg-close", "event-123", MarketChangeClose).Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	result, err := marketService.ApplyMarketStatus(context.Background(), &MarketStatusRequest{
+		MessageID: "msg-close",
+		MarketID:  "event-123",
+		Type:      MarketChangeClose,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.MarketStatusClosed, result.Status)
+	assert.Equal(t, 1, result.OrdersExpired)
+	assert.ErrorIs(t, setup.sequencer.CheckMarketOpen(context.Background(), "event-123"), models.ErrMarketClosed)
+
+	// Lay liability of the unmatched 20 at odds 3 goes back to the user
+	assert.True(t, decimal.NewFromInt(100).Equal(setup.wallet.Balance(userID)))
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestMarketService_ExpiryFailureLeavesMessageUnprocessed(t *testing.T) {
+	setup, marketRepo, marketService := setupMarketService(t)
+	defer setup.cleanup()
+
+	setup.mockPool.ExpectBegin()
+	expectMarket(marketRepo, "msg-close", &models.Market{ID: "event-123", Status: models.MarketStatusOpen}, false)
+	marketRepo.EXPECT().Save(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	setup.mockOrderRepo.EXPECT().
+		GetPendingOrders(gomock.Any(), "event-123").
+		Return(nil, errors.New("connection reset"))
+
+	// No MarkProcessed, so the redelivered close finishes the expiry
+	_, err := marketService.ApplyMarketStatus(context.Background(), &MarketStatusRequest{
+		MessageID: "msg-close",
+		MarketID:  "event-123",
+		Type:      MarketChangeClose,
+	})
+	assert.Error(t, err)
+	assert.ErrorIs(t, setup.sequencer.CheckMarketOpen(context.Background(), "event-123"), models.ErrMarketClosed)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestMarketService_RejectsInvalidTransitions(t *testing.T) {
+	winner := "team-a"
+	tests := []struct {
+		name   string
+		market models.Market
+		req    MarketStatusRequest
+	}{
+		{"resume closed market", models.Market{Status: models.MarketStatusClosed}, MarketStatusRequest{Type: MarketChangeResume}},
+		{"suspend settled market", models.Market{Status: models.MarketStatusSettled, WinningSelectionID: &winner}, MarketStatusRequest{Type: MarketChangeSuspend}},
+		{"result with another winner", models.Market{Status: models.MarketStatusSettled, WinningSelectionID: &winner}, MarketStatusRequest{Type: MarketChangeResult, WinningSelectionID: "team-b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup, marketRepo, marketService := setupMarketService(t)
+			defer setup.cleanup()
+
+			market := tt.market
+			market.ID = "event-123"
+			req := tt.req
+			req.MessageID = "msg-1"
+			req.MarketID = market.ID
+
+			setup.mockPool.ExpectBegin()
+			expectMarket(marketRepo, "msg-1", &market, false)
+			setup.mockPool.ExpectRollback()
+
+			_, err := marketService.ApplyMarketStatus(context.Background(), &req)
+			assert.ErrorIs(t, err, models.ErrInvalidMarketTransition)
+			assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMarketService_ResultRequiresWinner(t *testing.T) {
+	setup, _, marketService := setupMarketService(t)
+	defer setup.cleanup()
+
+	_, err := marketService.ApplyMarketStatus(context.Background(), &MarketStatusRequest{
+		MessageID: "msg-1",
+		MarketID:  "event-123",
+		Type:      MarketChangeResult,
+	})
+	assert.Error(t, err)
+}
+
+func TestOrderService_SettleMarket(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	backWinner := &models.Order{ID: uuid.New(), UserID: uuid.New(), MarketID: "event-123", SelectionID: "team-a", Side: models.OrderSideBack, Status: models.OrderStatusMatched}
+	layLoser := &models.Order{ID: uuid.New(), UserID: uuid.New(), MarketID: "event-123", SelectionID: "team-a", Side: models.OrderSideLay, Status: models.OrderStatusExpired}
+	layWinner := &models.Order{ID: uuid.New(), UserID: uuid.New(), MarketID: "event-123", SelectionID: "team-b", Side: models.OrderSideLay, Status: models.OrderStatusMatched}
+
+	setup.mockOrderRepo.EXPECT().
+		GetUnsettledMatchedOrders(gomock.Any(), "event-123").
+		Return([]*models.Order{backWinner, layLoser, layWinner}, nil)
+
+	reversedAt := time.Now()
+	matches := map[uuid.UUID][]*models.Match{
+		backWinner.ID: {
+			{Size: decimal.NewFromInt(10), Price: decimal.NewFromInt(3)},
+			{Size: decimal.NewFromInt(5), Price: decimal.RequireFromString("2.5")},
+			{Size: decimal.NewFromInt(50), Price: decimal.NewFromInt(4), ReversedAt: &reversedAt},
+		},
+		layWinner.ID: {
+			{Size: decimal.NewFromInt(8), Price: decimal.RequireFromString("1.5")},
+		},
+	}
+
+	payouts := map[uuid.UUID]string{}
+	results := map[uuid.UUID]string{}
+	for _, order := range []*models.Order{backWinner, layLoser, layWinner} {
+		if m, ok := matches[order.ID]; ok {
+			setup.mockPool.ExpectBegin()
+			setup.mockOrderRepo.EXPECT().GetMatchesByOrderID(gomock.Any(), gomock.Any(), order.ID).Return(m, nil)
+			setup.mockPool.ExpectRollback()
+		}
+
+		key := scopedIdempotencyKey(opSettleOrder, order.UserID, "market-result:event-123:"+order.ID.String())
+		setup.mockOrderRepo.EXPECT().GetByID(gomock.Any(), order.ID).Return(order, nil)
+		setup.mockIdempotencyRepo.EXPECT().
+			Claim(gomock.Any(), key, gomock.Any(), gomock.Any(), idempotencyLease).
+			Return(json.RawMessage(nil), true, nil)
+		setup.mockPool.ExpectBegin()
+		setup.mockOrderRepo.EXPECT().GetByIDForUpdate(gomock.Any(), gomock.Any(), order.ID).Return(order, nil)
+		setup.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), order).Return(nil)
+		setup.mockOutboxRepo.EXPECT().
+			Create(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ interface{}, event *models.OutboxEvent) error {
+				settled, err := events.FromPayload(event.EventType, event.SchemaVersion, event.EventPayload)
+				require.NoError(t, err)
+				payload := settled.(*events.OrderSettledV1)
+				id := uuid.MustParse(payload.OrderID)
+				payouts[id] = payload.ActualPayout
+				results[id] = payload.Result
+				return nil
+			})
+		setup.mockIdempotencyRepo.EXPECT().
+			StoreInTransaction(gomock.Any(), gomock.Any(), key, gomock.Any(), gomock.Any(), gomock.Any(), 24*time.Hour).
+			Return(nil)
+		setup.mockPool.ExpectCommit()
+	}
+
+	settled, err := setup.service.SettleMarket(context.Background(), "event-123", "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, 3, settled)
+
+	// Reversed matches pay nothing, winners get stake times matched odds
+	assert.Equal(t, "win", results[backWinner.ID])
+	assert.Equal(t, "42.5", payouts[backWinner.ID])
+	assert.Equal(t, "loss", results[layLoser.ID])
+	assert.Equal(t, "0", payouts[layLoser.ID])
+	assert.Equal(t, "win", results[layWinner.ID])
+	assert.Equal(t, "12", payouts[layWinner.ID])
+
+	assert.Equal(t, models.OrderStatusSettledWin, backWinner.Status)
+	assert.Equal(t, models.OrderStatusSettledLoss, layLoser.Status)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/events"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ExpireMarketOrders takes the resting orders of a closed market off the book and expires them
+// Orders that already left the book are skipped, so repeating it is safe
+func (s *OrderServiceImpl) ExpireMarketOrders(ctx context.Context, marketID string) (int, error) {
+	if _, err := s.sequencer.RemoveMarketOrders(ctx, marketID, nil); err != nil {
+		return 0, fmt.Errorf("failed to remove market orders: %w", err)
+	}
+
+	orders, err := s.orderRepo.GetPendingOrders(ctx, marketID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get resting orders: %w", err)
+	}
+
+	expired := 0
+	for _, resting := range orders {
+		ok, err := s.expireOrder(ctx, resting.ID)
+		if err != nil {
+			return expired, fmt.Errorf("failed to expire order %s: %w", resting.ID, err)
+		}
+		if ok {
+			expired++
+		}
+	}
+
+	s.logger.Info().
+		Str("market_id", marketID).
+		Int("expired", expired).
+		Msg("market orders expired")
+
+	return expired, nil
+}
+
+// expireOrder expires a single resting order and releases what is left of its hold
+// Returns false if the order is no longer resting
+func (s *OrderServiceImpl) expireOrder(ctx context.Context, orderID uuid.UUID) (bool, error) {
+	// Start transaction
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Get order with pessimistic lock
+	order, err := s.orderRepo.GetByIDForUpdate(ctx, tx, orderID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if order.Status != models.OrderStatusPending && order.Status != models.OrderStatusPartially {
+		return false, nil
+	}
+
+	now := time.Now()
+	order.Status = models.OrderStatusExpired
+
+	if err := s.orderRepo.Update(ctx, tx, order); err != nil {
+		return false, fmt.Errorf("failed to update order: %w", err)
+	}
+
+	// Create outbox event
+	outboxEvent, err := events.NewOutboxEvent(models.AggregateTypeOrder, order.ID, &events.OrderExpiredV1{
+		OrderID:       order.ID.String(),
+		UserID:        order.UserID.String(),
+		SizeRemaining: order.SizeRemaining.String(),
+		ExpiredAt:     now,
+	}, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to build outbox event: %w", err)
+	}
+
+	if err := s.outboxRepo.Create(ctx, tx, outboxEvent); err != nil {
+		return false, fmt.Errorf("failed to insert outbox event: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Return what is left of the hold to the user
+	s.releaseHold(ctx, order)
+
+	// Update metrics
+	s.metrics.OrdersExpiredTotal.Inc()
+	s.metrics.ActiveOrders.Dec()
+
+	s.logger.Debug().
+		Str("order_id", order.ID.String()).
+		Str("size_remaining", order.SizeRemaining.String()).
+		Msg("order expired")
+
+	return true, nil
+}
+
+// SettleMarket settles every matched order of a resulted market
+// Backers of the winning selection and layers of the others win the matched stake at the
+// matched odds; each settlement has a deterministic idempotency key so repeating it is safe
+func (s *OrderServiceImpl) SettleMarket(ctx context.Context, marketID, winningSelectionID string) (int, error) {
+	orders, err := s.orderRepo.GetUnsettledMatchedOrders(ctx, marketID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get matched orders: %w", err)
+	}
+
+	settled := 0
+	for _, order := range orders {
+		won := (order.SelectionID == winningSelectionID) == (order.Side == models.OrderSideBack)
+
+		result, payout := "loss", decimal.Zero
+		if won {
+			result = "win"
+			payout, err = s.matchedReturn(ctx, order.ID)
+			if err != nil {
+				return settled, err
+			}
+		}
+
+		if _, err := s.SettleOrder(ctx, &SettleOrderRequest{
+			OrderID:        order.ID,
+			Result:         result,
+			ActualPayout:   payout,
+			IdempotencyKey: fmt.Sprintf("market-result:%s:%s", marketID, order.ID),
+		}); err != nil {
+			return settled, fmt.Errorf("failed to settle order %s: %w", order.ID, err)
+		}
+		settled++
+	}
+
+	s.logger.Info().
+		Str("market_id", marketID).
+		Str("winning_selection_id", winningSelectionID).
+		Int("settled", settled).
+		Msg("market settled")
+
+	return settled, nil
+}
+
+// matchedReturn returns what a winning order is paid: stake times odds over its live matches
+// For a back bet that is stake plus winnings, for a lay bet its liability plus the backer's stake
+func (s *OrderServiceImpl) matchedReturn(ctx context.Context, orderID uuid.UUID) (decimal.Decimal, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	matches, err := s.orderRepo.GetMatchesByOrderID(ctx, tx, orderID)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to get matches: %w", err)
+	}
+
+	total := decimal.Zero
+	for _, match := range matches {
+		if match.IsReversed() {
+			continue
+		}
+		total = total.Add(match.Size.Mul(match.Price))
+	}
+
+	return total, nil
+}
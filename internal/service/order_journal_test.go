@@ -0,0 +1,442 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// useJournal replaces the test sequencer with one journaling to dir
+func (s *testServiceSetup) useJournal(t *testing.T, dir string) *matchingengine.Journal {
+	journal, err := matchingengine.OpenJournal(dir, matchingengine.JournalOptions{SegmentSize: 512})
+	require.NoError(t, err)
+
+	s.sequencer.Close()
+	s.sequencer = matchingengine.NewSequencer(matchingengine.WithJournal(journal))
+	s.service = NewOrderService(
+		s.mockPool,
+		s.mockOrderRepo,
+		s.mockOutboxRepo,
+		s.mockIdempotencyRepo,
+		s.mockSagaRepo,
+		s.sequencer,
+		s.wallet,
+		observability.NewMetricsWithRegistry(prometheus.NewRegistry()),
+		zerolog.Nop(),
+	)
+	return journal
+}
+
+// recoverBook rebuilds a sequencer from the journal in dir and returns its book
+func recoverBook(t *testing.T, dir string) (*models.MarketBook, int) {
+	sequencer := matchingengine.NewSequencer()
+	defer sequencer.Close()
+
+	replayed, err := sequencer.Recover(context.Background(), dir, nil)
+	require.NoError(t, err)
+
+	book, err := sequencer.MarketBook(context.Background(), "event-123", "team-a")
+	require.NoError(t, err)
+	return book, replayed
+}
+
+// engineState encodes the markets of a snapshot without its journal position and time
+func engineState(t *testing.T, snapshot *matchingengine.Snapshot) []byte {
+	state := *snapshot
+	state.Position = 0
+	state.TakenAt = time.Time{}
+	data, err := state.MarshalBinary()
+	require.NoError(t, err)
+	return data
+}
+
+// recoverState rebuilds a sequencer from a snapshot and the journal in dir and encodes its markets
+func recoverState(t *testing.T, dir string, snapshot *matchingengine.Snapshot) ([]byte, int) {
+	sequencer := matchingengine.NewSequencer()
+	defer sequencer.Close()
+
+	replayed, err := sequencer.Recover(context.Background(), dir, snapshot)
+	require.NoError(t, err)
+
+	recovered, err := sequencer.Snapshot(context.Background())
+	require.NoError(t, err)
+	return engineState(t, recovered), replayed
+}
+
+// expectPersistence lets every placement through the repositories
+// Orders they store are read back by GetByIDForUpdate, transactions are still
+// expected one by one on the pool
+func (s *testServiceSetup) expectPersistence() {
+	s.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil).
+		AnyTimes()
+	s.mockIdempotencyRepo.EXPECT().
+		StoreInTransaction(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), idempotencyTTL).
+		Return(nil).
+		AnyTimes()
+	s.mockIdempotencyRepo.EXPECT().Release(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	s.mockOrderRepo.EXPECT().CreateMatch(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	s.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(s.storeOrder).AnyTimes()
+	s.mockOrderRepo.EXPECT().
+		UpdateMatched(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ pgx.Tx, id uuid.UUID, sizeMatched, sizeRemaining decimal.Decimal, status models.OrderStatus, version int64) error {
+			if stored, ok := s.stored.Load(id); ok {
+				order := *stored.(*models.Order)
+				order.SizeMatched = sizeMatched
+				order.SizeRemaining = sizeRemaining
+				order.Status = status
+				order.Version = version + 1
+				s.stored.Store(id, &order)
+			}
+			return nil
+		}).
+		AnyTimes()
+	s.mockOrderRepo.EXPECT().
+		GetByIDForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ pgx.Tx, id uuid.UUID) (*models.Order, error) {
+			stored, ok := s.stored.Load(id)
+			if !ok {
+				return nil, models.ErrOrderNotFound
+			}
+			order := *stored.(*models.Order)
+			return &order, nil
+		}).
+		AnyTimes()
+	s.mockOutboxRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+}
+
+// storeOrder is a Create action that keeps a copy of the order for later reads
+func (s *testServiceSetup) storeOrder(_ context.Context, _ pgx.Tx, order *models.Order) error {
+	stored := *order
+	s.stored.Store(order.ID, &stored)
+	return nil
+}
+
+// placeAndCommit places an order whose persistence succeeds
+func (s *testServiceSetup) placeAndCommit(t *testing.T, betType string, amount int64, key string) {
+	s.mockPool.ExpectBegin()
+	s.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(s.storeOrder)
+	s.mockPool.ExpectCommit()
+	_, err := s.service.PlaceOrder(context.Background(), s.placeRequest(t, betType, amount, key))
+	require.NoError(t, err)
+}
+
+func TestOrderService_JournalReplayRebuildsBook(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	journal := setup.useJournal(t, dir)
+	setup.expectPersistence()
+
+	// Enough placements to span several segments
+	for i := 0; i < 6; i++ {
+		setup.placeAndCommit(t, "LAY", 100, "idem-key-lay-"+string(rune('a'+i)))
+	}
+
+	// A match that reached the journal but not the database is not replayed
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(assert.AnError)
+	setup.mockPool.ExpectRollback()
+	_, err := setup.service.PlaceOrder(ctx, setup.placeRequest(t, "BACK", 150, "idem-key-aborted"))
+	require.ErrorIs(t, err, assert.AnError)
+
+	setup.placeAndCommit(t, "BACK", 250, "idem-key-back")
+
+	live := setup.book(t)
+	require.NoError(t, journal.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.journal"))
+	require.NoError(t, err)
+	assert.Greater(t, len(segments), 1, "journal should have rotated")
+
+	book, replayed := recoverBook(t, dir)
+	assert.Equal(t, 7, replayed)
+	assert.Equal(t, live.BackOrders, book.BackOrders)
+	assert.Equal(t, live.LayOrders, book.LayOrders)
+
+	// A record torn by a crash is dropped and the journal accepts appends after it
+	last := segments[len(segments)-1]
+	f, err := os.OpenFile(last, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x40, 0, 0, 0, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	book, replayed = recoverBook(t, dir)
+	assert.Equal(t, 7, replayed)
+	assert.Equal(t, live.LayOrders, book.LayOrders)
+
+	reopened, err := matchingengine.OpenJournal(dir, matchingengine.JournalOptions{SegmentSize: 512})
+	require.NoError(t, err)
+	// Six lays took 1-6, the back order's three matches and placement took 7-10
+	require.NoError(t, reopened.Append(&matchingengine.Command{
+		Type:     matchingengine.CommandMarketStatus,
+		MarketID: "event-123",
+		Sequence: 11,
+		Status:   models.MarketStatusSuspended,
+	}))
+	require.NoError(t, reopened.Close())
+
+	_, replayed = recoverBook(t, dir)
+	assert.Equal(t, 8, replayed)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_JournalRecoveryDecidesInDoubtCommand(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	journal := setup.useJournal(t, dir)
+	setup.expectPersistence()
+
+	setup.placeAndCommit(t, "LAY", 100, "idem-key-lay")
+	live := setup.book(t)
+
+	// The process dies after the back order is fsynced and before it is stored or aborted
+	var inDoubt *models.Order
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().
+		Create(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ pgx.Tx, order *models.Order) error {
+			inDoubt = order
+			require.NoError(t, journal.Close())
+			return assert.AnError
+		})
+	setup.mockPool.ExpectRollback()
+	_, err := setup.service.PlaceOrder(ctx, setup.placeRequest(t, "BACK", 150, "idem-key-in-doubt"))
+	require.Error(t, err)
+
+	// Without a check the journal alone cannot tell the placement was lost
+	book, replayed := recoverBook(t, dir)
+	assert.Equal(t, 2, replayed)
+	assert.NotEqual(t, live.LayOrders, book.LayOrders)
+
+	// The orders table has no row for it, recovery aborts it
+	setup.mockOrderRepo.EXPECT().GetByID(gomock.Any(), inDoubt.ID).Return(nil, models.ErrOrderNotFound)
+
+	reopened, err := matchingengine.OpenJournal(dir, matchingengine.JournalOptions{SegmentSize: 512})
+	require.NoError(t, err)
+	sequencer := matchingengine.NewSequencer(
+		matchingengine.WithJournal(reopened),
+		matchingengine.WithCommittedCheck(CommandCommitted(setup.mockOrderRepo)),
+	)
+	replayed, err = sequencer.Recover(ctx, dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+
+	book, err = sequencer.MarketBook(ctx, "event-123", "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, live.LayOrders, book.LayOrders)
+	assert.Empty(t, book.BackOrders)
+	sequencer.Close()
+	require.NoError(t, reopened.Close())
+
+	// The abort record it journaled settles the command for later recoveries
+	book, replayed = recoverBook(t, dir)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, live.LayOrders, book.LayOrders)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_JournalRecoveryReplaysCommittedInDoubtCommand(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	journal := setup.useJournal(t, dir)
+	setup.expectPersistence()
+
+	setup.placeAndCommit(t, "LAY", 100, "idem-key-lay")
+	setup.placeAndCommit(t, "BACK", 150, "idem-key-back")
+	live := setup.book(t)
+	require.NoError(t, journal.Close())
+
+	// Only the market's last command is in doubt, its order row exists
+	setup.mockOrderRepo.EXPECT().
+		GetByID(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, orderID uuid.UUID) (*models.Order, error) {
+			return &models.Order{ID: orderID, Status: models.OrderStatusPartially}, nil
+		})
+
+	reopened, err := matchingengine.OpenJournal(dir, matchingengine.JournalOptions{SegmentSize: 512})
+	require.NoError(t, err)
+	defer reopened.Close()
+	sequencer := matchingengine.NewSequencer(
+		matchingengine.WithJournal(reopened),
+		matchingengine.WithCommittedCheck(CommandCommitted(setup.mockOrderRepo)),
+	)
+	defer sequencer.Close()
+
+	replayed, err := sequencer.Recover(ctx, dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+
+	book, err := sequencer.MarketBook(ctx, "event-123", "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, live.BackOrders, book.BackOrders)
+	assert.Equal(t, live.LayOrders, book.LayOrders)
+}
+
+func TestOrderService_JournalRejectsOversizedFrame(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	dir := t.TempDir()
+	journal := setup.useJournal(t, dir)
+	setup.expectPersistence()
+
+	setup.placeAndCommit(t, "LAY", 100, "idem-key-lay")
+	live := setup.book(t)
+	require.NoError(t, journal.Close())
+
+	// A damaged header claiming a 4 GiB record ends the journal instead of being allocated
+	segments, err := filepath.Glob(filepath.Join(dir, "*.journal"))
+	require.NoError(t, err)
+	f, err := os.OpenFile(segments[len(segments)-1], os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0xf0, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	book, replayed := recoverBook(t, dir)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, live.LayOrders, book.LayOrders)
+}
+
+func TestEngineSnapshotter_SnapshotPlusTailMatchesFullReplay(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	journal := setup.useJournal(t, dir)
+	defer journal.Close()
+	setup.expectPersistence()
+
+	store, err := matchingengine.NewFileSnapshotStore(t.TempDir())
+	require.NoError(t, err)
+	snapshotter := NewEngineSnapshotter(setup.sequencer, store, journal, time.Hour, zerolog.Nop())
+
+	for i := 0; i < 4; i++ {
+		setup.placeAndCommit(t, "LAY", 100, "idem-key-lay-"+string(rune('a'+i)))
+	}
+
+	snapshot, err := setup.sequencer.Snapshot(ctx)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, snapshot))
+	assert.Equal(t, int64(4), snapshot.Position)
+
+	// The tail partly fills a resting order and changes the market status
+	setup.placeAndCommit(t, "BACK", 150, "idem-key-back")
+	_, err = setup.sequencer.SetMarketStatus(ctx, "event-123", models.MarketStatusSuspended, nil)
+	require.NoError(t, err)
+	_, err = setup.sequencer.SetMarketStatus(ctx, "event-123", models.MarketStatusOpen, nil)
+	require.NoError(t, err)
+	setup.placeAndCommit(t, "LAY", 80, "idem-key-lay-tail")
+
+	live, err := setup.sequencer.Snapshot(ctx)
+	require.NoError(t, err)
+
+	saved, err := store.Latest(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, engineState(t, snapshot), engineState(t, saved))
+
+	full, fullReplayed := recoverState(t, dir, nil)
+	tail, tailReplayed := recoverState(t, dir, saved)
+	assert.Equal(t, 8, fullReplayed)
+	assert.Equal(t, 4, tailReplayed)
+	assert.Equal(t, engineState(t, live), full)
+	assert.Equal(t, full, tail)
+
+	// A checkpoint drops the segments it covers, the journal alone no longer suffices
+	segments, err := filepath.Glob(filepath.Join(dir, "*.journal"))
+	require.NoError(t, err)
+	require.Greater(t, len(segments), 1, "journal should have rotated")
+
+	checkpoint, err := snapshotter.Checkpoint(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), checkpoint.Position)
+
+	segments, err = filepath.Glob(filepath.Join(dir, "*.journal"))
+	require.NoError(t, err)
+	assert.Len(t, segments, 1)
+
+	sequencer := matchingengine.NewSequencer()
+	_, err = sequencer.Recover(ctx, dir, nil)
+	sequencer.Close()
+	assert.ErrorIs(t, err, matchingengine.ErrJournalCorrupt)
+
+	latest, err := store.Latest(ctx)
+	require.NoError(t, err)
+	recovered, _ := recoverState(t, dir, latest)
+	assert.Equal(t, engineState(t, live), recovered)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestMarketReplayer_ReproducesPersistedMatches(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	dir := t.TempDir()
+	journal := setup.useJournal(t, dir)
+
+	var persisted []*models.Match
+	setup.mockOrderRepo.EXPECT().
+		CreateMatch(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ pgx.Tx, match *models.Match) error {
+			persisted = append(persisted, match)
+			return nil
+		}).
+		AnyTimes()
+	setup.expectPersistence()
+
+	setup.placeAndCommit(t, "LAY", 100, "idem-key-lay-a")
+	setup.placeAndCommit(t, "LAY", 60, "idem-key-lay-b")
+	setup.placeAndCommit(t, "BACK", 130, "idem-key-back-a")
+	setup.placeAndCommit(t, "BACK", 50, "idem-key-back-b")
+	require.NoError(t, journal.Close())
+	require.Len(t, persisted, 3)
+
+	replayer := matchingengine.NewMarketReplayer("event-123")
+	var replayed []*models.Match
+	err := matchingengine.ReadJournal(dir, 0, func(cmd *matchingengine.Command) error {
+		batch, err := replayer.Apply(cmd)
+		require.NoError(t, err)
+		assert.Equal(t, cmd.Sequence, replayer.Sequence())
+		replayed = append(replayed, batch.Matches()...)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, replayed, len(persisted))
+	for i, match := range persisted {
+		assert.Equal(t, match.Sequence, replayed[i].Sequence)
+		assert.Equal(t, match.BackOrderID, replayed[i].BackOrderID)
+		assert.Equal(t, match.LayOrderID, replayed[i].LayOrderID)
+		assert.True(t, match.Price.Equal(replayed[i].Price))
+		assert.True(t, match.Size.Equal(replayed[i].Size))
+	}
+	assert.Equal(t, setup.book(t).LayOrders, replayer.Books()[0].LayOrders)
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// recordMarketSequences records the market sequence of every outbox event in insert order
+func (s *testServiceSetup) recordMarketSequences() *[]int64 {
+	var mu sync.Mutex
+	sequences := []int64{}
+	s.mockOutboxRepo.EXPECT().
+		Create(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ pgx.Tx, event *models.OutboxEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if event.MarketSequence != nil {
+				sequences = append(sequences, *event.MarketSequence)
+			}
+			return nil
+		}).
+		AnyTimes()
+	return &sequences
+}
+
+// placeRequest builds a funded placement on the market the tests trade on
+func (s *testServiceSetup) placeRequest(t testing.TB, betType string, amount int64, key string) *PlaceOrderRequest {
+	userID := uuid.New()
+	return &PlaceOrderRequest{
+		UserID:         userID,
+		EventID:        "event-123",
+		BetType:        betType,
+		Selection:      "team-a",
+		Amount:         decimal.NewFromInt(amount),
+		Odds:           decimal.NewFromFloat(2.5),
+		ReservationID:  s.reserve(t, userID, decimal.NewFromInt(1000), decimal.NewFromInt(amount)),
+		IdempotencyKey: key,
+	}
+}
+
+func TestOrderService_PlaceOrder_PersistsInSequenceOrder(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	const placements = 8
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil).
+		Times(placements)
+	setup.mockIdempotencyRepo.EXPECT().
+		StoreInTransaction(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), idempotencyTTL).
+		Return(nil).
+		Times(placements)
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(placements)
+	setup.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(placements)
+	sequences := setup.recordMarketSequences()
+
+	// Transactions run one after another, never interleaved
+	for i := 0; i < placements; i++ {
+		setup.mockPool.ExpectBegin()
+		setup.mockPool.ExpectCommit()
+	}
+
+	reqs := make([]*PlaceOrderRequest, placements)
+	for i := range reqs {
+		reqs[i] = setup.placeRequest(t, "BACK", 10, fmt.Sprintf("idem-key-seq-%d", i))
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, placements)
+	for i := range reqs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = setup.service.PlaceOrder(context.Background(), reqs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8}, *sequences)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_PlaceOrder_FailedPersistenceReusesSequence(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil).
+		Times(3)
+	setup.mockIdempotencyRepo.EXPECT().
+		StoreInTransaction(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), idempotencyTTL).
+		Return(nil).
+		Times(2)
+	setup.mockIdempotencyRepo.EXPECT().Release(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	sequences := setup.recordMarketSequences()
+
+	// A resting lay order takes sequence 1
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockPool.ExpectCommit()
+	lay, err := setup.service.PlaceOrder(ctx, setup.placeRequest(t, "LAY", 40, "idem-key-lay"))
+	require.NoError(t, err)
+
+	// A back order matches it but is not persisted
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(assert.AnError)
+	setup.mockPool.ExpectRollback()
+	_, err = setup.service.PlaceOrder(ctx, setup.placeRequest(t, "BACK", 40, "idem-key-failed"))
+	require.ErrorIs(t, err, assert.AnError)
+
+	// The next back order gets the failed order's numbers and the same counterparty
+	var created *models.Match
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockOrderRepo.EXPECT().
+		CreateMatch(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ pgx.Tx, match *models.Match) error {
+			created = match
+			return nil
+		})
+	setup.expectFill(lay, decimal.NewFromInt(40))
+	setup.mockPool.ExpectCommit()
+	order, err := setup.service.PlaceOrder(ctx, setup.placeRequest(t, "BACK", 40, "idem-key-retry"))
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusMatched, order.Status)
+
+	require.NotNil(t, created)
+	assert.Equal(t, int64(2), created.Sequence)
+	assert.Equal(t, []int64{1, 2, 3}, *sequences)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_CancelOrder_TakesOrderOffBook(t *testing.T) {
+	tests := []struct {
+		name      string
+		updateErr error
+		wantBook  int
+	}{
+		{"persisted", nil, 0},
+		{"persistence fails", assert.AnError, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup := setupTestService(t)
+			defer setup.cleanup()
+
+			ctx := context.Background()
+			resting := &models.Order{
+				ID:            uuid.New(),
+				UserID:        uuid.New(),
+				MarketID:      "event-123",
+				SelectionID:   "team-a",
+				Side:          models.OrderSideBack,
+				Price:         decimal.NewFromFloat(2.5),
+				Size:          decimal.NewFromInt(50),
+				SizeMatched:   decimal.Zero,
+				SizeRemaining: decimal.NewFromInt(50),
+				Status:        models.OrderStatusPending,
+			}
+			_, err := setup.sequencer.PlaceOrder(ctx, resting, nil)
+			require.NoError(t, err)
+
+			stored := *resting
+			key := scopedIdempotencyKey(opCancelOrder, resting.UserID, "cancel-idem-book")
+			setup.mockOrderRepo.EXPECT().GetByID(gomock.Any(), resting.ID).Return(&stored, nil)
+			setup.mockIdempotencyRepo.EXPECT().
+				Claim(gomock.Any(), key, gomock.Any(), gomock.Any(), idempotencyLease).
+				Return(json.RawMessage(nil), true, nil)
+			setup.mockPool.ExpectBegin()
+			setup.mockOrderRepo.EXPECT().GetByIDForUpdate(gomock.Any(), gomock.Any(), resting.ID).Return(&stored, nil)
+			setup.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(tt.updateErr)
+
+			var sequences *[]int64
+			if tt.updateErr == nil {
+				sequences = setup.recordMarketSequences()
+				setup.mockIdempotencyRepo.EXPECT().
+					StoreInTransaction(gomock.Any(), gomock.Any(), key, gomock.Any(), gomock.Any(), gomock.Any(), idempotencyTTL).
+					Return(nil)
+				setup.mockPool.ExpectCommit()
+			} else {
+				setup.mockPool.ExpectRollback()
+				setup.mockIdempotencyRepo.EXPECT().Release(gomock.Any(), key, gomock.Any()).Return(nil)
+			}
+
+			_, err = setup.service.CancelOrder(ctx, &CancelOrderRequest{
+				OrderID:        resting.ID,
+				IdempotencyKey: "cancel-idem-book",
+			})
+
+			book := setup.book(t)
+			require.Len(t, book.BackOrders, 1)
+			assert.Equal(t, tt.wantBook, book.BackOrders[0].OrderCount)
+			if tt.updateErr != nil {
+				assert.ErrorIs(t, err, tt.updateErr)
+				assert.Equal(t, models.OrderStatusPending, resting.Status)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, models.OrderStatusCancelled, resting.Status)
+				assert.Equal(t, []int64{2}, *sequences)
+			}
+			assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSequencer_ReadsDoNotCreateMarkets(t *testing.T) {
+	sequencer := matchingengine.NewSequencer()
+	defer sequencer.Close()
+
+	ctx := context.Background()
+
+	// Market IDs of reads come from clients, unknown ones get defaults
+	require.NoError(t, sequencer.CheckMarketOpen(ctx, "unknown-market"))
+	book, err := sequencer.MarketBook(ctx, "unknown-market", "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, "unknown-market", book.MarketID)
+	assert.Empty(t, book.BackOrders)
+	assert.Empty(t, book.LayOrders)
+	assert.Empty(t, sequencer.Markets())
+
+	// A command creates the market, reads then see its state
+	_, err = sequencer.SetMarketStatus(ctx, "event-123", models.MarketStatusSuspended, nil)
+	require.NoError(t, err)
+	assert.ErrorIs(t, sequencer.CheckMarketOpen(ctx, "event-123"), models.ErrMarketSuspended)
+	assert.Equal(t, []string{"event-123"}, sequencer.Markets())
+}
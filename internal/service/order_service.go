@@ -2,16 +2,18 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
+	"github.com/cypherlabdev/order-book-service/internal/events"
 	"github.com/cypherlabdev/order-book-service/internal/models"
 	"github.com/cypherlabdev/order-book-service/internal/observability"
 	"github.com/cypherlabdev/order-book-service/internal/repository"
+	"github.com/cypherlabdev/order-book-service/internal/wallet"
 	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog"
 	"github.com/shopspring/decimal"
 )
@@ -22,7 +24,9 @@ type OrderServiceImpl struct {
 	orderRepo       repository.OrderRepository
 	outboxRepo      repository.OutboxRepository
 	idempotencyRepo repository.IdempotencyRepository
-	matchingEngine  *matchingengine.Engine
+	sagaRepo        repository.SagaRepository
+	sequencer       *matchingengine.Sequencer
+	walletClient    wallet.WalletClient
 	metrics         *observability.Metrics
 	logger          zerolog.Logger
 	validator       *validator.Validate
@@ -34,7 +38,9 @@ func NewOrderService(
 	orderRepo repository.OrderRepository,
 	outboxRepo repository.OutboxRepository,
 	idempotencyRepo repository.IdempotencyRepository,
-	matchingEngine *matchingengine.Engine,
+	sagaRepo repository.SagaRepository,
+	sequencer *matchingengine.Sequencer,
+	walletClient wallet.WalletClient,
 	metrics *observability.Metrics,
 	logger zerolog.Logger,
 ) OrderService {
@@ -43,7 +49,9 @@ func NewOrderService(
 		orderRepo:       orderRepo,
 		outboxRepo:      outboxRepo,
 		idempotencyRepo: idempotencyRepo,
-		matchingEngine:  matchingEngine,
+		sagaRepo:        sagaRepo,
+		sequencer:       sequencer,
+		walletClient:    walletClient,
 		metrics:         metrics,
 		logger:          logger.With().Str("component", "order_service").Logger(),
 		validator:       validator.New(),
@@ -63,40 +71,50 @@ func (s *OrderServiceImpl) PlaceOrder(ctx context.Context, req *PlaceOrderReques
 		return nil, fmt.Errorf("failed to compute request hash: %w", err)
 	}
 
-	// Check idempotency
-	cachedResponse, exists, err := s.idempotencyRepo.Check(ctx, req.IdempotencyKey, requestHash)
+	// Claim the idempotency key, duplicates wait here for the first result
+	key := scopedIdempotencyKey(opPlaceOrder, req.UserID, req.IdempotencyKey)
+	cached, claim, err := s.claimIdempotency(ctx, key, requestHash)
 	if err != nil {
-		if err == models.ErrIdempotencyMismatch {
-			s.logger.Warn().
-				Str("idempotency_key", req.IdempotencyKey).
-				Msg("idempotency key reused with different request")
-			return nil, err
-		}
-		return nil, fmt.Errorf("failed to check idempotency: %w", err)
+		return nil, err
 	}
 
-	if exists {
+	if cached != nil {
 		// Return cached response
 		var order models.Order
-		if err := json.Unmarshal(cachedResponse, &order); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal cached response: %w", err)
+		if err := cached.replay(&order); err != nil {
+			return nil, err
 		}
 		s.logger.Info().
 			Str("order_id", order.ID.String()).
-			Str("idempotency_key", req.IdempotencyKey).
+			Str("idempotency_key", key).
 			Msg("returning cached order from idempotency check")
 		return &order, nil
 	}
 
-	// Start database transaction
-	tx, err := s.db.Begin(ctx)
+	order, err := s.placeOrder(ctx, req, claim)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start transaction: %w", err)
+		s.finishFailure(ctx, claim, err)
+		return nil, err
 	}
-	defer tx.Rollback(ctx)
 
-	// Calculate potential payout
-	potentialPayout := req.Amount.Mul(req.Odds)
+	return order, nil
+}
+
+// placeOrder reserves, matches and persists a new order
+// The market sequencer matches the order and persists the result before its next
+// command, so matches are stored in the order the engine made them. The response is
+// stored under the idempotency key in the same transaction
+func (s *OrderServiceImpl) placeOrder(ctx context.Context, req *PlaceOrderRequest, claim *idempotencyClaim) (*models.Order, error) {
+	// Reject orders on suspended and closed markets before touching the wallet
+	if err := s.sequencer.CheckMarketOpen(ctx, req.EventID); err != nil {
+		return nil, err
+	}
+
+	// Hold exactly the order's liability on the wallet reservation
+	reserved, err := s.reserveLiability(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create order entity
 	now := time.Now()
@@ -127,141 +145,296 @@ func (s *OrderServiceImpl) PlaceOrder(ctx context.Context, req *PlaceOrderReques
 		order.SagaID = req.SagaID.String()
 	}
 
-	// Insert order into database
-	if err := s.orderRepo.Create(ctx, tx, order); err != nil {
-		return nil, fmt.Errorf("failed to create order: %w", err)
+	// Match the order, the book changes are undone unless the placement is persisted
+	batch, err := s.sequencer.PlaceOrder(ctx, order, func(ctx context.Context, batch *matchingengine.Batch) error {
+		return s.persistPlacement(ctx, req, order, batch, claim)
+	})
+	if err != nil {
+		// Nothing of the order was kept, the hold goes back to what the caller reserved
+		s.restoreReservation(context.WithoutCancel(ctx), *req.ReservationID, reserved)
+		return nil, err
+	}
+
+	// Update metrics
+	s.metrics.OrdersPlacedTotal.WithLabelValues(req.BetType, req.Selection).Inc()
+	s.metrics.OrderAmountTotal.Add(req.Amount.InexactFloat64())
+	s.metrics.ActiveOrders.Inc()
+
+	// Commit matched funds off the market goroutine, the placement is compensated if that fails
+	if err := s.commitMatchedFunds(ctx, order, batch.Matches()); err != nil {
+		s.compensateUnfunded(context.WithoutCancel(ctx), order, err)
+		return nil, err
 	}
 
-	// Attempt to match order using matching engine
-	matches, err := s.matchingEngine.PlaceOrder(order)
+	// A fully matched order has no further use for its hold
+	if order.Status == models.OrderStatusMatched {
+		s.releaseHold(ctx, order)
+	}
+
+	s.logger.Info().
+		Str("order_id", order.ID.String()).
+		Str("user_id", order.UserID.String()).
+		Str("event_id", req.EventID).
+		Str("amount", req.Amount.String()).
+		Int64("sequence", batch.First(matchingengine.EventOrderPlaced).Sequence).
+		Msg("order placed successfully")
+
+	return order, nil
+}
+
+// persistPlacement stores a matched order, its matches and their outbox events in one transaction
+func (s *OrderServiceImpl) persistPlacement(ctx context.Context, req *PlaceOrderRequest, order *models.Order, batch *matchingengine.Batch, claim *idempotencyClaim) error {
+	// Start database transaction
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("matching engine error: %w", err)
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Reject placements whose saga was compensated before they arrived
+	if req.SagaID != nil {
+		if err := s.sagaRepo.Lock(ctx, tx, *req.SagaID); err != nil {
+			return fmt.Errorf("failed to lock saga: %w", err)
+		}
+		compensated, err := s.sagaRepo.IsCompensated(ctx, tx, *req.SagaID)
+		if err != nil {
+			return fmt.Errorf("failed to check saga compensation: %w", err)
+		}
+		if compensated {
+			s.logger.Warn().
+				Str("saga_id", req.SagaID.String()).
+				Msg("rejecting placement for compensated saga")
+			return models.ErrSagaCompensated
+		}
+	}
+
+	// Insert order into database
+	if err := s.orderRepo.Create(ctx, tx, order); err != nil {
+		return fmt.Errorf("failed to create order: %w", err)
 	}
 
 	// Process matches and persist to database
+	matches := batch.Matches()
 	for _, match := range matches {
 		// Insert match record
 		if err := s.orderRepo.CreateMatch(ctx, tx, match); err != nil {
-			return nil, fmt.Errorf("failed to create match: %w", err)
+			return fmt.Errorf("failed to create match: %w", err)
 		}
 
 		// Create outbox event for match
-		matchEventPayload := map[string]interface{}{
-			"match_id":       match.ID.String(),
-			"back_order_id":  match.BackOrderID.String(),
-			"lay_order_id":   match.LayOrderID.String(),
-			"matched_size":   match.Size.String(),
-			"matched_price":  match.Price.String(),
-			"matched_at":     match.MatchedAt.Format(time.RFC3339),
-		}
-
-		matchOutboxEvent := &models.OutboxEvent{
-			AggregateID:   match.ID,
-			AggregateType: "match",
-			EventType:     "bet.matched",
-			EventPayload:  matchEventPayload,
-			SagaID:        req.SagaID,
+		matchOutboxEvent, err := events.NewOutboxEvent(models.AggregateTypeMatch, match.ID, &events.MatchCreatedV1{
+			MatchID:      match.ID.String(),
+			MarketID:     match.MarketID,
+			SelectionID:  match.SelectionID,
+			BackOrderID:  match.BackOrderID.String(),
+			LayOrderID:   match.LayOrderID.String(),
+			MatchedSize:  match.Size.String(),
+			MatchedPrice: match.Price.String(),
+			MatchedAt:    match.MatchedAt,
+		}, req.SagaID)
+		if err != nil {
+			return fmt.Errorf("failed to build match outbox event: %w", err)
 		}
+		matchOutboxEvent.MarketSequence = &match.Sequence
 
 		if err := s.outboxRepo.Create(ctx, tx, matchOutboxEvent); err != nil {
-			return nil, fmt.Errorf("failed to insert match outbox event: %w", err)
+			return fmt.Errorf("failed to insert match outbox event: %w", err)
 		}
 	}
 
+	// Fill the resting orders the placement matched against
+	if err := s.persistCounterpartyFills(ctx, tx, order, matches); err != nil {
+		return err
+	}
+
 	// Update order in database with final status after matching
 	if err := s.orderRepo.Update(ctx, tx, order); err != nil {
-		return nil, fmt.Errorf("failed to update order after matching: %w", err)
+		return fmt.Errorf("failed to update order after matching: %w", err)
 	}
 
 	// Create outbox event for order.placed
-	eventPayload := map[string]interface{}{
-		"order_id":         order.ID.String(),
-		"user_id":          order.UserID.String(),
-		"event_id":         req.EventID,
-		"bet_type":         req.BetType,
-		"selection":        req.Selection,
-		"amount":           req.Amount.String(),
-		"odds":             req.Odds.String(),
-		"potential_payout": potentialPayout.String(),
-		"reservation_id":   order.ReservationID,
-		"placed_at":        order.PlacedAt.Format(time.RFC3339),
-		"status":           string(order.Status),
-		"size_matched":     order.SizeMatched.String(),
-		"matches_count":    len(matches),
-	}
-
-	outboxEvent := &models.OutboxEvent{
-		AggregateID:   order.ID,
-		AggregateType: "order",
-		EventType:     "order.placed",
-		EventPayload:  eventPayload,
-		SagaID:        req.SagaID,
+	potentialPayout := req.Amount.Mul(req.Odds)
+	outboxEvent, err := events.NewOutboxEvent(models.AggregateTypeOrder, order.ID, &events.OrderPlacedV1{
+		OrderID:         order.ID.String(),
+		UserID:          order.UserID.String(),
+		EventID:         req.EventID,
+		BetType:         req.BetType,
+		Selection:       req.Selection,
+		Amount:          req.Amount.String(),
+		Odds:            req.Odds.String(),
+		PotentialPayout: potentialPayout.String(),
+		ReservationID:   order.ReservationID,
+		PlacedAt:        order.PlacedAt,
+		Status:          string(order.Status),
+		SizeMatched:     order.SizeMatched.String(),
+		MatchesCount:    int32(len(matches)),
+	}, req.SagaID)
+	if err != nil {
+		return fmt.Errorf("failed to build outbox event: %w", err)
 	}
+	outboxEvent.MarketSequence = &batch.First(matchingengine.EventOrderPlaced).Sequence
 
 	if err := s.outboxRepo.Create(ctx, tx, outboxEvent); err != nil {
-		return nil, fmt.Errorf("failed to insert outbox event: %w", err)
+		return fmt.Errorf("failed to insert outbox event: %w", err)
 	}
 
 	// Store idempotency response
-	if err := s.idempotencyRepo.StoreInTransaction(ctx, tx, req.IdempotencyKey, requestHash, order, 24*time.Hour); err != nil {
-		return nil, fmt.Errorf("failed to store idempotency key: %w", err)
+	response, err := successResponse(order)
+	if err != nil {
+		return err
+	}
+	if err := s.idempotencyRepo.StoreInTransaction(ctx, tx, claim.key, claim.token, claim.requestHash, response, idempotencyTTL); err != nil {
+		return fmt.Errorf("failed to store idempotency key: %w", err)
 	}
 
 	// Commit transaction
 	if err := tx.Commit(ctx); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	claim.stored = true
+	return nil
+}
 
-	// Update metrics
-	s.metrics.OrdersPlacedTotal.WithLabelValues(req.BetType, req.Selection).Inc()
-	s.metrics.OrderAmountTotal.Add(req.Amount.InexactFloat64())
-	s.metrics.ActiveOrders.Inc()
+// persistCounterpartyFills adds each match's size to the resting order it filled, with its outbox event
+func (s *OrderServiceImpl) persistCounterpartyFills(ctx context.Context, tx pgx.Tx, order *models.Order, matches []*models.Match) error {
+	for _, match := range matches {
+		counterparty, err := s.orderRepo.GetByIDForUpdate(ctx, tx, match.CounterpartyOrderID(order.ID))
+		if err != nil {
+			return fmt.Errorf("failed to get counterparty order: %w", err)
+		}
 
-	s.logger.Info().
-		Str("order_id", order.ID.String()).
-		Str("user_id", order.UserID.String()).
-		Str("event_id", req.EventID).
-		Str("amount", req.Amount.String()).
-		Msg("order placed successfully")
+		counterparty.SizeMatched = counterparty.SizeMatched.Add(match.Size)
+		counterparty.SizeRemaining = counterparty.SizeRemaining.Sub(match.Size)
+
+		var event events.Event
+		if counterparty.SizeRemaining.IsZero() {
+			counterparty.Status = models.OrderStatusMatched
+			event = &events.OrderMatchedV1{
+				OrderID:     counterparty.ID.String(),
+				UserID:      counterparty.UserID.String(),
+				SizeMatched: counterparty.SizeMatched.String(),
+				MatchedAt:   match.MatchedAt,
+			}
+		} else {
+			counterparty.Status = models.OrderStatusPartially
+			event = &events.OrderPartiallyMatchedV1{
+				OrderID:       counterparty.ID.String(),
+				UserID:        counterparty.UserID.String(),
+				SizeMatched:   counterparty.SizeMatched.String(),
+				SizeRemaining: counterparty.SizeRemaining.String(),
+				MatchedAt:     match.MatchedAt,
+			}
+		}
 
-	return order, nil
+		if err := s.orderRepo.UpdateMatched(ctx, tx, counterparty.ID, counterparty.SizeMatched, counterparty.SizeRemaining, counterparty.Status, counterparty.Version); err != nil {
+			return fmt.Errorf("failed to update counterparty order: %w", err)
+		}
+
+		var sagaID *uuid.UUID
+		if parsed, err := uuid.Parse(counterparty.SagaID); err == nil {
+			sagaID = &parsed
+		}
+		outboxEvent, err := events.NewOutboxEvent(models.AggregateTypeOrder, counterparty.ID, event, sagaID)
+		if err != nil {
+			return fmt.Errorf("failed to build counterparty outbox event: %w", err)
+		}
+
+		if err := s.outboxRepo.Create(ctx, tx, outboxEvent); err != nil {
+			return fmt.Errorf("failed to insert counterparty outbox event: %w", err)
+		}
+	}
+	return nil
 }
 
 // CancelOrder cancels an active order
-func (s *OrderServiceImpl) CancelOrder(ctx context.Context, req *CancelOrderRequest) error {
+func (s *OrderServiceImpl) CancelOrder(ctx context.Context, req *CancelOrderRequest) (*CancelOrderResult, error) {
 	// Validate request
 	if err := s.validator.Struct(req); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
 	// Compute request hash for idempotency
 	requestHash, err := repository.ComputeRequestHash(req)
 	if err != nil {
-		return fmt.Errorf("failed to compute request hash: %w", err)
+		return nil, fmt.Errorf("failed to compute request hash: %w", err)
 	}
 
-	// Check idempotency
-	_, exists, err := s.idempotencyRepo.Check(ctx, req.IdempotencyKey, requestHash)
+	// The key is scoped by the order's owner, which the request does not carry
+	placed, err := s.lookupOrder(ctx, req.OrderID)
 	if err != nil {
-		if err == models.ErrIdempotencyMismatch {
-			return err
-		}
-		return fmt.Errorf("failed to check idempotency: %w", err)
+		return nil, err
+	}
+
+	// Claim the idempotency key, duplicates wait here for the first result
+	key := scopedIdempotencyKey(opCancelOrder, placed.UserID, req.IdempotencyKey)
+	cached, claim, err := s.claimIdempotency(ctx, key, requestHash)
+	if err != nil {
+		return nil, err
 	}
 
-	if exists {
+	if cached != nil {
 		// Already processed
+		var result CancelOrderResult
+		if err := cached.replay(&result); err != nil {
+			return nil, err
+		}
 		s.logger.Info().
 			Str("order_id", req.OrderID.String()).
-			Str("idempotency_key", req.IdempotencyKey).
+			Str("idempotency_key", key).
 			Msg("cancel already processed (idempotency)")
-		return nil
+		return &result, nil
+	}
+
+	result, err := s.cancelOrder(ctx, req, placed.MarketID, claim)
+	if err != nil {
+		s.finishFailure(ctx, claim, err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// cancelOrder takes a resting order off the book
+// The market sequencer removes the order and persists the cancellation before its
+// next command, so the order cannot match while it is being cancelled. The response
+// is stored under the idempotency key in the same transaction
+func (s *OrderServiceImpl) cancelOrder(ctx context.Context, req *CancelOrderRequest, marketID string, claim *idempotencyClaim) (*CancelOrderResult, error) {
+	var order *models.Order
+	var result *CancelOrderResult
+	_, err := s.sequencer.CancelOrder(ctx, marketID, req.OrderID, func(ctx context.Context, batch *matchingengine.Batch) error {
+		var err error
+		order, result, err = s.persistCancellation(ctx, req, batch.First(matchingengine.EventOrderCancelled), claim)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Return what is left of the hold to the user
+	s.releaseHold(ctx, order)
+
+	// Update metrics
+	s.metrics.OrdersCancelledTotal.WithLabelValues(string(order.Side)).Inc()
+	s.metrics.ActiveOrders.Dec()
+
+	s.logger.Info().
+		Str("order_id", order.ID.String()).
+		Msg("order cancelled successfully")
+
+	return result, nil
+}
+
+// persistCancellation stores a cancellation and its outbox event in one transaction
+func (s *OrderServiceImpl) persistCancellation(ctx context.Context, req *CancelOrderRequest, cancelled *matchingengine.Event, claim *idempotencyClaim) (*models.Order, *CancelOrderResult, error) {
+	// An order that is not on the book was filled or already taken off
+	if cancelled.Order == nil {
+		return nil, nil, fmt.Errorf("order cannot be cancelled: not on the book: %w", models.ErrInvalidOrderStatus)
 	}
 
 	// Start transaction
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to start transaction: %w", err)
+		return nil, nil, fmt.Errorf("failed to start transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
@@ -269,14 +442,14 @@ func (s *OrderServiceImpl) CancelOrder(ctx context.Context, req *CancelOrderRequ
 	order, err := s.orderRepo.GetByIDForUpdate(ctx, tx, req.OrderID)
 	if err != nil {
 		if err == models.ErrOrderNotFound {
-			return err
+			return nil, nil, err
 		}
-		return fmt.Errorf("failed to get order: %w", err)
+		return nil, nil, fmt.Errorf("failed to get order: %w", err)
 	}
 
 	// Check if order can be cancelled
 	if order.Status != models.OrderStatusPending && order.Status != models.OrderStatusPartially {
-		return fmt.Errorf("order cannot be cancelled: status=%s", order.Status)
+		return nil, nil, fmt.Errorf("order cannot be cancelled: status=%s: %w", order.Status, models.ErrInvalidOrderStatus)
 	}
 
 	// Update order status
@@ -285,99 +458,115 @@ func (s *OrderServiceImpl) CancelOrder(ctx context.Context, req *CancelOrderRequ
 	order.CancelledAt = &now
 
 	if err := s.orderRepo.Update(ctx, tx, order); err != nil {
-		return fmt.Errorf("failed to update order: %w", err)
+		return nil, nil, fmt.Errorf("failed to update order: %w", err)
 	}
 
 	// Create outbox event
-	eventPayload := map[string]interface{}{
-		"order_id":     order.ID.String(),
-		"user_id":      order.UserID.String(),
-		"cancelled_at": now.Format(time.RFC3339),
-	}
-
-	outboxEvent := &models.OutboxEvent{
-		AggregateID:   order.ID,
-		AggregateType: "order",
-		EventType:     "order.cancelled",
-		EventPayload:  eventPayload,
-		SagaID:        req.SagaID,
+	outboxEvent, err := events.NewOutboxEvent(models.AggregateTypeOrder, order.ID, &events.OrderCancelledV1{
+		OrderID:     order.ID.String(),
+		UserID:      order.UserID.String(),
+		CancelledAt: now,
+	}, req.SagaID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build outbox event: %w", err)
 	}
+	outboxEvent.MarketSequence = &cancelled.Sequence
 
 	if err := s.outboxRepo.Create(ctx, tx, outboxEvent); err != nil {
-		return fmt.Errorf("failed to insert outbox event: %w", err)
+		return nil, nil, fmt.Errorf("failed to insert outbox event: %w", err)
 	}
 
 	// Store idempotency
-	if err := s.idempotencyRepo.StoreInTransaction(ctx, tx, req.IdempotencyKey, requestHash, nil, 24*time.Hour); err != nil {
-		return fmt.Errorf("failed to store idempotency key: %w", err)
+	result := &CancelOrderResult{
+		OrderID:     order.ID,
+		Status:      order.Status,
+		CancelledAt: now,
+	}
+	response, err := successResponse(result)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.idempotencyRepo.StoreInTransaction(ctx, tx, claim.key, claim.token, claim.requestHash, response, idempotencyTTL); err != nil {
+		return nil, nil, fmt.Errorf("failed to store idempotency key: %w", err)
 	}
 
 	// Commit transaction
 	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
-
-	// Update metrics
-	s.metrics.OrdersCancelledTotal.WithLabelValues(string(order.Side)).Inc()
-	s.metrics.ActiveOrders.Dec()
-
-	s.logger.Info().
-		Str("order_id", order.ID.String()).
-		Msg("order cancelled successfully")
-
-	return nil
+	return order, result, nil
 }
 
 // SettleOrder settles a completed order
-func (s *OrderServiceImpl) SettleOrder(ctx context.Context, req *SettleOrderRequest) error {
+func (s *OrderServiceImpl) SettleOrder(ctx context.Context, req *SettleOrderRequest) (*SettleOrderResult, error) {
 	// Validate request
 	if err := s.validator.Struct(req); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
 	// Compute request hash for idempotency
 	requestHash, err := repository.ComputeRequestHash(req)
 	if err != nil {
-		return fmt.Errorf("failed to compute request hash: %w", err)
+		return nil, fmt.Errorf("failed to compute request hash: %w", err)
 	}
 
-	// Check idempotency
-	_, exists, err := s.idempotencyRepo.Check(ctx, req.IdempotencyKey, requestHash)
+	// The key is scoped by the order's owner, which the request does not carry
+	placed, err := s.lookupOrder(ctx, req.OrderID)
 	if err != nil {
-		if err == models.ErrIdempotencyMismatch {
-			return err
-		}
-		return fmt.Errorf("failed to check idempotency: %w", err)
+		return nil, err
+	}
+
+	// Claim the idempotency key, duplicates wait here for the first result
+	key := scopedIdempotencyKey(opSettleOrder, placed.UserID, req.IdempotencyKey)
+	cached, claim, err := s.claimIdempotency(ctx, key, requestHash)
+	if err != nil {
+		return nil, err
 	}
 
-	if exists {
+	if cached != nil {
+		var result SettleOrderResult
+		if err := cached.replay(&result); err != nil {
+			return nil, err
+		}
 		s.logger.Info().
 			Str("order_id", req.OrderID.String()).
-			Str("idempotency_key", req.IdempotencyKey).
+			Str("idempotency_key", key).
 			Msg("settlement already processed (idempotency)")
-		return nil
+		return &result, nil
 	}
 
+	result, err := s.settleOrder(ctx, req, claim)
+	if err != nil {
+		s.finishFailure(ctx, claim, err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// settleOrder records the result and payout of an order
+// The response is stored under the idempotency key in the same transaction
+func (s *OrderServiceImpl) settleOrder(ctx context.Context, req *SettleOrderRequest, claim *idempotencyClaim) (*SettleOrderResult, error) {
 	// Start transaction
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to start transaction: %w", err)
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
 	// Get order with pessimistic lock
 	order, err := s.orderRepo.GetByIDForUpdate(ctx, tx, req.OrderID)
 	if err != nil {
-		return fmt.Errorf("failed to get order: %w", err)
+		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
 
 	// Update order with settlement
 	now := time.Now()
 	var newStatus models.OrderStatus
 	if req.Result == "win" {
-		newStatus = "settled_win"
+		newStatus = models.OrderStatusSettledWin
 	} else {
-		newStatus = "settled_loss"
+		newStatus = models.OrderStatusSettledLoss
 	}
 
 	// For now, we'll need to add these fields to the Order model or use a different approach
@@ -385,38 +574,44 @@ func (s *OrderServiceImpl) SettleOrder(ctx context.Context, req *SettleOrderRequ
 	order.Status = newStatus
 
 	if err := s.orderRepo.Update(ctx, tx, order); err != nil {
-		return fmt.Errorf("failed to update order: %w", err)
+		return nil, fmt.Errorf("failed to update order: %w", err)
 	}
 
 	// Create outbox event
-	eventPayload := map[string]interface{}{
-		"order_id":      order.ID.String(),
-		"user_id":       order.UserID.String(),
-		"result":        req.Result,
-		"actual_payout": req.ActualPayout.String(),
-		"settled_at":    now.Format(time.RFC3339),
-	}
-
-	outboxEvent := &models.OutboxEvent{
-		AggregateID:   order.ID,
-		AggregateType: "order",
-		EventType:     "order.settled",
-		EventPayload:  eventPayload,
-		SagaID:        req.SagaID,
+	outboxEvent, err := events.NewOutboxEvent(models.AggregateTypeOrder, order.ID, &events.OrderSettledV1{
+		OrderID:      order.ID.String(),
+		UserID:       order.UserID.String(),
+		Result:       req.Result,
+		ActualPayout: req.ActualPayout.String(),
+		SettledAt:    now,
+	}, req.SagaID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build outbox event: %w", err)
 	}
 
 	if err := s.outboxRepo.Create(ctx, tx, outboxEvent); err != nil {
-		return fmt.Errorf("failed to insert outbox event: %w", err)
+		return nil, fmt.Errorf("failed to insert outbox event: %w", err)
 	}
 
 	// Store idempotency
-	if err := s.idempotencyRepo.StoreInTransaction(ctx, tx, req.IdempotencyKey, requestHash, nil, 24*time.Hour); err != nil {
-		return fmt.Errorf("failed to store idempotency key: %w", err)
+	result := &SettleOrderResult{
+		OrderID:      order.ID,
+		Result:       req.Result,
+		ActualPayout: req.ActualPayout,
+		Status:       order.Status,
+		SettledAt:    now,
+	}
+	response, err := successResponse(result)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.idempotencyRepo.StoreInTransaction(ctx, tx, claim.key, claim.token, claim.requestHash, response, idempotencyTTL); err != nil {
+		return nil, fmt.Errorf("failed to store idempotency key: %w", err)
 	}
 
 	// Commit transaction
 	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	// Update metrics
@@ -431,9 +626,259 @@ func (s *OrderServiceImpl) SettleOrder(ctx context.Context, req *SettleOrderRequ
 		Str("payout", req.ActualPayout.String()).
 		Msg("order settled successfully")
 
+	return result, nil
+}
+
+// lookupOrder returns an order outside of any transaction
+// Used for what does not change over an order's life: its owner scopes idempotency
+// keys and its market picks the sequencer
+func (s *OrderServiceImpl) lookupOrder(ctx context.Context, orderID uuid.UUID) (*models.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		if err == models.ErrOrderNotFound {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	return order, nil
+}
+
+// reserveLiability verifies the wallet reservation belongs to the user and resizes it to the order liability
+// Returns the amount held before, which restoreReservation puts back if the placement fails
+func (s *OrderServiceImpl) reserveLiability(ctx context.Context, req *PlaceOrderRequest) (decimal.Decimal, error) {
+	if req.ReservationID == nil {
+		return decimal.Zero, models.ErrReservationRequired
+	}
+
+	reservation, err := s.walletClient.GetReservation(ctx, *req.ReservationID)
+	if err != nil {
+		s.metrics.WalletOperationErrors.WithLabelValues("verify").Inc()
+		if err == wallet.ErrReservationNotFound || err == wallet.ErrReservationClosed {
+			return decimal.Zero, models.ErrInsufficientReservation
+		}
+		return decimal.Zero, fmt.Errorf("failed to get wallet reservation: %w", err)
+	}
+
+	if reservation.UserID != req.UserID {
+		s.logger.Warn().
+			Str("reservation_id", req.ReservationID.String()).
+			Str("user_id", req.UserID.String()).
+			Msg("wallet reservation belongs to a different user")
+		return decimal.Zero, models.ErrReservationMismatch
+	}
+
+	if !reservation.IsActive() {
+		return decimal.Zero, models.ErrInsufficientReservation
+	}
+
+	liability := models.Liability(models.OrderSide(req.BetType), req.Odds, req.Amount)
+	if reservation.Amount.Equal(liability) {
+		return reservation.Amount, nil
+	}
+
+	// Lay orders usually need more than the stake the caller reserved, back orders may need less
+	if err := s.walletClient.AdjustReservation(ctx, *req.ReservationID, liability); err != nil {
+		s.metrics.WalletOperationErrors.WithLabelValues("adjust").Inc()
+		if err == wallet.ErrInsufficientFunds || err == wallet.ErrReservationClosed {
+			return decimal.Zero, models.ErrInsufficientReservation
+		}
+		return decimal.Zero, fmt.Errorf("failed to adjust wallet reservation: %w", err)
+	}
+
+	s.logger.Debug().
+		Str("reservation_id", req.ReservationID.String()).
+		Str("reserved", reservation.Amount.String()).
+		Str("liability", liability.String()).
+		Msg("wallet reservation adjusted to order liability")
+
+	return reservation.Amount, nil
+}
+
+// restoreReservation puts a reservation back to the amount the caller reserved
+// Used when a placement fails after reserveLiability resized the hold
+func (s *OrderServiceImpl) restoreReservation(ctx context.Context, reservationID uuid.UUID, amount decimal.Decimal) {
+	if err := s.walletClient.AdjustReservation(ctx, reservationID, amount); err != nil {
+		s.metrics.WalletOperationErrors.WithLabelValues("restore").Inc()
+		s.logger.Error().Err(err).
+			Str("reservation_id", reservationID.String()).
+			Str("amount", amount.String()).
+			Msg("failed to restore wallet reservation")
+	}
+}
+
+// commitMatchedFunds converts both sides' matched liability into committed funds
+// A lay order fills at the resting back's odds, which can be above its own, so its
+// hold is first raised to what the fills risk. If any commit fails the commits made
+// so far are reverted
+func (s *OrderServiceImpl) commitMatchedFunds(ctx context.Context, order *models.Order, matches []*models.Match) error {
+	if len(matches) == 0 {
+		return nil
+	}
+
+	if err := s.coverLayFills(ctx, order, matches); err != nil {
+		return err
+	}
+
+	for i, match := range matches {
+		if err := s.commitReservation(ctx, match.BackReservationID, match.BackLiability, match.ID.String()+":back"); err != nil {
+			s.revertMatchedCommits(ctx, matches[:i+1])
+			return err
+		}
+		if err := s.commitReservation(ctx, match.LayReservationID, match.LayLiability, match.ID.String()+":lay"); err != nil {
+			s.revertMatchedCommits(ctx, matches[:i+1])
+			return err
+		}
+	}
 	return nil
 }
 
+// compensateUnfunded undoes a stored placement whose matched funds could not be committed
+// A retry replays the stored placement, the order then reads as compensated
+func (s *OrderServiceImpl) compensateUnfunded(ctx context.Context, order *models.Order, cause error) {
+	if _, err := s.compensateOrder(ctx, order, nil, "matched funds not committed"); err != nil {
+		s.logger.Error().Err(err).
+			AnErr("cause", cause).
+			Str("order_id", order.ID.String()).
+			Msg("failed to compensate unfunded placement")
+		return
+	}
+
+	s.metrics.SagaCompensationsTotal.WithLabelValues("unfunded").Inc()
+	s.logger.Warn().Err(cause).
+		Str("order_id", order.ID.String()).
+		Msg("placement compensated, matched funds not committed")
+}
+
+// coverLayFills raises an incoming lay order's hold to the liability of its fills and unmatched size
+// reserveLiability held the liability at the order's own odds
+func (s *OrderServiceImpl) coverLayFills(ctx context.Context, order *models.Order, matches []*models.Match) error {
+	if order.Side != models.OrderSideLay || order.ReservationID == "" {
+		return nil
+	}
+
+	needed := order.RemainingLiability()
+	for _, match := range matches {
+		if match.LayOrderID == order.ID {
+			needed = needed.Add(match.LayLiability)
+		}
+	}
+	if !needed.GreaterThan(order.Liability()) {
+		return nil
+	}
+
+	reservationID, err := uuid.Parse(order.ReservationID)
+	if err != nil {
+		return fmt.Errorf("invalid reservation id on order: %w", err)
+	}
+
+	if err := s.walletClient.AdjustReservation(ctx, reservationID, needed); err != nil {
+		s.metrics.WalletOperationErrors.WithLabelValues("adjust").Inc()
+		if err == wallet.ErrInsufficientFunds || err == wallet.ErrReservationClosed {
+			return models.ErrInsufficientReservation
+		}
+		return fmt.Errorf("failed to adjust wallet reservation: %w", err)
+	}
+
+	s.logger.Debug().
+		Str("order_id", order.ID.String()).
+		Str("reservation_id", order.ReservationID).
+		Str("liability", needed.String()).
+		Msg("wallet reservation raised to lay fills")
+
+	return nil
+}
+
+// commitReservation commits amount from a reservation
+func (s *OrderServiceImpl) commitReservation(ctx context.Context, reservationID string, amount decimal.Decimal, referenceID string) error {
+	if reservationID == "" {
+		return nil
+	}
+
+	parsed, err := uuid.Parse(reservationID)
+	if err != nil {
+		return fmt.Errorf("invalid reservation id on matched order: %w", err)
+	}
+
+	if err := s.walletClient.CommitReservation(ctx, parsed, amount, referenceID); err != nil {
+		s.metrics.WalletOperationErrors.WithLabelValues("commit").Inc()
+		s.logger.Error().Err(err).
+			Str("reservation_id", reservationID).
+			Str("reference_id", referenceID).
+			Str("amount", amount.String()).
+			Msg("failed to commit matched funds")
+		return fmt.Errorf("failed to commit matched funds: %w", err)
+	}
+	return nil
+}
+
+// revertMatchedCommits moves both sides' committed funds of matches that were not stored back into their holds
+// Reverting a reference that was never committed is a no-op
+func (s *OrderServiceImpl) revertMatchedCommits(ctx context.Context, matches []*models.Match) {
+	ctx = context.WithoutCancel(ctx)
+	for _, match := range matches {
+		for _, side := range []struct {
+			reservationID string
+			reference     string
+		}{
+			{match.BackReservationID, match.ID.String() + ":back"},
+			{match.LayReservationID, match.ID.String() + ":lay"},
+		} {
+			reservationID, err := uuid.Parse(side.reservationID)
+			if err != nil {
+				continue
+			}
+
+			if err := s.walletClient.RevertCommit(ctx, reservationID, side.reference); err != nil {
+				s.metrics.WalletOperationErrors.WithLabelValues("revert").Inc()
+				s.logger.Error().Err(err).
+					Str("reservation_id", side.reservationID).
+					Str("reference_id", side.reference).
+					Msg("failed to revert matched funds")
+			}
+		}
+	}
+}
+
+// releaseHold returns what is left of an order's hold to the user
+// Used once an order is done with the book (fully matched, cancelled, expired), which
+// also frees the surplus of fills at better odds than the order's own
+func (s *OrderServiceImpl) releaseHold(ctx context.Context, order *models.Order) {
+	if order.ReservationID == "" {
+		return
+	}
+
+	reservationID, err := uuid.Parse(order.ReservationID)
+	if err != nil {
+		s.logger.Error().Err(err).
+			Str("order_id", order.ID.String()).
+			Str("reservation_id", order.ReservationID).
+			Msg("invalid reservation id on order")
+		return
+	}
+
+	reservation, err := s.walletClient.GetReservation(ctx, reservationID)
+	if err != nil {
+		s.metrics.WalletOperationErrors.WithLabelValues("release").Inc()
+		s.logger.Error().Err(err).
+			Str("order_id", order.ID.String()).
+			Str("reservation_id", order.ReservationID).
+			Msg("failed to get reservation to release")
+		return
+	}
+	if !reservation.IsActive() || reservation.Amount.IsZero() {
+		return
+	}
+
+	if err := s.walletClient.ReleaseReservation(ctx, reservationID, reservation.Amount); err != nil {
+		s.metrics.WalletOperationErrors.WithLabelValues("release").Inc()
+		s.logger.Error().Err(err).
+			Str("order_id", order.ID.String()).
+			Str("reservation_id", order.ReservationID).
+			Str("amount", reservation.Amount.String()).
+			Msg("failed to release reservation")
+	}
+}
+
 // GetOrderByID retrieves a single order by ID
 func (s *OrderServiceImpl) GetOrderByID(ctx context.Context, orderID uuid.UUID) (*models.Order, error) {
 	order, err := s.orderRepo.GetByID(ctx, orderID)
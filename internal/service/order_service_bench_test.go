@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"go.uber.org/mock/gomock"
+
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+)
+
+// benchDatabase hands out transactions that commit instantly
+// The mock pool slows down with every expectation it has seen, which would
+// dominate a benchmark; the repositories are mocks and never use the transaction
+type benchDatabase struct{}
+
+func (benchDatabase) Begin(ctx context.Context) (pgx.Tx, error) {
+	return benchTx{}, nil
+}
+
+type benchTx struct {
+	pgx.Tx
+}
+
+func (benchTx) Commit(ctx context.Context) error { return nil }
+
+func (benchTx) Rollback(ctx context.Context) error { return nil }
+
+// BenchmarkOrderService_PlaceOrder runs placements through the whole service path
+// against in-memory repositories: validation, idempotency, wallet checks, the
+// sequencer and persistence of the order, its matches and events. Backs and lays
+// alternate at one price, so every second placement matches the one before it
+func BenchmarkOrderService_PlaceOrder(b *testing.B) {
+	setup := setupTestService(b)
+	defer setup.cleanup()
+	setup.expectPersistence()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	svc := NewOrderService(
+		benchDatabase{},
+		setup.mockOrderRepo,
+		setup.mockOutboxRepo,
+		setup.mockIdempotencyRepo,
+		setup.mockSagaRepo,
+		setup.sequencer,
+		setup.wallet,
+		observability.NewMetricsWithRegistry(prometheus.NewRegistry()),
+		zerolog.Nop(),
+	)
+
+	requests := make([]*PlaceOrderRequest, b.N)
+	for i := range requests {
+		betType := "LAY"
+		if i%2 == 1 {
+			betType = "BACK"
+		}
+		requests[i] = setup.placeRequest(b, betType, 100, fmt.Sprintf("idem-key-%d", i))
+	}
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for _, req := range requests {
+		if _, err := svc.PlaceOrder(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// Steps of PlaceOrder after the matching engine has changed the book
+const (
+	stepCreateMatch = iota + 1
+	stepMatchOutbox
+	stepFillCounterparty
+	stepCounterpartyOutbox
+	stepUpdateOrder
+	stepOrderOutbox
+	stepStoreIdempotency
+	stepCommit
+)
+
+func TestOrderService_PlaceOrder_FailureAfterMatchingRestoresBook(t *testing.T) {
+	tests := []struct {
+		name    string
+		failAt  int
+		wantErr string
+	}{
+		{"create match", stepCreateMatch, "failed to create match"},
+		{"match outbox event", stepMatchOutbox, "failed to insert match outbox event"},
+		{"fill counterparty", stepFillCounterparty, "failed to update counterparty order"},
+		{"counterparty outbox event", stepCounterpartyOutbox, "failed to insert counterparty outbox event"},
+		{"update order", stepUpdateOrder, "failed to update order after matching"},
+		{"order outbox event", stepOrderOutbox, "failed to insert outbox event"},
+		{"store idempotency key", stepStoreIdempotency, "failed to store idempotency key"},
+		{"commit", stepCommit, "failed to commit transaction"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup := setupTestService(t)
+			defer setup.cleanup()
+
+			// A resting lay order the incoming back order partially matches
+			counterparty := &models.Order{
+				ID:            uuid.New(),
+				UserID:        uuid.New(),
+				MarketID:      "event-123",
+				SelectionID:   "team-a",
+				Side:          models.OrderSideLay,
+				Price:         decimal.NewFromFloat(2.5),
+				Size:          decimal.NewFromInt(40),
+				SizeMatched:   decimal.Zero,
+				SizeRemaining: decimal.NewFromInt(40),
+				Status:        models.OrderStatusPending,
+			}
+			_, err := setup.sequencer.PlaceOrder(context.Background(), counterparty, nil)
+			require.NoError(t, err)
+			bookBefore := setup.book(t)
+
+			userID := uuid.New()
+			req := &PlaceOrderRequest{
+				UserID:         userID,
+				EventID:        "event-123",
+				BetType:        "BACK",
+				Selection:      "team-a",
+				Amount:         decimal.NewFromInt(100),
+				Odds:           decimal.NewFromFloat(2.5),
+				ReservationID:  setup.reserve(t, userID, decimal.NewFromInt(500), decimal.NewFromInt(100)),
+				IdempotencyKey: "idem-key-rollback",
+			}
+			key := scopedIdempotencyKey(opPlaceOrder, userID, "idem-key-rollback")
+
+			injected := errors.New("injected failure")
+			fail := func(step int) error {
+				if step == tt.failAt {
+					return injected
+				}
+				return nil
+			}
+
+			setup.mockIdempotencyRepo.EXPECT().
+				Claim(gomock.Any(), key, gomock.Any(), gomock.Any(), idempotencyLease).
+				Return(json.RawMessage(nil), true, nil)
+			setup.mockPool.ExpectBegin()
+			setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+			// Every step up to and including the failing one is reached
+			setup.mockOrderRepo.EXPECT().CreateMatch(gomock.Any(), gomock.Any(), gomock.Any()).Return(fail(stepCreateMatch))
+			if tt.failAt > stepCreateMatch {
+				setup.mockOutboxRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(fail(stepMatchOutbox))
+			}
+			if tt.failAt > stepMatchOutbox {
+				stored := *counterparty
+				setup.mockOrderRepo.EXPECT().GetByIDForUpdate(gomock.Any(), gomock.Any(), counterparty.ID).Return(&stored, nil)
+				setup.mockOrderRepo.EXPECT().
+					UpdateMatched(gomock.Any(), gomock.Any(), counterparty.ID, gomock.Any(), gomock.Any(), models.OrderStatusMatched, stored.Version).
+					Return(fail(stepFillCounterparty))
+			}
+			if tt.failAt > stepFillCounterparty {
+				setup.mockOutboxRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(fail(stepCounterpartyOutbox))
+			}
+			if tt.failAt > stepCounterpartyOutbox {
+				setup.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(fail(stepUpdateOrder))
+			}
+			if tt.failAt > stepUpdateOrder {
+				setup.mockOutboxRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(fail(stepOrderOutbox))
+			}
+			if tt.failAt > stepOrderOutbox {
+				setup.mockIdempotencyRepo.EXPECT().
+					StoreInTransaction(gomock.Any(), gomock.Any(), key, gomock.Any(), gomock.Any(), gomock.Any(), idempotencyTTL).
+					Return(fail(stepStoreIdempotency))
+			}
+			if tt.failAt == stepCommit {
+				setup.mockPool.ExpectCommit().WillReturnError(injected)
+			} else {
+				setup.mockPool.ExpectRollback()
+			}
+
+			// The key is released so the client can retry
+			setup.mockIdempotencyRepo.EXPECT().Release(gomock.Any(), key, gomock.Any()).Return(nil)
+
+			order, err := setup.service.PlaceOrder(context.Background(), req)
+			require.Error(t, err)
+			assert.Nil(t, order)
+			assert.ErrorIs(t, err, injected)
+			assert.Contains(t, err.Error(), tt.wantErr)
+
+			// The book is exactly as it was before the placement
+			bookAfter := setup.book(t)
+			assert.Equal(t, bookBefore.BackOrders, bookAfter.BackOrders)
+			assert.Equal(t, bookBefore.LayOrders, bookAfter.LayOrders)
+
+			assert.True(t, decimal.NewFromInt(40).Equal(counterparty.SizeRemaining))
+			assert.True(t, counterparty.SizeMatched.IsZero())
+			assert.Equal(t, models.OrderStatusPending, counterparty.Status)
+			assert.Nil(t, counterparty.MatchedAt)
+			assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+
+			// The counterparty is still available to the next order
+			next := &models.Order{
+				ID:            uuid.New(),
+				MarketID:      "event-123",
+				SelectionID:   "team-a",
+				Side:          models.OrderSideBack,
+				Price:         decimal.NewFromFloat(2.5),
+				Size:          decimal.NewFromInt(40),
+				SizeMatched:   decimal.Zero,
+				SizeRemaining: decimal.NewFromInt(40),
+			}
+			batch, err := setup.sequencer.PlaceOrder(context.Background(), next, nil)
+			require.NoError(t, err)
+			matches := batch.Matches()
+			require.Len(t, matches, 1)
+			assert.Equal(t, counterparty.ID, matches[0].LayOrderID)
+			assert.Equal(t, models.OrderStatusMatched, counterparty.Status)
+		})
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/cypherlabdev/order-book-service/internal/mocks"
 	"github.com/cypherlabdev/order-book-service/internal/models"
 	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/wallet"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/pashagolub/pgxmock/v4"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
@@ -26,17 +30,22 @@ type testServiceSetup struct {
 	mockOrderRepo       *mocks.MockOrderRepository
 	mockOutboxRepo      *mocks.MockOutboxRepository
 	mockIdempotencyRepo *mocks.MockIdempotencyRepository
+	mockSagaRepo        *mocks.MockSagaRepository
 	mockPool            pgxmock.PgxPoolIface
+	sequencer           *matchingengine.Sequencer
+	wallet              *wallet.InMemoryWalletClient
 	ctrl                *gomock.Controller
+	stored              sync.Map // orders created through expectPersistence, by ID
 }
 
 // setupTestService creates a test service with all mocked dependencies
-func setupTestService(t *testing.T) *testServiceSetup {
+func setupTestService(t testing.TB) *testServiceSetup {
 	ctrl := gomock.NewController(t)
 
 	mockOrderRepo := mocks.NewMockOrderRepository(ctrl)
 	mockOutboxRepo := mocks.NewMockOutboxRepository(ctrl)
 	mockIdempotencyRepo := mocks.NewMockIdempotencyRepository(ctrl)
+	mockSagaRepo := mocks.NewMockSagaRepository(ctrl)
 
 	logger := zerolog.Nop()
 
@@ -47,11 +56,17 @@ func setupTestService(t *testing.T) *testServiceSetup {
 	mockPool, err := pgxmock.NewPool()
 	require.NoError(t, err)
 
+	sequencer := matchingengine.NewSequencer()
+	walletClient := wallet.NewInMemoryWalletClient()
+
 	service := NewOrderService(
 		mockPool,
 		mockOrderRepo,
 		mockOutboxRepo,
 		mockIdempotencyRepo,
+		mockSagaRepo,
+		sequencer,
+		walletClient,
 		metrics,
 		logger,
 	)
@@ -61,15 +76,62 @@ func setupTestService(t *testing.T) *testServiceSetup {
 		mockOrderRepo:       mockOrderRepo,
 		mockOutboxRepo:      mockOutboxRepo,
 		mockIdempotencyRepo: mockIdempotencyRepo,
+		mockSagaRepo:        mockSagaRepo,
 		mockPool:            mockPool,
+		sequencer:           sequencer,
+		wallet:              walletClient,
 		ctrl:                ctrl,
 	}
 }
 
+// reserve funds the user's wallet and creates a reservation of amount
+func (s *testServiceSetup) reserve(t testing.TB, userID uuid.UUID, balance, amount decimal.Decimal) *uuid.UUID {
+	s.wallet.Deposit(userID, balance)
+	reservationID, err := s.wallet.Reserve(userID, amount)
+	require.NoError(t, err)
+	return &reservationID
+}
+
+// expectStoredFailure expects a failed call to be stored under key with the given error code
+func (s *testServiceSetup) expectStoredFailure(key, code string) {
+	s.mockIdempotencyRepo.EXPECT().
+		Store(gomock.Any(), key, gomock.Any(), gomock.Any(), gomock.Cond(func(resp *idempotentResponse) bool {
+			return resp.ErrorCode == code && resp.Result == nil
+		}), 24*time.Hour).
+		Return(nil)
+}
+
+// expectFill expects a placement to fill size of a resting order as the orders table holds it
+func (s *testServiceSetup) expectFill(resting *models.Order, size decimal.Decimal) {
+	stored := *resting
+	matched := stored.SizeMatched.Add(size)
+	remaining := stored.SizeRemaining.Sub(size)
+	status := models.OrderStatusPartially
+	if remaining.IsZero() {
+		status = models.OrderStatusMatched
+	}
+
+	s.mockOrderRepo.EXPECT().GetByIDForUpdate(gomock.Any(), gomock.Any(), resting.ID).Return(&stored, nil)
+	s.mockOrderRepo.EXPECT().
+		UpdateMatched(gomock.Any(), gomock.Any(), resting.ID,
+			gomock.Cond(func(d decimal.Decimal) bool { return d.Equal(matched) }),
+			gomock.Cond(func(d decimal.Decimal) bool { return d.Equal(remaining) }),
+			status, stored.Version).
+		Return(nil)
+}
+
 // cleanup cleans up test resources
 func (s *testServiceSetup) cleanup() {
 	s.ctrl.Finish()
 	s.mockPool.Close()
+	s.sequencer.Close()
+}
+
+// book returns the order book of the selection the tests trade on
+func (s *testServiceSetup) book(t *testing.T) *models.MarketBook {
+	book, err := s.sequencer.MarketBook(context.Background(), "event-123", "team-a")
+	require.NoError(t, err)
+	return book
 }
 
 func TestOrderService_PlaceOrder_Success(t *testing.T) {
@@ -82,10 +144,11 @@ func TestOrderService_PlaceOrder_Success(t *testing.T) {
 	req := &PlaceOrderRequest{
 		UserID:         userID,
 		EventID:        "event-123",
-		BetType:        "moneyline",
+		BetType:        "BACK",
 		Selection:      "team-a",
 		Amount:         decimal.NewFromFloat(100.00),
 		Odds:           decimal.NewFromFloat(2.5),
+		ReservationID:  setup.reserve(t, userID, decimal.NewFromInt(500), decimal.NewFromInt(100)),
 		SagaID:         &sagaID,
 		IdempotencyKey: "idem-key-123",
 	}
@@ -95,14 +158,27 @@ func TestOrderService_PlaceOrder_Success(t *testing.T) {
 
 	// Mock idempotency check - key doesn't exist
 	setup.mockIdempotencyRepo.EXPECT().
-		Check(gomock.Any(), "idem-key-123", gomock.Any()).
-		Return(json.RawMessage(nil), false, nil)
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-123"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
+
+	// Mock saga lock and compensation check
+	setup.mockSagaRepo.EXPECT().
+		Lock(gomock.Any(), gomock.Any(), sagaID).
+		Return(nil)
+	setup.mockSagaRepo.EXPECT().
+		IsCompensated(gomock.Any(), gomock.Any(), sagaID).
+		Return(false, nil)
 
 	// Mock order creation
 	setup.mockOrderRepo.EXPECT().
 		Create(gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(nil)
 
+	// Mock order update after matching
+	setup.mockOrderRepo.EXPECT().
+		Update(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
 	// Mock outbox creation
 	setup.mockOutboxRepo.EXPECT().
 		Create(gomock.Any(), gomock.Any(), gomock.Any()).
@@ -110,7 +186,7 @@ func TestOrderService_PlaceOrder_Success(t *testing.T) {
 
 	// Mock idempotency storage
 	setup.mockIdempotencyRepo.EXPECT().
-		StoreInTransaction(gomock.Any(), gomock.Any(), "idem-key-123", gomock.Any(), gomock.Any(), 24*time.Hour).
+		StoreInTransaction(gomock.Any(), gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-123"), gomock.Any(), gomock.Any(), gomock.Any(), 24*time.Hour).
 		Return(nil)
 
 	setup.mockPool.ExpectCommit()
@@ -140,7 +216,8 @@ func TestOrderService_PlaceOrder_IdempotencyHit(t *testing.T) {
 		UserID:  uuid.New(),
 		Status:  models.OrderStatusPending,
 	}
-	cachedJSON, _ := json.Marshal(cachedOrder)
+	cachedResult, _ := json.Marshal(cachedOrder)
+	cachedJSON, _ := json.Marshal(&idempotentResponse{Result: cachedResult})
 
 	req := &PlaceOrderRequest{
 		UserID:         uuid.New(),
@@ -154,8 +231,8 @@ func TestOrderService_PlaceOrder_IdempotencyHit(t *testing.T) {
 
 	// Mock idempotency check - key exists
 	setup.mockIdempotencyRepo.EXPECT().
-		Check(gomock.Any(), "idem-key-cached", gomock.Any()).
-		Return(json.RawMessage(cachedJSON), true, nil)
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-cached"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(cachedJSON), false, nil)
 
 	// Execute
 	order, err := setup.service.PlaceOrder(ctx, req)
@@ -183,7 +260,7 @@ func TestOrderService_PlaceOrder_IdempotencyMismatch(t *testing.T) {
 
 	// Mock idempotency check - mismatch error
 	setup.mockIdempotencyRepo.EXPECT().
-		Check(gomock.Any(), "idem-key-mismatch", gomock.Any()).
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-mismatch"), gomock.Any(), gomock.Any(), idempotencyLease).
 		Return(json.RawMessage(nil), false, models.ErrIdempotencyMismatch)
 
 	// Execute
@@ -220,20 +297,27 @@ func TestOrderService_PlaceOrder_TransactionBeginError(t *testing.T) {
 	defer setup.cleanup()
 
 	ctx := context.Background()
+	userID := uuid.New()
 	req := &PlaceOrderRequest{
-		UserID:         uuid.New(),
+		UserID:         userID,
 		EventID:        "event-123",
 		BetType:        "moneyline",
 		Selection:      "team-a",
 		Amount:         decimal.NewFromFloat(100.00),
 		Odds:           decimal.NewFromFloat(2.5),
+		ReservationID:  setup.reserve(t, userID, decimal.NewFromInt(100), decimal.NewFromInt(100)),
 		IdempotencyKey: "idem-key-tx-error",
 	}
 
 	// Mock idempotency check - key doesn't exist
 	setup.mockIdempotencyRepo.EXPECT().
-		Check(gomock.Any(), "idem-key-tx-error", gomock.Any()).
-		Return(json.RawMessage(nil), false, nil)
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-tx-error"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
+
+	// The failure is not replayed, the key is released for a retry
+	setup.mockIdempotencyRepo.EXPECT().
+		Release(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-tx-error"), gomock.Any()).
+		Return(nil)
 
 	// Mock transaction begin failure
 	setup.mockPool.ExpectBegin().WillReturnError(errors.New("db connection failed"))
@@ -252,21 +336,28 @@ func TestOrderService_PlaceOrder_OrderCreationError(t *testing.T) {
 	defer setup.cleanup()
 
 	ctx := context.Background()
+	userID := uuid.New()
 	req := &PlaceOrderRequest{
-		UserID:         uuid.New(),
+		UserID:         userID,
 		EventID:        "event-123",
 		BetType:        "moneyline",
 		Selection:      "team-a",
 		Amount:         decimal.NewFromFloat(100.00),
 		Odds:           decimal.NewFromFloat(2.5),
+		ReservationID:  setup.reserve(t, userID, decimal.NewFromInt(100), decimal.NewFromInt(100)),
 		IdempotencyKey: "idem-key-create-error",
 	}
 
 	setup.mockPool.ExpectBegin()
 
 	setup.mockIdempotencyRepo.EXPECT().
-		Check(gomock.Any(), "idem-key-create-error", gomock.Any()).
-		Return(json.RawMessage(nil), false, nil)
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-create-error"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
+
+	// The failure is not replayed, the key is released for a retry
+	setup.mockIdempotencyRepo.EXPECT().
+		Release(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-create-error"), gomock.Any()).
+		Return(nil)
 
 	// Mock order creation failure
 	setup.mockOrderRepo.EXPECT().
@@ -284,6 +375,664 @@ func TestOrderService_PlaceOrder_OrderCreationError(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to create order")
 }
 
+func TestOrderService_PlaceOrder_MissingReservation(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	req := &PlaceOrderRequest{
+		UserID:         uuid.New(),
+		EventID:        "event-123",
+		BetType:        "BACK",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromFloat(100.00),
+		Odds:           decimal.NewFromFloat(2.5),
+		IdempotencyKey: "idem-key-no-reservation",
+	}
+
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-no-reservation"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
+	setup.expectStoredFailure(scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-no-reservation"), "reservation_required")
+
+	// Execute
+	order, err := setup.service.PlaceOrder(ctx, req)
+
+	// Assert
+	assert.Nil(t, order)
+	assert.Equal(t, models.ErrReservationRequired, err)
+}
+
+func TestOrderService_PlaceOrder_ReservationOfAnotherUser(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	req := &PlaceOrderRequest{
+		UserID:         uuid.New(),
+		EventID:        "event-123",
+		BetType:        "BACK",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromFloat(100.00),
+		Odds:           decimal.NewFromFloat(2.5),
+		ReservationID:  setup.reserve(t, uuid.New(), decimal.NewFromInt(100), decimal.NewFromInt(100)),
+		IdempotencyKey: "idem-key-foreign-reservation",
+	}
+
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-foreign-reservation"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
+	setup.expectStoredFailure(scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-foreign-reservation"), "reservation_mismatch")
+
+	// Execute
+	order, err := setup.service.PlaceOrder(ctx, req)
+
+	// Assert
+	assert.Nil(t, order)
+	assert.Equal(t, models.ErrReservationMismatch, err)
+}
+
+func TestOrderService_PlaceOrder_LayLiabilityExceedsBalance(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	userID := uuid.New()
+
+	// Laying 100 at 4.0 needs a 300 hold, the user can only cover 200
+	req := &PlaceOrderRequest{
+		UserID:         userID,
+		EventID:        "event-123",
+		BetType:        "LAY",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromFloat(100.00),
+		Odds:           decimal.NewFromFloat(4.0),
+		ReservationID:  setup.reserve(t, userID, decimal.NewFromInt(200), decimal.NewFromInt(100)),
+		IdempotencyKey: "idem-key-lay-liability",
+	}
+
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-lay-liability"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
+	setup.mockIdempotencyRepo.EXPECT().
+		Release(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-lay-liability"), gomock.Any()).
+		Return(nil)
+
+	// Execute
+	order, err := setup.service.PlaceOrder(ctx, req)
+
+	// Assert
+	assert.Nil(t, order)
+	assert.Equal(t, models.ErrInsufficientReservation, err)
+}
+
+func TestOrderService_PlaceOrder_MatchCommitsReservedFunds(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	layUserID := uuid.New()
+	backUserID := uuid.New()
+
+	// Resting lay order of 100 at 3.0 holds 200
+	layReservationID := setup.reserve(t, layUserID, decimal.NewFromInt(200), decimal.NewFromInt(200))
+	layOrder := &models.Order{
+		ID:            uuid.New(),
+		UserID:        layUserID,
+		MarketID:      "event-123",
+		SelectionID:   "team-a",
+		Side:          models.OrderSideLay,
+		Price:         decimal.NewFromInt(3),
+		Size:          decimal.NewFromInt(100),
+		SizeMatched:   decimal.Zero,
+		SizeRemaining: decimal.NewFromInt(100),
+		Status:        models.OrderStatusPending,
+		ReservationID: layReservationID.String(),
+	}
+	_, err := setup.sequencer.PlaceOrder(ctx, layOrder, nil)
+	require.NoError(t, err)
+
+	req := &PlaceOrderRequest{
+		UserID:         backUserID,
+		EventID:        "event-123",
+		BetType:        "BACK",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromFloat(40.00),
+		Odds:           decimal.NewFromFloat(3.0),
+		ReservationID:  setup.reserve(t, backUserID, decimal.NewFromInt(40), decimal.NewFromInt(40)),
+		IdempotencyKey: "idem-key-match-commit",
+	}
+
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-match-commit"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
+
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockOrderRepo.EXPECT().CreateMatch(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.expectFill(layOrder, decimal.NewFromInt(40))
+	setup.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockOutboxRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(3)
+	setup.mockIdempotencyRepo.EXPECT().
+		StoreInTransaction(gomock.Any(), gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-match-commit"), gomock.Any(), gomock.Any(), gomock.Any(), 24*time.Hour).
+		Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	// Execute
+	order, err := setup.service.PlaceOrder(ctx, req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusMatched, order.Status)
+
+	backReservation, err := setup.wallet.GetReservation(ctx, *req.ReservationID)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(40).Equal(backReservation.Committed))
+	assert.True(t, backReservation.Amount.IsZero())
+
+	// 40 matched at 3.0 commits 80 of the lay hold, 120 stays reserved
+	layReservation, err := setup.wallet.GetReservation(ctx, *layReservationID)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(80).Equal(layReservation.Committed))
+	assert.True(t, decimal.NewFromInt(120).Equal(layReservation.Amount))
+}
+
+func TestOrderService_PlaceOrder_LayFilledAboveItsOddsRaisesHold(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	layUserID := uuid.New()
+	backUserID := uuid.New()
+
+	// Resting back order of 100 at 3.0 holds its stake
+	backReservationID := setup.reserve(t, backUserID, decimal.NewFromInt(100), decimal.NewFromInt(100))
+	backOrder := &models.Order{
+		ID:            uuid.New(),
+		UserID:        backUserID,
+		MarketID:      "event-123",
+		SelectionID:   "team-a",
+		Side:          models.OrderSideBack,
+		Price:         decimal.NewFromInt(3),
+		Size:          decimal.NewFromInt(100),
+		SizeMatched:   decimal.Zero,
+		SizeRemaining: decimal.NewFromInt(100),
+		Status:        models.OrderStatusPending,
+		ReservationID: backReservationID.String(),
+	}
+	_, err := setup.sequencer.PlaceOrder(ctx, backOrder, nil)
+	require.NoError(t, err)
+
+	// Laying 100 at 2.0 holds 100 but fills at 3.0, which risks 200
+	req := &PlaceOrderRequest{
+		UserID:         layUserID,
+		EventID:        "event-123",
+		BetType:        "LAY",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromInt(100),
+		Odds:           decimal.NewFromInt(2),
+		ReservationID:  setup.reserve(t, layUserID, decimal.NewFromInt(300), decimal.NewFromInt(100)),
+		IdempotencyKey: "idem-key-lay-above-odds",
+	}
+
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-lay-above-odds"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
+
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockOrderRepo.EXPECT().CreateMatch(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.expectFill(backOrder, decimal.NewFromInt(100))
+	setup.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockOutboxRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(3)
+	setup.mockIdempotencyRepo.EXPECT().
+		StoreInTransaction(gomock.Any(), gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-lay-above-odds"), gomock.Any(), gomock.Any(), gomock.Any(), 24*time.Hour).
+		Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	order, err := setup.service.PlaceOrder(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusMatched, order.Status)
+
+	layReservation, err := setup.wallet.GetReservation(ctx, *req.ReservationID)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(200).Equal(layReservation.Committed))
+	assert.True(t, layReservation.Amount.IsZero())
+	assert.True(t, decimal.NewFromInt(100).Equal(setup.wallet.Balance(layUserID)))
+
+	backReservation, err := setup.wallet.GetReservation(ctx, *backReservationID)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(backReservation.Committed))
+}
+
+func TestOrderService_PlaceOrder_FailedPersistRestoresReservations(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	layUserID := uuid.New()
+	backUserID := uuid.New()
+
+	// Resting back order of 50 at 2.5
+	backReservationID := setup.reserve(t, backUserID, decimal.NewFromInt(50), decimal.NewFromInt(50))
+	_, err := setup.sequencer.PlaceOrder(ctx, &models.Order{
+		ID:            uuid.New(),
+		UserID:        backUserID,
+		MarketID:      "event-123",
+		SelectionID:   "team-a",
+		Side:          models.OrderSideBack,
+		Price:         decimal.NewFromFloat(2.5),
+		Size:          decimal.NewFromInt(50),
+		SizeMatched:   decimal.Zero,
+		SizeRemaining: decimal.NewFromInt(50),
+		ReservationID: backReservationID.String(),
+	}, nil)
+	require.NoError(t, err)
+
+	// Laying 100 at 2.5 raises the 100 reservation to 150 and commits funds for the fill
+	req := &PlaceOrderRequest{
+		UserID:         layUserID,
+		EventID:        "event-123",
+		BetType:        "LAY",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromInt(100),
+		Odds:           decimal.NewFromFloat(2.5),
+		ReservationID:  setup.reserve(t, layUserID, decimal.NewFromInt(500), decimal.NewFromInt(100)),
+		IdempotencyKey: "idem-key-persist-fails",
+	}
+
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-persist-fails"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
+	setup.mockIdempotencyRepo.EXPECT().
+		Release(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-persist-fails"), gomock.Any()).
+		Return(nil)
+
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("database error"))
+	setup.mockPool.ExpectRollback()
+
+	_, err = setup.service.PlaceOrder(ctx, req)
+	require.Error(t, err)
+
+	// Both holds are as they were before the placement
+	layReservation, err := setup.wallet.GetReservation(ctx, *req.ReservationID)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(layReservation.Amount))
+	assert.True(t, layReservation.Committed.IsZero())
+	assert.True(t, decimal.NewFromInt(400).Equal(setup.wallet.Balance(layUserID)))
+
+	backReservation, err := setup.wallet.GetReservation(ctx, *backReservationID)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(50).Equal(backReservation.Amount))
+	assert.True(t, backReservation.Committed.IsZero())
+
+	require.Len(t, setup.book(t).BackOrders, 1)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_PlaceOrder_FailedCommitCompensatesPlacement(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+
+	// Resting back order whose reservation the wallet closes
+	backReq := setup.placeRequest(t, "BACK", 50, "idem-key-back")
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(setup.storeOrder)
+	setup.mockPool.ExpectCommit()
+
+	// The lay order matching it is stored before the funds are committed
+	layReq := setup.placeRequest(t, "LAY", 50, "idem-key-commit-fails")
+	var matches []*models.Match
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(setup.storeOrder)
+	setup.mockOrderRepo.EXPECT().
+		CreateMatch(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ pgx.Tx, match *models.Match) error {
+			matches = append(matches, match)
+			return nil
+		})
+	setup.mockPool.ExpectCommit()
+	setup.expectPersistence()
+
+	_, err := setup.service.PlaceOrder(ctx, backReq)
+	require.NoError(t, err)
+	require.NoError(t, setup.wallet.ReleaseReservation(ctx, *backReq.ReservationID, decimal.NewFromInt(50)))
+
+	// The commit fails and the placement is compensated
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().
+		GetMatchesByOrderID(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, pgx.Tx, uuid.UUID) ([]*models.Match, error) {
+			return matches, nil
+		})
+	setup.mockOrderRepo.EXPECT().ReverseMatch(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	_, err = setup.service.PlaceOrder(ctx, layReq)
+	assert.ErrorIs(t, err, wallet.ErrReservationClosed)
+	require.Len(t, matches, 1)
+
+	stored, ok := setup.stored.Load(matches[0].LayOrderID)
+	require.True(t, ok)
+	assert.Equal(t, models.OrderStatusCompensated, stored.(*models.Order).Status)
+
+	// Nothing is committed and the lay hold goes back to the user
+	layReservation, err := setup.wallet.GetReservation(ctx, *layReq.ReservationID)
+	require.NoError(t, err)
+	assert.True(t, layReservation.Amount.IsZero())
+	assert.True(t, layReservation.Committed.IsZero())
+	assert.True(t, decimal.NewFromInt(1000).Equal(setup.wallet.Balance(layReq.UserID)))
+
+	// The back order is on the book again, the lay order is not
+	book := setup.book(t)
+	require.Len(t, book.BackOrders, 1)
+	assert.Equal(t, 1, book.BackOrders[0].OrderCount)
+	for _, level := range book.LayOrders {
+		assert.Zero(t, level.OrderCount)
+	}
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_PlaceOrder_SagaAlreadyCompensated(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	userID := uuid.New()
+	sagaID := uuid.New()
+	req := &PlaceOrderRequest{
+		UserID:         userID,
+		EventID:        "event-123",
+		BetType:        "BACK",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromFloat(100.00),
+		Odds:           decimal.NewFromFloat(2.5),
+		ReservationID:  setup.reserve(t, userID, decimal.NewFromInt(100), decimal.NewFromInt(100)),
+		SagaID:         &sagaID,
+		IdempotencyKey: "idem-key-late-placement",
+	}
+
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-late-placement"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
+	setup.expectStoredFailure(scopedIdempotencyKey(opPlaceOrder, req.UserID, "idem-key-late-placement"), "saga_compensated")
+
+	setup.mockPool.ExpectBegin()
+	setup.mockSagaRepo.EXPECT().Lock(gomock.Any(), gomock.Any(), sagaID).Return(nil)
+	setup.mockSagaRepo.EXPECT().IsCompensated(gomock.Any(), gomock.Any(), sagaID).Return(true, nil)
+	setup.mockPool.ExpectRollback()
+
+	// Execute
+	order, err := setup.service.PlaceOrder(ctx, req)
+
+	// Assert
+	assert.Nil(t, order)
+	assert.Equal(t, models.ErrSagaCompensated, err)
+	assert.Empty(t, setup.book(t).BackOrders)
+}
+
+func TestOrderService_CompensatePlaceOrder_ReversesMatches(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	sagaID := uuid.New()
+
+	// Lay order of 100 at 3.0 holds 200
+	layReq := setup.placeRequest(t, "LAY", 100, "idem-key-lay")
+	layReq.Odds = decimal.NewFromInt(3)
+	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(setup.storeOrder)
+	setup.mockPool.ExpectCommit()
+
+	// The saga's back order fully matches it
+	backReq := setup.placeRequest(t, "BACK", 100, "idem-key-back")
+	backReq.Odds = decimal.NewFromInt(3)
+	backReq.SagaID = &sagaID
+	var matches []*models.Match
+	setup.mockPool.ExpectBegin()
+	setup.mockSagaRepo.EXPECT().Lock(gomock.Any(), gomock.Any(), sagaID).Return(nil)
+	setup.mockSagaRepo.EXPECT().IsCompensated(gomock.Any(), gomock.Any(), sagaID).Return(false, nil)
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(setup.storeOrder)
+	setup.mockOrderRepo.EXPECT().
+		CreateMatch(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ pgx.Tx, match *models.Match) error {
+			matches = append(matches, match)
+			return nil
+		})
+	setup.mockPool.ExpectCommit()
+	setup.expectPersistence()
+
+	layOrder, err := setup.service.PlaceOrder(ctx, layReq)
+	require.NoError(t, err)
+	backOrder, err := setup.service.PlaceOrder(ctx, backReq)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+
+	// The placement stored the lay order's fill
+	stored, ok := setup.stored.Load(layOrder.ID)
+	require.True(t, ok)
+	assert.Equal(t, models.OrderStatusMatched, stored.(*models.Order).Status)
+	assert.True(t, decimal.NewFromInt(100).Equal(stored.(*models.Order).SizeMatched))
+	assert.True(t, stored.(*models.Order).SizeRemaining.IsZero())
+	for _, level := range setup.book(t).LayOrders {
+		assert.Zero(t, level.OrderCount)
+	}
+
+	setup.mockPool.ExpectBegin()
+	setup.mockSagaRepo.EXPECT().Lock(gomock.Any(), gomock.Any(), sagaID).Return(nil)
+	setup.mockOrderRepo.EXPECT().GetBySagaID(gomock.Any(), sagaID.String()).DoAndReturn(func(context.Context, string) (*models.Order, error) {
+		stored, _ := setup.stored.Load(backOrder.ID)
+		order := *stored.(*models.Order)
+		return &order, nil
+	})
+	setup.mockOrderRepo.EXPECT().GetMatchesByOrderID(gomock.Any(), gomock.Any(), backOrder.ID).Return(matches, nil)
+	setup.mockOrderRepo.EXPECT().ReverseMatch(gomock.Any(), gomock.Any(), matches[0].ID).Return(nil)
+	setup.mockSagaRepo.EXPECT().RecordCompensation(gomock.Any(), gomock.Any(), sagaID, &backOrder.ID, "bet rejected").Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	// Execute
+	result, err := setup.service.CompensatePlaceOrder(ctx, &CompensatePlaceOrderRequest{
+		SagaID: sagaID,
+		Reason: "bet rejected",
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.ReversedMatches)
+	assert.False(t, result.AlreadyCompensated)
+
+	stored, _ = setup.stored.Load(backOrder.ID)
+	assert.Equal(t, models.OrderStatusCompensated, stored.(*models.Order).Status)
+
+	// Lay order is back on the book with its full size
+	stored, _ = setup.stored.Load(layOrder.ID)
+	assert.Equal(t, models.OrderStatusPending, stored.(*models.Order).Status)
+	assert.True(t, stored.(*models.Order).SizeMatched.IsZero())
+	assert.True(t, decimal.NewFromInt(100).Equal(stored.(*models.Order).SizeRemaining))
+	book := setup.book(t)
+	require.Len(t, book.LayOrders, 1)
+	assert.True(t, decimal.NewFromInt(100).Equal(book.LayOrders[0].TotalSize))
+
+	// The lay order's committed funds are held again
+	layReservation, err := setup.wallet.GetReservation(ctx, *layReq.ReservationID)
+	require.NoError(t, err)
+	assert.True(t, layReservation.Committed.IsZero())
+	assert.True(t, decimal.NewFromInt(200).Equal(layReservation.Amount))
+
+	// The compensated order's hold goes back to the user
+	backReservation, err := setup.wallet.GetReservation(ctx, *backReq.ReservationID)
+	require.NoError(t, err)
+	assert.True(t, backReservation.Committed.IsZero())
+	assert.True(t, backReservation.Amount.IsZero())
+	assert.True(t, decimal.NewFromInt(1000).Equal(setup.wallet.Balance(backReq.UserID)))
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_CompensatePlaceOrder_Repeated(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	sagaID := uuid.New()
+	order := &models.Order{
+		ID:     uuid.New(),
+		Status: models.OrderStatusCompensated,
+		SagaID: sagaID.String(),
+	}
+
+	setup.mockOrderRepo.EXPECT().GetBySagaID(gomock.Any(), sagaID.String()).Return(order, nil)
+
+	// Execute
+	result, err := setup.service.CompensatePlaceOrder(ctx, &CompensatePlaceOrderRequest{SagaID: sagaID})
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, result.AlreadyCompensated)
+	assert.Equal(t, order.ID, *result.OrderID)
+}
+
+func TestOrderService_CompensatePlaceOrder_BeforePlacement(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	sagaID := uuid.New()
+
+	setup.mockOrderRepo.EXPECT().GetBySagaID(gomock.Any(), sagaID.String()).Return(nil, models.ErrOrderNotFound)
+	setup.mockPool.ExpectBegin()
+	setup.mockSagaRepo.EXPECT().Lock(gomock.Any(), gomock.Any(), sagaID).Return(nil)
+	setup.mockOrderRepo.EXPECT().GetBySagaID(gomock.Any(), sagaID.String()).Return(nil, models.ErrOrderNotFound)
+	setup.mockSagaRepo.EXPECT().RecordCompensation(gomock.Any(), gomock.Any(), sagaID, nil, "timeout").Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	// Execute
+	result, err := setup.service.CompensatePlaceOrder(ctx, &CompensatePlaceOrderRequest{
+		SagaID: sagaID,
+		Reason: "timeout",
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Nil(t, result.OrderID)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_CompensatePlaceOrder_ReleasesRestingRemainder(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	sagaID := uuid.New()
+	userID := uuid.New()
+	reservationID := setup.reserve(t, userID, decimal.NewFromInt(100), decimal.NewFromInt(100))
+
+	// The saga's back order rests unmatched on the book
+	order := &models.Order{
+		ID:            uuid.New(),
+		UserID:        userID,
+		MarketID:      "event-123",
+		SelectionID:   "team-a",
+		Side:          models.OrderSideBack,
+		Price:         decimal.NewFromInt(3),
+		Size:          decimal.NewFromInt(100),
+		SizeMatched:   decimal.Zero,
+		SizeRemaining: decimal.NewFromInt(100),
+		ReservationID: reservationID.String(),
+		SagaID:        sagaID.String(),
+	}
+	resting := *order
+	_, err := setup.sequencer.PlaceOrder(ctx, &resting, nil)
+	require.NoError(t, err)
+
+	setup.mockOrderRepo.EXPECT().GetBySagaID(gomock.Any(), sagaID.String()).Return(order, nil)
+	setup.mockPool.ExpectBegin()
+	setup.mockSagaRepo.EXPECT().Lock(gomock.Any(), gomock.Any(), sagaID).Return(nil)
+	setup.mockOrderRepo.EXPECT().GetByIDForUpdate(gomock.Any(), gomock.Any(), order.ID).Return(order, nil)
+	setup.mockOrderRepo.EXPECT().GetMatchesByOrderID(gomock.Any(), gomock.Any(), order.ID).Return(nil, nil)
+	setup.mockOrderRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockSagaRepo.EXPECT().RecordCompensation(gomock.Any(), gomock.Any(), sagaID, &order.ID, "bet rejected").Return(nil)
+	setup.mockOutboxRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	setup.mockPool.ExpectCommit()
+
+	_, err = setup.service.CompensatePlaceOrder(ctx, &CompensatePlaceOrderRequest{
+		SagaID: sagaID,
+		Reason: "bet rejected",
+	})
+	require.NoError(t, err)
+
+	// The order is off the book and its hold is back in the balance
+	book := setup.book(t)
+	require.Len(t, book.BackOrders, 1)
+	assert.Zero(t, book.BackOrders[0].OrderCount)
+	reservation, err := setup.wallet.GetReservation(ctx, *reservationID)
+	require.NoError(t, err)
+	assert.True(t, reservation.Amount.IsZero())
+	assert.True(t, decimal.NewFromInt(100).Equal(setup.wallet.Balance(userID)))
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_CompensatePlaceOrder_RejectsSettledOrder(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	sagaID := uuid.New()
+	order := &models.Order{
+		ID:     uuid.New(),
+		Status: models.OrderStatusSettledWin,
+		SagaID: sagaID.String(),
+	}
+
+	setup.mockOrderRepo.EXPECT().GetBySagaID(gomock.Any(), sagaID.String()).Return(order, nil)
+
+	// Execute
+	result, err := setup.service.CompensatePlaceOrder(ctx, &CompensatePlaceOrderRequest{SagaID: sagaID})
+
+	// Assert
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, models.ErrInvalidOrderStatus)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_CompensatePlaceOrder_BookFailureWritesNothing(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	sagaID := uuid.New()
+	order := &models.Order{
+		ID:            uuid.New(),
+		UserID:        uuid.New(),
+		MarketID:      "event-123",
+		Side:          models.OrderSideBack,
+		Status:        models.OrderStatusPending,
+		Size:          decimal.NewFromInt(100),
+		SizeRemaining: decimal.NewFromInt(100),
+		SagaID:        sagaID.String(),
+	}
+
+	// The market was handed to another replica, its book cannot take the order off
+	_, err := setup.sequencer.Freeze(ctx, "event-123")
+	require.NoError(t, err)
+
+	// No transaction starts, locks are only taken in the market's persist step
+	setup.mockOrderRepo.EXPECT().GetBySagaID(gomock.Any(), sagaID.String()).Return(order, nil)
+
+	// Execute
+	_, err = setup.service.CompensatePlaceOrder(ctx, &CompensatePlaceOrderRequest{
+		SagaID: sagaID,
+		Reason: "bet rejected",
+	})
+
+	// Assert
+	assert.ErrorIs(t, err, models.ErrNotMarketOwner)
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
 func TestOrderService_CancelOrder_Success(t *testing.T) {
 	setup := setupTestService(t)
 	defer setup.cleanup()
@@ -293,13 +1042,24 @@ func TestOrderService_CancelOrder_Success(t *testing.T) {
 	userID := uuid.New()
 	sagaID := uuid.New()
 
+	reservationID := setup.reserve(t, userID, decimal.NewFromInt(100), decimal.NewFromInt(100))
+
 	existingOrder := &models.Order{
 		ID:             orderID,
 		UserID:         userID,
+		MarketID:       "event-123",
+		SelectionID:    "team-a",
+		Side:           models.OrderSideBack,
+		Price:          decimal.NewFromFloat(2.5),
 		Status:         models.OrderStatusPending,
+		Size:           decimal.NewFromFloat(100.00),
 		SizeRemaining:  decimal.NewFromFloat(100.00),
+		ReservationID:  reservationID.String(),
 		Version:        1,
 	}
+	resting := *existingOrder
+	_, err := setup.sequencer.PlaceOrder(ctx, &resting, nil)
+	require.NoError(t, err)
 
 	req := &CancelOrderRequest{
 		OrderID:        orderID,
@@ -307,10 +1067,16 @@ func TestOrderService_CancelOrder_Success(t *testing.T) {
 		IdempotencyKey: "cancel-idem-123",
 	}
 
+	// Mock owner lookup for the idempotency scope
+	setup.mockOrderRepo.EXPECT().
+		GetByID(gomock.Any(), orderID).
+		Return(existingOrder, nil)
+
 	// Mock idempotency check
+	key := scopedIdempotencyKey(opCancelOrder, userID, "cancel-idem-123")
 	setup.mockIdempotencyRepo.EXPECT().
-		Check(gomock.Any(), "cancel-idem-123", gomock.Any()).
-		Return(json.RawMessage(nil), false, nil)
+		Claim(gomock.Any(), key, gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
 
 	setup.mockPool.ExpectBegin()
 
@@ -330,18 +1096,34 @@ func TestOrderService_CancelOrder_Success(t *testing.T) {
 		Return(nil)
 
 	// Mock idempotency storage
+	var stored *idempotentResponse
 	setup.mockIdempotencyRepo.EXPECT().
-		StoreInTransaction(gomock.Any(), gomock.Any(), "cancel-idem-123", gomock.Any(), gomock.Any(), 24*time.Hour).
-		Return(nil)
+		StoreInTransaction(gomock.Any(), gomock.Any(), key, gomock.Any(), gomock.Any(), gomock.Any(), 24*time.Hour).
+		DoAndReturn(func(_ context.Context, _ pgx.Tx, _ string, _ uuid.UUID, _ string, response interface{}, _ time.Duration) error {
+			stored = response.(*idempotentResponse)
+			return nil
+		})
 
 	setup.mockPool.ExpectCommit()
 
 	// Execute
-	err := setup.service.CancelOrder(ctx, req)
+	result, err := setup.service.CancelOrder(ctx, req)
 
 	// Assert
-	assert.NoError(t, err)
+	require.NoError(t, err)
+	assert.Equal(t, orderID, result.OrderID)
+	assert.Equal(t, models.OrderStatusCancelled, result.Status)
 	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+
+	// The full result is stored for replay
+	require.NotNil(t, stored)
+	var storedResult CancelOrderResult
+	require.NoError(t, json.Unmarshal(stored.Result, &storedResult))
+	assert.Equal(t, orderID, storedResult.OrderID)
+	assert.Equal(t, models.OrderStatusCancelled, storedResult.Status)
+
+	// Unmatched hold is returned to the user's balance
+	assert.True(t, decimal.NewFromInt(100).Equal(setup.wallet.Balance(userID)))
 }
 
 func TestOrderService_CancelOrder_OrderNotFound(t *testing.T) {
@@ -356,24 +1138,16 @@ func TestOrderService_CancelOrder_OrderNotFound(t *testing.T) {
 		IdempotencyKey: "cancel-not-found",
 	}
 
-	setup.mockIdempotencyRepo.EXPECT().
-		Check(gomock.Any(), "cancel-not-found", gomock.Any()).
-		Return(json.RawMessage(nil), false, nil)
-
-	setup.mockPool.ExpectBegin()
-
 	// Mock order not found
 	setup.mockOrderRepo.EXPECT().
-		GetByIDForUpdate(gomock.Any(), gomock.Any(), orderID).
+		GetByID(gomock.Any(), orderID).
 		Return(nil, models.ErrOrderNotFound)
 
-	setup.mockPool.ExpectRollback()
-
 	// Execute
-	err := setup.service.CancelOrder(ctx, req)
+	result, err := setup.service.CancelOrder(ctx, req)
 
 	// Assert
-	assert.Error(t, err)
+	assert.Nil(t, result)
 	assert.Equal(t, models.ErrOrderNotFound, err)
 }
 
@@ -396,24 +1170,159 @@ func TestOrderService_CancelOrder_InvalidStatus(t *testing.T) {
 		IdempotencyKey: "cancel-invalid-status",
 	}
 
+	key := scopedIdempotencyKey(opCancelOrder, existingOrder.UserID, "cancel-invalid-status")
+	setup.mockOrderRepo.EXPECT().
+		GetByID(gomock.Any(), orderID).
+		Return(existingOrder, nil)
 	setup.mockIdempotencyRepo.EXPECT().
-		Check(gomock.Any(), "cancel-invalid-status", gomock.Any()).
-		Return(json.RawMessage(nil), false, nil)
+		Claim(gomock.Any(), key, gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(nil), true, nil)
 
+	// The order is not on the book, no transaction starts
+	// The failure is stored so a retry gets the same error
+	setup.expectStoredFailure(key, "invalid_order_status")
+
+	// Execute
+	result, err := setup.service.CancelOrder(ctx, req)
+
+	// Assert
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, models.ErrInvalidOrderStatus)
+	assert.Contains(t, err.Error(), "cannot be cancelled")
+}
+
+func TestOrderService_CancelOrder_FilledRestingOrder(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	setup.expectPersistence()
+
+	// A resting lay order of 100 at 2.5 is fully filled
+	layReq := setup.placeRequest(t, "LAY", 100, "idem-key-lay")
 	setup.mockPool.ExpectBegin()
+	setup.mockOrderRepo.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(setup.storeOrder)
+	setup.mockPool.ExpectCommit()
+	layOrder, err := setup.service.PlaceOrder(ctx, layReq)
+	require.NoError(t, err)
+	setup.placeAndCommit(t, "BACK", 100, "idem-key-back")
+
+	// The book no longer has the order, no transaction starts
+	setup.mockOrderRepo.EXPECT().GetByID(gomock.Any(), layOrder.ID).Return(layOrder, nil)
+	setup.expectStoredFailure(scopedIdempotencyKey(opCancelOrder, layReq.UserID, "cancel-filled"), "invalid_order_status")
+
+	// Execute
+	result, err := setup.service.CancelOrder(ctx, &CancelOrderRequest{
+		OrderID:        layOrder.ID,
+		IdempotencyKey: "cancel-filled",
+	})
+
+	// Assert
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, models.ErrInvalidOrderStatus)
+
+	// The committed funds stay committed, nothing is released
+	reservation, err := setup.wallet.GetReservation(ctx, *layReq.ReservationID)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(150).Equal(reservation.Committed))
+	assert.NoError(t, setup.mockPool.ExpectationsWereMet())
+}
+
+func TestOrderService_CancelOrder_ReplaysStoredResult(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	orderID := uuid.New()
+	userID := uuid.New()
+	cancelledAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	stored, _ := json.Marshal(&idempotentResponse{Result: json.RawMessage(
+		`{"order_id":"` + orderID.String() + `","status":"CANCELLED","cancelled_at":"2024-05-01T12:00:00Z"}`,
+	)})
 
 	setup.mockOrderRepo.EXPECT().
-		GetByIDForUpdate(gomock.Any(), gomock.Any(), orderID).
-		Return(existingOrder, nil)
+		GetByID(gomock.Any(), orderID).
+		Return(&models.Order{ID: orderID, UserID: userID, Status: models.OrderStatusCancelled}, nil)
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), scopedIdempotencyKey(opCancelOrder, userID, "cancel-replay"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(stored), false, nil)
 
-	setup.mockPool.ExpectRollback()
+	// Execute, nothing else is touched on replay
+	result, err := setup.service.CancelOrder(ctx, &CancelOrderRequest{
+		OrderID:        orderID,
+		IdempotencyKey: "cancel-replay",
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, &CancelOrderResult{
+		OrderID:     orderID,
+		Status:      models.OrderStatusCancelled,
+		CancelledAt: cancelledAt,
+	}, result)
+}
+
+func TestOrderService_SettleOrder_ReplaysStoredFailure(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	orderID := uuid.New()
+	userID := uuid.New()
+
+	stored, _ := json.Marshal(&idempotentResponse{
+		ErrorCode: "invalid_order_status",
+		Error:     "order cannot be settled: status=cancelled: invalid order status",
+	})
+
+	setup.mockOrderRepo.EXPECT().
+		GetByID(gomock.Any(), orderID).
+		Return(&models.Order{ID: orderID, UserID: userID}, nil)
+	setup.mockIdempotencyRepo.EXPECT().
+		Claim(gomock.Any(), scopedIdempotencyKey(opSettleOrder, userID, "settle-replay"), gomock.Any(), gomock.Any(), idempotencyLease).
+		Return(json.RawMessage(stored), false, nil)
 
 	// Execute
-	err := setup.service.CancelOrder(ctx, req)
+	result, err := setup.service.SettleOrder(ctx, &SettleOrderRequest{
+		OrderID:        orderID,
+		Result:         "win",
+		ActualPayout:   decimal.NewFromInt(250),
+		IdempotencyKey: "settle-replay",
+	})
+
+	// Assert, the replayed error maps like the original
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, models.ErrInvalidOrderStatus)
+	assert.Equal(t, "order cannot be settled: status=cancelled: invalid order status", err.Error())
+}
 
-	// Assert
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "cannot be cancelled")
+func TestOrderService_IdempotencyKeysScopedByUser(t *testing.T) {
+	setup := setupTestService(t)
+	defer setup.cleanup()
+
+	ctx := context.Background()
+	first, second := uuid.New(), uuid.New()
+
+	// Both users send the same client key, each gets its own scope
+	for _, userID := range []uuid.UUID{first, second} {
+		setup.mockIdempotencyRepo.EXPECT().
+			Claim(gomock.Any(), "place_order:"+userID.String()+":shared-key", gomock.Any(), gomock.Any(), idempotencyLease).
+			Return(json.RawMessage(nil), false, models.ErrIdempotencyMismatch)
+	}
+
+	for _, userID := range []uuid.UUID{first, second} {
+		_, err := setup.service.PlaceOrder(ctx, &PlaceOrderRequest{
+			UserID:         userID,
+			EventID:        "event-123",
+			BetType:        "BACK",
+			Selection:      "team-a",
+			Amount:         decimal.NewFromInt(10),
+			Odds:           decimal.NewFromFloat(2.5),
+			IdempotencyKey: "shared-key",
+		})
+		assert.Equal(t, models.ErrIdempotencyMismatch, err)
+	}
 }
 
 func TestOrderService_GetOrderByID_Success(t *testing.T) {
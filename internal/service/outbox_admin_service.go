@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/repository"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// OutboxAdminServiceImpl implements the OutboxAdminService interface
+type OutboxAdminServiceImpl struct {
+	outboxRepo repository.OutboxRepository
+	metrics    *observability.Metrics
+	logger     zerolog.Logger
+}
+
+// NewOutboxAdminService creates a new outbox admin service instance
+func NewOutboxAdminService(
+	outboxRepo repository.OutboxRepository,
+	metrics *observability.Metrics,
+	logger zerolog.Logger,
+) OutboxAdminService {
+	return &OutboxAdminServiceImpl{
+		outboxRepo: outboxRepo,
+		metrics:    metrics,
+		logger:     logger.With().Str("component", "outbox_admin_service").Logger(),
+	}
+}
+
+// ListDeadLetteredEvents retrieves events that exhausted their retries
+func (s *OutboxAdminServiceImpl) ListDeadLetteredEvents(ctx context.Context, limit, offset int) ([]*models.OutboxEvent, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	events, err := s.outboxRepo.ListDeadLettered(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead-lettered events: %w", err)
+	}
+	return events, nil
+}
+
+// GetOutboxEvent retrieves a single outbox event by ID
+func (s *OutboxAdminServiceImpl) GetOutboxEvent(ctx context.Context, eventID uuid.UUID) (*models.OutboxEvent, error) {
+	event, err := s.outboxRepo.GetByID(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox event: %w", err)
+	}
+	return event, nil
+}
+
+// ReplayDeadLetteredEvent puts a dead-lettered event back in the publish queue
+func (s *OutboxAdminServiceImpl) ReplayDeadLetteredEvent(ctx context.Context, eventID uuid.UUID) error {
+	if err := s.outboxRepo.ReplayDeadLettered(ctx, eventID); err != nil {
+		return fmt.Errorf("failed to replay dead-lettered event: %w", err)
+	}
+
+	s.metrics.OutboxEventsReplayed.Inc()
+	s.metrics.OutboxDeadLetterBacklog.Dec()
+
+	s.logger.Info().
+		Str("event_id", eventID.String()).
+		Msg("dead-lettered event queued for replay")
+
+	return nil
+}
@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/events"
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// matchReversal tracks a reversed match and the counterparty order it was undone for
+type matchReversal struct {
+	match        *models.Match
+	counterparty *models.Order
+	reinstated   bool // counterparty went back on the book
+}
+
+// compensation is what persistCompensation undid for an order
+type compensation struct {
+	order     *models.Order
+	reversals []*matchReversal
+	already   bool // order was compensated before
+}
+
+// CompensatePlaceOrder undoes the order placed by a saga, reversing its matches
+func (s *OrderServiceImpl) CompensatePlaceOrder(ctx context.Context, req *CompensatePlaceOrderRequest) (*CompensatePlaceOrderResult, error) {
+	// Validate request
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	placed, err := s.orderRepo.GetBySagaID(ctx, req.SagaID.String())
+	if err == models.ErrOrderNotFound {
+		placed, err = s.compensateBeforePlacement(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if placed == nil {
+			return &CompensatePlaceOrderResult{}, nil
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get order by saga: %w", err)
+	}
+
+	if placed.Status == models.OrderStatusCompensated {
+		s.logAlreadyCompensated(req.SagaID, placed.ID)
+		return &CompensatePlaceOrderResult{
+			OrderID:            &placed.ID,
+			AlreadyCompensated: true,
+		}, nil
+	}
+	if err := checkCompensable(placed); err != nil {
+		s.logger.Warn().
+			Str("saga_id", req.SagaID.String()).
+			Str("order_id", placed.ID.String()).
+			Str("status", string(placed.Status)).
+			Msg("rejecting compensation of finished order")
+		return nil, err
+	}
+
+	done, err := s.compensateOrder(ctx, placed, &req.SagaID, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	if done.already {
+		s.logAlreadyCompensated(req.SagaID, placed.ID)
+		return &CompensatePlaceOrderResult{
+			OrderID:            &placed.ID,
+			AlreadyCompensated: true,
+		}, nil
+	}
+
+	s.metrics.SagaCompensationsTotal.WithLabelValues("compensated").Inc()
+	s.logger.Info().
+		Str("saga_id", req.SagaID.String()).
+		Str("order_id", placed.ID.String()).
+		Int("reversed_matches", len(done.reversals)).
+		Msg("order placement compensated")
+
+	return &CompensatePlaceOrderResult{
+		OrderID:         &placed.ID,
+		ReversedMatches: len(done.reversals),
+	}, nil
+}
+
+// compensateBeforePlacement marks the saga compensated unless its order was placed meanwhile
+func (s *OrderServiceImpl) compensateBeforePlacement(ctx context.Context, req *CompensatePlaceOrderRequest) (*models.Order, error) {
+	// Start transaction
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize with the placement's persist step
+	if err := s.sagaRepo.Lock(ctx, tx, req.SagaID); err != nil {
+		return nil, fmt.Errorf("failed to lock saga: %w", err)
+	}
+
+	placed, err := s.orderRepo.GetBySagaID(ctx, req.SagaID.String())
+	if err == nil {
+		return placed, nil
+	}
+	if err != models.ErrOrderNotFound {
+		return nil, fmt.Errorf("failed to get order by saga: %w", err)
+	}
+
+	if err := s.sagaRepo.RecordCompensation(ctx, tx, req.SagaID, nil, req.Reason); err != nil {
+		return nil, fmt.Errorf("failed to record saga compensation: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.metrics.SagaCompensationsTotal.WithLabelValues("no_order").Inc()
+	s.logger.Info().
+		Str("saga_id", req.SagaID.String()).
+		Msg("saga compensated before order placement")
+
+	return nil, nil
+}
+
+// logAlreadyCompensated records a repeated compensation
+func (s *OrderServiceImpl) logAlreadyCompensated(sagaID, orderID uuid.UUID) {
+	s.metrics.SagaCompensationsTotal.WithLabelValues("already_compensated").Inc()
+	s.logger.Info().
+		Str("saga_id", sagaID.String()).
+		Str("order_id", orderID.String()).
+		Msg("saga already compensated")
+}
+
+// checkCompensable rejects orders that were settled or cancelled
+func checkCompensable(order *models.Order) error {
+	switch order.Status {
+	case models.OrderStatusSettledWin, models.OrderStatusSettledLoss, models.OrderStatusCancelled:
+		return fmt.Errorf("order cannot be compensated: status=%s: %w", order.Status, models.ErrInvalidOrderStatus)
+	}
+	return nil
+}
+
+// compensateOrder takes an order off the book, reversing its matches in the removal's persist step
+func (s *OrderServiceImpl) compensateOrder(ctx context.Context, placed *models.Order, sagaID *uuid.UUID, reason string) (*compensation, error) {
+	var done *compensation
+	batch, err := s.sequencer.CancelOrder(ctx, placed.MarketID, placed.ID, func(ctx context.Context, batch *matchingengine.Batch) error {
+		var err error
+		done, err = s.persistCompensation(ctx, placed.ID, sagaID, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if done.already {
+		return done, nil
+	}
+	if batch.First(matchingengine.EventOrderCancelled).Order != nil {
+		s.metrics.ActiveOrders.Dec()
+	}
+
+	// Put the counterparties back on the book
+	s.reinstateCounterparties(context.WithoutCancel(ctx), done.reversals)
+
+	// Return committed funds to the holds they came from
+	for _, reversal := range done.reversals {
+		s.revertMatchedFunds(ctx, done.order, reversal)
+	}
+
+	// Release the compensated order's hold
+	s.releaseHold(ctx, done.order)
+
+	return done, nil
+}
+
+// persistCompensation stores a compensated order, its reversed matches and their outbox events in one transaction
+func (s *OrderServiceImpl) persistCompensation(ctx context.Context, orderID uuid.UUID, sagaID *uuid.UUID, reason string) (*compensation, error) {
+	// Start transaction
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize with placement and concurrent compensations of the same saga
+	if sagaID != nil {
+		if err := s.sagaRepo.Lock(ctx, tx, *sagaID); err != nil {
+			return nil, fmt.Errorf("failed to lock saga: %w", err)
+		}
+	}
+
+	// Get order with pessimistic lock
+	order, err := s.orderRepo.GetByIDForUpdate(ctx, tx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if order.Status == models.OrderStatusCompensated {
+		return &compensation{order: order, already: true}, nil
+	}
+	if err := checkCompensable(order); err != nil {
+		return nil, err
+	}
+
+	matches, err := s.orderRepo.GetMatchesByOrderID(ctx, tx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order matches: %w", err)
+	}
+
+	now := time.Now()
+	reversals := make([]*matchReversal, 0, len(matches))
+	for _, match := range matches {
+		if match.IsReversed() {
+			continue
+		}
+
+		reversal, err := s.reverseMatch(ctx, tx, order, match, sagaID, reason, now)
+		if err != nil {
+			return nil, err
+		}
+		reversals = append(reversals, reversal)
+	}
+
+	// Nothing of the compensated order stays matched or on the book
+	order.Status = models.OrderStatusCompensated
+	order.SizeMatched = decimal.Zero
+	order.SizeRemaining = decimal.Zero
+	order.CancelledAt = &now
+
+	if err := s.orderRepo.Update(ctx, tx, order); err != nil {
+		return nil, fmt.Errorf("failed to update order: %w", err)
+	}
+
+	if sagaID != nil {
+		if err := s.sagaRepo.RecordCompensation(ctx, tx, *sagaID, &order.ID, reason); err != nil {
+			return nil, fmt.Errorf("failed to record saga compensation: %w", err)
+		}
+	}
+
+	// Create outbox event
+	compensated := &events.OrderCompensatedV1{
+		OrderID:         order.ID.String(),
+		UserID:          order.UserID.String(),
+		Reason:          reason,
+		ReversedMatches: int32(len(reversals)),
+		CompensatedAt:   now,
+	}
+	if sagaID != nil {
+		compensated.SagaID = sagaID.String()
+	}
+	outboxEvent, err := events.NewOutboxEvent(models.AggregateTypeOrder, order.ID, compensated, sagaID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build outbox event: %w", err)
+	}
+
+	if err := s.outboxRepo.Create(ctx, tx, outboxEvent); err != nil {
+		return nil, fmt.Errorf("failed to insert outbox event: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &compensation{order: order, reversals: reversals}, nil
+}
+
+// reinstateCounterparties puts the open counterparties of reversed matches back on the book
+func (s *OrderServiceImpl) reinstateCounterparties(ctx context.Context, reversals []*matchReversal) {
+	for _, reversal := range reversals {
+		if !reversal.reinstated {
+			continue
+		}
+		if _, err := s.sequencer.ReinstateOrder(ctx, reversal.counterparty, nil); err != nil {
+			s.logger.Error().Err(err).
+				Str("order_id", reversal.counterparty.ID.String()).
+				Msg("failed to reinstate counterparty order")
+		}
+	}
+}
+
+// reverseMatch undoes a single match within the compensation transaction
+func (s *OrderServiceImpl) reverseMatch(ctx context.Context, tx pgx.Tx, order *models.Order, match *models.Match, sagaID *uuid.UUID, reason string, now time.Time) (*matchReversal, error) {
+	counterparty, err := s.orderRepo.GetByIDForUpdate(ctx, tx, match.CounterpartyOrderID(order.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get counterparty order: %w", err)
+	}
+
+	counterparty.SizeMatched = counterparty.SizeMatched.Sub(match.Size)
+	counterparty.SizeRemaining = counterparty.SizeRemaining.Add(match.Size)
+
+	reinstated := false
+	switch counterparty.Status {
+	case models.OrderStatusPending, models.OrderStatusPartially, models.OrderStatusMatched:
+		reinstated = true
+		counterparty.MatchedAt = nil
+		if counterparty.SizeMatched.IsZero() {
+			counterparty.Status = models.OrderStatusPending
+		} else {
+			counterparty.Status = models.OrderStatusPartially
+		}
+	}
+
+	if err := s.orderRepo.Update(ctx, tx, counterparty); err != nil {
+		return nil, fmt.Errorf("failed to update counterparty order: %w", err)
+	}
+
+	if err := s.orderRepo.ReverseMatch(ctx, tx, match.ID); err != nil {
+		return nil, fmt.Errorf("failed to reverse match: %w", err)
+	}
+
+	// Create outbox event
+	outboxEvent, err := events.NewOutboxEvent(models.AggregateTypeMatch, match.ID, &events.MatchReversedV1{
+		MatchID:      match.ID.String(),
+		BackOrderID:  match.BackOrderID.String(),
+		LayOrderID:   match.LayOrderID.String(),
+		MatchedSize:  match.Size.String(),
+		MatchedPrice: match.Price.String(),
+		Reason:       reason,
+		ReversedAt:   now,
+	}, sagaID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build match reversal outbox event: %w", err)
+	}
+
+	if err := s.outboxRepo.Create(ctx, tx, outboxEvent); err != nil {
+		return nil, fmt.Errorf("failed to insert match reversal outbox event: %w", err)
+	}
+
+	return &matchReversal{
+		match:        match,
+		counterparty: counterparty,
+		reinstated:   reinstated,
+	}, nil
+}
+
+// revertMatchedFunds moves both sides' committed funds for a reversed match back into their holds
+func (s *OrderServiceImpl) revertMatchedFunds(ctx context.Context, order *models.Order, reversal *matchReversal) {
+	match := reversal.match
+	for _, side := range []struct {
+		order     *models.Order
+		reference string
+	}{
+		{order, match.ID.String() + ":" + sideReference(order, match)},
+		{reversal.counterparty, match.ID.String() + ":" + sideReference(reversal.counterparty, match)},
+	} {
+		if side.order.ReservationID == "" {
+			continue
+		}
+
+		reservationID, err := uuid.Parse(side.order.ReservationID)
+		if err != nil {
+			s.logger.Error().Err(err).
+				Str("order_id", side.order.ID.String()).
+				Str("reservation_id", side.order.ReservationID).
+				Msg("invalid reservation id on order")
+			continue
+		}
+
+		if err := s.walletClient.RevertCommit(ctx, reservationID, side.reference); err != nil {
+			s.metrics.WalletOperationErrors.WithLabelValues("revert").Inc()
+			s.logger.Error().Err(err).
+				Str("reservation_id", side.order.ReservationID).
+				Str("reference_id", side.reference).
+				Msg("failed to revert matched funds")
+		}
+	}
+
+	if reversal.reinstated || reversal.counterparty.ReservationID == "" {
+		return
+	}
+
+	reservationID, err := uuid.Parse(reversal.counterparty.ReservationID)
+	if err != nil {
+		return
+	}
+
+	amount := models.Liability(reversal.counterparty.Side, match.Price, match.Size)
+	if err := s.walletClient.ReleaseReservation(ctx, reservationID, amount); err != nil {
+		s.metrics.WalletOperationErrors.WithLabelValues("release").Inc()
+		s.logger.Error().Err(err).
+			Str("order_id", reversal.counterparty.ID.String()).
+			Str("reservation_id", reversal.counterparty.ReservationID).
+			Str("amount", amount.String()).
+			Msg("failed to release reversed match funds")
+	}
+}
+
+// sideReference returns the commit reference suffix used for an order's side of a match
+func sideReference(order *models.Order, match *models.Match) string {
+	if match.BackOrderID == order.ID {
+		return "back"
+	}
+	return "lay"
+}
@@ -0,0 +1,154 @@
+package sharding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+// Transport is the sharding endpoints of the other replicas
+type Transport interface {
+	// Forward runs a service call on a replica and decodes its result into result
+	// A failure with a known code unwraps to its domain error
+	Forward(ctx context.Context, ordinal int, call string, req, result any) error
+
+	// Freeze freezes a market on its holder and returns its state
+	// Returns models.ErrNotMarketOwner if the holder still owns the market under
+	// the given layout version
+	Freeze(ctx context.Context, ordinal int, marketID string, version int64) (*matchingengine.MarketSnapshot, error)
+
+	// Release makes a replica drop a market this replica adopted
+	Release(ctx context.Context, ordinal int, marketID string) error
+}
+
+// requestTimeout bounds a request to another replica
+const requestTimeout = 10 * time.Second
+
+// forwardedError is a failure returned by the replica a call was forwarded to
+// It unwraps to the error of its code so callers map it like a local failure
+type forwardedError struct {
+	code    string
+	message string
+}
+
+func (e *forwardedError) Error() string { return e.message }
+
+func (e *forwardedError) Unwrap() error { return errorFromCode(e.code) }
+
+// Client implements Transport over HTTP
+type Client struct {
+	client      *http.Client
+	peerAddress string
+}
+
+// NewClient creates a sharding client
+// peerAddress is the base URL of a replica with %d in place of its ordinal, e.g.
+// http://order-book-%d.order-book:8080
+func NewClient(peerAddress string) *Client {
+	return &Client{
+		client:      &http.Client{Timeout: requestTimeout},
+		peerAddress: peerAddress,
+	}
+}
+
+// Forward runs a service call on a replica
+func (c *Client) Forward(ctx context.Context, ordinal int, call string, req, result any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("encode forwarded %s: %w", call, err)
+	}
+
+	path := strings.Replace(CallPath, "{call}", url.PathEscape(call), 1)
+	resp, err := c.do(ctx, ordinal, path, nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var outcome callResponse
+	if err := json.NewDecoder(resp.Body).Decode(&outcome); err != nil {
+		return fmt.Errorf("decode forwarded %s: %w", call, err)
+	}
+	if outcome.Error != "" {
+		return &forwardedError{code: outcome.ErrorCode, message: outcome.Error}
+	}
+	if err := json.Unmarshal(outcome.Result, result); err != nil {
+		return fmt.Errorf("decode forwarded %s result: %w", call, err)
+	}
+	return nil
+}
+
+// Freeze freezes a market on its holder and returns its state
+func (c *Client) Freeze(ctx context.Context, ordinal int, marketID string, version int64) (*matchingengine.MarketSnapshot, error) {
+	path := strings.Replace(FreezePath, "{market}", url.PathEscape(marketID), 1)
+	query := url.Values{"version": {strconv.FormatInt(version, 10)}}
+	resp, err := c.do(ctx, ordinal, path, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read frozen market: %w", err)
+	}
+	snapshot, err := matchingengine.UnmarshalSnapshot(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshot.Markets) != 1 || snapshot.Markets[0].MarketID != marketID {
+		return nil, fmt.Errorf("replica %d returned the wrong state for market %s", ordinal, marketID)
+	}
+	return snapshot.Markets[0], nil
+}
+
+// Release makes a replica drop a market this replica adopted
+func (c *Client) Release(ctx context.Context, ordinal int, marketID string) error {
+	path := strings.Replace(ReleasePath, "{market}", url.PathEscape(marketID), 1)
+	resp, err := c.do(ctx, ordinal, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do posts to a replica and maps error statuses
+func (c *Client) do(ctx context.Context, ordinal int, path string, query url.Values, body []byte) (*http.Response, error) {
+	target := strings.TrimSuffix(fmt.Sprintf(c.peerAddress, ordinal), "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build sharding request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("replica %d: %w", ordinal, err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode == http.StatusConflict {
+		return nil, fmt.Errorf("%w: %s", models.ErrNotMarketOwner, strings.TrimSpace(string(message)))
+	}
+	return nil, fmt.Errorf("replica %d: %s: status %d: %s", ordinal, path, resp.StatusCode, strings.TrimSpace(string(message)))
+}
@@ -0,0 +1,193 @@
+package sharding
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/service"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+// Endpoint paths
+const (
+	FreezePath  = "/sharding/markets/{market}/freeze"
+	ReleasePath = "/sharding/markets/{market}/release"
+	CallPath    = "/sharding/calls/{call}"
+)
+
+// callResponse is the outcome of a forwarded call
+// A failure carries the code of its domain error, so the caller gets the same error back
+type callResponse struct {
+	Result    json.RawMessage `json:"result,omitempty"`
+	ErrorCode string          `json:"error_code,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// retryableCodes are the errors a forwarded call may fail with that a retry can fix
+// The domain errors of models.ErrorCode are passed on as well
+var retryableCodes = map[string]error{
+	"not_market_owner":          models.ErrNotMarketOwner,
+	"not_leader":                models.ErrNotLeader,
+	"fenced":                    models.ErrFenced,
+	"idempotency_in_progress":   models.ErrIdempotencyInProgress,
+	"idempotency_claim_lost":    models.ErrIdempotencyClaimLost,
+	"idempotency_mismatch":      models.ErrIdempotencyMismatch,
+	"optimistic_lock":           models.ErrOptimisticLock,
+	"invalid_market_transition": models.ErrInvalidMarketTransition,
+	"market_suspended":          models.ErrMarketSuspended,
+	"insufficient_reservation":  models.ErrInsufficientReservation,
+}
+
+// errorCode returns the code of the error err wraps, "" if it has none
+func errorCode(err error) string {
+	if code := models.ErrorCode(err); code != "" {
+		return code
+	}
+	for code, codeErr := range retryableCodes {
+		if errors.Is(err, codeErr) {
+			return code
+		}
+	}
+	return ""
+}
+
+// errorFromCode returns the error with the given code, nil if the code is unknown
+func errorFromCode(code string) error {
+	if err := models.ErrorFromCode(code); err != nil {
+		return err
+	}
+	return retryableCodes[code]
+}
+
+// Handler serves the sharding endpoints of a replica
+type Handler struct {
+	shards  *Shards
+	orders  service.OrderService
+	markets service.MarketService
+	logger  zerolog.Logger
+}
+
+// NewHandler creates the sharding endpoints of a replica
+// Forwarded calls go through orders and markets, which must be the routers, so a
+// replica that no longer owns the market refuses them
+func NewHandler(shards *Shards, orders service.OrderService, markets service.MarketService, logger zerolog.Logger) *Handler {
+	return &Handler{
+		shards:  shards,
+		orders:  orders,
+		markets: markets,
+		logger:  logger.With().Str("component", "sharding_handler").Logger(),
+	}
+}
+
+// Register adds the sharding endpoints to mux
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+FreezePath, h.freeze)
+	mux.HandleFunc("POST "+ReleasePath, h.release)
+	mux.HandleFunc("POST "+CallPath, h.call)
+}
+
+func (h *Handler) freeze(w http.ResponseWriter, r *http.Request) {
+	marketID := r.PathValue("market")
+	version, err := strconv.ParseInt(r.URL.Query().Get("version"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid version", http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := h.shards.Freeze(r.Context(), marketID, version)
+	if errors.Is(err, models.ErrNotMarketOwner) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Str("market_id", marketID).Msg("failed to freeze market for handoff")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := (&matchingengine.Snapshot{Markets: []*matchingengine.MarketSnapshot{snapshot}}).MarshalBinary()
+	if err != nil {
+		h.logger.Error().Err(err).Str("market_id", marketID).Msg("failed to encode market for handoff")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info().
+		Str("market_id", marketID).
+		Int64("sequence", snapshot.Sequence).
+		Msg("market frozen for handoff")
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (h *Handler) release(w http.ResponseWriter, r *http.Request) {
+	marketID := r.PathValue("market")
+	if err := h.shards.Release(r.Context(), marketID); err != nil {
+		h.logger.Error().Err(err).Str("market_id", marketID).Msg("failed to release market")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info().Str("market_id", marketID).Msg("market released after handoff")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) call(w http.ResponseWriter, r *http.Request) {
+	ctx := withForwarded(r.Context())
+
+	switch call := r.PathValue("call"); call {
+	case CallPlaceOrder:
+		serve(h, w, r, func(req *service.PlaceOrderRequest) (any, error) {
+			return h.orders.PlaceOrder(ctx, req)
+		})
+	case CallCancelOrder:
+		serve(h, w, r, func(req *service.CancelOrderRequest) (any, error) {
+			return h.orders.CancelOrder(ctx, req)
+		})
+	case CallCompensatePlaceOrder:
+		serve(h, w, r, func(req *service.CompensatePlaceOrderRequest) (any, error) {
+			return h.orders.CompensatePlaceOrder(ctx, req)
+		})
+	case CallExpireMarketOrders:
+		serve(h, w, r, func(req *expireRequest) (any, error) {
+			return h.orders.ExpireMarketOrders(ctx, req.MarketID)
+		})
+	case CallApplyMarketStatus:
+		serve(h, w, r, func(req *service.MarketStatusRequest) (any, error) {
+			return h.markets.ApplyMarketStatus(ctx, req)
+		})
+	default:
+		http.Error(w, "unknown call "+call, http.StatusNotFound)
+	}
+}
+
+// serve decodes a forwarded request, runs it and writes its outcome
+func serve[Req any](h *Handler, w http.ResponseWriter, r *http.Request, fn func(*Req) (any, error)) {
+	req := new(Req)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var resp callResponse
+	result, err := fn(req)
+	if err != nil {
+		resp.ErrorCode = errorCode(err)
+		resp.Error = err.Error()
+	} else if resp.Result, err = json.Marshal(result); err != nil {
+		h.logger.Error().Err(err).Str("call", r.PathValue("call")).Msg("failed to encode forwarded call result")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&resp); err != nil && r.Context().Err() == nil {
+		h.logger.Warn().Err(err).Msg("failed to write forwarded call result")
+	}
+}
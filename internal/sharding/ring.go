@@ -0,0 +1,88 @@
+// Package sharding spreads the matching engine's markets over StatefulSet pods
+//
+// Markets are assigned to pod ordinals by consistent hashing, so changing the
+// number of replicas only moves the markets of the replicas added or removed.
+// Any replica takes calls for any market and forwards those of markets it does
+// not own to the owner. When the layout changes, the new owner of a market pulls
+// its book from the replica holding it: the holder freezes the market and hands
+// over its state, the new owner adopts it and records itself as the holder, and
+// the old holder drops it. Replicas talk to each other over HTTP:
+//
+//	POST /sharding/markets/{market}/freeze?version=N   freeze a market and return its book
+//	POST /sharding/markets/{market}/release            drop a market another replica adopted
+//	POST /sharding/calls/{call}                        run a forwarded service call
+package sharding
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// virtualNodes is how many points each replica has on the ring
+// More points spread markets more evenly over the replicas
+const virtualNodes = 128
+
+// Ring assigns markets to the pod ordinals 0 to replicas-1
+type Ring struct {
+	replicas int
+	points   []ringPoint // sorted by hash
+}
+
+type ringPoint struct {
+	hash    uint64
+	ordinal int
+}
+
+// NewRing creates the ring of a layout with the given number of replicas
+func NewRing(replicas int) *Ring {
+	r := &Ring{replicas: replicas, points: make([]ringPoint, 0, replicas*virtualNodes)}
+	for ordinal := 0; ordinal < replicas; ordinal++ {
+		for node := 0; node < virtualNodes; node++ {
+			r.points = append(r.points, ringPoint{
+				hash:    hashKey(strconv.Itoa(ordinal) + "#" + strconv.Itoa(node)),
+				ordinal: ordinal,
+			})
+		}
+	}
+	sort.Slice(r.points, func(a, b int) bool {
+		return r.points[a].hash < r.points[b].hash
+	})
+	return r
+}
+
+// Replicas returns the number of replicas markets are spread over
+func (r *Ring) Replicas() int {
+	return r.replicas
+}
+
+// Owner returns the ordinal of the replica that owns a market
+func (r *Ring) Owner(marketID string) int {
+	if len(r.points) == 0 {
+		return 0
+	}
+
+	h := hashKey(marketID)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].ordinal
+}
+
+// hashKey hashes a key onto the ring
+// FNV clusters similar short keys, the finalizer of splitmix64 spreads them out
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,48 @@
+package sharding
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing_SpreadsMarketsEvenly(t *testing.T) {
+	ring := NewRing(4)
+
+	counts := make(map[int]int)
+	for i := 0; i < 10000; i++ {
+		counts[ring.Owner(fmt.Sprintf("event-%d", i))]++
+	}
+
+	assert.Len(t, counts, 4)
+	for ordinal, count := range counts {
+		assert.InDelta(t, 2500, count, 500, "replica %d owns %d markets", ordinal, count)
+	}
+}
+
+func TestRing_AddingReplicaOnlyMovesMarketsToIt(t *testing.T) {
+	before := NewRing(3)
+	after := NewRing(4)
+
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		marketID := fmt.Sprintf("event-%d", i)
+		from, to := before.Owner(marketID), after.Owner(marketID)
+		if from != to {
+			assert.Equal(t, 3, to, "market %s moved between existing replicas", marketID)
+			moved++
+		}
+	}
+
+	// About a quarter of the markets move to the new replica
+	assert.InDelta(t, 2500, moved, 500)
+}
+
+func TestRing_SingleReplicaOwnsEverything(t *testing.T) {
+	ring := NewRing(1)
+
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, 0, ring.Owner(fmt.Sprintf("event-%d", i)))
+	}
+}
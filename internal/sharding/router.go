@@ -0,0 +1,200 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/repository"
+	"github.com/cypherlabdev/order-book-service/internal/service"
+)
+
+// Service calls that can be forwarded to the replica owning a market
+const (
+	CallPlaceOrder           = "place_order"
+	CallCancelOrder          = "cancel_order"
+	CallCompensatePlaceOrder = "compensate_place_order"
+	CallExpireMarketOrders   = "expire_market_orders"
+	CallApplyMarketStatus    = "apply_market_status"
+)
+
+// expireRequest carries the market of a forwarded ExpireMarketOrders call
+type expireRequest struct {
+	MarketID string `json:"market_id"`
+}
+
+type forwardedKey struct{}
+
+// withForwarded marks a context as serving a call another replica forwarded
+func withForwarded(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forwardedKey{}, true)
+}
+
+// forwarded returns true if the context serves a forwarded call
+// A forwarded call is never forwarded again, replicas that disagree on the
+// layout would otherwise pass it around
+func forwarded(ctx context.Context) bool {
+	v, _ := ctx.Value(forwardedKey{}).(bool)
+	return v
+}
+
+// router runs a market's engine calls on the replica that owns it
+type router struct {
+	shards    *Shards
+	transport Transport
+	metrics   *observability.Metrics
+}
+
+// route calls local if this replica owns the market, or forwards req to the owner
+func route[T any](ctx context.Context, r *router, marketID, call string, req any, local func(context.Context) (T, error)) (T, error) {
+	var result T
+
+	owner, self, err := r.shards.Owner(ctx, marketID)
+	if err != nil {
+		return result, err
+	}
+	if self {
+		return local(ctx)
+	}
+	if forwarded(ctx) {
+		return result, fmt.Errorf("%w: market %s belongs to replica %d", models.ErrNotMarketOwner, marketID, owner)
+	}
+
+	r.metrics.EngineForwardedCalls.WithLabelValues(call).Inc()
+	if err := r.transport.Forward(ctx, owner, call, req, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// OrderRouter is an OrderService that runs engine calls on the replica owning the market
+// Reads and settlement do not touch the engine and run locally
+type OrderRouter struct {
+	service.OrderService
+	router
+	orderRepo repository.OrderRepository
+	validator *validator.Validate
+}
+
+// NewOrderRouter wraps this replica's order service
+func NewOrderRouter(
+	local service.OrderService,
+	orderRepo repository.OrderRepository,
+	shards *Shards,
+	transport Transport,
+	metrics *observability.Metrics,
+) *OrderRouter {
+	return &OrderRouter{
+		OrderService: local,
+		router:       router{shards: shards, transport: transport, metrics: metrics},
+		orderRepo:    orderRepo,
+		validator:    validator.New(),
+	}
+}
+
+// PlaceOrder places an order on the replica owning its market
+func (o *OrderRouter) PlaceOrder(ctx context.Context, req *service.PlaceOrderRequest) (*models.Order, error) {
+	if err := o.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	return route(ctx, &o.router, req.EventID, CallPlaceOrder, req, func(ctx context.Context) (*models.Order, error) {
+		return o.OrderService.PlaceOrder(ctx, req)
+	})
+}
+
+// CancelOrder cancels an order on the replica owning its market
+func (o *OrderRouter) CancelOrder(ctx context.Context, req *service.CancelOrderRequest) (*service.CancelOrderResult, error) {
+	if err := o.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	order, err := o.orderRepo.GetByID(ctx, req.OrderID)
+	if errors.Is(err, models.ErrOrderNotFound) {
+		// Nothing to route, the local service reports it
+		return o.OrderService.CancelOrder(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return route(ctx, &o.router, order.MarketID, CallCancelOrder, req, func(ctx context.Context) (*service.CancelOrderResult, error) {
+		return o.OrderService.CancelOrder(ctx, req)
+	})
+}
+
+// CompensatePlaceOrder undoes a saga's order on the replica owning its market
+func (o *OrderRouter) CompensatePlaceOrder(ctx context.Context, req *service.CompensatePlaceOrderRequest) (*service.CompensatePlaceOrderResult, error) {
+	if err := o.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	order, err := o.orderRepo.GetBySagaID(ctx, req.SagaID.String())
+	if errors.Is(err, models.ErrOrderNotFound) {
+		// No placement to undo yet, the local service records the compensation
+		return o.OrderService.CompensatePlaceOrder(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return route(ctx, &o.router, order.MarketID, CallCompensatePlaceOrder, req, func(ctx context.Context) (*service.CompensatePlaceOrderResult, error) {
+		return o.OrderService.CompensatePlaceOrder(ctx, req)
+	})
+}
+
+// ExpireMarketOrders expires a market's resting orders on the replica owning it
+func (o *OrderRouter) ExpireMarketOrders(ctx context.Context, marketID string) (int, error) {
+	return route(ctx, &o.router, marketID, CallExpireMarketOrders, &expireRequest{MarketID: marketID}, func(ctx context.Context) (int, error) {
+		return o.OrderService.ExpireMarketOrders(ctx, marketID)
+	})
+}
+
+// MarketRouter is a MarketService that applies status changes on the replica owning the market
+type MarketRouter struct {
+	service.MarketService
+	router
+	validator *validator.Validate
+}
+
+// NewMarketRouter wraps this replica's market service
+func NewMarketRouter(
+	local service.MarketService,
+	shards *Shards,
+	transport Transport,
+	metrics *observability.Metrics,
+) *MarketRouter {
+	return &MarketRouter{
+		MarketService: local,
+		router:        router{shards: shards, transport: transport, metrics: metrics},
+		validator:     validator.New(),
+	}
+}
+
+// RestoreMarkets loads the status of the markets this replica owns
+// Each one is taken over first, so its status moves with its book when the
+// layout changes
+func (m *MarketRouter) RestoreMarkets(ctx context.Context, owned service.MarketFilter) error {
+	return m.MarketService.RestoreMarkets(ctx, func(ctx context.Context, marketID string) (bool, error) {
+		if owned != nil {
+			if own, err := owned(ctx, marketID); err != nil || !own {
+				return false, err
+			}
+		}
+		_, self, err := m.shards.Owner(ctx, marketID)
+		return self, err
+	})
+}
+
+// ApplyMarketStatus applies a status change on the replica owning the market
+func (m *MarketRouter) ApplyMarketStatus(ctx context.Context, req *service.MarketStatusRequest) (*service.MarketStatusResult, error) {
+	if err := m.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	return route(ctx, &m.router, req.MarketID, CallApplyMarketStatus, req, func(ctx context.Context) (*service.MarketStatusResult, error) {
+		return m.MarketService.ApplyMarketStatus(ctx, req)
+	})
+}
@@ -0,0 +1,387 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/repository"
+	"github.com/cypherlabdev/order-book-service/internal/service"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+// handoffTimeout bounds a handoff, a market frozen for longer is taken back
+// A new owner that has not recorded itself as the holder by then gives up
+const handoffTimeout = 30 * time.Second
+
+// Config configures the shards of a replica
+type Config struct {
+	Layout        string        // Layout name, shared by all replicas
+	Ordinal       int           // This replica's pod ordinal
+	Replicas      int           // Replicas of the layout if it does not exist yet
+	CheckInterval time.Duration // How often the layout is checked and markets rebalanced
+}
+
+// Shards tracks which markets this replica owns and holds the books of
+// A market is owned by the replica the ring assigns it to, and held by the
+// replica recorded as holding its book. A replica only serves the markets it owns,
+// taking over their books from the holder first
+type Shards struct {
+	config      Config
+	sequencer   *matchingengine.Sequencer
+	repo        repository.ShardRepository
+	transport   Transport
+	snapshotter *service.EngineSnapshotter // nil without a journal
+	metrics     *observability.Metrics
+	logger      zerolog.Logger
+
+	mu      sync.RWMutex
+	ring    *Ring
+	version int64
+	held    map[string]bool      // markets whose book this replica holds
+	handing map[string]time.Time // markets frozen for another replica, and since when
+
+	acquireMu sync.Mutex // one handoff at a time
+}
+
+// NewShards creates the shards of a replica
+// snapshotter may be nil when the engine has no journal, adopted books are then
+// lost on restart like every other book
+func NewShards(
+	sequencer *matchingengine.Sequencer,
+	repo repository.ShardRepository,
+	transport Transport,
+	snapshotter *service.EngineSnapshotter,
+	config Config,
+	metrics *observability.Metrics,
+	logger zerolog.Logger,
+) *Shards {
+	return &Shards{
+		config:      config,
+		sequencer:   sequencer,
+		repo:        repo,
+		transport:   transport,
+		snapshotter: snapshotter,
+		metrics:     metrics,
+		logger:      logger.With().Str("component", "engine_shards").Int("ordinal", config.Ordinal).Logger(),
+		held:        make(map[string]bool),
+		handing:     make(map[string]time.Time),
+	}
+}
+
+// Ordinal returns this replica's pod ordinal
+func (s *Shards) Ordinal() int {
+	return s.config.Ordinal
+}
+
+// Load reads the layout and sorts out the books recovered by this replica
+// Markets recorded as held elsewhere are dropped. A recovered market nobody holds
+// yet is claimed if this replica owns it or it has resting orders, so the books of
+// a replica that ran before sharding are kept and handed on. Must run after
+// recovery and before the engine takes commands
+func (s *Shards) Load(ctx context.Context) error {
+	layout, err := s.repo.EnsureLayout(ctx, s.config.Layout, s.config.Replicas)
+	if err != nil {
+		return err
+	}
+	s.setLayout(layout)
+
+	owners, err := s.repo.ListMarketOwners(ctx)
+	if err != nil {
+		return err
+	}
+	recovered, err := s.sequencer.Snapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("snapshot recovered markets: %w", err)
+	}
+
+	for _, market := range recovered.Markets {
+		holder, ok := owners[market.MarketID]
+		if !ok {
+			if s.ring.Owner(market.MarketID) != s.config.Ordinal && !hasOrders(market) {
+				continue
+			}
+			if holder, err = s.repo.ClaimMarket(ctx, market.MarketID, s.config.Ordinal); err != nil {
+				return err
+			}
+		}
+
+		if holder == s.config.Ordinal {
+			s.held[market.MarketID] = true
+			continue
+		}
+		if err := s.sequencer.Drop(ctx, market.MarketID); err != nil {
+			return err
+		}
+	}
+	for marketID, holder := range owners {
+		if holder == s.config.Ordinal {
+			s.held[marketID] = true
+		}
+	}
+	s.metrics.EngineShardMarkets.Set(float64(len(s.held)))
+
+	s.logger.Info().
+		Int("replicas", layout.Replicas).
+		Int64("version", layout.Version).
+		Int("markets", len(s.held)).
+		Msg("engine shards loaded")
+	return nil
+}
+
+// Start checks the layout every interval and takes over the books of the markets
+// this replica owns, until the context is cancelled
+func (s *Shards) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.rebalance(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Warn().Err(err).Msg("failed to rebalance engine shards")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Owner returns the ordinal owning a market and whether it is this replica
+// The book of a market this replica owns is taken over from its holder first
+func (s *Shards) Owner(ctx context.Context, marketID string) (int, bool, error) {
+	s.mu.RLock()
+	owner := s.ring.Owner(marketID)
+	held := s.held[marketID]
+	s.mu.RUnlock()
+
+	if owner != s.config.Ordinal {
+		return owner, false, nil
+	}
+	if held {
+		return owner, true, nil
+	}
+	return owner, true, s.acquire(ctx, marketID)
+}
+
+// Freeze hands a market's book to the replica that now owns it
+// A requester with a newer layout makes this replica read it first. Returns
+// models.ErrNotMarketOwner if the market is still this replica's
+func (s *Shards) Freeze(ctx context.Context, marketID string, version int64) (*matchingengine.MarketSnapshot, error) {
+	if err := s.refresh(ctx, version); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.ring.Owner(marketID) == s.config.Ordinal {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: market %s belongs to replica %d under layout version %d",
+			models.ErrNotMarketOwner, marketID, s.config.Ordinal, s.version)
+	}
+	delete(s.held, marketID)
+	s.handing[marketID] = time.Now()
+	s.metrics.EngineShardMarkets.Set(float64(len(s.held)))
+	s.mu.Unlock()
+
+	return s.sequencer.Freeze(ctx, marketID)
+}
+
+// Release drops a market another replica has adopted
+func (s *Shards) Release(ctx context.Context, marketID string) error {
+	s.mu.Lock()
+	delete(s.handing, marketID)
+	s.mu.Unlock()
+
+	return s.sequencer.Drop(ctx, marketID)
+}
+
+// acquire takes over a market this replica owns but does not hold
+func (s *Shards) acquire(ctx context.Context, marketID string) error {
+	s.acquireMu.Lock()
+	defer s.acquireMu.Unlock()
+
+	s.mu.RLock()
+	held := s.held[marketID]
+	handing, frozen := s.handing[marketID]
+	s.mu.RUnlock()
+	if held {
+		return nil
+	}
+
+	holder, err := s.repo.ClaimMarket(ctx, marketID, s.config.Ordinal)
+	if err != nil {
+		return err
+	}
+
+	if holder != s.config.Ordinal {
+		if err := s.pull(ctx, marketID, holder); err != nil {
+			s.metrics.EngineShardHandoffs.WithLabelValues("failed").Inc()
+			return err
+		}
+		s.metrics.EngineShardHandoffs.WithLabelValues("adopted").Inc()
+	} else if frozen {
+		// A handoff that has not completed may still record its replica as holder
+		if time.Since(handing) < handoffTimeout {
+			return fmt.Errorf("%w: market %s is being handed off", models.ErrNotMarketOwner, marketID)
+		}
+		if err := s.sequencer.Thaw(ctx, marketID); err != nil {
+			return err
+		}
+		s.logger.Warn().Str("market_id", marketID).Msg("market handoff did not complete, market taken back")
+	}
+
+	s.mu.Lock()
+	delete(s.handing, marketID)
+	s.held[marketID] = true
+	s.metrics.EngineShardMarkets.Set(float64(len(s.held)))
+	s.mu.Unlock()
+	return nil
+}
+
+// pull takes over a market's book from its holder
+// The adopted book is saved in a snapshot before this replica records itself as
+// holder, so a restart recovers it
+func (s *Shards) pull(ctx context.Context, marketID string, holder int) error {
+	ctx, cancel := context.WithTimeout(ctx, handoffTimeout/2)
+	defer cancel()
+
+	s.mu.RLock()
+	version := s.version
+	s.mu.RUnlock()
+
+	snapshot, err := s.transport.Freeze(ctx, holder, marketID, version)
+	if err != nil {
+		return fmt.Errorf("freeze market %s on replica %d: %w", marketID, holder, err)
+	}
+
+	// A failed handoff leaves nothing behind, the holder takes the market back
+	adopted := false
+	defer func() {
+		if !adopted {
+			if err := s.sequencer.Drop(context.WithoutCancel(ctx), marketID); err != nil {
+				s.logger.Error().Err(err).Str("market_id", marketID).Msg("failed to drop market after failed handoff")
+			}
+		}
+	}()
+
+	if err := s.sequencer.Adopt(ctx, snapshot); err != nil {
+		return err
+	}
+	if s.snapshotter != nil {
+		if _, err := s.snapshotter.Checkpoint(ctx); err != nil {
+			return fmt.Errorf("save adopted market %s: %w", marketID, err)
+		}
+	}
+	if err := s.repo.TransferMarket(ctx, marketID, holder, s.config.Ordinal); err != nil {
+		return err
+	}
+	adopted = true
+
+	if err := s.transport.Release(ctx, holder, marketID); err != nil {
+		// The holder drops the market once it sees the new holder
+		s.logger.Warn().Err(err).Str("market_id", marketID).Int("holder", holder).Msg("failed to release market on old holder")
+	}
+
+	s.logger.Info().
+		Str("market_id", marketID).
+		Int("from", holder).
+		Int64("sequence", snapshot.Sequence).
+		Msg("market book taken over")
+	return nil
+}
+
+// rebalance reads the layout and takes over the books of owned markets held elsewhere
+func (s *Shards) rebalance(ctx context.Context) error {
+	if err := s.refresh(ctx, 0); err != nil {
+		return err
+	}
+	owners, err := s.repo.ListMarketOwners(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	moving := 0
+	for marketID, holder := range owners {
+		s.mu.RLock()
+		owner := s.ring.Owner(marketID)
+		_, frozen := s.handing[marketID]
+		s.mu.RUnlock()
+
+		switch {
+		case owner == s.config.Ordinal && holder != s.config.Ordinal:
+			if err := s.acquire(ctx, marketID); err != nil {
+				errs = append(errs, err)
+			}
+		case frozen && holder != s.config.Ordinal:
+			// Adopted by a replica that failed to release it
+			if err := s.Release(ctx, marketID); err != nil {
+				errs = append(errs, err)
+			}
+		case owner != s.config.Ordinal && holder == s.config.Ordinal:
+			moving++
+		}
+	}
+
+	if moving > 0 {
+		s.logger.Info().Int("markets", moving).Msg("markets waiting for their new owner to take them over")
+	}
+	return errors.Join(errs...)
+}
+
+// refresh reads the layout unless this replica already has version
+func (s *Shards) refresh(ctx context.Context, version int64) error {
+	s.mu.RLock()
+	current := s.version
+	s.mu.RUnlock()
+	if version != 0 && version <= current {
+		return nil
+	}
+
+	layout, err := s.repo.GetLayout(ctx, s.config.Layout)
+	if err != nil {
+		return err
+	}
+	if layout == nil {
+		return fmt.Errorf("shard layout %s does not exist", s.config.Layout)
+	}
+	if layout.Version != current {
+		s.setLayout(layout)
+	}
+	return nil
+}
+
+// setLayout rebuilds the ring of a layout
+func (s *Shards) setLayout(layout *models.ShardLayout) {
+	ring := NewRing(layout.Replicas)
+
+	s.mu.Lock()
+	s.ring = ring
+	s.version = layout.Version
+	s.mu.Unlock()
+
+	s.logger.Info().
+		Int("replicas", layout.Replicas).
+		Int64("version", layout.Version).
+		Msg("engine shard layout applied")
+}
+
+// hasOrders returns true if any book of the market has resting orders
+func hasOrders(market *matchingengine.MarketSnapshot) bool {
+	for _, engine := range market.Engines {
+		for _, levels := range [][]*matchingengine.LevelSnapshot{engine.Back, engine.Lay} {
+			for _, level := range levels {
+				if len(level.Orders) > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,393 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/internal/observability"
+	"github.com/cypherlabdev/order-book-service/internal/service"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+// memoryShardRepository keeps the layout and market holders in memory
+type memoryShardRepository struct {
+	mu      sync.Mutex
+	layout  *models.ShardLayout
+	holders map[string]int
+}
+
+func newMemoryShardRepository() *memoryShardRepository {
+	return &memoryShardRepository{holders: make(map[string]int)}
+}
+
+func (r *memoryShardRepository) EnsureLayout(ctx context.Context, name string, replicas int) (*models.ShardLayout, error) {
+	r.mu.Lock()
+	if r.layout == nil {
+		r.layout = &models.ShardLayout{Name: name, Replicas: replicas, Version: 1, UpdatedAt: time.Now()}
+	}
+	r.mu.Unlock()
+	return r.GetLayout(ctx, name)
+}
+
+func (r *memoryShardRepository) GetLayout(ctx context.Context, name string) (*models.ShardLayout, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.layout == nil {
+		return nil, nil
+	}
+	layout := *r.layout
+	return &layout, nil
+}
+
+// scale changes the number of replicas like an operator's update
+func (r *memoryShardRepository) scale(replicas int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.layout.Replicas = replicas
+	r.layout.Version++
+}
+
+func (r *memoryShardRepository) ClaimMarket(ctx context.Context, marketID string, ordinal int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if holder, ok := r.holders[marketID]; ok {
+		return holder, nil
+	}
+	r.holders[marketID] = ordinal
+	return ordinal, nil
+}
+
+func (r *memoryShardRepository) TransferMarket(ctx context.Context, marketID string, from, to int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.holders[marketID] != from {
+		return models.ErrNotMarketOwner
+	}
+	r.holders[marketID] = to
+	return nil
+}
+
+func (r *memoryShardRepository) ListMarketOwners(ctx context.Context) (map[string]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	owners := make(map[string]int, len(r.holders))
+	for marketID, holder := range r.holders {
+		owners[marketID] = holder
+	}
+	return owners, nil
+}
+
+func (r *memoryShardRepository) holder(marketID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.holders[marketID]
+}
+
+// stubOrderService is the local order service of a test replica
+// Only PlaceOrder is implemented, it answers with order or fails with err
+type stubOrderService struct {
+	service.OrderService
+	mu     sync.Mutex
+	placed []*service.PlaceOrderRequest
+	err    error
+}
+
+func (s *stubOrderService) PlaceOrder(ctx context.Context, req *service.PlaceOrderRequest) (*models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.placed = append(s.placed, req)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.Order{
+		ID:          uuid.NewSHA1(uuid.NameSpaceOID, []byte(req.IdempotencyKey)),
+		UserID:      req.UserID,
+		MarketID:    req.EventID,
+		SelectionID: req.Selection,
+		Side:        models.OrderSide(req.BetType),
+		Price:       req.Odds,
+		Size:        req.Amount,
+		Status:      models.OrderStatusPending,
+	}, nil
+}
+
+// podTransport sends pod-N requests to the Nth test server
+type podTransport struct {
+	servers []*httptest.Server
+}
+
+func (p *podTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ordinal, err := strconv.Atoi(strings.TrimPrefix(req.URL.Hostname(), "pod-"))
+	if err != nil || ordinal >= len(p.servers) {
+		return nil, fmt.Errorf("no pod %s", req.URL.Host)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Host = strings.TrimPrefix(p.servers[ordinal].URL, "http://")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// testPod is one replica of a sharded engine
+type testPod struct {
+	sequencer *matchingengine.Sequencer
+	shards    *Shards
+	local     *stubOrderService
+	orders    *OrderRouter
+	metrics   *observability.Metrics
+}
+
+type testCluster struct {
+	repo      *memoryShardRepository
+	transport *podTransport
+	pods      []*testPod
+}
+
+func newTestCluster(t *testing.T, replicas int) *testCluster {
+	c := &testCluster{repo: newMemoryShardRepository(), transport: &podTransport{}}
+	_, err := c.repo.EnsureLayout(context.Background(), "engine", replicas)
+	require.NoError(t, err)
+	for i := 0; i < replicas; i++ {
+		c.addPod(t)
+	}
+	return c
+}
+
+// addPod starts the next replica
+func (c *testCluster) addPod(t *testing.T) *testPod {
+	ordinal := len(c.pods)
+	client := NewClient("http://pod-%d")
+	client.client = &http.Client{Transport: c.transport, Timeout: requestTimeout}
+
+	sequencer := matchingengine.NewSequencer()
+	t.Cleanup(sequencer.Close)
+	metrics := observability.NewMetricsWithRegistry(prometheus.NewRegistry())
+	shards := NewShards(sequencer, c.repo, client, nil, Config{
+		Layout:        "engine",
+		Ordinal:       ordinal,
+		Replicas:      ordinal + 1,
+		CheckInterval: time.Hour,
+	}, metrics, zerolog.Nop())
+
+	pod := &testPod{
+		sequencer: sequencer,
+		shards:    shards,
+		local:     &stubOrderService{},
+		metrics:   metrics,
+	}
+	pod.orders = NewOrderRouter(pod.local, nil, shards, client, metrics)
+
+	mux := http.NewServeMux()
+	NewHandler(shards, pod.orders, nil, zerolog.Nop()).Register(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	c.transport.servers = append(c.transport.servers, server)
+	c.pods = append(c.pods, pod)
+	require.NoError(t, shards.Load(context.Background()))
+	return pod
+}
+
+// marketOwnedBy returns a market the ring of replicas assigns to ordinal
+func marketOwnedBy(t *testing.T, replicas, ordinal int) string {
+	ring := NewRing(replicas)
+	for i := 0; i < 1000; i++ {
+		marketID := fmt.Sprintf("event-%d", i)
+		if ring.Owner(marketID) == ordinal {
+			return marketID
+		}
+	}
+	t.Fatalf("no market owned by replica %d of %d", ordinal, replicas)
+	return ""
+}
+
+func restingOrder(marketID string, side models.OrderSide, price string, size int64) *models.Order {
+	amount := decimal.NewFromInt(size)
+	return &models.Order{
+		ID:            uuid.New(),
+		UserID:        uuid.New(),
+		MarketID:      marketID,
+		SelectionID:   "team-a",
+		Side:          side,
+		Price:         decimal.RequireFromString(price),
+		Size:          amount,
+		SizeMatched:   decimal.Zero,
+		SizeRemaining: amount,
+		Status:        models.OrderStatusPending,
+		PlacedAt:      time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC),
+		Version:       1,
+	}
+}
+
+func placeRequest(marketID, key string) *service.PlaceOrderRequest {
+	return &service.PlaceOrderRequest{
+		UserID:         uuid.New(),
+		EventID:        marketID,
+		BetType:        "BACK",
+		Selection:      "team-a",
+		Amount:         decimal.NewFromInt(100),
+		Odds:           decimal.RequireFromString("2.5"),
+		IdempotencyKey: key,
+	}
+}
+
+// encodedMarket returns a market's state in the snapshot encoding, which compares
+// decimals by value
+func encodedMarket(t *testing.T, market *matchingengine.MarketSnapshot) []byte {
+	t.Helper()
+	data, err := (&matchingengine.Snapshot{Markets: []*matchingengine.MarketSnapshot{market}}).MarshalBinary()
+	require.NoError(t, err)
+	return data
+}
+
+func marketState(t *testing.T, sequencer *matchingengine.Sequencer, marketID string) *matchingengine.MarketSnapshot {
+	t.Helper()
+	snapshot, err := sequencer.Snapshot(context.Background())
+	require.NoError(t, err)
+	for _, market := range snapshot.Markets {
+		if market.MarketID == marketID {
+			return market
+		}
+	}
+	return nil
+}
+
+func TestShards_ScaleUpHandsBookToNewOwner(t *testing.T) {
+	ctx := context.Background()
+	cluster := newTestCluster(t, 1)
+	old := cluster.pods[0]
+
+	// The single replica holds the book of a market the second replica will own
+	marketID := marketOwnedBy(t, 2, 1)
+	_, self, err := old.shards.Owner(ctx, marketID)
+	require.NoError(t, err)
+	require.True(t, self)
+	for _, order := range []*models.Order{
+		restingOrder(marketID, models.OrderSideBack, "2.5", 100),
+		restingOrder(marketID, models.OrderSideBack, "2.4", 50),
+		restingOrder(marketID, models.OrderSideLay, "2.6", 75),
+	} {
+		_, err := old.sequencer.PlaceOrder(ctx, order, nil)
+		require.NoError(t, err)
+	}
+	before := marketState(t, old.sequencer, marketID)
+	require.NotNil(t, before)
+
+	// The operator scales up: the layout first, then the new pod
+	cluster.repo.scale(2)
+	added := cluster.addPod(t)
+
+	owner, self, err := added.shards.Owner(ctx, marketID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, owner)
+	assert.True(t, self)
+
+	// The new owner has the same book and is recorded as its holder
+	adopted := marketState(t, added.sequencer, marketID)
+	require.NotNil(t, adopted)
+	assert.Equal(t, encodedMarket(t, before), encodedMarket(t, adopted))
+	assert.Equal(t, 1, cluster.repo.holder(marketID))
+
+	// The old holder dropped the market and refuses its commands
+	dropped := marketState(t, old.sequencer, marketID)
+	require.NotNil(t, dropped)
+	assert.Empty(t, dropped.Engines)
+	_, err = old.sequencer.PlaceOrder(ctx, restingOrder(marketID, models.OrderSideBack, "2.5", 10), nil)
+	assert.ErrorIs(t, err, models.ErrNotMarketOwner)
+
+	// The old holder now forwards the market's calls
+	owner, self, err = old.shards.Owner(ctx, marketID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, owner)
+	assert.False(t, self)
+
+	// The new owner keeps matching on the adopted book
+	batch, err := added.sequencer.PlaceOrder(ctx, restingOrder(marketID, models.OrderSideLay, "2.5", 100), nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, batch.Matches)
+}
+
+func TestShards_HolderRefusesFreezeOfOwnedMarket(t *testing.T) {
+	ctx := context.Background()
+	cluster := newTestCluster(t, 2)
+
+	marketID := marketOwnedBy(t, 2, 0)
+	_, _, err := cluster.pods[0].shards.Owner(ctx, marketID)
+	require.NoError(t, err)
+
+	_, err = cluster.pods[1].shards.transport.Freeze(ctx, 0, marketID, 1)
+	assert.ErrorIs(t, err, models.ErrNotMarketOwner)
+
+	// The market still takes commands on its owner
+	_, err = cluster.pods[0].sequencer.PlaceOrder(ctx, restingOrder(marketID, models.OrderSideBack, "2.5", 10), nil)
+	assert.NoError(t, err)
+}
+
+func TestOrderRouter_ForwardsToOwner(t *testing.T) {
+	ctx := context.Background()
+	cluster := newTestCluster(t, 2)
+	entry, owner := cluster.pods[0], cluster.pods[1]
+
+	marketID := marketOwnedBy(t, 2, 1)
+	req := placeRequest(marketID, "idem-key-forwarded")
+
+	order, err := entry.orders.PlaceOrder(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, marketID, order.MarketID)
+	assert.True(t, req.Amount.Equal(order.Size))
+	assert.Empty(t, entry.local.placed)
+	require.Len(t, owner.local.placed, 1)
+	assert.Equal(t, req.IdempotencyKey, owner.local.placed[0].IdempotencyKey)
+
+	// Markets the entry replica owns stay local
+	_, err = entry.orders.PlaceOrder(ctx, placeRequest(marketOwnedBy(t, 2, 0), "idem-key-local"))
+	require.NoError(t, err)
+	assert.Len(t, entry.local.placed, 1)
+}
+
+func TestOrderRouter_ForwardedErrorsKeepTheirDomainError(t *testing.T) {
+	ctx := context.Background()
+	cluster := newTestCluster(t, 2)
+	entry, owner := cluster.pods[0], cluster.pods[1]
+	marketID := marketOwnedBy(t, 2, 1)
+
+	owner.local.err = fmt.Errorf("place order: %w", models.ErrMarketSuspended)
+	_, err := entry.orders.PlaceOrder(ctx, placeRequest(marketID, "idem-key-suspended"))
+	assert.ErrorIs(t, err, models.ErrMarketSuspended)
+
+	owner.local.err = models.ErrIdempotencyInProgress
+	_, err = entry.orders.PlaceOrder(ctx, placeRequest(marketID, "idem-key-in-progress"))
+	assert.ErrorIs(t, err, models.ErrIdempotencyInProgress)
+
+	// Validation fails before routing, with the validator's own error
+	_, err = entry.orders.PlaceOrder(ctx, &service.PlaceOrderRequest{EventID: marketID})
+	assert.ErrorContains(t, err, "validation failed")
+	assert.Len(t, owner.local.placed, 2)
+}
+
+func TestOrderRouter_ForwardedCallIsNotForwardedAgain(t *testing.T) {
+	ctx := context.Background()
+	cluster := newTestCluster(t, 2)
+
+	// The second replica gets a call for a market of the first one, as if the
+	// replicas disagreed on the layout
+	marketID := marketOwnedBy(t, 2, 0)
+	var order *models.Order
+	err := cluster.pods[0].shards.transport.Forward(ctx, 1, CallPlaceOrder, placeRequest(marketID, "idem-key-bounced"), &order)
+	assert.ErrorIs(t, err, models.ErrNotMarketOwner)
+	assert.Empty(t, cluster.pods[0].local.placed)
+	assert.Empty(t, cluster.pods[1].local.placed)
+}
@@ -0,0 +1,62 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Wallet errors
+var (
+	ErrReservationNotFound = errors.New("wallet reservation not found")
+	ErrInsufficientFunds   = errors.New("insufficient funds to cover reservation")
+	ErrReservationClosed   = errors.New("wallet reservation is no longer active")
+)
+
+// ReservationStatus represents the lifecycle state of a wallet reservation
+type ReservationStatus string
+
+const (
+	ReservationStatusActive    ReservationStatus = "ACTIVE"    // Funds are held
+	ReservationStatusReleased  ReservationStatus = "RELEASED"  // Hold fully returned to balance
+	ReservationStatusCommitted ReservationStatus = "COMMITTED" // Hold fully converted to committed funds
+)
+
+// Reservation is a hold on a user's balance placed by the wallet service
+type Reservation struct {
+	ID        uuid.UUID         `json:"id"`
+	UserID    uuid.UUID         `json:"user_id"`
+	Amount    decimal.Decimal   `json:"amount"`    // Currently held amount
+	Committed decimal.Decimal   `json:"committed"` // Amount converted to committed funds
+	Status    ReservationStatus `json:"status"`
+}
+
+// IsActive returns true if the reservation can still be adjusted or committed
+func (r *Reservation) IsActive() bool {
+	return r.Status == ReservationStatusActive
+}
+
+// WalletClient defines the operations the order book needs from the wallet service
+type WalletClient interface {
+	// GetReservation retrieves a reservation by ID
+	// Returns ErrReservationNotFound if reservation doesn't exist
+	GetReservation(ctx context.Context, reservationID uuid.UUID) (*Reservation, error)
+
+	// AdjustReservation resizes the held amount to exactly amount
+	// Returns ErrInsufficientFunds if the balance cannot cover an increase
+	AdjustReservation(ctx context.Context, reservationID uuid.UUID, amount decimal.Decimal) error
+
+	// ReleaseReservation returns amount from the hold back to the user's balance
+	// Releasing more than is held releases the full hold
+	ReleaseReservation(ctx context.Context, reservationID uuid.UUID, amount decimal.Decimal) error
+
+	// CommitReservation converts amount from the hold into committed funds
+	// referenceID makes the call idempotent (e.g. match ID and side)
+	CommitReservation(ctx context.Context, reservationID uuid.UUID, amount decimal.Decimal, referenceID string) error
+
+	// RevertCommit moves funds committed under referenceID back into the hold
+	// Reverting an unknown or already reverted reference is a no-op
+	RevertCommit(ctx context.Context, reservationID uuid.UUID, referenceID string) error
+}
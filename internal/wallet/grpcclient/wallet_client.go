@@ -0,0 +1,145 @@
+package grpcclient
+
+import (
+	"context"
+	"fmt"
+
+	walletv1 "github.com/cypherlabdev/cypherlabdev-protos/gen/go/wallet/v1"
+	"github.com/cypherlabdev/order-book-service/internal/wallet"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// WalletClient implements wallet.WalletClient against the wallet-service gRPC API
+type WalletClient struct {
+	conn   *grpc.ClientConn
+	client walletv1.WalletServiceClient
+	logger zerolog.Logger
+}
+
+// NewWalletClient dials the wallet service at address
+func NewWalletClient(address string, logger zerolog.Logger) (*WalletClient, error) {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("dial wallet service: %w", err)
+	}
+
+	return &WalletClient{
+		conn:   conn,
+		client: walletv1.NewWalletServiceClient(conn),
+		logger: logger.With().Str("component", "grpc_wallet_client").Logger(),
+	}, nil
+}
+
+// Close closes the underlying connection
+func (c *WalletClient) Close() error {
+	return c.conn.Close()
+}
+
+// GetReservation retrieves a reservation by ID
+func (c *WalletClient) GetReservation(ctx context.Context, reservationID uuid.UUID) (*wallet.Reservation, error) {
+	resp, err := c.client.GetReservation(ctx, &walletv1.GetReservationRequest{
+		ReservationId: reservationID.String(),
+	})
+	if err != nil {
+		return nil, c.mapError(err, "get reservation", reservationID)
+	}
+
+	userID, err := uuid.Parse(resp.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("parse reservation user_id: %w", err)
+	}
+
+	amount, err := decimal.NewFromString(resp.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("parse reservation amount: %w", err)
+	}
+
+	committed := decimal.Zero
+	if resp.CommittedAmount != "" {
+		committed, err = decimal.NewFromString(resp.CommittedAmount)
+		if err != nil {
+			return nil, fmt.Errorf("parse reservation committed_amount: %w", err)
+		}
+	}
+
+	return &wallet.Reservation{
+		ID:        reservationID,
+		UserID:    userID,
+		Amount:    amount,
+		Committed: committed,
+		Status:    wallet.ReservationStatus(resp.Status),
+	}, nil
+}
+
+// AdjustReservation resizes the held amount to exactly amount
+func (c *WalletClient) AdjustReservation(ctx context.Context, reservationID uuid.UUID, amount decimal.Decimal) error {
+	_, err := c.client.AdjustReservation(ctx, &walletv1.AdjustReservationRequest{
+		ReservationId: reservationID.String(),
+		Amount:        amount.String(),
+	})
+	if err != nil {
+		return c.mapError(err, "adjust reservation", reservationID)
+	}
+	return nil
+}
+
+// ReleaseReservation returns amount from the hold back to the user's balance
+func (c *WalletClient) ReleaseReservation(ctx context.Context, reservationID uuid.UUID, amount decimal.Decimal) error {
+	_, err := c.client.ReleaseReservation(ctx, &walletv1.ReleaseReservationRequest{
+		ReservationId: reservationID.String(),
+		Amount:        amount.String(),
+	})
+	if err != nil {
+		return c.mapError(err, "release reservation", reservationID)
+	}
+	return nil
+}
+
+// CommitReservation converts amount from the hold into committed funds
+func (c *WalletClient) CommitReservation(ctx context.Context, reservationID uuid.UUID, amount decimal.Decimal, referenceID string) error {
+	_, err := c.client.CommitReservation(ctx, &walletv1.CommitReservationRequest{
+		ReservationId:  reservationID.String(),
+		Amount:         amount.String(),
+		IdempotencyKey: referenceID,
+	})
+	if err != nil {
+		return c.mapError(err, "commit reservation", reservationID)
+	}
+	return nil
+}
+
+// RevertCommit moves funds committed under referenceID back into the hold
+func (c *WalletClient) RevertCommit(ctx context.Context, reservationID uuid.UUID, referenceID string) error {
+	_, err := c.client.RevertCommit(ctx, &walletv1.RevertCommitRequest{
+		ReservationId:  reservationID.String(),
+		IdempotencyKey: referenceID,
+	})
+	if err != nil {
+		return c.mapError(err, "revert commit", reservationID)
+	}
+	return nil
+}
+
+// mapError maps wallet-service gRPC status codes to wallet package errors
+func (c *WalletClient) mapError(err error, operation string, reservationID uuid.UUID) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return wallet.ErrReservationNotFound
+	case codes.FailedPrecondition:
+		return wallet.ErrReservationClosed
+	case codes.ResourceExhausted:
+		return wallet.ErrInsufficientFunds
+	default:
+		c.logger.Error().Err(err).
+			Str("reservation_id", reservationID.String()).
+			Str("operation", operation).
+			Msg("wallet service call failed")
+		return fmt.Errorf("%s: %w", operation, err)
+	}
+}
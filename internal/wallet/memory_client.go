@@ -0,0 +1,182 @@
+package wallet
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// InMemoryWalletClient is a WalletClient fake that keeps balances and reservations in memory
+// Used for tests and local development without a wallet service
+type InMemoryWalletClient struct {
+	balances     map[uuid.UUID]decimal.Decimal
+	reservations map[uuid.UUID]*Reservation
+	commits      map[string]decimal.Decimal // referenceID -> committed amount
+
+	mu sync.Mutex
+}
+
+// NewInMemoryWalletClient creates an empty in-memory wallet
+func NewInMemoryWalletClient() *InMemoryWalletClient {
+	return &InMemoryWalletClient{
+		balances:     make(map[uuid.UUID]decimal.Decimal),
+		reservations: make(map[uuid.UUID]*Reservation),
+		commits:      make(map[string]decimal.Decimal),
+	}
+}
+
+// Deposit credits a user's available balance
+func (c *InMemoryWalletClient) Deposit(userID uuid.UUID, amount decimal.Decimal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.balances[userID] = c.balances[userID].Add(amount)
+}
+
+// Reserve creates an active reservation for a user, moving amount out of the available balance
+func (c *InMemoryWalletClient) Reserve(userID uuid.UUID, amount decimal.Decimal) (uuid.UUID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.balances[userID].LessThan(amount) {
+		return uuid.Nil, ErrInsufficientFunds
+	}
+
+	reservation := &Reservation{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Amount:    amount,
+		Committed: decimal.Zero,
+		Status:    ReservationStatusActive,
+	}
+	c.balances[userID] = c.balances[userID].Sub(amount)
+	c.reservations[reservation.ID] = reservation
+
+	return reservation.ID, nil
+}
+
+// Balance returns a user's available balance
+func (c *InMemoryWalletClient) Balance(userID uuid.UUID) decimal.Decimal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.balances[userID]
+}
+
+// GetReservation retrieves a copy of a reservation by ID
+func (c *InMemoryWalletClient) GetReservation(ctx context.Context, reservationID uuid.UUID) (*Reservation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reservation, ok := c.reservations[reservationID]
+	if !ok {
+		return nil, ErrReservationNotFound
+	}
+
+	copied := *reservation
+	return &copied, nil
+}
+
+// AdjustReservation resizes the held amount to exactly amount
+func (c *InMemoryWalletClient) AdjustReservation(ctx context.Context, reservationID uuid.UUID, amount decimal.Decimal) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reservation, ok := c.reservations[reservationID]
+	if !ok {
+		return ErrReservationNotFound
+	}
+	if !reservation.IsActive() {
+		return ErrReservationClosed
+	}
+
+	delta := amount.Sub(reservation.Amount)
+	if delta.GreaterThan(c.balances[reservation.UserID]) {
+		return ErrInsufficientFunds
+	}
+
+	c.balances[reservation.UserID] = c.balances[reservation.UserID].Sub(delta)
+	reservation.Amount = amount
+	return nil
+}
+
+// ReleaseReservation returns amount from the hold back to the user's balance
+func (c *InMemoryWalletClient) ReleaseReservation(ctx context.Context, reservationID uuid.UUID, amount decimal.Decimal) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reservation, ok := c.reservations[reservationID]
+	if !ok {
+		return ErrReservationNotFound
+	}
+	if !reservation.IsActive() {
+		return ErrReservationClosed
+	}
+
+	released := decimal.Min(amount, reservation.Amount)
+	c.balances[reservation.UserID] = c.balances[reservation.UserID].Add(released)
+	reservation.Amount = reservation.Amount.Sub(released)
+
+	if reservation.Amount.IsZero() {
+		if reservation.Committed.IsZero() {
+			reservation.Status = ReservationStatusReleased
+		} else {
+			reservation.Status = ReservationStatusCommitted
+		}
+	}
+	return nil
+}
+
+// CommitReservation converts amount from the hold into committed funds
+func (c *InMemoryWalletClient) CommitReservation(ctx context.Context, reservationID uuid.UUID, amount decimal.Decimal, referenceID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reservation, ok := c.reservations[reservationID]
+	if !ok {
+		return ErrReservationNotFound
+	}
+
+	// Replayed commit with the same reference is a no-op
+	if _, done := c.commits[referenceID]; done {
+		return nil
+	}
+
+	if !reservation.IsActive() {
+		return ErrReservationClosed
+	}
+	if amount.GreaterThan(reservation.Amount) {
+		return ErrInsufficientFunds
+	}
+
+	reservation.Amount = reservation.Amount.Sub(amount)
+	reservation.Committed = reservation.Committed.Add(amount)
+	c.commits[referenceID] = amount
+
+	return nil
+}
+
+// RevertCommit moves funds committed under referenceID back into the hold
+func (c *InMemoryWalletClient) RevertCommit(ctx context.Context, reservationID uuid.UUID, referenceID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reservation, ok := c.reservations[reservationID]
+	if !ok {
+		return ErrReservationNotFound
+	}
+
+	amount, done := c.commits[referenceID]
+	if !done {
+		return nil
+	}
+
+	reservation.Committed = reservation.Committed.Sub(amount)
+	reservation.Amount = reservation.Amount.Add(amount)
+	reservation.Status = ReservationStatusActive
+	delete(c.commits, referenceID)
+
+	return nil
+}
@@ -0,0 +1,7 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_matches_market;
+DROP INDEX IF EXISTS idx_matches_lay_order_id;
+DROP INDEX IF EXISTS idx_matches_back_order_id;
+
+-- Drop table
+DROP TABLE IF EXISTS matches;
@@ -0,0 +1,27 @@
+-- Create matches table for trades produced by the matching engine
+CREATE TABLE IF NOT EXISTS matches (
+    id              UUID PRIMARY KEY,
+    market_id       VARCHAR(255) NOT NULL,
+    selection_id    VARCHAR(255) NOT NULL,
+    back_order_id   UUID NOT NULL,
+    lay_order_id    UUID NOT NULL,
+    back_user_id    UUID NOT NULL,
+    lay_user_id     UUID NOT NULL,
+    price           DECIMAL(20,8) NOT NULL,
+    size            DECIMAL(20,8) NOT NULL,
+    back_liability  DECIMAL(20,8) NOT NULL,
+    lay_liability   DECIMAL(20,8) NOT NULL,
+    matched_at      TIMESTAMP NOT NULL,
+    settled_at      TIMESTAMP
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_matches_back_order_id ON matches(back_order_id);
+CREATE INDEX idx_matches_lay_order_id ON matches(lay_order_id);
+CREATE INDEX idx_matches_market ON matches(market_id, selection_id, matched_at);
+
+-- Add comments
+COMMENT ON TABLE matches IS 'Trades produced by the matching engine';
+COMMENT ON COLUMN matches.back_liability IS 'Amount the back bettor risks (matched stake)';
+COMMENT ON COLUMN matches.lay_liability IS 'Amount the lay bettor risks (stake * (price - 1))';
+COMMENT ON COLUMN matches.settled_at IS 'Timestamp when the match was settled';
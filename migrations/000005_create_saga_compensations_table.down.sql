@@ -0,0 +1,5 @@
+-- Drop column
+ALTER TABLE matches DROP COLUMN IF EXISTS reversed_at;
+
+-- Drop table
+DROP TABLE IF EXISTS saga_compensations;
@@ -0,0 +1,15 @@
+-- Track saga compensations so they can be repeated and can arrive before the placement
+CREATE TABLE IF NOT EXISTS saga_compensations (
+    saga_id         UUID PRIMARY KEY,
+    order_id        UUID,
+    reason          TEXT,
+    compensated_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Matches undone by compensation are kept for audit
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS reversed_at TIMESTAMP;
+
+-- Add comments
+COMMENT ON TABLE saga_compensations IS 'Sagas whose order placement has been compensated';
+COMMENT ON COLUMN saga_compensations.order_id IS 'Compensated order, NULL if compensation arrived before placement';
+COMMENT ON COLUMN matches.reversed_at IS 'Timestamp when the match was reversed by saga compensation';
@@ -0,0 +1,9 @@
+-- Restore original unprocessed index
+DROP INDEX IF EXISTS idx_outbox_unprocessed;
+CREATE INDEX idx_outbox_unprocessed ON outbox_events(created_at)
+WHERE processed_at IS NULL;
+
+-- Drop columns
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS claimed_until;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS claimed_by;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS last_error;
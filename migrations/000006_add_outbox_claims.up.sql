@@ -0,0 +1,16 @@
+-- Let multiple publisher instances claim outbox events with a lease
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS claimed_by VARCHAR(255);
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMP;
+
+-- Error from the last failed publish, read and written by the publisher
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS last_error TEXT;
+
+-- Replace the unprocessed index so claim polling can skip leased rows cheaply
+DROP INDEX IF EXISTS idx_outbox_unprocessed;
+CREATE INDEX idx_outbox_unprocessed ON outbox_events(created_at, claimed_until)
+WHERE processed_at IS NULL;
+
+-- Add comments
+COMMENT ON COLUMN outbox_events.claimed_by IS 'Publisher instance currently holding the claim';
+COMMENT ON COLUMN outbox_events.claimed_until IS 'Claim lease expiry, after which another publisher may claim the event';
+COMMENT ON COLUMN outbox_events.last_error IS 'Error message from the most recent failed publish attempt';
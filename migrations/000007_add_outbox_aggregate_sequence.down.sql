@@ -0,0 +1,7 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_outbox_aggregate_unprocessed;
+DROP INDEX IF EXISTS idx_outbox_aggregate_sequence;
+
+-- Drop column and table
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS aggregate_sequence;
+DROP TABLE IF EXISTS outbox_aggregate_sequences;
@@ -0,0 +1,34 @@
+-- Per-aggregate sequence counters for ordered outbox delivery
+CREATE TABLE IF NOT EXISTS outbox_aggregate_sequences (
+    aggregate_id    UUID PRIMARY KEY,
+    last_sequence   BIGINT NOT NULL
+);
+
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS aggregate_sequence BIGINT;
+
+-- Backfill existing events in creation order
+UPDATE outbox_events e
+SET aggregate_sequence = s.seq
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY aggregate_id ORDER BY created_at, id) AS seq
+    FROM outbox_events
+) s
+WHERE e.id = s.id;
+
+INSERT INTO outbox_aggregate_sequences (aggregate_id, last_sequence)
+SELECT aggregate_id, MAX(aggregate_sequence)
+FROM outbox_events
+GROUP BY aggregate_id
+ON CONFLICT (aggregate_id) DO NOTHING;
+
+ALTER TABLE outbox_events ALTER COLUMN aggregate_sequence SET NOT NULL;
+
+-- Create indexes for performance
+CREATE UNIQUE INDEX idx_outbox_aggregate_sequence ON outbox_events(aggregate_id, aggregate_sequence);
+
+CREATE INDEX idx_outbox_aggregate_unprocessed ON outbox_events(aggregate_id, aggregate_sequence)
+WHERE processed_at IS NULL;
+
+-- Add comments
+COMMENT ON TABLE outbox_aggregate_sequences IS 'Last outbox sequence number issued per aggregate';
+COMMENT ON COLUMN outbox_events.aggregate_sequence IS 'Position of the event within its aggregate, published in this order';
@@ -0,0 +1,3 @@
+-- Drop trigger and function
+DROP TRIGGER IF EXISTS trg_outbox_events_notify ON outbox_events;
+DROP FUNCTION IF EXISTS notify_outbox_events();
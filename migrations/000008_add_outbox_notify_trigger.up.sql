@@ -0,0 +1,16 @@
+-- Wake outbox publishers as soon as new events are committed
+CREATE OR REPLACE FUNCTION notify_outbox_events() RETURNS trigger AS $$
+BEGIN
+    -- Delivered on commit, duplicates within a transaction are folded into one
+    PERFORM pg_notify('outbox_events', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_outbox_events_notify
+AFTER INSERT ON outbox_events
+FOR EACH STATEMENT
+EXECUTE FUNCTION notify_outbox_events();
+
+-- Add comments
+COMMENT ON FUNCTION notify_outbox_events() IS 'Sends NOTIFY outbox_events so LISTENing publishers drain without waiting for the next poll';
@@ -0,0 +1,3 @@
+-- Drop index and column
+DROP INDEX IF EXISTS idx_outbox_dead_lettered;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS dead_lettered_at;
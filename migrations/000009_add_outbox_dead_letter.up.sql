@@ -0,0 +1,14 @@
+-- Dead-letter state for events that exhausted their retries
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMP;
+
+-- Events that already ran out of retries are dead-lettered now
+UPDATE outbox_events
+SET dead_lettered_at = NOW()
+WHERE processed_at IS NULL AND retry_count >= max_retries;
+
+-- Create indexes for performance
+CREATE INDEX idx_outbox_dead_lettered ON outbox_events(dead_lettered_at)
+WHERE dead_lettered_at IS NOT NULL AND processed_at IS NULL;
+
+-- Add comments
+COMMENT ON COLUMN outbox_events.dead_lettered_at IS 'Timestamp when the event exhausted its retries, cleared on replay';
@@ -0,0 +1,10 @@
+-- Restore previous unprocessed index
+DROP INDEX IF EXISTS idx_outbox_unprocessed;
+CREATE INDEX idx_outbox_unprocessed ON outbox_events(created_at, claimed_until)
+WHERE processed_at IS NULL;
+
+-- Restore previous retry default
+ALTER TABLE outbox_events ALTER COLUMN max_retries SET DEFAULT 3;
+
+-- Drop column
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS next_attempt_at;
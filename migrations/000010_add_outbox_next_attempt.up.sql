@@ -0,0 +1,13 @@
+-- Schedule outbox retries with backoff instead of retrying on every poll
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP;
+
+-- More attempts now that they are spread out over minutes
+ALTER TABLE outbox_events ALTER COLUMN max_retries SET DEFAULT 8;
+
+-- Replace the unprocessed index so claims can skip events that aren't due
+DROP INDEX IF EXISTS idx_outbox_unprocessed;
+CREATE INDEX idx_outbox_unprocessed ON outbox_events(created_at, next_attempt_at, claimed_until)
+WHERE processed_at IS NULL;
+
+-- Add comments
+COMMENT ON COLUMN outbox_events.next_attempt_at IS 'Earliest time the next publish attempt may be made, NULL when due immediately';
@@ -0,0 +1,2 @@
+-- Drop column
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS schema_version;
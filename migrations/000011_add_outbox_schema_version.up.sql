@@ -0,0 +1,8 @@
+-- Version of the typed payload schema each event was written with
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS schema_version INT NOT NULL DEFAULT 1;
+
+-- Match events were written as bet.matched before typed schemas
+UPDATE outbox_events SET event_type = 'match.created' WHERE event_type = 'bet.matched' AND processed_at IS NULL;
+
+-- Add comments
+COMMENT ON COLUMN outbox_events.schema_version IS 'Payload schema version, see internal/events and events.proto';
@@ -0,0 +1,3 @@
+-- Drop columns
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS trace_state;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS trace_parent;
@@ -0,0 +1,7 @@
+-- W3C trace context of the request that wrote the event
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS trace_parent TEXT;
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS trace_state TEXT;
+
+-- Add comments
+COMMENT ON COLUMN outbox_events.trace_parent IS 'W3C traceparent captured at write time, continued by the publisher';
+COMMENT ON COLUMN outbox_events.trace_state IS 'W3C tracestate captured alongside trace_parent';
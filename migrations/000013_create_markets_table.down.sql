@@ -0,0 +1,3 @@
+-- Drop tables
+DROP TABLE IF EXISTS market_status_inbox;
+DROP TABLE IF EXISTS markets;
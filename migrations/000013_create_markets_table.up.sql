@@ -0,0 +1,26 @@
+-- Market state driven by the upstream market status feed
+CREATE TABLE IF NOT EXISTS markets (
+    market_id               VARCHAR(255) PRIMARY KEY,
+    status                  VARCHAR(50) NOT NULL,  -- 'OPEN', 'SUSPENDED', 'CLOSED', 'SETTLED'
+    winning_selection_id    VARCHAR(255),
+    updated_at              TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Upstream status messages already applied, so redeliveries are skipped
+CREATE TABLE IF NOT EXISTS market_status_inbox (
+    message_id      VARCHAR(255) PRIMARY KEY,
+    market_id       VARCHAR(255) NOT NULL,
+    message_type    VARCHAR(50) NOT NULL,
+    processed_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_markets_status ON markets(status) WHERE status <> 'OPEN';
+CREATE INDEX idx_market_status_inbox_processed_at ON market_status_inbox(processed_at);
+
+-- Add comments
+COMMENT ON TABLE markets IS 'Markets whose status was set by the upstream feed, missing markets are open';
+COMMENT ON COLUMN markets.status IS 'Current trading status of the market';
+COMMENT ON COLUMN markets.winning_selection_id IS 'Winning selection once the market is resulted';
+COMMENT ON TABLE market_status_inbox IS 'Upstream market status messages that have been fully applied';
+COMMENT ON COLUMN market_status_inbox.message_id IS 'Upstream message ID used to deduplicate redeliveries';
@@ -0,0 +1,3 @@
+-- Lets the maintenance job find the oldest processed events without a table scan
+CREATE INDEX IF NOT EXISTS idx_outbox_processed_at ON outbox_events(processed_at)
+WHERE processed_at IS NOT NULL;
@@ -0,0 +1,7 @@
+-- Restore comments
+COMMENT ON COLUMN idempotency_keys.idempotency_key IS 'Client-provided unique key for the operation';
+COMMENT ON COLUMN idempotency_keys.response_data IS 'Cached response data';
+
+-- Scoped keys longer than the old limit cannot be kept
+DELETE FROM idempotency_keys WHERE LENGTH(idempotency_key) > 255;
+ALTER TABLE idempotency_keys ALTER COLUMN idempotency_key TYPE VARCHAR(255);
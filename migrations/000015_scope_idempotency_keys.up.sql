@@ -0,0 +1,8 @@
+-- Keys are stored as <operation>:<user_id>:<client key>, leave room for the prefix
+ALTER TABLE idempotency_keys ALTER COLUMN idempotency_key TYPE VARCHAR(512);
+
+-- Keys stored before scoping are never looked up again and expire on their own
+
+-- Add comments
+COMMENT ON COLUMN idempotency_keys.idempotency_key IS 'Client-provided key scoped by operation and user: <operation>:<user_id>:<key>';
+COMMENT ON COLUMN idempotency_keys.response_data IS 'Stored response envelope, either the result or the error code and message';
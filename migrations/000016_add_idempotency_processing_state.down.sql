@@ -0,0 +1,6 @@
+-- In-progress claims have no response to keep
+DELETE FROM idempotency_keys WHERE status = 'processing';
+
+-- Drop columns
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS status;
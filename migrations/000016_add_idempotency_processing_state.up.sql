@@ -0,0 +1,7 @@
+-- Keys are claimed before the call runs, concurrent duplicates see the claim and wait
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'completed';
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+
+-- Add comments
+COMMENT ON COLUMN idempotency_keys.status IS 'processing while the first call runs, completed once its response is stored';
+COMMENT ON COLUMN idempotency_keys.locked_until IS 'End of the processing lease, after which a retry may take the key over';
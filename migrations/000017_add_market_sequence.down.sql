@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_matches_market_sequence;
+
+-- Drop columns
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS market_sequence;
+ALTER TABLE matches DROP COLUMN IF EXISTS sequence;
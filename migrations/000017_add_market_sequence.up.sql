@@ -0,0 +1,10 @@
+-- Matches and their events carry the position the market sequencer gave them
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS sequence BIGINT;
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS market_sequence BIGINT;
+
+-- Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_matches_market_sequence ON matches(market_id, sequence);
+
+-- Add comments
+COMMENT ON COLUMN matches.sequence IS 'Position in the market event sequence, NULL for matches made before sequencing';
+COMMENT ON COLUMN outbox_events.market_sequence IS 'Position in the market event sequence, NULL for events outside the order book';
@@ -0,0 +1,2 @@
+-- Drop table
+DROP TABLE IF EXISTS engine_snapshots;
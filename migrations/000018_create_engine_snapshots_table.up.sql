@@ -0,0 +1,18 @@
+-- Point-in-time matching engine state, recovery replays the journal from it
+CREATE TABLE IF NOT EXISTS engine_snapshots (
+    id                  BIGSERIAL PRIMARY KEY,
+    engine_id           VARCHAR(255) NOT NULL,
+    journal_position    BIGINT NOT NULL,
+    taken_at            TIMESTAMP NOT NULL,
+    data                BYTEA NOT NULL,
+    created_at          TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_engine_snapshots_engine_position ON engine_snapshots(engine_id, journal_position DESC);
+
+-- Add comments
+COMMENT ON TABLE engine_snapshots IS 'Matching engine snapshots, the latest few per engine are kept';
+COMMENT ON COLUMN engine_snapshots.engine_id IS 'Engine replica whose journal the snapshot belongs to';
+COMMENT ON COLUMN engine_snapshots.journal_position IS 'Journal position of the first command not included in the snapshot';
+COMMENT ON COLUMN engine_snapshots.data IS 'Binary encoded resting orders, market status and sequence of every market';
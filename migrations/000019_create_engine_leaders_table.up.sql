@@ -0,0 +1,16 @@
+-- Elected leader of the matching engine, followed by its standbys
+CREATE TABLE IF NOT EXISTS engine_leaders (
+    name                VARCHAR(255) PRIMARY KEY,
+    epoch               BIGINT NOT NULL,
+    engine_id           VARCHAR(255) NOT NULL,
+    journal_id          UUID NOT NULL,
+    address             TEXT NOT NULL,
+    elected_at          TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Add comments
+COMMENT ON TABLE engine_leaders IS 'Current matching engine leader per election, one row per election name';
+COMMENT ON COLUMN engine_leaders.epoch IS 'Incremented by every election, transactions of an older epoch are fenced off';
+COMMENT ON COLUMN engine_leaders.engine_id IS 'Engine replica that won the election';
+COMMENT ON COLUMN engine_leaders.journal_id IS 'ID of the leader''s journal, a restarted leader with the same journal may take over again';
+COMMENT ON COLUMN engine_leaders.address IS 'Base URL standbys stream the leader''s journal from';
@@ -0,0 +1,3 @@
+-- Drop tables
+DROP TABLE IF EXISTS engine_market_owners;
+DROP TABLE IF EXISTS engine_shard_layouts;
@@ -0,0 +1,23 @@
+-- Number of replicas the matching engine's markets are spread over
+CREATE TABLE IF NOT EXISTS engine_shard_layouts (
+    name                VARCHAR(255) PRIMARY KEY,
+    replicas            INTEGER NOT NULL,
+    version             BIGINT NOT NULL DEFAULT 1,
+    updated_at          TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Replica holding each market's book
+CREATE TABLE IF NOT EXISTS engine_market_owners (
+    market_id           VARCHAR(255) PRIMARY KEY,
+    ordinal             INTEGER NOT NULL,
+    updated_at          TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Index for listing the markets of a replica
+CREATE INDEX idx_engine_market_owners_ordinal ON engine_market_owners(ordinal);
+
+-- Add comments
+COMMENT ON TABLE engine_shard_layouts IS 'Shard layout per name; to rebalance, UPDATE replicas and increment version';
+COMMENT ON COLUMN engine_shard_layouts.replicas IS 'Pod ordinals 0 to replicas-1 own markets; scale the StatefulSet up before raising it and down only after lowering it and draining';
+COMMENT ON COLUMN engine_shard_layouts.version IS 'Incremented by every change, replicas rebalance when they see a new version';
+COMMENT ON TABLE engine_market_owners IS 'Pod ordinal holding each market''s book, moved when another replica takes the book over';
@@ -0,0 +1,2 @@
+-- Drop columns
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS claim_token;
@@ -0,0 +1,5 @@
+-- Fences a claim holder whose lease expired off the key once a retry takes it over
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS claim_token UUID;
+
+-- Add comments
+COMMENT ON COLUMN idempotency_keys.claim_token IS 'Token of the call holding a processing key, required to complete or release it';
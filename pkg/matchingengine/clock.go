@@ -0,0 +1,53 @@
+package matchingengine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Clock returns the current time
+type Clock func() time.Time
+
+// IDGenerator returns a new unique ID
+type IDGenerator func() uuid.UUID
+
+// EngineOption configures an Engine
+type EngineOption func(*Engine)
+
+// WithClock sets the clock matches, fills and cancellations are stamped with
+// Defaults to time.Now
+func WithClock(clock Clock) EngineOption {
+	return func(e *Engine) {
+		e.now = clock
+	}
+}
+
+// WithIDGenerator sets how the engine names matches, defaults to random UUIDs
+func WithIDGenerator(ids IDGenerator) EngineOption {
+	return func(e *Engine) {
+		e.newID = ids
+	}
+}
+
+// SequentialIDs returns name-based UUIDs for seed/1, seed/2, ... in namespace
+// The same namespace and seed always give the same IDs. Not safe for concurrent use
+func SequentialIDs(namespace uuid.UUID, seed string) IDGenerator {
+	var n int64
+	return func() uuid.UUID {
+		n++
+		return uuid.NewSHA1(namespace, []byte(fmt.Sprintf("%s/%d", seed, n)))
+	}
+}
+
+// MatchIDFunc names a match after its market and sequence number
+type MatchIDFunc func(marketID string, sequence int64) uuid.UUID
+
+// SequenceMatchIDs returns name-based UUIDs for marketID/sequence in namespace
+// Replaying a journal then reproduces the match IDs of the original run
+func SequenceMatchIDs(namespace uuid.UUID) MatchIDFunc {
+	return func(marketID string, sequence int64) uuid.UUID {
+		return uuid.NewSHA1(namespace, []byte(fmt.Sprintf("%s/%d", marketID, sequence)))
+	}
+}
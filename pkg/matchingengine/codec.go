@@ -0,0 +1,208 @@
+package matchingengine
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+)
+
+// errShortBuffer is returned when a record ends before all its fields are read
+var errShortBuffer = errors.New("record truncated")
+
+// encoder appends fields to a binary record
+// Integers are varints, strings and decimals are length prefixed, decimals keep
+// their exact string form
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.uint8(1)
+	} else {
+		e.uint8(0)
+	}
+}
+
+func (e *encoder) int64(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *encoder) string(v string) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) uuid(v uuid.UUID) {
+	e.buf = append(e.buf, v[:]...)
+}
+
+func (e *encoder) decimal(v decimal.Decimal) {
+	e.string(v.String())
+}
+
+// time keeps the instant only, decoded times are in UTC
+func (e *encoder) time(v time.Time) {
+	e.bool(!v.IsZero())
+	if !v.IsZero() {
+		e.int64(v.UnixNano())
+	}
+}
+
+func (e *encoder) timePtr(v *time.Time) {
+	e.bool(v != nil)
+	if v != nil {
+		e.time(*v)
+	}
+}
+
+func (e *encoder) order(o *models.Order) {
+	e.uuid(o.ID)
+	e.uuid(o.UserID)
+	e.string(o.MarketID)
+	e.string(o.SelectionID)
+	e.string(string(o.Side))
+	e.decimal(o.Price)
+	e.decimal(o.Size)
+	e.decimal(o.SizeMatched)
+	e.decimal(o.SizeRemaining)
+	e.string(string(o.Status))
+	e.string(o.ReservationID)
+	e.string(o.SagaID)
+	e.string(o.IdempotencyKey)
+	e.time(o.PlacedAt)
+	e.timePtr(o.MatchedAt)
+	e.timePtr(o.CancelledAt)
+	e.int64(o.Version)
+}
+
+// decoder reads fields written by encoder
+// The first error sticks, later reads return zero values
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) uint8() uint8 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.buf) < 1 {
+		d.fail(errShortBuffer)
+		return 0
+	}
+	v := d.buf[0]
+	d.buf = d.buf[1:]
+	return v
+}
+
+func (d *decoder) bool() bool {
+	return d.uint8() != 0
+}
+
+func (d *decoder) int64() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail(errShortBuffer)
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	if d.err != nil {
+		return ""
+	}
+	length, n := binary.Uvarint(d.buf)
+	if n <= 0 || uint64(len(d.buf)-n) < length {
+		d.fail(errShortBuffer)
+		return ""
+	}
+	v := string(d.buf[n : n+int(length)])
+	d.buf = d.buf[n+int(length):]
+	return v
+}
+
+func (d *decoder) uuid() uuid.UUID {
+	var v uuid.UUID
+	if d.err != nil {
+		return v
+	}
+	if len(d.buf) < len(v) {
+		d.fail(errShortBuffer)
+		return v
+	}
+	copy(v[:], d.buf)
+	d.buf = d.buf[len(v):]
+	return v
+}
+
+func (d *decoder) decimal() decimal.Decimal {
+	s := d.string()
+	if d.err != nil {
+		return decimal.Zero
+	}
+	v, err := decimal.NewFromString(s)
+	if err != nil {
+		d.fail(fmt.Errorf("parse decimal %q: %w", s, err))
+		return decimal.Zero
+	}
+	return v
+}
+
+func (d *decoder) time() time.Time {
+	if !d.bool() {
+		return time.Time{}
+	}
+	return time.Unix(0, d.int64()).UTC()
+}
+
+func (d *decoder) timePtr() *time.Time {
+	if !d.bool() {
+		return nil
+	}
+	v := d.time()
+	return &v
+}
+
+func (d *decoder) order() *models.Order {
+	return &models.Order{
+		ID:             d.uuid(),
+		UserID:         d.uuid(),
+		MarketID:       d.string(),
+		SelectionID:    d.string(),
+		Side:           models.OrderSide(d.string()),
+		Price:          d.decimal(),
+		Size:           d.decimal(),
+		SizeMatched:    d.decimal(),
+		SizeRemaining:  d.decimal(),
+		Status:         models.OrderStatus(d.string()),
+		ReservationID:  d.string(),
+		SagaID:         d.string(),
+		IdempotencyKey: d.string(),
+		PlacedAt:       d.time(),
+		MatchedAt:      d.timePtr(),
+		CancelledAt:    d.timePtr(),
+		Version:        d.int64(),
+	}
+}
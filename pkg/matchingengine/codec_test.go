@@ -0,0 +1,144 @@
+package matchingengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+)
+
+// codecOrder is an order with every field the codec writes set
+func codecOrder() *models.Order {
+	matchedAt := time.Date(2026, 3, 1, 10, 0, 0, 123456789, time.UTC)
+	return &models.Order{
+		ID:             uuid.New(),
+		UserID:         uuid.New(),
+		MarketID:       "market-1",
+		SelectionID:    "team-a",
+		Side:           models.OrderSideLay,
+		Price:          decimal.RequireFromString("2.52"),
+		Size:           decimal.RequireFromString("100"),
+		SizeMatched:    decimal.RequireFromString("40.5"),
+		SizeRemaining:  decimal.RequireFromString("59.5"),
+		Status:         models.OrderStatusPartially,
+		ReservationID:  uuid.NewString(),
+		SagaID:         uuid.NewString(),
+		IdempotencyKey: "idem-key-1",
+		PlacedAt:       time.Date(2026, 3, 1, 9, 59, 0, 1, time.UTC),
+		MatchedAt:      &matchedAt,
+		Version:        7,
+	}
+}
+
+func TestCodec_OrderRoundTrip(t *testing.T) {
+	order := codecOrder()
+	e := &encoder{}
+	e.order(order)
+
+	d := &decoder{buf: e.buf}
+	decoded := d.order()
+	require.NoError(t, d.err)
+	assert.Empty(t, d.buf)
+
+	// Decimals keep their exact value, times their instant in UTC
+	assert.Equal(t, order, decoded)
+	assert.Nil(t, decoded.CancelledAt)
+}
+
+func TestCodec_TimesAreUTC(t *testing.T) {
+	local := time.Date(2026, 3, 1, 12, 0, 0, 5, time.FixedZone("UTC+2", 2*60*60))
+	e := &encoder{}
+	e.time(local)
+	e.time(time.Time{})
+
+	d := &decoder{buf: e.buf}
+	decoded := d.time()
+	require.NoError(t, d.err)
+	assert.True(t, local.Equal(decoded))
+	assert.Equal(t, time.UTC, decoded.Location())
+	assert.True(t, d.time().IsZero())
+}
+
+func TestCommand_EncodeRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		cmd  *Command
+	}{
+		{"place", &Command{Type: CommandPlace, MarketID: "market-1", Sequence: 3, Time: at, Order: codecOrder()}},
+		{"cancel", &Command{Type: CommandCancel, MarketID: "market-1", Sequence: 4, Time: at, OrderID: uuid.New()}},
+		{"amend", &Command{
+			Type: CommandAmend, MarketID: "market-1", Sequence: 6, Time: at, OrderID: uuid.New(),
+			Price: decimal.RequireFromString("3.1"), Size: decimal.RequireFromString("25"),
+		}},
+		{"market status", &Command{Type: CommandMarketStatus, MarketID: "market-1", Sequence: 7, Time: at, Status: models.MarketStatusClosed}},
+		{"abort", &Command{Type: CommandAbort, MarketID: "market-1", Sequence: 7, Time: at}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.cmd.encode()
+			decoded, err := decodeCommand(data)
+			require.NoError(t, err)
+
+			// Unset decimals decode as zero in another form, so compare by value
+			assert.Equal(t, data, decoded.encode())
+			assert.Equal(t, tt.cmd.Order, decoded.Order)
+			assert.True(t, tt.cmd.Price.Equal(decoded.Price))
+			assert.True(t, tt.cmd.Size.Equal(decoded.Size))
+		})
+	}
+}
+
+func TestDecodeCommand_RejectsTruncatedRecord(t *testing.T) {
+	data := (&Command{Type: CommandPlace, MarketID: "market-1", Sequence: 3, Order: codecOrder()}).encode()
+
+	// Every field is needed, so any cut fails rather than decoding zero values
+	for n := 0; n < len(data); n++ {
+		_, err := decodeCommand(data[:n])
+		assert.Error(t, err, "record cut to %d of %d bytes", n, len(data))
+	}
+}
+
+func TestDecodeCommand_RejectsBadInput(t *testing.T) {
+	valid := (&Command{Type: CommandAmend, MarketID: "market-1", Price: decimal.RequireFromString("2.5")}).encode()
+
+	version := append([]byte(nil), valid...)
+	version[0] = commandVersion + 1
+	_, err := decodeCommand(version)
+	assert.ErrorContains(t, err, "unsupported command version")
+
+	e := &encoder{}
+	e.uint8(commandVersion)
+	e.uint8(uint8(CommandAmend))
+	e.string("market-1")
+	e.int64(1)
+	e.time(time.Time{})
+	e.bool(false)
+	e.uuid(uuid.New())
+	e.string("not-a-decimal")
+	_, err = decodeCommand(e.buf)
+	assert.ErrorContains(t, err, "parse decimal")
+}
+
+func TestDecoder_FirstErrorSticks(t *testing.T) {
+	e := &encoder{}
+	e.string("market-1")
+
+	d := &decoder{buf: e.buf[:3]}
+	assert.Empty(t, d.string())
+	require.ErrorIs(t, d.err, errShortBuffer)
+
+	// Later reads return zero values and keep the first error
+	d.buf = e.buf
+	assert.Empty(t, d.string())
+	assert.Zero(t, d.int64())
+	assert.Equal(t, uuid.Nil, d.uuid())
+	assert.True(t, d.decimal().IsZero())
+	assert.ErrorIs(t, d.err, errShortBuffer)
+}
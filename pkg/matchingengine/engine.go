@@ -1,7 +1,6 @@
 package matchingengine
 
 import (
-	"fmt"
 	"sync"
 	"time"
 
@@ -25,6 +24,13 @@ type Engine struct {
 	backPrices []decimal.Decimal // Sorted descending (best back first)
 	layPrices  []decimal.Decimal // Sorted ascending (best lay first)
 
+	// Markets that are not open, driven by the upstream status feed
+	marketStatus map[string]models.MarketStatus
+
+	// Sources of match times and IDs, replaceable so results can be reproduced
+	now   Clock
+	newID IDGenerator
+
 	mu sync.RWMutex
 }
 
@@ -35,31 +41,43 @@ type OrderQueue struct {
 }
 
 // NewEngine creates a new matching engine for a market
-func NewEngine(marketID, selectionID string) *Engine {
-	return &Engine{
+func NewEngine(marketID, selectionID string, opts ...EngineOption) *Engine {
+	e := &Engine{
 		marketID:    marketID,
 		selectionID: selectionID,
 		backOrders:  make(map[string]*OrderQueue),
 		layOrders:   make(map[string]*OrderQueue),
 		backPrices:  make([]decimal.Decimal, 0),
 		layPrices:   make([]decimal.Decimal, 0),
+
+		marketStatus: make(map[string]models.MarketStatus),
+
+		now:   time.Now,
+		newID: uuid.New,
+	}
+	for _, opt := range opts {
+		opt(e)
 	}
+	return e
 }
 
 // PlaceOrder places an order and attempts to match it
 // Returns the order and any matches that occurred
+// Use Begin to place an order whose changes can be rolled back
 func (e *Engine) PlaceOrder(order *models.Order) ([]*models.Match, error) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+	txn := e.Begin()
+	defer txn.Rollback()
 
-	if order.Side == models.OrderSideBack {
-		return e.placeBackOrder(order)
+	matches, err := txn.PlaceOrder(order)
+	if err != nil {
+		return nil, err
 	}
-	return e.placeLayOrder(order)
+	txn.Commit()
+	return matches, nil
 }
 
 // placeBackOrder places a back order and matches against lay orders
-func (e *Engine) placeBackOrder(order *models.Order) ([]*models.Match, error) {
+func (e *Engine) placeBackOrder(order *models.Order, undo *undoLog) ([]*models.Match, error) {
 	matches := make([]*models.Match, 0)
 
 	// Try to match with existing lay orders
@@ -75,16 +93,18 @@ func (e *Engine) placeBackOrder(order *models.Order) ([]*models.Match, error) {
 		}
 
 		queue := e.layOrders[layPrice.String()]
-		newMatches := e.matchOrders(order, queue, layPrice)
+		newMatches := e.matchOrders(order, queue, layPrice, undo)
 		matches = append(matches, newMatches...)
 	}
 
 	// If not fully matched, add remainder to book
 	if !order.SizeRemaining.IsZero() {
-		e.addBackOrder(order)
-		order.Status = models.OrderStatusPartially
+		e.addBackOrder(order, undo)
+		if !order.SizeMatched.IsZero() {
+			order.Status = models.OrderStatusPartially
+		}
 	} else {
-		now := time.Now()
+		now := e.now()
 		order.MatchedAt = &now
 		order.Status = models.OrderStatusMatched
 	}
@@ -93,7 +113,7 @@ func (e *Engine) placeBackOrder(order *models.Order) ([]*models.Match, error) {
 }
 
 // placeLayOrder places a lay order and matches against back orders
-func (e *Engine) placeLayOrder(order *models.Order) ([]*models.Match, error) {
+func (e *Engine) placeLayOrder(order *models.Order, undo *undoLog) ([]*models.Match, error) {
 	matches := make([]*models.Match, 0)
 
 	// Try to match with existing back orders
@@ -109,16 +129,18 @@ func (e *Engine) placeLayOrder(order *models.Order) ([]*models.Match, error) {
 		}
 
 		queue := e.backOrders[backPrice.String()]
-		newMatches := e.matchOrders(order, queue, backPrice)
+		newMatches := e.matchOrders(order, queue, backPrice, undo)
 		matches = append(matches, newMatches...)
 	}
 
 	// If not fully matched, add remainder to book
 	if !order.SizeRemaining.IsZero() {
-		e.addLayOrder(order)
-		order.Status = models.OrderStatusPartially
+		e.addLayOrder(order, undo)
+		if !order.SizeMatched.IsZero() {
+			order.Status = models.OrderStatusPartially
+		}
 	} else {
-		now := time.Now()
+		now := e.now()
 		order.MatchedAt = &now
 		order.Status = models.OrderStatusMatched
 	}
@@ -127,23 +149,27 @@ func (e *Engine) placeLayOrder(order *models.Order) ([]*models.Match, error) {
 }
 
 // matchOrders matches an incoming order against a queue
-func (e *Engine) matchOrders(incoming *models.Order, queue *OrderQueue, matchPrice decimal.Decimal) []*models.Match {
+func (e *Engine) matchOrders(incoming *models.Order, queue *OrderQueue, matchPrice decimal.Decimal, undo *undoLog) []*models.Match {
 	matches := make([]*models.Match, 0)
+	if len(queue.orders) > 0 {
+		undo.saveQueue(queue)
+	}
 
 	for i := 0; i < len(queue.orders) && !incoming.SizeRemaining.IsZero(); {
 		existing := queue.orders[i]
 
 		// Calculate matched size
 		matchSize := decimal.Min(incoming.SizeRemaining, existing.SizeRemaining)
+		undo.saveOrder(existing)
 
 		// Create match
 		match := &models.Match{
-			ID:          uuid.New(),
+			ID:          e.newID(),
 			MarketID:    e.marketID,
 			SelectionID: e.selectionID,
 			Price:       matchPrice,
 			Size:        matchSize,
-			MatchedAt:   time.Now(),
+			MatchedAt:   e.now(),
 		}
 
 		// Set match participants based on sides
@@ -152,6 +178,8 @@ func (e *Engine) matchOrders(incoming *models.Order, queue *OrderQueue, matchPri
 			match.LayOrderID = existing.ID
 			match.BackUserID = incoming.UserID
 			match.LayUserID = existing.UserID
+			match.BackReservationID = incoming.ReservationID
+			match.LayReservationID = existing.ReservationID
 			match.BackLiability = matchSize                                  // Back bettor risks stake
 			match.LayLiability = matchSize.Mul(matchPrice.Sub(decimal.NewFromInt(1))) // Lay bettor risks liability
 		} else {
@@ -159,6 +187,8 @@ func (e *Engine) matchOrders(incoming *models.Order, queue *OrderQueue, matchPri
 			match.LayOrderID = incoming.ID
 			match.BackUserID = existing.UserID
 			match.LayUserID = incoming.UserID
+			match.BackReservationID = existing.ReservationID
+			match.LayReservationID = incoming.ReservationID
 			match.BackLiability = matchSize
 			match.LayLiability = matchSize.Mul(matchPrice.Sub(decimal.NewFromInt(1)))
 		}
@@ -174,7 +204,7 @@ func (e *Engine) matchOrders(incoming *models.Order, queue *OrderQueue, matchPri
 
 		// Remove fully matched orders from queue
 		if existing.SizeRemaining.IsZero() {
-			now := time.Now()
+			now := e.now()
 			existing.MatchedAt = &now
 			existing.Status = models.OrderStatusMatched
 			queue.orders = append(queue.orders[:i], queue.orders[i+1:]...)
@@ -188,17 +218,20 @@ func (e *Engine) matchOrders(incoming *models.Order, queue *OrderQueue, matchPri
 }
 
 // addBackOrder adds a back order to the book
-func (e *Engine) addBackOrder(order *models.Order) {
+func (e *Engine) addBackOrder(order *models.Order, undo *undoLog) {
 	priceKey := order.Price.String()
 
 	queue, exists := e.backOrders[priceKey]
 	if !exists {
+		undo.saveLevel(e.backOrders, &e.backPrices, priceKey)
 		queue = &OrderQueue{
 			price:  order.Price,
 			orders: make([]*models.Order, 0),
 		}
 		e.backOrders[priceKey] = queue
 		e.backPrices = insertPriceDescending(e.backPrices, order.Price)
+	} else {
+		undo.saveQueue(queue)
 	}
 
 	queue.orders = append(queue.orders, order)
@@ -206,17 +239,20 @@ func (e *Engine) addBackOrder(order *models.Order) {
 }
 
 // addLayOrder adds a lay order to the book
-func (e *Engine) addLayOrder(order *models.Order) {
+func (e *Engine) addLayOrder(order *models.Order, undo *undoLog) {
 	priceKey := order.Price.String()
 
 	queue, exists := e.layOrders[priceKey]
 	if !exists {
+		undo.saveLevel(e.layOrders, &e.layPrices, priceKey)
 		queue = &OrderQueue{
 			price:  order.Price,
 			orders: make([]*models.Order, 0),
 		}
 		e.layOrders[priceKey] = queue
 		e.layPrices = insertPriceAscending(e.layPrices, order.Price)
+	} else {
+		undo.saveQueue(queue)
 	}
 
 	queue.orders = append(queue.orders, order)
@@ -225,36 +261,106 @@ func (e *Engine) addLayOrder(order *models.Order) {
 
 // CancelOrder removes an order from the book
 func (e *Engine) CancelOrder(orderID uuid.UUID) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+	txn := e.Begin()
+	defer txn.Commit()
 
-	// Search in back orders
-	for _, queue := range e.backOrders {
-		for i, order := range queue.orders {
-			if order.ID == orderID {
-				queue.orders = append(queue.orders[:i], queue.orders[i+1:]...)
-				now := time.Now()
-				order.CancelledAt = &now
-				order.Status = models.OrderStatusCancelled
-				return nil
-			}
-		}
+	_, err := txn.CancelOrder(orderID)
+	return err
+}
+
+// AmendOrder changes the price and total size of a resting order
+// Returns the amended order and any matches a new price produced
+func (e *Engine) AmendOrder(orderID uuid.UUID, price, size decimal.Decimal) (*models.Order, []*models.Match, error) {
+	txn := e.Begin()
+	defer txn.Rollback()
+
+	order, matches, err := txn.AmendOrder(orderID, price, size)
+	if err != nil {
+		return nil, nil, err
 	}
+	txn.Commit()
+	return order, matches, nil
+}
 
-	// Search in lay orders
-	for _, queue := range e.layOrders {
-		for i, order := range queue.orders {
-			if order.ID == orderID {
-				queue.orders = append(queue.orders[:i], queue.orders[i+1:]...)
-				now := time.Now()
-				order.CancelledAt = &now
-				order.Status = models.OrderStatusCancelled
-				return nil
+// findOrder returns the queue holding a resting order and its position, nil if it is not on the book
+func (e *Engine) findOrder(orderID uuid.UUID) (*OrderQueue, int) {
+	for _, book := range []map[string]*OrderQueue{e.backOrders, e.layOrders} {
+		for _, queue := range book {
+			for i, order := range queue.orders {
+				if order.ID == orderID {
+					return queue, i
+				}
 			}
 		}
 	}
+	return nil, -1
+}
+
+// SetMarketStatus records the trading state of a market
+// Orders on suspended, closed or settled markets are rejected
+func (e *Engine) SetMarketStatus(marketID string, status models.MarketStatus) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if status == models.MarketStatusOpen {
+		delete(e.marketStatus, marketID)
+		return
+	}
+	e.marketStatus[marketID] = status
+}
+
+// MarketStatus returns the trading state of a market, open unless set otherwise
+func (e *Engine) MarketStatus(marketID string) models.MarketStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if status, ok := e.marketStatus[marketID]; ok {
+		return status
+	}
+	return models.MarketStatusOpen
+}
+
+// CheckMarketOpen returns ErrMarketSuspended or ErrMarketClosed if the market does not accept orders
+func (e *Engine) CheckMarketOpen(marketID string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.checkMarketOpen(marketID)
+}
+
+func (e *Engine) checkMarketOpen(marketID string) error {
+	return checkOpen(e.marketStatus[marketID])
+}
+
+// checkOpen returns the error for orders on a market in status
+func checkOpen(status models.MarketStatus) error {
+	switch status {
+	case models.MarketStatusSuspended:
+		return models.ErrMarketSuspended
+	case models.MarketStatusClosed, models.MarketStatusSettled:
+		return models.ErrMarketClosed
+	default:
+		return nil
+	}
+}
+
+// RemoveMarketOrders takes every resting order of a market off the book
+// Returns the removed orders; their status is left to the caller
+func (e *Engine) RemoveMarketOrders(marketID string) []*models.Order {
+	txn := e.Begin()
+	defer txn.Commit()
+
+	return txn.RemoveMarketOrders(marketID)
+}
+
+// ReinstateOrder puts an order back on the book after one of its matches was reversed
+// If the order is still resting it is replaced in place, otherwise it is inserted
+// into its price level by original placement time so it keeps its priority
+func (e *Engine) ReinstateOrder(order *models.Order) {
+	txn := e.Begin()
+	defer txn.Commit()
 
-	return fmt.Errorf("order not found: %s", orderID)
+	txn.ReinstateOrder(order)
 }
 
 // GetMarketBook returns the current state of the order book
@@ -295,7 +401,7 @@ func (e *Engine) GetMarketBook() *models.MarketBook {
 		SelectionID: e.selectionID,
 		BackOrders:  backLevels,
 		LayOrders:   layLevels,
-		UpdatedAt:   time.Now(),
+		UpdatedAt:   e.now(),
 	}
 }
 
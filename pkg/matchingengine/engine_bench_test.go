@@ -0,0 +1,277 @@
+package matchingengine_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/cypherlabdev/order-book-service/pkg/matchingengine"
+)
+
+// Book depths the benchmarks run at, in resting orders per side
+var benchDepths = []int{10, 100, 1000, 10000}
+
+// ordersPerLevel is how many resting orders share a price level
+const ordersPerLevel = 10
+
+var (
+	tick     = decimal.RequireFromString("0.01")
+	bestBack = decimal.RequireFromString("10.00")
+	bestLay  = decimal.RequireFromString("10.01")
+)
+
+func benchOrder(marketID string, side models.OrderSide, price decimal.Decimal, size int64) *models.Order {
+	amount := decimal.NewFromInt(size)
+	return &models.Order{
+		ID:            uuid.New(),
+		UserID:        uuid.New(),
+		MarketID:      marketID,
+		SelectionID:   "team-a",
+		Side:          side,
+		Price:         price,
+		Size:          amount,
+		SizeMatched:   decimal.Zero,
+		SizeRemaining: amount,
+		Status:        models.OrderStatusPending,
+		PlacedAt:      time.Now(),
+		Version:       1,
+	}
+}
+
+// levelPrice returns the price of a side's level, 0 being the best
+func levelPrice(side models.OrderSide, level int) decimal.Decimal {
+	offset := tick.Mul(decimal.NewFromInt(int64(level)))
+	if side == models.OrderSideBack {
+		return bestBack.Sub(offset)
+	}
+	return bestLay.Add(offset)
+}
+
+// levels returns how many price levels a book of depth orders per side has
+func levels(depth int) int {
+	return max(1, depth/ordersPerLevel)
+}
+
+// benchBook creates an engine whose book holds depth resting orders per side
+// The book does not cross, best back is one tick under best lay
+func benchBook(b *testing.B, depth int) (*matchingengine.Engine, []*models.Order) {
+	b.Helper()
+	engine := matchingengine.NewEngine("event-bench", "team-a")
+
+	resting := make([]*models.Order, 0, 2*depth)
+	for i := 0; i < depth; i++ {
+		level := i % levels(depth)
+		for _, side := range []models.OrderSide{models.OrderSideBack, models.OrderSideLay} {
+			order := benchOrder("event-bench", side, levelPrice(side, level), 100)
+			if _, err := engine.PlaceOrder(order); err != nil {
+				b.Fatal(err)
+			}
+			resting = append(resting, order)
+		}
+	}
+	return engine, resting
+}
+
+// BenchmarkEngine_PlaceOrder places an order that rests on an existing level
+func BenchmarkEngine_PlaceOrder(b *testing.B) {
+	for _, depth := range benchDepths {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			engine, _ := benchBook(b, depth)
+			rng := rand.New(rand.NewPCG(1, uint64(depth)))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				order := benchOrder("event-bench", models.OrderSideBack, levelPrice(models.OrderSideBack, rng.IntN(levels(depth))), 100)
+				b.StartTimer()
+
+				if _, err := engine.PlaceOrder(order); err != nil {
+					b.Fatal(err)
+				}
+
+				// Keep the book at depth
+				b.StopTimer()
+				if err := engine.CancelOrder(order.ID); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// BenchmarkEngine_CancelOrder cancels a resting order anywhere in the book
+func BenchmarkEngine_CancelOrder(b *testing.B) {
+	for _, depth := range benchDepths {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			engine, resting := benchBook(b, depth)
+			rng := rand.New(rand.NewPCG(2, uint64(depth)))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				n := rng.IntN(len(resting))
+				order := resting[n]
+				b.StartTimer()
+
+				if err := engine.CancelOrder(order.ID); err != nil {
+					b.Fatal(err)
+				}
+
+				// Put an order back on the same level to keep the book at depth
+				b.StopTimer()
+				replacement := benchOrder("event-bench", order.Side, order.Price, 100)
+				if _, err := engine.PlaceOrder(replacement); err != nil {
+					b.Fatal(err)
+				}
+				resting[n] = replacement
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// BenchmarkEngine_MatchOrder places a back order that fills the first lay order at the best price
+func BenchmarkEngine_MatchOrder(b *testing.B) {
+	for _, depth := range benchDepths {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			engine, _ := benchBook(b, depth)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				order := benchOrder("event-bench", models.OrderSideBack, bestLay, 100)
+				b.StartTimer()
+
+				matches, err := engine.PlaceOrder(order)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(matches) != 1 {
+					b.Fatalf("got %d matches, want 1", len(matches))
+				}
+
+				// Replace the filled lay order at the back of its queue
+				b.StopTimer()
+				if _, err := engine.PlaceOrder(benchOrder("event-bench", models.OrderSideLay, bestLay, 100)); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// BenchmarkEngine_SweepOrder places a back order that fills every order of the ten best lay levels
+func BenchmarkEngine_SweepOrder(b *testing.B) {
+	const sweptLevels = 10
+
+	for _, depth := range benchDepths {
+		if levels(depth) < sweptLevels {
+			continue
+		}
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			engine, _ := benchBook(b, depth)
+			perLevel := depth / levels(depth)
+			size := int64(sweptLevels * perLevel * 100)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				order := benchOrder("event-bench", models.OrderSideBack, levelPrice(models.OrderSideLay, sweptLevels-1), size)
+				b.StartTimer()
+
+				matches, err := engine.PlaceOrder(order)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(matches) != sweptLevels*perLevel {
+					b.Fatalf("got %d matches, want %d", len(matches), sweptLevels*perLevel)
+				}
+
+				// Refill the swept levels
+				b.StopTimer()
+				for level := 0; level < sweptLevels; level++ {
+					for n := 0; n < perLevel; n++ {
+						refill := benchOrder("event-bench", models.OrderSideLay, levelPrice(models.OrderSideLay, level), 100)
+						if _, err := engine.PlaceOrder(refill); err != nil {
+							b.Fatal(err)
+						}
+					}
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// BenchmarkSequencer_PlaceOrder runs placements through a market's goroutine
+// Every other order matches the one before it, so the book stays small
+func BenchmarkSequencer_PlaceOrder(b *testing.B) {
+	b.Run("memory", func(b *testing.B) {
+		benchSequencerPlace(b, matchingengine.NewSequencer())
+	})
+	b.Run("journal", func(b *testing.B) {
+		journal, err := matchingengine.OpenJournal(b.TempDir(), matchingengine.JournalOptions{SegmentSize: 64 << 20})
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer journal.Close()
+		benchSequencerPlace(b, matchingengine.NewSequencer(matchingengine.WithJournal(journal)))
+	})
+}
+
+func benchSequencerPlace(b *testing.B, sequencer *matchingengine.Sequencer) {
+	defer sequencer.Close()
+	ctx := context.Background()
+	price := decimal.RequireFromString("2.5")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		side := models.OrderSideLay
+		if i%2 == 1 {
+			side = models.OrderSideBack
+		}
+		if _, err := sequencer.PlaceOrder(ctx, benchOrder("event-bench", side, price, 100), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSequencer_PlaceOrderParallel places orders on many markets at once
+// Markets run on their own goroutines, so throughput should scale with cores
+func BenchmarkSequencer_PlaceOrderParallel(b *testing.B) {
+	sequencer := matchingengine.NewSequencer()
+	defer sequencer.Close()
+	ctx := context.Background()
+	price := decimal.RequireFromString("2.5")
+
+	var workers atomic.Uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		marketID := fmt.Sprintf("event-bench-%d", workers.Add(1))
+		for i := 0; pb.Next(); i++ {
+			side := models.OrderSideLay
+			if i%2 == 1 {
+				side = models.OrderSideBack
+			}
+			if _, err := sequencer.PlaceOrder(ctx, benchOrder(marketID, side, price, 100), nil); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package matchingengine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// A market moves between sequencers in three steps: the holder freezes it, which
+// refuses its commands and captures its state, the new holder adopts that state,
+// and the old holder drops it. Until it is dropped a frozen market can be thawed
+// or frozen again, which captures the same state
+
+// Freeze stops a market from taking commands and returns its state
+// Commands already queued run first; later ones fail with models.ErrNotMarketOwner.
+// Reads still work. Freezing is not journaled, a recovered market is not frozen
+func (s *Sequencer) Freeze(ctx context.Context, marketID string) (*MarketSnapshot, error) {
+	var snapshot *MarketSnapshot
+	_, err := s.submit(ctx, &command{
+		ctx:      ctx,
+		marketID: marketID,
+		apply: func(c *commandTxn) error {
+			c.market.frozen = true
+			snapshot = c.market.snapshot()
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("freeze market %s: %w", marketID, err)
+	}
+	return snapshot, nil
+}
+
+// Thaw lets a frozen market take commands again
+func (s *Sequencer) Thaw(ctx context.Context, marketID string) error {
+	_, err := s.submit(ctx, &command{
+		ctx:      ctx,
+		marketID: marketID,
+		apply: func(c *commandTxn) error {
+			c.market.frozen = false
+			return nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("thaw market %s: %w", marketID, err)
+	}
+	return nil
+}
+
+// Adopt replaces a market with the state another sequencer froze it in
+// The market takes commands afterwards. Adoption is not journaled, so a snapshot
+// must be saved before the market's commands can be recovered
+func (s *Sequencer) Adopt(ctx context.Context, snapshot *MarketSnapshot) error {
+	_, err := s.submit(ctx, &command{
+		ctx:      ctx,
+		marketID: snapshot.MarketID,
+		apply: func(c *commandTxn) error {
+			c.market.restore(snapshot)
+			c.market.frozen = false
+			return nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("adopt market %s: %w", snapshot.MarketID, err)
+	}
+	return nil
+}
+
+// Drop empties a market that another sequencer has adopted
+// The market stays frozen, its commands keep failing with models.ErrNotMarketOwner
+func (s *Sequencer) Drop(ctx context.Context, marketID string) error {
+	_, err := s.submit(ctx, &command{
+		ctx:      ctx,
+		marketID: marketID,
+		apply: func(c *commandTxn) error {
+			c.market.reset()
+			c.market.frozen = true
+			return nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("drop market %s: %w", marketID, err)
+	}
+	return nil
+}
+
+// Markets returns the IDs of the markets the sequencer has state for, sorted
+func (s *Sequencer) Markets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.markets))
+	for id := range s.markets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,859 @@
+package matchingengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+)
+
+// Journal errors
+var (
+	ErrJournalClosed  = errors.New("journal closed")
+	ErrJournalCorrupt = errors.New("journal corrupt")
+
+	// ErrPositionUnavailable is returned when following a journal from a position it
+	// no longer has, or does not have yet
+	ErrPositionUnavailable = errors.New("journal position not available")
+)
+
+// CommandType identifies a journaled sequencer command
+type CommandType uint8
+
+// Command types
+const (
+	CommandPlace CommandType = iota + 1
+	CommandCancel
+	CommandAmend
+	CommandMarketStatus
+	CommandRemoveMarketOrders
+	CommandReinstate
+
+	// CommandAbort marks the command journaled with Sequence as undone because it
+	// could not be persisted; its sequence numbers are given to the next command
+	CommandAbort
+)
+
+// String returns the name of the command type
+func (t CommandType) String() string {
+	switch t {
+	case CommandPlace:
+		return "place"
+	case CommandCancel:
+		return "cancel"
+	case CommandAmend:
+		return "amend"
+	case CommandMarketStatus:
+		return "market_status"
+	case CommandRemoveMarketOrders:
+		return "remove_market_orders"
+	case CommandReinstate:
+		return "reinstate"
+	case CommandAbort:
+		return "abort"
+	default:
+		return fmt.Sprintf("command(%d)", uint8(t))
+	}
+}
+
+// commandVersion is written first in every record so the encoding can evolve
+const commandVersion = 1
+
+// Command is a sequencer command as recorded in the journal
+// Replaying the commands of a market in order rebuilds its book
+type Command struct {
+	Type     CommandType
+	MarketID string
+	Sequence int64     // Last sequence number the command gave out
+	Time     time.Time // When the sequencer applied the command
+
+	Order   *models.Order       // Place and reinstate, the order as submitted
+	OrderID uuid.UUID           // Cancel and amend
+	Price   decimal.Decimal     // Amend
+	Size    decimal.Decimal     // Amend
+	Status  models.MarketStatus // Market status
+
+	// Position is the command's index in the journal, set when read or appended
+	Position int64
+}
+
+// clone copies the command and its order so later changes to the order are not recorded
+func (c *Command) clone() *Command {
+	cloned := *c
+	if c.Order != nil {
+		order := *c.Order
+		cloned.Order = &order
+	}
+	return &cloned
+}
+
+func (c *Command) encode() []byte {
+	e := &encoder{}
+	e.uint8(commandVersion)
+	e.uint8(uint8(c.Type))
+	e.string(c.MarketID)
+	e.int64(c.Sequence)
+	e.time(c.Time)
+	e.bool(c.Order != nil)
+	if c.Order != nil {
+		e.order(c.Order)
+	}
+	e.uuid(c.OrderID)
+	e.decimal(c.Price)
+	e.decimal(c.Size)
+	e.string(string(c.Status))
+	return e.buf
+}
+
+func decodeCommand(data []byte) (*Command, error) {
+	d := &decoder{buf: data}
+	if version := d.uint8(); d.err == nil && version != commandVersion {
+		return nil, fmt.Errorf("unsupported command version %d", version)
+	}
+
+	c := &Command{
+		Type:     CommandType(d.uint8()),
+		MarketID: d.string(),
+		Sequence: d.int64(),
+		Time:     d.time(),
+	}
+	if d.bool() {
+		c.Order = d.order()
+	}
+	c.OrderID = d.uuid()
+	c.Price = d.decimal()
+	c.Size = d.decimal()
+	c.Status = models.MarketStatus(d.string())
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return c, nil
+}
+
+// JournalOptions tunes how a journal writes
+type JournalOptions struct {
+	// SegmentSize is the size in bytes after which appends start a new segment file
+	SegmentSize int64
+
+	// SyncDelay is how long a flush waits for more appends before it writes and fsyncs
+	// Zero flushes as soon as an append arrives, appends that arrive during a flush
+	// still share the next fsync
+	SyncDelay time.Duration
+}
+
+// DefaultJournalOptions returns the options used for zero fields
+func DefaultJournalOptions() JournalOptions {
+	return JournalOptions{
+		SegmentSize: 64 << 20,
+	}
+}
+
+// Journal is an append-only log of sequencer commands
+// Records are framed as length, CRC-32C and payload, and written to segment files
+// named after the position of their first record. Appends are batched: each waits
+// until the fsync that covers it, so one fsync serves every market that appended
+// in the meantime
+type Journal struct {
+	dir  string
+	id   uuid.UUID
+	opts JournalOptions
+
+	mu       sync.Mutex
+	pending  []*journalWrite
+	err      error // first write failure, nothing is accepted after it
+	closed   bool
+	position int64         // position after the last fsynced record
+	flushed  chan struct{} // closed and replaced whenever position moves
+
+	wake    chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+
+	// Owned by the flush goroutine once the journal is open
+	file *os.File
+	size int64 // bytes in the current segment
+	next int64 // position of the next record
+}
+
+type journalWrite struct {
+	frame    []byte
+	position int64 // set when written
+	done     chan error
+}
+
+// journalFrameHeader is the length and checksum before each record
+const journalFrameHeader = 8
+
+// maxFrameSize bounds a record's payload, commands are far smaller
+// A larger length in a header can only come from a damaged record
+const maxFrameSize = 1 << 20
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// OpenJournal opens the journal in dir for appending, creating it if needed
+// A record torn by a crash at the end of the last segment is cut off
+func OpenJournal(dir string, opts JournalOptions) (*Journal, error) {
+	defaults := DefaultJournalOptions()
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = defaults.SegmentSize
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create journal directory: %w", err)
+	}
+
+	id, err := loadJournalID(dir, true)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Journal{
+		dir:     dir,
+		id:      id,
+		opts:    opts,
+		flushed: make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		if err := j.createSegment(0); err != nil {
+			return nil, err
+		}
+	} else if err := j.openLastSegment(segments[len(segments)-1]); err != nil {
+		return nil, err
+	}
+
+	j.position = j.next
+
+	go j.flushLoop()
+	return j, nil
+}
+
+// Append records a command and returns once it is fsynced
+// The command's Position is set to where it was written
+func (j *Journal) Append(cmd *Command) error {
+	payload := cmd.encode()
+	if len(payload) > maxFrameSize {
+		return fmt.Errorf("journal record of %d bytes exceeds %d", len(payload), maxFrameSize)
+	}
+	write := &journalWrite{frame: encodeFrame(payload), done: make(chan error, 1)}
+
+	j.mu.Lock()
+	switch {
+	case j.closed:
+		j.mu.Unlock()
+		return ErrJournalClosed
+	case j.err != nil:
+		err := j.err
+		j.mu.Unlock()
+		return err
+	}
+	j.pending = append(j.pending, write)
+	j.mu.Unlock()
+
+	select {
+	case j.wake <- struct{}{}:
+	default:
+	}
+	if err := <-write.done; err != nil {
+		return err
+	}
+	cmd.Position = write.position
+	return nil
+}
+
+// encodeFrame prefixes a record with its length and checksum
+func encodeFrame(payload []byte) []byte {
+	frame := make([]byte, journalFrameHeader, journalFrameHeader+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	return append(frame, payload...)
+}
+
+// Close flushes pending appends and closes the journal
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	if j.closed {
+		j.mu.Unlock()
+		return nil
+	}
+	j.closed = true
+	j.mu.Unlock()
+
+	close(j.stop)
+	<-j.stopped
+	return j.file.Close()
+}
+
+// Dir returns the directory of the journal
+func (j *Journal) Dir() string {
+	return j.dir
+}
+
+// ID returns the random ID the journal was created with
+// It changes when the directory is lost, together with the sequence numbers the
+// journal restores, which makes it a namespace for names derived from them
+func (j *Journal) ID() uuid.UUID {
+	return j.id
+}
+
+// ReadJournalID returns the ID of the journal in dir
+func ReadJournalID(dir string) (uuid.UUID, error) {
+	return loadJournalID(dir, false)
+}
+
+const journalIDFile = "journal.id"
+
+// loadJournalID reads the journal's ID, creating it first if create is set
+func loadJournalID(dir string, create bool) (uuid.UUID, error) {
+	path := filepath.Join(dir, journalIDFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		id, err := uuid.ParseBytes(bytes.TrimSpace(data))
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("%w: %s: %v", ErrJournalCorrupt, journalIDFile, err)
+		}
+		return id, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) || !create {
+		return uuid.Nil, fmt.Errorf("read journal id: %w", err)
+	}
+
+	id := uuid.New()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(id.String()+"\n"), 0o644); err != nil {
+		return uuid.Nil, fmt.Errorf("write journal id: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return uuid.Nil, fmt.Errorf("write journal id: %w", err)
+	}
+	return id, syncDir(dir)
+}
+
+// Position returns the position the next record will be written at
+// Every record before it has been fsynced
+func (j *Journal) Position() int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.position
+}
+
+// watch returns the fsynced position and a channel closed once it moves on
+func (j *Journal) watch() (int64, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.position, j.flushed
+}
+
+// Follow calls fn for every record from position on, abort records included, and
+// then waits for more. Only fsynced records are read. It returns when ctx is done,
+// the journal closes or fn fails, and ErrPositionUnavailable if position has been
+// truncated away or is past the end of the journal
+func (j *Journal) Follow(ctx context.Context, position int64, fn func(*Command) error) error {
+	if err := j.Available(position); err != nil {
+		return err
+	}
+
+	cursor, err := openJournalCursor(j.dir, position)
+	if err != nil {
+		return err
+	}
+	defer cursor.close()
+
+	for {
+		end, flushed := j.watch()
+		for cursor.next < end {
+			cmd, err := cursor.read()
+			if err != nil {
+				return err
+			}
+			if err := fn(cmd); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-flushed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-j.stopped:
+			return ErrJournalClosed
+		}
+	}
+}
+
+// Available returns ErrPositionUnavailable unless the journal can be followed from position
+func (j *Journal) Available(position int64) error {
+	if end := j.Position(); position > end {
+		return fmt.Errorf("%w: position %d is past the end of the journal at %d", ErrPositionUnavailable, position, end)
+	}
+
+	segments, err := listSegments(j.dir)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 || segments[0].position > position {
+		return fmt.Errorf("%w: position %d has been truncated", ErrPositionUnavailable, position)
+	}
+	return nil
+}
+
+// journalCursor reads a journal record by record across its segments
+// It only reads records known to be fsynced, so it never meets a torn one
+type journalCursor struct {
+	dir  string
+	file *os.File
+	next int64 // position of the next record
+}
+
+// openJournalCursor opens the segment holding position and skips to it
+func openJournalCursor(dir string, position int64) (*journalCursor, error) {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segment *journalSegment
+	for i := range segments {
+		if segments[i].position <= position {
+			segment = &segments[i]
+		}
+	}
+	if segment == nil {
+		return nil, fmt.Errorf("%w: position %d has been truncated", ErrPositionUnavailable, position)
+	}
+
+	c := &journalCursor{dir: dir}
+	if err := c.open(*segment); err != nil {
+		return nil, err
+	}
+	for c.next < position {
+		if _, err := c.read(); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *journalCursor) open(segment journalSegment) error {
+	file, err := os.Open(segment.path)
+	if err != nil {
+		return fmt.Errorf("open journal segment: %w", err)
+	}
+	c.close()
+	c.file = file
+	c.next = segment.position
+	return nil
+}
+
+// read returns the next record, moving to the next segment at the end of one
+func (c *journalCursor) read() (*Command, error) {
+	payload, err := readFrame(c.file)
+	if err == io.EOF {
+		// The record is fsynced, so it starts the next segment
+		if err := c.open(journalSegment{path: filepath.Join(c.dir, segmentName(c.next)), position: c.next}); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPositionUnavailable, err)
+		}
+		payload, err = readFrame(c.file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: position %d: %v", ErrJournalCorrupt, c.next, err)
+	}
+
+	cmd, err := decodeCommand(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: position %d: %v", ErrJournalCorrupt, c.next, err)
+	}
+	cmd.Position = c.next
+	c.next++
+	return cmd, nil
+}
+
+func (c *journalCursor) close() {
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+}
+
+// Truncate removes the segments whose records all come before position
+// The segment being written is always kept. Returns the number of segments removed
+func (j *Journal) Truncate(position int64) (int, error) {
+	segments, err := listSegments(j.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i := 0; i+1 < len(segments) && segments[i+1].position <= position; i++ {
+		if err := os.Remove(segments[i].path); err != nil {
+			return removed, fmt.Errorf("remove journal segment: %w", err)
+		}
+		removed++
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, syncDir(j.dir)
+}
+
+// flushLoop writes and fsyncs pending appends in batches until the journal closes
+func (j *Journal) flushLoop() {
+	defer close(j.stopped)
+
+	for {
+		select {
+		case <-j.wake:
+		case <-j.stop:
+			j.flush()
+			return
+		}
+
+		if j.opts.SyncDelay > 0 {
+			select {
+			case <-time.After(j.opts.SyncDelay):
+			case <-j.stop:
+			}
+		}
+		j.flush()
+	}
+}
+
+// flush writes the pending appends with a single fsync and reports the result to each
+func (j *Journal) flush() {
+	j.mu.Lock()
+	batch := j.pending
+	j.pending = nil
+	failed := j.err
+	j.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	err := failed
+	if err == nil {
+		err = j.write(batch)
+		if err != nil {
+			err = fmt.Errorf("write journal: %w", err)
+			j.mu.Lock()
+			j.err = err
+			j.mu.Unlock()
+		} else {
+			j.mu.Lock()
+			j.position = j.next
+			close(j.flushed)
+			j.flushed = make(chan struct{})
+			j.mu.Unlock()
+		}
+	}
+
+	for _, write := range batch {
+		write.done <- err
+	}
+}
+
+func (j *Journal) write(batch []*journalWrite) error {
+	for _, write := range batch {
+		if j.size > 0 && j.size+int64(len(write.frame)) > j.opts.SegmentSize {
+			if err := j.rotate(); err != nil {
+				return err
+			}
+		}
+		if _, err := j.file.Write(write.frame); err != nil {
+			return err
+		}
+		j.size += int64(len(write.frame))
+		write.position = j.next
+		j.next++
+	}
+	return j.file.Sync()
+}
+
+// rotate closes the current segment and starts one at the next position
+func (j *Journal) rotate() error {
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	return j.createSegment(j.next)
+}
+
+func (j *Journal) createSegment(position int64) error {
+	file, err := os.OpenFile(filepath.Join(j.dir, segmentName(position)), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("create journal segment: %w", err)
+	}
+	if err := syncDir(j.dir); err != nil {
+		file.Close()
+		return err
+	}
+
+	j.file = file
+	j.size = 0
+	j.next = position
+	return nil
+}
+
+// openLastSegment opens the newest segment for appending after its last whole record
+func (j *Journal) openLastSegment(segment journalSegment) error {
+	file, err := os.OpenFile(segment.path, os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("open journal segment: %w", err)
+	}
+
+	records, valid, err := scanSegment(file, nil)
+	if err != nil && !errors.Is(err, errTornRecord) {
+		file.Close()
+		return err
+	}
+
+	// Cut off a torn record so appends continue from the last whole one
+	if err := file.Truncate(valid); err != nil {
+		file.Close()
+		return fmt.Errorf("truncate journal segment: %w", err)
+	}
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return fmt.Errorf("seek journal segment: %w", err)
+	}
+
+	j.file = file
+	j.size = valid
+	j.next = segment.position + records
+	return nil
+}
+
+// ReadJournal calls fn for every command in the journal from position on, in journal order
+// Aborted commands are skipped, so each market's commands arrive in sequence order.
+// A torn record at the end of the last segment ends the journal, a damaged record
+// anywhere else, or a journal truncated past position, returns ErrJournalCorrupt
+func ReadJournal(dir string, position int64, fn func(*Command) error) error {
+	return readJournal(dir, position, func(cmd *Command, _ bool) error {
+		return fn(cmd)
+	})
+}
+
+// readJournal is ReadJournal telling fn which commands are in doubt
+// A market's last command has no later record showing it was not aborted, the
+// sequencer that journaled it may have stopped before persisting it
+func readJournal(dir string, position int64, fn func(cmd *Command, inDoubt bool) error) error {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return err
+	}
+	if len(segments) > 0 && segments[0].position > position {
+		return fmt.Errorf("%w: journal starts at position %d, after %d", ErrJournalCorrupt, segments[0].position, position)
+	}
+
+	filter := newAbortFilter()
+	release := func(cmd *Command, inDoubt bool) error {
+		if cmd == nil || cmd.Position < position {
+			return nil
+		}
+		return fn(cmd, inDoubt)
+	}
+
+	for i, segment := range segments {
+		// Skip segments that end before position
+		if i+1 < len(segments) && segments[i+1].position <= position {
+			continue
+		}
+
+		file, err := os.Open(segment.path)
+		if err != nil {
+			return fmt.Errorf("open journal segment: %w", err)
+		}
+
+		next := segment.position
+		_, _, err = scanSegment(file, func(payload []byte) error {
+			cmd, err := decodeCommand(payload)
+			if err != nil {
+				return fmt.Errorf("%w: position %d: %v", ErrJournalCorrupt, next, err)
+			}
+			cmd.Position = next
+			next++
+			return release(filter.add(cmd), false)
+		})
+		file.Close()
+
+		if errors.Is(err, errTornRecord) {
+			if i+1 < len(segments) {
+				return fmt.Errorf("%w: segment %s", ErrJournalCorrupt, filepath.Base(segment.path))
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Deliver what is left in journal order
+	for _, cmd := range filter.rest() {
+		if err := release(cmd, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// abortFilter drops aborted commands from a stream of journal records
+// A market's command is held back until its next record shows it was not aborted
+type abortFilter struct {
+	held map[string]*Command
+}
+
+func newAbortFilter() *abortFilter {
+	return &abortFilter{held: make(map[string]*Command)}
+}
+
+// add takes the next record and returns the command it releases, nil if none
+func (f *abortFilter) add(cmd *Command) *Command {
+	held := f.held[cmd.MarketID]
+	if cmd.Type == CommandAbort {
+		if held != nil && held.Sequence == cmd.Sequence {
+			delete(f.held, cmd.MarketID)
+		}
+		return nil
+	}
+	f.held[cmd.MarketID] = cmd
+	return held
+}
+
+// rest releases the commands still held, in journal order
+func (f *abortFilter) rest() []*Command {
+	rest := make([]*Command, 0, len(f.held))
+	for _, cmd := range f.held {
+		rest = append(rest, cmd)
+	}
+	sort.Slice(rest, func(a, b int) bool { return rest[a].Position < rest[b].Position })
+	f.held = make(map[string]*Command)
+	return rest
+}
+
+// errTornRecord marks a record cut short or failing its checksum
+var errTornRecord = errors.New("torn journal record")
+
+// scanSegment calls fn with the payload of each whole record in a segment
+// Returns the number of whole records and the offset after the last one
+func scanSegment(r io.Reader, fn func(payload []byte) error) (int64, int64, error) {
+	var records, offset int64
+	for {
+		payload, err := readFrame(r)
+		if err == io.EOF {
+			return records, offset, nil
+		}
+		if err != nil {
+			return records, offset, err
+		}
+
+		if fn != nil {
+			if err := fn(payload); err != nil {
+				return records, offset, err
+			}
+		}
+		records++
+		offset += journalFrameHeader + int64(len(payload))
+	}
+}
+
+// readFrame reads one record and checks it against its checksum
+// Returns io.EOF at the end of r and errTornRecord for a partial or damaged record
+func readFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, journalFrameHeader)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, errTornRecord
+	}
+
+	length := binary.LittleEndian.Uint32(header[0:4])
+	checksum := binary.LittleEndian.Uint32(header[4:8])
+	if length > maxFrameSize {
+		return nil, errTornRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errTornRecord
+	}
+	if crc32.Checksum(payload, crcTable) != checksum {
+		return nil, errTornRecord
+	}
+	return payload, nil
+}
+
+// journalSegment is a segment file and the position of its first record
+type journalSegment struct {
+	path     string
+	position int64
+}
+
+const segmentSuffix = ".journal"
+
+func segmentName(position int64) string {
+	return fmt.Sprintf("%020d%s", position, segmentSuffix)
+}
+
+// listSegments returns the segments in dir ordered by position
+func listSegments(dir string) ([]journalSegment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read journal directory: %w", err)
+	}
+
+	var segments []journalSegment
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		position, err := strconv.ParseInt(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, journalSegment{path: filepath.Join(dir, name), position: position})
+	}
+
+	sort.Slice(segments, func(a, b int) bool { return segments[a].position < segments[b].position })
+	return segments, nil
+}
+
+// syncDir fsyncs a directory so created and removed files survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open journal directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync journal directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+package matchingengine
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+)
+
+// statusCommand is a small journal record for market, stamped with sequence
+func statusCommand(market string, sequence int64) *Command {
+	return &Command{
+		Type:     CommandMarketStatus,
+		MarketID: market,
+		Sequence: sequence,
+		Time:     time.Unix(0, sequence).UTC(),
+		Status:   models.MarketStatusSuspended,
+	}
+}
+
+// appendCommands appends commands and returns their positions
+func appendCommands(t *testing.T, journal *Journal, cmds ...*Command) []int64 {
+	t.Helper()
+	positions := make([]int64, 0, len(cmds))
+	for _, cmd := range cmds {
+		require.NoError(t, journal.Append(cmd))
+		positions = append(positions, cmd.Position)
+	}
+	return positions
+}
+
+// readAll reads the journal in dir from position on
+func readAll(t *testing.T, dir string, position int64) []*Command {
+	t.Helper()
+	var cmds []*Command
+	require.NoError(t, ReadJournal(dir, position, func(cmd *Command) error {
+		cmds = append(cmds, cmd)
+		return nil
+	}))
+	return cmds
+}
+
+// sequences returns the market and sequence of each command
+func sequences(cmds []*Command) []string {
+	out := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		out = append(out, fmt.Sprintf("%s:%d", cmd.MarketID, cmd.Sequence))
+	}
+	return out
+}
+
+func TestJournal_RotatesSegments(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir, JournalOptions{SegmentSize: 128})
+	require.NoError(t, err)
+
+	var cmds []*Command
+	for i := int64(1); i <= 20; i++ {
+		cmds = append(cmds, statusCommand("market-1", i))
+	}
+	positions := appendCommands(t, journal, cmds...)
+	require.NoError(t, journal.Close())
+
+	for i, position := range positions {
+		assert.Equal(t, int64(i), position)
+	}
+
+	// Every segment is named after its first record and stays within the size
+	segments, err := listSegments(dir)
+	require.NoError(t, err)
+	require.Greater(t, len(segments), 1)
+	assert.Equal(t, int64(0), segments[0].position)
+	for i, segment := range segments {
+		info, err := os.Stat(segment.path)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(128))
+		if i > 0 {
+			assert.Greater(t, segment.position, segments[i-1].position)
+		}
+	}
+
+	// Reading crosses the segments in order
+	read := readAll(t, dir, 0)
+	require.Len(t, read, 20)
+	for i, cmd := range read {
+		assert.Equal(t, int64(i), cmd.Position)
+		assert.Equal(t, int64(i+1), cmd.Sequence)
+	}
+
+	// Reopening appends after the last record
+	journal, err = OpenJournal(dir, JournalOptions{SegmentSize: 128})
+	require.NoError(t, err)
+	defer journal.Close()
+	assert.Equal(t, int64(20), journal.Position())
+	assert.Equal(t, []int64{20}, appendCommands(t, journal, statusCommand("market-1", 21)))
+}
+
+func TestJournal_CutsOffTornTail(t *testing.T) {
+	tests := []struct {
+		name string
+		tail func(frame []byte) []byte
+	}{
+		{"partial header", func(frame []byte) []byte { return frame[:journalFrameHeader-3] }},
+		{"partial payload", func(frame []byte) []byte { return frame[:len(frame)-2] }},
+		{"bad checksum", func(frame []byte) []byte {
+			damaged := append([]byte(nil), frame...)
+			damaged[len(damaged)-1] ^= 0xff
+			return damaged
+		}},
+		{"oversized length", func(frame []byte) []byte {
+			damaged := append([]byte(nil), frame...)
+			binary.LittleEndian.PutUint32(damaged[0:4], maxFrameSize+1)
+			return damaged
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			journal, err := OpenJournal(dir, JournalOptions{})
+			require.NoError(t, err)
+			appendCommands(t, journal, statusCommand("market-1", 1), statusCommand("market-1", 2))
+			require.NoError(t, journal.Close())
+
+			segments, err := listSegments(dir)
+			require.NoError(t, err)
+			require.Len(t, segments, 1)
+			info, err := os.Stat(segments[0].path)
+			require.NoError(t, err)
+			whole := info.Size()
+
+			// A crash mid-write leaves part of a third record
+			file, err := os.OpenFile(segments[0].path, os.O_WRONLY|os.O_APPEND, 0o644)
+			require.NoError(t, err)
+			_, err = file.Write(tt.tail(encodeFrame(statusCommand("market-1", 3).encode())))
+			require.NoError(t, err)
+			require.NoError(t, file.Close())
+
+			// Reading stops before the torn record
+			assert.Len(t, readAll(t, dir, 0), 2)
+
+			// Opening cuts it off and appends in its place
+			journal, err = OpenJournal(dir, JournalOptions{})
+			require.NoError(t, err)
+			defer journal.Close()
+			info, err = os.Stat(segments[0].path)
+			require.NoError(t, err)
+			assert.Equal(t, whole, info.Size())
+			assert.Equal(t, int64(2), journal.Position())
+
+			assert.Equal(t, []int64{2}, appendCommands(t, journal, statusCommand("market-1", 3)))
+			assert.Len(t, readAll(t, dir, 0), 3)
+		})
+	}
+}
+
+func TestJournal_TornRecordBeforeLastSegmentIsCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir, JournalOptions{SegmentSize: 64})
+	require.NoError(t, err)
+	for i := int64(1); i <= 6; i++ {
+		appendCommands(t, journal, statusCommand("market-1", i))
+	}
+	require.NoError(t, journal.Close())
+
+	segments, err := listSegments(dir)
+	require.NoError(t, err)
+	require.Greater(t, len(segments), 1)
+
+	// Only the last segment may end in a torn record
+	data, err := os.ReadFile(segments[0].path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(segments[0].path, data[:len(data)-1], 0o644))
+
+	err = ReadJournal(dir, 0, func(*Command) error { return nil })
+	assert.ErrorIs(t, err, ErrJournalCorrupt)
+}
+
+func TestReadJournal_FiltersAbortedCommands(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir, JournalOptions{})
+	require.NoError(t, err)
+	defer journal.Close()
+
+	appendCommands(t, journal,
+		statusCommand("market-1", 1),
+		statusCommand("market-1", 2),
+		statusCommand("market-2", 1),
+		// market-1's command 2 was not persisted, the next command reuses its sequence
+		&Command{Type: CommandAbort, MarketID: "market-1", Sequence: 2},
+		statusCommand("market-1", 2),
+		// An abort naming an older sequence leaves the held command alone
+		&Command{Type: CommandAbort, MarketID: "market-2", Sequence: 0},
+		statusCommand("market-1", 3),
+		statusCommand("market-2", 2),
+		&Command{Type: CommandAbort, MarketID: "market-2", Sequence: 2},
+	)
+
+	// A command is released once the market's next record shows it was not aborted
+	read := readAll(t, dir, 0)
+	assert.Equal(t, []string{"market-1:1", "market-1:2", "market-2:1", "market-1:3"}, sequences(read))
+	assert.Equal(t, []int64{0, 4, 2, 6}, []int64{read[0].Position, read[1].Position, read[2].Position, read[3].Position})
+	for _, cmd := range read {
+		assert.NotEqual(t, CommandAbort, cmd.Type)
+	}
+
+	// market-1's last command has no later record vouching for it
+	inDoubt := map[string]bool{}
+	require.NoError(t, readJournal(dir, 0, func(cmd *Command, doubt bool) error {
+		if doubt {
+			inDoubt[sequences([]*Command{cmd})[0]] = true
+		}
+		return nil
+	}))
+	assert.Equal(t, map[string]bool{"market-1:3": true}, inDoubt)
+
+	// Reading from a position skips earlier commands but still filters aborts
+	assert.Equal(t, []string{"market-1:2", "market-1:3"}, sequences(readAll(t, dir, 3)))
+}
+
+func TestJournal_Truncate(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir, JournalOptions{SegmentSize: 64})
+	require.NoError(t, err)
+	defer journal.Close()
+
+	for i := int64(1); i <= 12; i++ {
+		appendCommands(t, journal, statusCommand("market-1", i))
+	}
+	segments, err := listSegments(dir)
+	require.NoError(t, err)
+	require.Greater(t, len(segments), 3)
+
+	// Nothing before the second segment's start is whole, so nothing goes
+	removed, err := journal.Truncate(segments[1].position - 1)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+
+	// Segments wholly before the position go, the one holding it stays
+	keep := segments[2].position
+	removed, err = journal.Truncate(keep)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	left, err := listSegments(dir)
+	require.NoError(t, err)
+	assert.Equal(t, segments[2].position, left[0].position)
+
+	assert.ErrorIs(t, journal.Available(0), ErrPositionUnavailable)
+	assert.NoError(t, journal.Available(keep))
+	assert.ErrorIs(t, ReadJournal(dir, 0, func(*Command) error { return nil }), ErrJournalCorrupt)
+
+	read := readAll(t, dir, keep)
+	require.NotEmpty(t, read)
+	assert.Equal(t, keep, read[0].Position)
+	assert.Equal(t, int64(11), read[len(read)-1].Position)
+
+	// The segment being written is never removed
+	_, err = journal.Truncate(1 << 40)
+	require.NoError(t, err)
+	left, err = listSegments(dir)
+	require.NoError(t, err)
+	require.Len(t, left, 1)
+	assert.Equal(t, segments[len(segments)-1].position, left[0].position)
+	assert.Equal(t, []int64{12}, appendCommands(t, journal, statusCommand("market-1", 13)))
+}
+
+func TestJournal_Follow(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir, JournalOptions{SegmentSize: 64})
+	require.NoError(t, err)
+	defer journal.Close()
+
+	appendCommands(t, journal, statusCommand("market-1", 1), statusCommand("market-1", 2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	followed := make(chan *Command, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- journal.Follow(ctx, 1, func(cmd *Command) error {
+			followed <- cmd
+			return nil
+		})
+	}()
+
+	// Follow sees what is already there, abort records included, then waits for more
+	appendCommands(t, journal,
+		&Command{Type: CommandAbort, MarketID: "market-1", Sequence: 2},
+		statusCommand("market-1", 2),
+		statusCommand("market-1", 3),
+	)
+
+	var got []*Command
+	for len(got) < 4 {
+		select {
+		case cmd := <-followed:
+			got = append(got, cmd)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("followed %d of 4 records", len(got))
+		}
+	}
+	for i, cmd := range got {
+		assert.Equal(t, int64(i+1), cmd.Position)
+	}
+	assert.Equal(t, CommandAbort, got[1].Type)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Follow did not return after cancel")
+	}
+
+	// fn failing ends Follow with its error
+	errStop := errors.New("stop")
+	err = journal.Follow(context.Background(), 0, func(*Command) error { return errStop })
+	assert.ErrorIs(t, err, errStop)
+
+	// Positions past the end cannot be followed
+	err = journal.Follow(context.Background(), journal.Position()+1, func(*Command) error { return nil })
+	assert.ErrorIs(t, err, ErrPositionUnavailable)
+}
+
+func TestJournal_FollowEndsWhenClosed(t *testing.T) {
+	journal, err := OpenJournal(t.TempDir(), JournalOptions{})
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- journal.Follow(context.Background(), 0, func(*Command) error { return nil })
+	}()
+	require.NoError(t, journal.Close())
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrJournalClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Follow did not return after close")
+	}
+	assert.ErrorIs(t, journal.Append(statusCommand("market-1", 1)), ErrJournalClosed)
+}
+
+func TestJournal_RejectsOversizedRecord(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir, JournalOptions{})
+	require.NoError(t, err)
+	defer journal.Close()
+
+	appendCommands(t, journal, statusCommand("market-1", 1))
+
+	oversized := statusCommand(strings.Repeat("m", maxFrameSize), 2)
+	err = journal.Append(oversized)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds")
+
+	// The journal is not failed by it and nothing was written
+	assert.Equal(t, int64(1), journal.Position())
+	assert.Equal(t, []int64{1}, appendCommands(t, journal, statusCommand("market-1", 2)))
+
+	segments, err := listSegments(dir)
+	require.NoError(t, err)
+	info, err := os.Stat(segments[0].path)
+	require.NoError(t, err)
+	assert.Less(t, info.Size(), int64(maxFrameSize))
+}
@@ -0,0 +1,76 @@
+package matchingengine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+)
+
+// MarketReplayer rebuilds one market by applying its commands to fresh engines
+// It runs the sequencer's command logic on the caller's goroutine, without a
+// journal or persistence, so tools can step through a market's history and
+// inspect the events and book after every command
+type MarketReplayer struct {
+	market *market
+	clock  Clock
+}
+
+// NewMarketReplayer creates a replayer for an open market with no orders
+// Pass the options of the sequencer that recorded the history; commands are
+// applied at their journaled time and only WithMatchIDs and WithCommandClock,
+// for commands without a time, are used
+func NewMarketReplayer(marketID string, opts ...SequencerOption) *MarketReplayer {
+	s := &Sequencer{clock: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return &MarketReplayer{market: newMarket(marketID, s.matchIDs), clock: s.clock}
+}
+
+// Apply applies a command and returns its events in sequence order
+// A command that fails leaves the market as it was. Command.Sequence is not
+// checked, compare it with the batch to detect a diverging history
+func (r *MarketReplayer) Apply(cmd *Command) (*Batch, error) {
+	if cmd.MarketID != r.market.id {
+		return nil, fmt.Errorf("command for market %s replayed on %s", cmd.MarketID, r.market.id)
+	}
+
+	at := cmd.Time
+	if at.IsZero() {
+		at = r.clock()
+	}
+
+	c := r.market.begin(at)
+	result := c.finish(c.applyCommand(cmd.clone()))
+	return result.batch, result.err
+}
+
+// MarketID returns the market being replayed
+func (r *MarketReplayer) MarketID() string {
+	return r.market.id
+}
+
+// Sequence returns the last sequence number given out
+func (r *MarketReplayer) Sequence() int64 {
+	return r.market.sequence
+}
+
+// Status returns the trading state of the market
+func (r *MarketReplayer) Status() models.MarketStatus {
+	return r.market.status
+}
+
+// Books returns the book of every selection the market has seen, ordered by selection
+func (r *MarketReplayer) Books() []*models.MarketBook {
+	books := make([]*models.MarketBook, 0, len(r.market.engines))
+	for _, selectionID := range r.market.selections() {
+		books = append(books, r.market.engines[selectionID].GetMarketBook())
+	}
+	return books
+}
+
+// Snapshot captures the market, see Sequencer.Snapshot
+func (r *MarketReplayer) Snapshot() *MarketSnapshot {
+	return r.market.snapshot()
+}
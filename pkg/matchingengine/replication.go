@@ -0,0 +1,266 @@
+package matchingengine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Replication stream message kinds
+const (
+	streamCommand   = 1
+	streamHeartbeat = 2
+)
+
+// StreamMessage is a message of a leader's replication stream
+// The stream carries the leader's journal records in order, abort records
+// included, with heartbeats in between while the journal is idle
+type StreamMessage struct {
+	Command *Command // A journal record, nil for a heartbeat
+
+	// Position of the record, or for a heartbeat the position after the leader's
+	// last fsynced record
+	Position int64
+
+	// Attached is set on heartbeats while the leader's commands wait for standby
+	// acknowledgements before they persist, see ReplicaAcks
+	Attached bool
+}
+
+// WriteStreamMessage writes a message framed like a journal record
+func WriteStreamMessage(w io.Writer, msg *StreamMessage) error {
+	e := &encoder{}
+	if msg.Command != nil {
+		e.uint8(streamCommand)
+		e.int64(msg.Command.Position)
+		e.buf = append(e.buf, msg.Command.encode()...)
+	} else {
+		e.uint8(streamHeartbeat)
+		e.int64(msg.Position)
+		e.bool(msg.Attached)
+	}
+	_, err := w.Write(encodeFrame(e.buf))
+	return err
+}
+
+// ReadStreamMessage reads a message written by WriteStreamMessage
+// Returns io.EOF at the end of the stream
+func ReadStreamMessage(r io.Reader) (*StreamMessage, error) {
+	payload, err := readFrame(r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read replication stream: %w", err)
+	}
+
+	d := &decoder{buf: payload}
+	kind := d.uint8()
+	msg := &StreamMessage{Position: d.int64()}
+	if d.err != nil {
+		return nil, fmt.Errorf("read replication stream: %w", d.err)
+	}
+
+	switch kind {
+	case streamHeartbeat:
+		msg.Attached = d.bool()
+		if d.err != nil {
+			return nil, fmt.Errorf("read replication stream: %w", d.err)
+		}
+	case streamCommand:
+		cmd, err := decodeCommand(d.buf)
+		if err != nil {
+			return nil, fmt.Errorf("read replication stream: position %d: %w", msg.Position, err)
+		}
+		cmd.Position = msg.Position
+		msg.Command = cmd
+	default:
+		return nil, fmt.Errorf("read replication stream: unknown message kind %d", kind)
+	}
+	return msg, nil
+}
+
+// Standby keeps a standby sequencer in step with the leader's replication stream
+// Records are applied in journal order, and a market's command only once the
+// market's next record shows the leader did not abort it, as ReadJournal does.
+// Not safe for concurrent use
+type Standby struct {
+	sequencer *Sequencer
+	filter    *abortFilter
+	position  int64 // leader position of the next record
+	leader    int64 // leader's fsynced position at the last heartbeat
+	attached  bool  // leader waited for acknowledgements at the last heartbeat
+}
+
+// NewStandby creates a standby that applies the leader's commands to sequencer
+// The sequencer should be a standby itself, see WithStandby
+func NewStandby(sequencer *Sequencer) *Standby {
+	return &Standby{sequencer: sequencer, filter: newAbortFilter()}
+}
+
+// Load starts over from a snapshot of the leader
+// The stream is then followed from the snapshot's position
+func (s *Standby) Load(ctx context.Context, snapshot *Snapshot) error {
+	if err := s.sequencer.Load(ctx, snapshot); err != nil {
+		return err
+	}
+	s.filter = newAbortFilter()
+	s.position = snapshot.Position
+	s.leader = snapshot.Position
+	s.attached = false
+	return nil
+}
+
+// Apply applies a message of the leader's stream
+// Records must arrive in order from Position, a gap returns ErrPositionUnavailable
+func (s *Standby) Apply(ctx context.Context, msg *StreamMessage) error {
+	if msg.Command == nil {
+		s.leader = msg.Position
+		s.attached = msg.Attached
+		return nil
+	}
+	if msg.Position != s.position {
+		return fmt.Errorf("%w: stream sent position %d, expected %d", ErrPositionUnavailable, msg.Position, s.position)
+	}
+
+	s.position++
+	if s.position > s.leader {
+		s.leader = s.position
+	}
+
+	released := s.filter.add(msg.Command)
+	if released == nil {
+		return nil
+	}
+	return s.sequencer.Replicate(ctx, released)
+}
+
+// Position returns the leader position of the next record the standby expects
+// Every record before it has been received
+func (s *Standby) Position() int64 {
+	return s.position
+}
+
+// Lag returns how many fsynced records of the leader the standby has not received,
+// as of the leader's last heartbeat
+func (s *Standby) Lag() int64 {
+	return s.leader - s.position
+}
+
+// Synced returns true if the standby held every command the leader may have
+// persisted as of its last heartbeat: the leader waited for acknowledgements and
+// the standby had received its whole journal. A leader that detaches the standby
+// after an acknowledgement timeout clears it with its next heartbeat
+func (s *Standby) Synced() bool {
+	return s.attached && s.Lag() == 0
+}
+
+// Attached returns true if the leader waited for acknowledgements at its last heartbeat
+func (s *Standby) Attached() bool {
+	return s.attached
+}
+
+// Release applies the commands still held back for an abort record
+// The stream has ended for good when a standby takes over. A held command is the
+// last one the old leader journaled for its market, which it may have died before
+// persisting, so it is only applied if the sequencer's committed check says it was
+// committed, see WithCommittedCheck. Returns the number applied
+func (s *Standby) Release(ctx context.Context) (int, error) {
+	released := 0
+	for _, cmd := range s.filter.rest() {
+		committed, err := s.sequencer.decide(ctx, cmd)
+		if err != nil {
+			return released, err
+		}
+		if !committed {
+			// Never journaled here, the new leader's next command takes its sequence numbers
+			continue
+		}
+		if err := s.sequencer.Replicate(ctx, cmd); err != nil {
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+// ReplicaAcks tracks how far standbys have received the leader's journal
+// Journaled commands wait until a standby acknowledges them before they are
+// persisted, so a standby that takes over has every command the leader persisted.
+// A standby that misses the timeout is detached and commands stop waiting for it
+// until it has caught up with the journal again; a missing standby costs a single
+// timeout. With several standbys the most advanced one counts
+type ReplicaAcks struct {
+	journal *Journal
+	timeout time.Duration
+
+	mu       sync.Mutex
+	acked    int64         // standbys hold every record before it
+	attached bool          // commands wait for acknowledgements
+	changed  chan struct{} // closed and replaced whenever acked moves
+}
+
+// NewReplicaAcks creates the acknowledgements for a leader's journal
+// It starts detached, commands wait once a standby has caught up
+func NewReplicaAcks(journal *Journal, timeout time.Duration) *ReplicaAcks {
+	return &ReplicaAcks{
+		journal: journal,
+		timeout: timeout,
+		changed: make(chan struct{}),
+	}
+}
+
+// Acknowledge records that a standby holds every record before position
+func (a *ReplicaAcks) Acknowledge(position int64) {
+	end := a.journal.Position()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if position > a.acked {
+		a.acked = position
+		close(a.changed)
+		a.changed = make(chan struct{})
+	}
+	if !a.attached && position >= end {
+		a.attached = true
+	}
+}
+
+// Attached returns true if commands wait for a standby
+func (a *ReplicaAcks) Attached() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.attached
+}
+
+// wait blocks until a standby holds every record before position, or the timeout
+// passes and detaches it
+func (a *ReplicaAcks) wait(position int64) {
+	a.mu.Lock()
+	if !a.attached || a.acked >= position {
+		a.mu.Unlock()
+		return
+	}
+
+	timer := time.NewTimer(a.timeout)
+	defer timer.Stop()
+
+	for a.acked < position {
+		changed := a.changed
+		a.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			a.mu.Lock()
+			a.attached = false
+			a.mu.Unlock()
+			return
+		}
+		a.mu.Lock()
+	}
+	a.mu.Unlock()
+}
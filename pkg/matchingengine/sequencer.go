@@ -0,0 +1,780 @@
+package matchingengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+)
+
+// Sequencer errors
+var (
+	ErrSequencerClosed = errors.New("sequencer closed")
+	ErrReplayDiverged  = errors.New("replay diverged from journal")
+
+	// errNoMarket is returned for reads of a market the sequencer has no state for
+	errNoMarket = errors.New("market has no state")
+)
+
+// EventType identifies a change the sequencer applied to a market
+type EventType string
+
+// Event types
+const (
+	EventOrderPlaced     EventType = "order_placed"
+	EventMatch           EventType = "match"
+	EventOrderCancelled  EventType = "order_cancelled"
+	EventOrderAmended    EventType = "order_amended"
+	EventOrderRemoved    EventType = "order_removed"
+	EventOrderReinstated EventType = "order_reinstated"
+	EventMarketStatus    EventType = "market_status"
+)
+
+// Event is a change to a market, numbered in the order the sequencer applied it
+type Event struct {
+	Sequence int64
+	Type     EventType
+	MarketID string
+	OrderID  uuid.UUID           // Order the change is about, unset for market status changes
+	Order    *models.Order       // Copy of the order after the change, nil if it was not on the book
+	Match    *models.Match       // Set for EventMatch
+	Status   models.MarketStatus // Set for EventMarketStatus
+}
+
+// Batch holds the events of one command in sequence order
+type Batch struct {
+	MarketID string
+	Events   []*Event
+}
+
+// First returns the first event of type t, nil if there is none
+func (b *Batch) First(t EventType) *Event {
+	for _, event := range b.Events {
+		if event.Type == t {
+			return event
+		}
+	}
+	return nil
+}
+
+// Matches returns the matches of the batch in sequence order
+func (b *Batch) Matches() []*models.Match {
+	var matches []*models.Match
+	for _, event := range b.Events {
+		if event.Type == EventMatch {
+			matches = append(matches, event.Match)
+		}
+	}
+	return matches
+}
+
+// PersistFunc stores the events of a command
+// It runs on the market's goroutine, so batches are persisted one at a time in
+// sequence order. An error undoes the command and its sequence numbers are given
+// to the next one. It must not submit commands to the sequencer
+type PersistFunc func(ctx context.Context, batch *Batch) error
+
+// CommittedFunc reports whether a journaled command reached the database
+// A command is fsynced to the journal before it is persisted, so a sequencer that
+// stops in between leaves a command without an abort record that was never
+// persisted. Recovery and a standby taking over ask about such in-doubt commands
+// before applying them. Commands without a persist step count as committed
+type CommittedFunc func(ctx context.Context, cmd *Command) (bool, error)
+
+// Sequencer applies the commands of each market on a single goroutine
+// Commands of a market are serialized through its channel, every event they produce
+// gets the next sequence number of the market, and persistence runs in that order
+// Each market keeps an engine per selection, so orders only match within their selection
+type Sequencer struct {
+	mu        sync.Mutex
+	markets   map[string]*market
+	journal   *Journal
+	clock     Clock
+	matchIDs  MatchIDFunc   // nil gives random match IDs
+	acks      *ReplicaAcks  // standbys commands wait for, nil when there are none
+	committed CommittedFunc // decides in-doubt commands, nil applies them all
+	standby   atomic.Bool   // refuses commands that change the book
+	demoted   atomic.Bool   // a standby that was the leader, its journal is the leader's
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// market is the state owned by a market's goroutine
+type market struct {
+	id       string
+	status   models.MarketStatus
+	engines  map[string]*Engine // selection -> engine
+	sequence int64              // last sequence number given out
+	frozen   bool               // handed to another sequencer, refuses commands
+	commands chan *command
+
+	now      time.Time   // time of the current command, the engines' clock
+	matchIDs MatchIDFunc // names matches by sequence number, nil for random IDs
+	issued   int64       // match IDs issued by the current command
+}
+
+// command is a unit of work for a market's goroutine
+type command struct {
+	ctx      context.Context
+	marketID string
+	at       time.Time // time to apply the command at, the sequencer's clock if zero
+	apply    func(c *commandTxn) error
+	persist  PersistFunc
+	record   *Command // journal record, nil for reads and replays
+	write    bool     // submitted by a caller, refused on a standby
+	read     bool     // only runs on a market that exists, never creates one
+	result   chan commandResult
+}
+
+type commandResult struct {
+	batch *Batch
+	err   error
+}
+
+// SequencerOption configures a Sequencer
+type SequencerOption func(*Sequencer)
+
+// WithJournal records every command that changes a book in the journal
+// A command is journaled before it is persisted and answered, so replaying the
+// journal rebuilds the books. Persistence still runs synchronously in the persist
+// step, Journal.Follow lets a consumer drive it from the journal instead
+func WithJournal(journal *Journal) SequencerOption {
+	return func(s *Sequencer) {
+		s.journal = journal
+	}
+}
+
+// WithCommandClock sets the clock commands are stamped with
+// Every time a command sets on orders and matches is the command's time, which is
+// journaled, so replay reproduces them. Defaults to time.Now
+func WithCommandClock(clock Clock) SequencerOption {
+	return func(s *Sequencer) {
+		s.clock = clock
+	}
+}
+
+// WithMatchIDs names matches after their market and sequence number instead of
+// random UUIDs, so replaying a journal reproduces the IDs as well
+// Sequence numbers restart when the books are not recovered from a journal, so
+// only use it together with WithJournal and recovery, in the journal's namespace:
+//
+//	WithMatchIDs(SequenceMatchIDs(journal.ID()))
+func WithMatchIDs(ids MatchIDFunc) SequencerOption {
+	return func(s *Sequencer) {
+		s.matchIDs = ids
+	}
+}
+
+// WithReplicaAcks makes journaled commands wait for a standby to receive them
+// before they are persisted, see ReplicaAcks
+func WithReplicaAcks(acks *ReplicaAcks) SequencerOption {
+	return func(s *Sequencer) {
+		s.acks = acks
+	}
+}
+
+// WithCommittedCheck decides in-doubt commands against the database, see CommittedFunc
+// Without it recovery and promotion apply every in-doubt command
+func WithCommittedCheck(committed CommittedFunc) SequencerOption {
+	return func(s *Sequencer) {
+		s.committed = committed
+	}
+}
+
+// WithStandby starts the sequencer as a standby, see Demote
+func WithStandby() SequencerOption {
+	return func(s *Sequencer) {
+		s.standby.Store(true)
+	}
+}
+
+// NewSequencer creates a sequencer, market goroutines start on their first command
+func NewSequencer(opts ...SequencerOption) *Sequencer {
+	s := &Sequencer{
+		markets: make(map[string]*market),
+		clock:   time.Now,
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Close stops the market goroutines once their current command finishes
+// Commands submitted afterwards fail with ErrSequencerClosed
+func (s *Sequencer) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+	s.wg.Wait()
+}
+
+// PlaceOrder places an order and attempts to match it
+// The batch holds its matches followed by the placement with the order's final state
+func (s *Sequencer) PlaceOrder(ctx context.Context, order *models.Order, persist PersistFunc) (*Batch, error) {
+	return s.submitCommand(ctx, &Command{Type: CommandPlace, MarketID: order.MarketID, Order: order}, persist)
+}
+
+// CancelOrder takes an order off the book
+// An order that is not resting is still cancelled in sequence, its event has no order
+func (s *Sequencer) CancelOrder(ctx context.Context, marketID string, orderID uuid.UUID, persist PersistFunc) (*Batch, error) {
+	return s.submitCommand(ctx, &Command{Type: CommandCancel, MarketID: marketID, OrderID: orderID}, persist)
+}
+
+// AmendOrder changes the price and total size of a resting order
+// The batch holds any matches at the new price followed by the amendment
+func (s *Sequencer) AmendOrder(ctx context.Context, marketID string, orderID uuid.UUID, price, size decimal.Decimal, persist PersistFunc) (*Batch, error) {
+	return s.submitCommand(ctx, &Command{Type: CommandAmend, MarketID: marketID, OrderID: orderID, Price: price, Size: size}, persist)
+}
+
+// SetMarketStatus records the trading state of a market
+// Orders on suspended, closed or settled markets are rejected. Setting the current
+// status again produces no event
+func (s *Sequencer) SetMarketStatus(ctx context.Context, marketID string, status models.MarketStatus, persist PersistFunc) (*Batch, error) {
+	return s.submitCommand(ctx, &Command{Type: CommandMarketStatus, MarketID: marketID, Status: status}, persist)
+}
+
+// RemoveMarketOrders takes every resting order of a market off the book
+// The batch holds an event per removed order; their status is left to the caller
+func (s *Sequencer) RemoveMarketOrders(ctx context.Context, marketID string, persist PersistFunc) (*Batch, error) {
+	return s.submitCommand(ctx, &Command{Type: CommandRemoveMarketOrders, MarketID: marketID}, persist)
+}
+
+// ReinstateOrder puts an order back on the book after one of its matches was reversed
+// See Engine.ReinstateOrder
+func (s *Sequencer) ReinstateOrder(ctx context.Context, order *models.Order, persist PersistFunc) (*Batch, error) {
+	return s.submitCommand(ctx, &Command{Type: CommandReinstate, MarketID: order.MarketID, Order: order}, persist)
+}
+
+// Promote makes a standby accept commands
+func (s *Sequencer) Promote() {
+	s.demoted.Store(false)
+	s.standby.Store(false)
+}
+
+// Demote turns the sequencer into a standby
+// A standby refuses commands with models.ErrNotLeader; its books change only
+// through Replay and Replicate. Commands already running finish
+func (s *Sequencer) Demote() {
+	if !s.standby.Swap(true) {
+		s.demoted.Store(true)
+	}
+}
+
+// Standby returns true if the sequencer refuses commands
+func (s *Sequencer) Standby() bool {
+	return s.standby.Load()
+}
+
+// Demoted returns true if the sequencer is a standby because it was demoted
+// Its journal then holds the commands it sequenced as leader, which the replica
+// taking over may still need to read
+func (s *Sequencer) Demoted() bool {
+	return s.demoted.Load()
+}
+
+// Replay applies a command read from the journal
+// The command must give out the sequence numbers it was journaled with, otherwise
+// it is undone and ErrReplayDiverged is returned. Replayed commands are not journaled
+func (s *Sequencer) Replay(ctx context.Context, cmd *Command) error {
+	return s.replay(ctx, cmd, false)
+}
+
+// Replicate applies a command from the leader's journal, checked like Replay
+// Unlike a replayed command it is journaled, so a standby recovers it after a restart
+func (s *Sequencer) Replicate(ctx context.Context, cmd *Command) error {
+	return s.replay(ctx, cmd, true)
+}
+
+func (s *Sequencer) replay(ctx context.Context, cmd *Command, journal bool) error {
+	replayed := &command{
+		ctx:      ctx,
+		marketID: cmd.MarketID,
+		at:       cmd.Time,
+		apply: func(c *commandTxn) error {
+			if err := c.applyCommand(cmd); err != nil {
+				return fmt.Errorf("replay position %d: %w", cmd.Position, err)
+			}
+			if last := c.lastSequence(); last != cmd.Sequence {
+				return fmt.Errorf("%w: market %s position %d reached sequence %d, journal has %d",
+					ErrReplayDiverged, cmd.MarketID, cmd.Position, last, cmd.Sequence)
+			}
+			return nil
+		},
+	}
+	if journal && s.journal != nil {
+		replayed.record = cmd.clone()
+	}
+	_, err := s.submit(ctx, replayed)
+	return err
+}
+
+// Recover rebuilds the markets from a snapshot and the journal in dir
+// The snapshot's markets are restored and only the commands after it are
+// replayed; a nil snapshot replays the whole journal. A market's last command is
+// in doubt and only replayed if it was committed, otherwise it gets the abort
+// record the sequencer did not write. Must run before any other command is submitted
+func (s *Sequencer) Recover(ctx context.Context, dir string, snapshot *Snapshot) (int, error) {
+	var position int64
+	if snapshot != nil {
+		if err := s.restore(ctx, snapshot); err != nil {
+			return 0, err
+		}
+		position = snapshot.Position
+	}
+
+	replayed := 0
+	err := readJournal(dir, position, func(cmd *Command, inDoubt bool) error {
+		if inDoubt {
+			committed, err := s.decide(ctx, cmd)
+			if err != nil {
+				return err
+			}
+			if !committed {
+				return s.abort(cmd)
+			}
+		}
+
+		if err := s.Replay(ctx, cmd); err != nil {
+			return err
+		}
+		replayed++
+		return nil
+	})
+	return replayed, err
+}
+
+// decide returns true if an in-doubt command was committed
+func (s *Sequencer) decide(ctx context.Context, cmd *Command) (bool, error) {
+	if s.committed == nil {
+		return true, nil
+	}
+	committed, err := s.committed(ctx, cmd)
+	if err != nil {
+		return false, fmt.Errorf("decide %s command at position %d: %w", cmd.Type, cmd.Position, err)
+	}
+	return committed, nil
+}
+
+// abort journals the abort record of a command that was journaled but never committed
+// Its sequence numbers go to the market's next command, which replays of the
+// journal then read in place of it
+func (s *Sequencer) abort(cmd *Command) error {
+	if s.journal == nil {
+		return nil
+	}
+	if err := s.journal.Append(&Command{
+		Type:     CommandAbort,
+		MarketID: cmd.MarketID,
+		Sequence: cmd.Sequence,
+		Time:     s.clock(),
+	}); err != nil {
+		return fmt.Errorf("abort uncommitted command at position %d: %w", cmd.Position, err)
+	}
+	return nil
+}
+
+// MarketStatus returns the trading state of a market, open unless set otherwise
+func (s *Sequencer) MarketStatus(ctx context.Context, marketID string) (models.MarketStatus, error) {
+	var status models.MarketStatus
+	_, err := s.submit(ctx, &command{
+		ctx:      ctx,
+		marketID: marketID,
+		apply: func(c *commandTxn) error {
+			status = c.market.status
+			return nil
+		},
+		read: true,
+	})
+	if errors.Is(err, errNoMarket) {
+		return models.MarketStatusOpen, nil
+	}
+	return status, err
+}
+
+// CheckMarketOpen returns ErrMarketSuspended or ErrMarketClosed if the market does not accept orders
+func (s *Sequencer) CheckMarketOpen(ctx context.Context, marketID string) error {
+	status, err := s.MarketStatus(ctx, marketID)
+	if err != nil {
+		return err
+	}
+	return checkOpen(status)
+}
+
+// MarketBook returns the order book of a selection
+// Reads are sequenced like commands, so the book reflects every command before it
+func (s *Sequencer) MarketBook(ctx context.Context, marketID, selectionID string) (*models.MarketBook, error) {
+	var book *models.MarketBook
+	_, err := s.submit(ctx, &command{
+		ctx:      ctx,
+		marketID: marketID,
+		apply: func(c *commandTxn) error {
+			engine, ok := c.market.engines[selectionID]
+			if !ok {
+				engine = NewEngine(marketID, selectionID, c.market.engineOptions()...)
+			}
+			book = engine.GetMarketBook()
+			return nil
+		},
+		read: true,
+	})
+	if errors.Is(err, errNoMarket) {
+		return NewEngine(marketID, selectionID).GetMarketBook(), nil
+	}
+	return book, err
+}
+
+// submitCommand submits a command that changes the book and is journaled
+func (s *Sequencer) submitCommand(ctx context.Context, cmd *Command, persist PersistFunc) (*Batch, error) {
+	submitted := &command{
+		ctx:      ctx,
+		marketID: cmd.MarketID,
+		apply: func(c *commandTxn) error {
+			return c.applyCommand(cmd)
+		},
+		persist: persist,
+		write:   true,
+	}
+	if s.journal != nil {
+		// The order as submitted, before matching changes it
+		submitted.record = cmd.clone()
+	}
+	return s.submit(ctx, submitted)
+}
+
+// submit queues a command on the market's goroutine and waits for its result
+// A caller that gives up while the command is queued leaves it unapplied; once the
+// command runs the caller waits for it, and ctx reaches persist instead. Reads of a
+// market without state fail with errNoMarket
+func (s *Sequencer) submit(ctx context.Context, cmd *command) (*Batch, error) {
+	m, err := s.market(cmd.marketID, !cmd.read)
+	if err != nil {
+		return nil, err
+	}
+	cmd.result = make(chan commandResult, 1)
+
+	select {
+	case m.commands <- cmd:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.done:
+		return nil, ErrSequencerClosed
+	}
+
+	result := <-cmd.result
+	return result.batch, result.err
+}
+
+// market returns the state of a market, starting its goroutine on first use
+// Market IDs of reads come from clients, so only commands create markets; without
+// create a market that does not exist yet gives errNoMarket
+func (s *Sequencer) market(marketID string, create bool) (*market, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil, ErrSequencerClosed
+	default:
+	}
+
+	m, ok := s.markets[marketID]
+	if !ok && !create {
+		return nil, errNoMarket
+	}
+	if !ok {
+		m = newMarket(marketID, s.matchIDs)
+		m.commands = make(chan *command)
+		s.markets[marketID] = m
+
+		s.wg.Add(1)
+		go s.run(m)
+	}
+	return m, nil
+}
+
+// run executes a market's commands one at a time until the sequencer closes
+func (s *Sequencer) run(m *market) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case cmd := <-m.commands:
+			cmd.result <- s.execute(m, cmd)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// execute applies, journals and persists a command, undoing it if any step fails
+// A command that was journaled but not persisted is followed by an abort record
+func (s *Sequencer) execute(m *market, cmd *command) commandResult {
+	if err := cmd.ctx.Err(); err != nil {
+		return commandResult{err: err}
+	}
+	if cmd.write && s.standby.Load() {
+		return commandResult{err: models.ErrNotLeader}
+	}
+	if cmd.write && m.frozen {
+		return commandResult{err: models.ErrNotMarketOwner}
+	}
+
+	at := cmd.at
+	if at.IsZero() {
+		at = s.clock()
+	}
+
+	c := m.begin(at)
+	err := cmd.apply(c)
+	if err != nil || len(c.batch.Events) == 0 {
+		return c.finish(err)
+	}
+
+	var journaled *Command
+	if cmd.record != nil {
+		journaled = cmd.record
+		journaled.Sequence = c.lastSequence()
+		journaled.Time = m.now
+		if err := s.journal.Append(journaled); err != nil {
+			return c.finish(fmt.Errorf("failed to journal command: %w", err))
+		}
+		if cmd.write && s.acks != nil {
+			s.acks.wait(journaled.Position + 1)
+		}
+	}
+
+	if cmd.persist != nil {
+		if err := cmd.persist(cmd.ctx, c.batch); err != nil {
+			if journaled != nil {
+				// A failed abort has failed the journal, which then refuses every
+				// later command, so the unpersisted command is never built upon
+				_ = s.journal.Append(&Command{
+					Type:     CommandAbort,
+					MarketID: m.id,
+					Sequence: journaled.Sequence,
+					Time:     s.clock(),
+				})
+			}
+			return c.finish(err)
+		}
+	}
+
+	return c.finish(nil)
+}
+
+// finish commits the command if err is nil and undoes it otherwise
+func (c *commandTxn) finish(err error) commandResult {
+	if err != nil {
+		c.rollback()
+		return commandResult{err: err}
+	}
+	c.commit()
+	return commandResult{batch: c.batch}
+}
+
+// newMarket creates an open market with no orders
+func newMarket(marketID string, matchIDs MatchIDFunc) *market {
+	return &market{
+		id:       marketID,
+		status:   models.MarketStatusOpen,
+		engines:  make(map[string]*Engine),
+		matchIDs: matchIDs,
+	}
+}
+
+// engineOptions makes the market's engines stamp the current command's time and,
+// if set, name matches by sequence number
+func (m *market) engineOptions() []EngineOption {
+	opts := []EngineOption{WithClock(func() time.Time { return m.now })}
+	if m.matchIDs != nil {
+		// Matches are the first events of a command, so the n-th ID of a command
+		// names the match that gets sequence number sequence+n
+		opts = append(opts, WithIDGenerator(func() uuid.UUID {
+			m.issued++
+			return m.matchIDs(m.id, m.sequence+m.issued)
+		}))
+	}
+	return opts
+}
+
+// begin starts a command applied at now on the market
+func (m *market) begin(now time.Time) *commandTxn {
+	m.now = now
+	m.issued = 0
+	return &commandTxn{
+		market: m,
+		status: m.status,
+		txns:   make(map[string]*Txn),
+		batch:  &Batch{MarketID: m.id},
+	}
+}
+
+// selections returns the selections of the market in a stable order
+func (m *market) selections() []string {
+	selections := make([]string, 0, len(m.engines))
+	for selectionID := range m.engines {
+		selections = append(selections, selectionID)
+	}
+	sort.Strings(selections)
+	return selections
+}
+
+// commandTxn holds the changes of a command until it is persisted
+type commandTxn struct {
+	market *market
+	status models.MarketStatus // market status before the command
+	txns   map[string]*Txn     // selection -> engine transaction
+	batch  *Batch
+}
+
+// engine returns the transaction on a selection's engine, creating the engine on first use
+func (c *commandTxn) engine(selectionID string) *Txn {
+	if txn, ok := c.txns[selectionID]; ok {
+		return txn
+	}
+
+	engine, ok := c.market.engines[selectionID]
+	if !ok {
+		engine = NewEngine(c.market.id, selectionID, c.market.engineOptions()...)
+		c.market.engines[selectionID] = engine
+	}
+
+	txn := engine.Begin()
+	c.txns[selectionID] = txn
+	return txn
+}
+
+// findOrder runs op on the engine of each selection until one has the order
+// Returns ErrOrderNotFound if the order is not on any book of the market
+func (c *commandTxn) findOrder(orderID uuid.UUID, op func(txn *Txn) (*models.Order, error)) (*models.Order, error) {
+	for _, selectionID := range c.market.selections() {
+		order, err := op(c.engine(selectionID))
+		if errors.Is(err, models.ErrOrderNotFound) {
+			continue
+		}
+		return order, err
+	}
+	return nil, models.ErrOrderNotFound
+}
+
+// applyCommand applies a command to the market's books
+func (c *commandTxn) applyCommand(cmd *Command) error {
+	switch cmd.Type {
+	case CommandPlace:
+		if err := checkOpen(c.market.status); err != nil {
+			return err
+		}
+
+		order := cmd.Order
+		matches, err := c.engine(order.SelectionID).PlaceOrder(order)
+		if err != nil {
+			return err
+		}
+
+		for _, match := range matches {
+			c.emit(&Event{Type: EventMatch, OrderID: order.ID, Match: match})
+		}
+		c.emitOrder(EventOrderPlaced, order.ID, order)
+
+	case CommandCancel:
+		order, err := c.findOrder(cmd.OrderID, func(txn *Txn) (*models.Order, error) {
+			return txn.CancelOrder(cmd.OrderID)
+		})
+		if err != nil && !errors.Is(err, models.ErrOrderNotFound) {
+			return err
+		}
+		c.emitOrder(EventOrderCancelled, cmd.OrderID, order)
+
+	case CommandAmend:
+		if err := checkOpen(c.market.status); err != nil {
+			return err
+		}
+
+		var matches []*models.Match
+		order, err := c.findOrder(cmd.OrderID, func(txn *Txn) (order *models.Order, err error) {
+			order, matches, err = txn.AmendOrder(cmd.OrderID, cmd.Price, cmd.Size)
+			return order, err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, match := range matches {
+			c.emit(&Event{Type: EventMatch, OrderID: cmd.OrderID, Match: match})
+		}
+		c.emitOrder(EventOrderAmended, cmd.OrderID, order)
+
+	case CommandMarketStatus:
+		if c.market.status == cmd.Status {
+			return nil
+		}
+		c.market.status = cmd.Status
+		c.emit(&Event{Type: EventMarketStatus, Status: cmd.Status})
+
+	case CommandRemoveMarketOrders:
+		for _, selectionID := range c.market.selections() {
+			for _, order := range c.engine(selectionID).RemoveMarketOrders(c.market.id) {
+				c.emitOrder(EventOrderRemoved, order.ID, order)
+			}
+		}
+
+	case CommandReinstate:
+		c.engine(cmd.Order.SelectionID).ReinstateOrder(cmd.Order)
+		c.emitOrder(EventOrderReinstated, cmd.Order.ID, cmd.Order)
+
+	default:
+		return fmt.Errorf("unknown command type %d", cmd.Type)
+	}
+	return nil
+}
+
+// lastSequence returns the sequence number of the command's last event
+func (c *commandTxn) lastSequence() int64 {
+	return c.market.sequence + int64(len(c.batch.Events))
+}
+
+// emit gives an event the next sequence number and adds it to the batch
+func (c *commandTxn) emit(event *Event) {
+	event.Sequence = c.lastSequence() + 1
+	event.MarketID = c.market.id
+	if event.Match != nil {
+		event.Match.Sequence = event.Sequence
+	}
+	c.batch.Events = append(c.batch.Events, event)
+}
+
+// emitOrder emits an event with a copy of the order, later commands do not change it
+func (c *commandTxn) emitOrder(t EventType, orderID uuid.UUID, order *models.Order) {
+	event := &Event{Type: t, OrderID: orderID}
+	if order != nil {
+		snapshot := *order
+		event.Order = &snapshot
+	}
+	c.emit(event)
+}
+
+// commit keeps the command's changes and its sequence numbers
+func (c *commandTxn) commit() {
+	for _, txn := range c.txns {
+		txn.Commit()
+	}
+	c.market.sequence += int64(len(c.batch.Events))
+}
+
+// rollback undoes the command, its sequence numbers are given out again
+func (c *commandTxn) rollback() {
+	for _, txn := range c.txns {
+		txn.Rollback()
+	}
+	c.market.status = c.status
+}
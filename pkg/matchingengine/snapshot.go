@@ -0,0 +1,470 @@
+package matchingengine
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+)
+
+// ErrSnapshotCorrupt is returned when a snapshot fails to decode or its checksum does not match
+var ErrSnapshotCorrupt = errors.New("snapshot corrupt")
+
+// snapshotVersion is written first in every snapshot so the encoding can evolve
+const snapshotVersion = 1
+
+// Snapshot is the state of every market at one point in the journal
+// Restoring it and replaying the journal from Position gives the same books as
+// replaying the whole journal
+type Snapshot struct {
+	Position int64     // Journal position of the first command not included
+	TakenAt  time.Time // When the markets were captured
+	Markets  []*MarketSnapshot
+}
+
+// MarketSnapshot is the state of one market
+type MarketSnapshot struct {
+	MarketID string
+	Status   models.MarketStatus
+	Sequence int64 // Last sequence number given out
+	Engines  []*EngineSnapshot
+}
+
+// EngineSnapshot holds the resting orders of a selection's book
+// Levels are in price priority and orders in time priority, so restoring them in
+// order rebuilds the same queues
+type EngineSnapshot struct {
+	SelectionID string
+	Back        []*LevelSnapshot
+	Lay         []*LevelSnapshot
+}
+
+// LevelSnapshot is a price level and copies of its orders
+// Levels whose orders have all gone are kept so the book reads the same after a restore
+type LevelSnapshot struct {
+	Price  decimal.Decimal
+	Orders []*models.Order
+}
+
+// Snapshot copies the resting orders of the book
+// The copies do not change as the engine goes on matching
+func (e *Engine) Snapshot() *EngineSnapshot {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return &EngineSnapshot{
+		SelectionID: e.selectionID,
+		Back:        snapshotLevels(e.backOrders, e.backPrices),
+		Lay:         snapshotLevels(e.layOrders, e.layPrices),
+	}
+}
+
+func snapshotLevels(book map[string]*OrderQueue, prices []decimal.Decimal) []*LevelSnapshot {
+	levels := make([]*LevelSnapshot, 0, len(prices))
+	for _, price := range prices {
+		queue := book[price.String()]
+		level := &LevelSnapshot{Price: queue.price, Orders: make([]*models.Order, 0, len(queue.orders))}
+		for _, order := range queue.orders {
+			saved := *order
+			level.Orders = append(level.Orders, &saved)
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// RestoreEngine creates an engine holding the orders of a snapshot
+func RestoreEngine(marketID string, snapshot *EngineSnapshot, opts ...EngineOption) *Engine {
+	e := NewEngine(marketID, snapshot.SelectionID, opts...)
+	restoreLevels(e.backOrders, &e.backPrices, snapshot.Back)
+	restoreLevels(e.layOrders, &e.layPrices, snapshot.Lay)
+	return e
+}
+
+func restoreLevels(book map[string]*OrderQueue, prices *[]decimal.Decimal, levels []*LevelSnapshot) {
+	for _, level := range levels {
+		queue := &OrderQueue{price: level.Price, orders: make([]*models.Order, 0, len(level.Orders))}
+		for _, order := range level.Orders {
+			restored := *order
+			queue.orders = append(queue.orders, &restored)
+		}
+		book[level.Price.String()] = queue
+		*prices = append(*prices, level.Price)
+	}
+}
+
+// Snapshot captures every market at a single point in the journal
+// Each market finishes its current command and then waits until all markets have
+// been captured, so no command is half in the snapshot. New markets wait as well
+func (s *Sequencer) Snapshot(ctx context.Context) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil, ErrSequencerClosed
+	default:
+	}
+
+	ids := make([]string, 0, len(s.markets))
+	for id := range s.markets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// Markets stay parked until every one of them is captured
+	release := make(chan struct{})
+	defer close(release)
+
+	captured := make(chan *MarketSnapshot, len(ids))
+	for _, id := range ids {
+		m := s.markets[id]
+		cmd := &command{
+			ctx:      ctx,
+			marketID: id,
+			apply: func(c *commandTxn) error {
+				captured <- c.market.snapshot()
+				<-release
+				return nil
+			},
+			result: make(chan commandResult, 1),
+		}
+
+		select {
+		case m.commands <- cmd:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.done:
+			return nil, ErrSequencerClosed
+		}
+	}
+
+	snapshot := &Snapshot{Markets: make([]*MarketSnapshot, 0, len(ids))}
+	for range ids {
+		select {
+		case market := <-captured:
+			snapshot.Markets = append(snapshot.Markets, market)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// Journal appends finish before a command does, so nothing is in flight
+	if s.journal != nil {
+		snapshot.Position = s.journal.Position()
+	}
+	snapshot.TakenAt = s.clock()
+
+	sort.Slice(snapshot.Markets, func(a, b int) bool {
+		return snapshot.Markets[a].MarketID < snapshot.Markets[b].MarketID
+	})
+	return snapshot, nil
+}
+
+// snapshot captures the market, called on its goroutine
+func (m *market) snapshot() *MarketSnapshot {
+	snapshot := &MarketSnapshot{
+		MarketID: m.id,
+		Status:   m.status,
+		Sequence: m.sequence,
+		Engines:  make([]*EngineSnapshot, 0, len(m.engines)),
+	}
+	for _, selectionID := range m.selections() {
+		snapshot.Engines = append(snapshot.Engines, m.engines[selectionID].Snapshot())
+	}
+	return snapshot
+}
+
+// restore replaces the state of the snapshot's markets
+func (s *Sequencer) restore(ctx context.Context, snapshot *Snapshot) error {
+	for _, saved := range snapshot.Markets {
+		saved := saved
+		_, err := s.submit(ctx, &command{
+			ctx:      ctx,
+			marketID: saved.MarketID,
+			apply: func(c *commandTxn) error {
+				c.market.restore(saved)
+				return nil
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("restore market %s: %w", saved.MarketID, err)
+		}
+	}
+	return nil
+}
+
+// restore replaces the market's state, called on its goroutine
+func (m *market) restore(saved *MarketSnapshot) {
+	m.status = saved.Status
+	m.sequence = saved.Sequence
+	m.engines = make(map[string]*Engine, len(saved.Engines))
+	for _, engine := range saved.Engines {
+		m.engines[engine.SelectionID] = RestoreEngine(saved.MarketID, engine, m.engineOptions()...)
+	}
+}
+
+// reset empties the market, called on its goroutine
+func (m *market) reset() {
+	empty := newMarket(m.id, m.matchIDs)
+	m.status = empty.status
+	m.sequence = empty.sequence
+	m.engines = empty.engines
+}
+
+// Load replaces every market with its state in the snapshot
+// Markets the snapshot does not have are emptied. Unlike recovery it may run on a
+// sequencer that already has markets, standbys use it to start over from the leader
+func (s *Sequencer) Load(ctx context.Context, snapshot *Snapshot) error {
+	if err := s.restore(ctx, snapshot); err != nil {
+		return err
+	}
+
+	saved := make(map[string]bool, len(snapshot.Markets))
+	for _, market := range snapshot.Markets {
+		saved[market.MarketID] = true
+	}
+
+	s.mu.Lock()
+	var stale []string
+	for id := range s.markets {
+		if !saved[id] {
+			stale = append(stale, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, id := range stale {
+		_, err := s.submit(ctx, &command{
+			ctx:      ctx,
+			marketID: id,
+			apply: func(c *commandTxn) error {
+				c.market.reset()
+				return nil
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("empty market %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// MarshalBinary encodes the snapshot, followed by a CRC-32C of the encoding
+func (s *Snapshot) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.uint8(snapshotVersion)
+	e.int64(s.Position)
+	e.time(s.TakenAt)
+	e.int64(int64(len(s.Markets)))
+	for _, market := range s.Markets {
+		e.string(market.MarketID)
+		e.string(string(market.Status))
+		e.int64(market.Sequence)
+		e.int64(int64(len(market.Engines)))
+		for _, engine := range market.Engines {
+			e.string(engine.SelectionID)
+			e.levels(engine.Back)
+			e.levels(engine.Lay)
+		}
+	}
+	return binary.LittleEndian.AppendUint32(e.buf, crc32.Checksum(e.buf, crcTable)), nil
+}
+
+func (e *encoder) levels(levels []*LevelSnapshot) {
+	e.int64(int64(len(levels)))
+	for _, level := range levels {
+		e.decimal(level.Price)
+		e.int64(int64(len(level.Orders)))
+		for _, order := range level.Orders {
+			e.order(order)
+		}
+	}
+}
+
+// UnmarshalSnapshot decodes a snapshot written by MarshalBinary
+func UnmarshalSnapshot(data []byte) (*Snapshot, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, errShortBuffer)
+	}
+	body, checksum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.Checksum(body, crcTable) != checksum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrSnapshotCorrupt)
+	}
+
+	d := &decoder{buf: body}
+	if version := d.uint8(); d.err == nil && version != snapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrSnapshotCorrupt, version)
+	}
+
+	s := &Snapshot{
+		Position: d.int64(),
+		TakenAt:  d.time(),
+	}
+	for n := d.count(); n > 0; n-- {
+		market := &MarketSnapshot{
+			MarketID: d.string(),
+			Status:   models.MarketStatus(d.string()),
+			Sequence: d.int64(),
+		}
+		for m := d.count(); m > 0; m-- {
+			market.Engines = append(market.Engines, &EngineSnapshot{
+				SelectionID: d.string(),
+				Back:        d.levels(),
+				Lay:         d.levels(),
+			})
+		}
+		s.Markets = append(s.Markets, market)
+	}
+
+	if d.err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, d.err)
+	}
+	return s, nil
+}
+
+// count reads a length, stopping at zero once the record has failed
+func (d *decoder) count() int64 {
+	n := d.int64()
+	if d.err != nil || n < 0 {
+		d.fail(errShortBuffer)
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) levels() []*LevelSnapshot {
+	var levels []*LevelSnapshot
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		level := &LevelSnapshot{Price: d.decimal()}
+		for m := d.count(); m > 0 && d.err == nil; m-- {
+			level.Orders = append(level.Orders, d.order())
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// SnapshotStore keeps the snapshots recovery starts from
+type SnapshotStore interface {
+	// Save stores a snapshot, older snapshots may be dropped once it is durable
+	Save(ctx context.Context, snapshot *Snapshot) error
+
+	// Latest returns the most recent snapshot, nil if there is none
+	Latest(ctx context.Context) (*Snapshot, error)
+}
+
+// snapshotsRetained is how many snapshots a store keeps
+// The one before the latest is kept in case the latest cannot be read
+const snapshotsRetained = 2
+
+const snapshotSuffix = ".snapshot"
+
+// FileSnapshotStore keeps snapshots as files in a directory
+// Files are named after the snapshot's journal position and written atomically
+type FileSnapshotStore struct {
+	dir string
+}
+
+// NewFileSnapshotStore creates a snapshot store in dir, creating the directory if needed
+func NewFileSnapshotStore(dir string) (*FileSnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create snapshot directory: %w", err)
+	}
+	return &FileSnapshotStore{dir: dir}, nil
+}
+
+// Save writes the snapshot to a temporary file and renames it into place
+func (f *FileSnapshotStore) Save(_ context.Context, snapshot *Snapshot) error {
+	data, err := snapshot.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.dir, "snapshot-*.tmp")
+	if err != nil {
+		return fmt.Errorf("create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync snapshot file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close snapshot file: %w", err)
+	}
+
+	name := filepath.Join(f.dir, fmt.Sprintf("%020d%s", snapshot.Position, snapshotSuffix))
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("rename snapshot file: %w", err)
+	}
+	if err := syncDir(f.dir); err != nil {
+		return err
+	}
+
+	files, err := f.list()
+	if err != nil {
+		return err
+	}
+	for len(files) > snapshotsRetained {
+		if err := os.Remove(files[0]); err != nil {
+			return fmt.Errorf("remove snapshot file: %w", err)
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// Latest reads the newest snapshot file
+func (f *FileSnapshotStore) Latest(_ context.Context) (*Snapshot, error) {
+	files, err := f.list()
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(files[len(files)-1])
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot file: %w", err)
+	}
+	return UnmarshalSnapshot(data)
+}
+
+// list returns the snapshot files ordered by position
+func (f *FileSnapshotStore) list() ([]string, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		if _, err := strconv.ParseInt(strings.TrimSuffix(name, snapshotSuffix), 10, 64); err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(f.dir, name))
+	}
+
+	// Names are zero padded, so lexical order is position order
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,250 @@
+package matchingengine
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+)
+
+// restingOrder is an unmatched order on market-1
+func restingOrder(selectionID string, side models.OrderSide, price string, size int64) *models.Order {
+	return &models.Order{
+		ID:            uuid.New(),
+		UserID:        uuid.New(),
+		MarketID:      "market-1",
+		SelectionID:   selectionID,
+		Side:          side,
+		Price:         decimal.RequireFromString(price),
+		Size:          decimal.NewFromInt(size),
+		SizeMatched:   decimal.Zero,
+		SizeRemaining: decimal.NewFromInt(size),
+		Status:        models.OrderStatusPending,
+	}
+}
+
+// snapshotSequencer places resting orders on two selections and suspends a second market
+func snapshotSequencer(t *testing.T) *Sequencer {
+	t.Helper()
+	ctx := context.Background()
+	sequencer := NewSequencer()
+	t.Cleanup(sequencer.Close)
+
+	for _, order := range []*models.Order{
+		restingOrder("team-a", models.OrderSideBack, "2.5", 50),
+		restingOrder("team-a", models.OrderSideBack, "2.5", 30),
+		restingOrder("team-a", models.OrderSideBack, "2.4", 20),
+		restingOrder("team-a", models.OrderSideLay, "2.6", 40),
+		restingOrder("team-b", models.OrderSideLay, "1.8", 10),
+	} {
+		_, err := sequencer.PlaceOrder(ctx, order, nil)
+		require.NoError(t, err)
+	}
+	_, err := sequencer.SetMarketStatus(ctx, "market-2", models.MarketStatusSuspended, nil)
+	require.NoError(t, err)
+	return sequencer
+}
+
+// marshalSnapshot captures the sequencer and encodes the snapshot
+func marshalSnapshot(t *testing.T, sequencer *Sequencer) (*Snapshot, []byte) {
+	t.Helper()
+	snapshot, err := sequencer.Snapshot(context.Background())
+	require.NoError(t, err)
+	data, err := snapshot.MarshalBinary()
+	require.NoError(t, err)
+	return snapshot, data
+}
+
+// withChecksum replaces the trailing checksum of an encoded snapshot body
+func withChecksum(body []byte) []byte {
+	return binary.LittleEndian.AppendUint32(append([]byte(nil), body...), crc32.Checksum(body, crcTable))
+}
+
+func TestSnapshot_MarshalRoundTrip(t *testing.T) {
+	snapshot, _ := marshalSnapshot(t, snapshotSequencer(t))
+	snapshot.Position = 42
+	snapshot.TakenAt = time.Date(2026, 3, 1, 12, 0, 0, 7, time.UTC)
+	data, err := snapshot.MarshalBinary()
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalSnapshot(data)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), decoded.Position)
+	assert.True(t, snapshot.TakenAt.Equal(decoded.TakenAt))
+
+	// Encoding what was decoded gives the same bytes
+	again, err := decoded.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	require.Len(t, decoded.Markets, len(snapshot.Markets))
+	for i, market := range snapshot.Markets {
+		got := decoded.Markets[i]
+		assert.Equal(t, market.MarketID, got.MarketID)
+		assert.Equal(t, market.Status, got.Status)
+		assert.Equal(t, market.Sequence, got.Sequence)
+		require.Len(t, got.Engines, len(market.Engines))
+		for j, engine := range market.Engines {
+			assert.Equal(t, engine.SelectionID, got.Engines[j].SelectionID)
+			assertLevels(t, engine.Back, got.Engines[j].Back)
+			assertLevels(t, engine.Lay, got.Engines[j].Lay)
+		}
+	}
+}
+
+// assertLevels checks decoded levels hold the same prices and orders in the same order
+func assertLevels(t *testing.T, want, got []*LevelSnapshot) {
+	t.Helper()
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.True(t, want[i].Price.Equal(got[i].Price))
+		require.Len(t, got[i].Orders, len(want[i].Orders))
+		for j := range want[i].Orders {
+			assert.Equal(t, want[i].Orders[j].ID, got[i].Orders[j].ID)
+			assert.True(t, want[i].Orders[j].SizeRemaining.Equal(got[i].Orders[j].SizeRemaining))
+		}
+	}
+}
+
+func TestUnmarshalSnapshot_RejectsTruncatedInput(t *testing.T) {
+	_, data := marshalSnapshot(t, snapshotSequencer(t))
+
+	for n := 0; n < len(data); n++ {
+		_, err := UnmarshalSnapshot(data[:n])
+		assert.ErrorIs(t, err, ErrSnapshotCorrupt, "snapshot cut to %d of %d bytes", n, len(data))
+	}
+
+	// A cut body with a matching checksum still fails to decode
+	body := data[:len(data)-4]
+	for n := 0; n < len(body); n++ {
+		_, err := UnmarshalSnapshot(withChecksum(body[:n]))
+		assert.ErrorIs(t, err, ErrSnapshotCorrupt, "body cut to %d of %d bytes", n, len(body))
+	}
+}
+
+func TestUnmarshalSnapshot_RejectsCorruptInput(t *testing.T) {
+	_, data := marshalSnapshot(t, snapshotSequencer(t))
+
+	// Any damaged byte fails the checksum
+	for i := range data {
+		damaged := append([]byte(nil), data...)
+		damaged[i] ^= 0x01
+		_, err := UnmarshalSnapshot(damaged)
+		assert.ErrorIs(t, err, ErrSnapshotCorrupt, "byte %d damaged", i)
+	}
+
+	body := data[:len(data)-4]
+
+	version := append([]byte(nil), body...)
+	version[0] = snapshotVersion + 1
+	_, err := UnmarshalSnapshot(withChecksum(version))
+	assert.ErrorIs(t, err, ErrSnapshotCorrupt)
+	assert.ErrorContains(t, err, "unsupported version")
+
+	e := &encoder{}
+	e.uint8(snapshotVersion)
+	e.int64(0)
+	e.time(time.Time{})
+	e.int64(-1)
+	_, err = UnmarshalSnapshot(withChecksum(e.buf))
+	assert.ErrorIs(t, err, ErrSnapshotCorrupt)
+}
+
+func TestSequencer_LoadRestoresBooks(t *testing.T) {
+	ctx := context.Background()
+	source := snapshotSequencer(t)
+	snapshot, data := marshalSnapshot(t, source)
+	decoded, err := UnmarshalSnapshot(data)
+	require.NoError(t, err)
+
+	restored := NewSequencer()
+	defer restored.Close()
+	_, err = restored.SetMarketStatus(ctx, "stale-market", models.MarketStatusSuspended, nil)
+	require.NoError(t, err)
+	require.NoError(t, restored.Load(ctx, decoded))
+
+	for _, selectionID := range []string{"team-a", "team-b"} {
+		want, err := source.MarketBook(ctx, "market-1", selectionID)
+		require.NoError(t, err)
+		got, err := restored.MarketBook(ctx, "market-1", selectionID)
+		require.NoError(t, err)
+		assertBook(t, want, got)
+	}
+	assert.ErrorIs(t, restored.CheckMarketOpen(ctx, "market-2"), models.ErrMarketSuspended)
+
+	// Markets the snapshot does not have are emptied
+	assert.NoError(t, restored.CheckMarketOpen(ctx, "stale-market"))
+
+	// Restored queues keep time priority and sequence numbers carry on
+	var market1 *MarketSnapshot
+	for _, market := range snapshot.Markets {
+		if market.MarketID == "market-1" {
+			market1 = market
+		}
+	}
+	require.NotNil(t, market1)
+	first := market1.Engines[0].Back[0].Orders[0]
+
+	batch, err := restored.PlaceOrder(ctx, restingOrder("team-a", models.OrderSideLay, "2.5", 10), nil)
+	require.NoError(t, err)
+	matches := batch.Matches()
+	require.Len(t, matches, 1)
+	assert.Equal(t, first.ID, matches[0].BackOrderID)
+	assert.Equal(t, market1.Sequence+1, batch.Events[0].Sequence)
+}
+
+// assertBook checks two books have the same levels
+func assertBook(t *testing.T, want, got *models.MarketBook) {
+	t.Helper()
+	for _, side := range []struct{ want, got []*models.PriceLevel }{
+		{want.BackOrders, got.BackOrders},
+		{want.LayOrders, got.LayOrders},
+	} {
+		require.Len(t, side.got, len(side.want))
+		for i := range side.want {
+			assert.True(t, side.want[i].Price.Equal(side.got[i].Price))
+			assert.True(t, side.want[i].TotalSize.Equal(side.got[i].TotalSize))
+			assert.Equal(t, side.want[i].OrderCount, side.got[i].OrderCount)
+		}
+	}
+}
+
+func TestFileSnapshotStore_KeepsLatest(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileSnapshotStore(t.TempDir())
+	require.NoError(t, err)
+
+	latest, err := store.Latest(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, latest)
+
+	snapshot, _ := marshalSnapshot(t, snapshotSequencer(t))
+	for _, position := range []int64{5, 10, 15} {
+		snapshot.Position = position
+		require.NoError(t, store.Save(ctx, snapshot))
+	}
+
+	latest, err = store.Latest(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), latest.Position)
+
+	files, err := store.list()
+	require.NoError(t, err)
+	assert.Len(t, files, snapshotsRetained)
+
+	// A damaged latest file is reported, not silently skipped
+	data, err := os.ReadFile(files[len(files)-1])
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(files[len(files)-1], data[:len(data)/2], 0o644))
+	_, err = store.Latest(ctx)
+	assert.ErrorIs(t, err, ErrSnapshotCorrupt)
+}
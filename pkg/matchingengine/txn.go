@@ -0,0 +1,234 @@
+package matchingengine
+
+import (
+	"fmt"
+
+	"github.com/cypherlabdev/order-book-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Txn is an exclusive engine transaction whose changes can be rolled back
+// The engine is locked from Begin until Commit or Rollback, so no other order
+// can match against changes that may still be undone
+type Txn struct {
+	engine *Engine
+	undo   undoLog
+	done   bool
+}
+
+// Begin starts an engine transaction
+// The caller must end it with Commit or Rollback and must not call other engine
+// methods in between
+func (e *Engine) Begin() *Txn {
+	e.mu.Lock()
+	return &Txn{engine: e}
+}
+
+// PlaceOrder places an order and attempts to match it within the transaction
+func (t *Txn) PlaceOrder(order *models.Order) ([]*models.Match, error) {
+	if err := t.engine.checkMarketOpen(order.MarketID); err != nil {
+		return nil, err
+	}
+
+	t.undo.saveOrder(order)
+	if order.Side == models.OrderSideBack {
+		return t.engine.placeBackOrder(order, &t.undo)
+	}
+	return t.engine.placeLayOrder(order, &t.undo)
+}
+
+// CancelOrder takes a resting order off the book within the transaction
+func (t *Txn) CancelOrder(orderID uuid.UUID) (*models.Order, error) {
+	queue, i := t.engine.findOrder(orderID)
+	if queue == nil {
+		return nil, fmt.Errorf("%w: %s", models.ErrOrderNotFound, orderID)
+	}
+	order := queue.orders[i]
+
+	t.undo.saveQueue(queue)
+	t.undo.saveOrder(order)
+	queue.orders = append(queue.orders[:i], queue.orders[i+1:]...)
+
+	now := t.engine.now()
+	order.CancelledAt = &now
+	order.Status = models.OrderStatusCancelled
+	return order, nil
+}
+
+// AmendOrder changes the price and total size of a resting order within the transaction
+// The size must exceed what is already matched. Reducing the size at the same price
+// keeps the order's place in its queue, any other change requeues it like a new
+// order, where it may match
+func (t *Txn) AmendOrder(orderID uuid.UUID, price, size decimal.Decimal) (*models.Order, []*models.Match, error) {
+	queue, i := t.engine.findOrder(orderID)
+	if queue == nil {
+		return nil, nil, fmt.Errorf("%w: %s", models.ErrOrderNotFound, orderID)
+	}
+	order := queue.orders[i]
+
+	if err := t.engine.checkMarketOpen(order.MarketID); err != nil {
+		return nil, nil, err
+	}
+	if !price.IsPositive() || !size.GreaterThan(order.SizeMatched) {
+		return nil, nil, fmt.Errorf("%w: price %s size %s with %s matched",
+			models.ErrInvalidAmendment, price, size, order.SizeMatched)
+	}
+
+	t.undo.saveOrder(order)
+	if price.Equal(order.Price) && size.LessThanOrEqual(order.Size) {
+		order.Size = size
+		order.SizeRemaining = size.Sub(order.SizeMatched)
+		return order, nil, nil
+	}
+
+	t.undo.saveQueue(queue)
+	queue.orders = append(queue.orders[:i], queue.orders[i+1:]...)
+
+	order.Price = price
+	order.Size = size
+	order.SizeRemaining = size.Sub(order.SizeMatched)
+
+	var matches []*models.Match
+	var err error
+	if order.Side == models.OrderSideBack {
+		matches, err = t.engine.placeBackOrder(order, &t.undo)
+	} else {
+		matches, err = t.engine.placeLayOrder(order, &t.undo)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return order, matches, nil
+}
+
+// RemoveMarketOrders takes every resting order of a market off the book within the transaction
+// Returns the removed orders; their status is left to the caller
+func (t *Txn) RemoveMarketOrders(marketID string) []*models.Order {
+	var removed []*models.Order
+	for _, book := range []map[string]*OrderQueue{t.engine.backOrders, t.engine.layOrders} {
+		for _, queue := range book {
+			kept := make([]*models.Order, 0, len(queue.orders))
+			for _, order := range queue.orders {
+				if order.MarketID == marketID {
+					removed = append(removed, order)
+					continue
+				}
+				kept = append(kept, order)
+			}
+			if len(kept) != len(queue.orders) {
+				t.undo.saveQueue(queue)
+				queue.orders = kept
+			}
+		}
+	}
+
+	return removed
+}
+
+// ReinstateOrder puts an order back on the book within the transaction
+// See Engine.ReinstateOrder
+func (t *Txn) ReinstateOrder(order *models.Order) {
+	e := t.engine
+	orders, prices := e.backOrders, &e.backPrices
+	if order.Side != models.OrderSideBack {
+		orders, prices = e.layOrders, &e.layPrices
+	}
+
+	priceKey := order.Price.String()
+	queue, exists := orders[priceKey]
+	if !exists {
+		t.undo.saveLevel(orders, prices, priceKey)
+		queue = &OrderQueue{
+			price:  order.Price,
+			orders: make([]*models.Order, 0),
+		}
+		orders[priceKey] = queue
+		if order.Side == models.OrderSideBack {
+			*prices = insertPriceDescending(*prices, order.Price)
+		} else {
+			*prices = insertPriceAscending(*prices, order.Price)
+		}
+	} else {
+		t.undo.saveQueue(queue)
+	}
+
+	for i, existing := range queue.orders {
+		if existing.ID == order.ID {
+			queue.orders[i] = order
+			return
+		}
+	}
+
+	i := 0
+	for i < len(queue.orders) && !queue.orders[i].PlacedAt.After(order.PlacedAt) {
+		i++
+	}
+	queue.orders = append(queue.orders, nil)
+	copy(queue.orders[i+1:], queue.orders[i:])
+	queue.orders[i] = order
+}
+
+// Commit keeps the transaction's changes and unlocks the engine
+func (t *Txn) Commit() {
+	if t.done {
+		return
+	}
+	t.done = true
+	t.undo.steps = nil
+	t.engine.mu.Unlock()
+}
+
+// Rollback undoes the transaction's changes and unlocks the engine
+// Safe to defer, does nothing after Commit
+func (t *Txn) Rollback() {
+	if t.done {
+		return
+	}
+	t.done = true
+	t.undo.rollback()
+	t.engine.mu.Unlock()
+}
+
+// undoLog records how to reverse each change made to the book
+type undoLog struct {
+	steps []func()
+}
+
+func (u *undoLog) push(step func()) {
+	u.steps = append(u.steps, step)
+}
+
+// rollback applies the recorded steps in reverse order
+func (u *undoLog) rollback() {
+	for i := len(u.steps) - 1; i >= 0; i-- {
+		u.steps[i]()
+	}
+	u.steps = nil
+}
+
+// saveOrder records the state of an order
+func (u *undoLog) saveOrder(order *models.Order) {
+	saved := *order
+	u.push(func() {
+		*order = saved
+	})
+}
+
+// saveQueue records the orders of a price level
+// The slice is copied because removals shift the shared backing array
+func (u *undoLog) saveQueue(queue *OrderQueue) {
+	orders := append([]*models.Order(nil), queue.orders...)
+	u.push(func() {
+		queue.orders = orders
+	})
+}
+
+// saveLevel records that a price level did not exist before
+func (u *undoLog) saveLevel(book map[string]*OrderQueue, prices *[]decimal.Decimal, priceKey string) {
+	saved := append([]decimal.Decimal(nil), (*prices)...)
+	u.push(func() {
+		delete(book, priceKey)
+		*prices = saved
+	})
+}